@@ -1,6 +1,7 @@
 package backwards_test
 
 import (
+	"fmt"
 	"os"
 	"testing"
 
@@ -108,4 +109,207 @@ jobs:
 		assert.Expect(step.AgentSafety).To(BeEmpty())
 		assert.Expect(step.AgentContextGuard).To(BeNil())
 	})
+
+	t.Run("parses and validates mcp_servers", func(t *testing.T) {
+		t.Parallel()
+
+		assert := NewGomegaWithT(t)
+
+		pipeline := func(servers string) []byte {
+			return []byte(`
+jobs:
+  - name: review
+    plan:
+      - agent: my-agent
+        prompt: Do something
+        model: openrouter/google/gemini-3.1-flash-lite-preview
+        mcp_servers:
+` + servers + `
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: node }
+          run:
+            path: echo
+`)
+		}
+
+		config, err := backwards.ParseConfig(pipeline(`
+          - name: filesystem
+            command:
+              path: npx
+              args: ["-y", "@modelcontextprotocol/server-filesystem", "repo"]
+            deny: [write_file]
+          - name: github
+            url: https://api.githubcopilot.com/mcp/
+            headers:
+              Authorization: secret:github-token
+            allow: ["get_*"]`))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		servers := config.Jobs[0].Plan[0].AgentMCPServers
+		assert.Expect(servers).To(HaveLen(2))
+		assert.Expect(servers[0].Command.Path).To(Equal("npx"))
+		assert.Expect(servers[0].Deny).To(Equal([]string{"write_file"}))
+		assert.Expect(servers[1].URL).To(Equal("https://api.githubcopilot.com/mcp/"))
+		assert.Expect(servers[1].Headers).To(HaveKeyWithValue("Authorization", "secret:github-token"))
+		assert.Expect(servers[1].Allow).To(Equal([]string{"get_*"}))
+
+		_, err = backwards.ParseConfig(pipeline(`
+          - name: nothing`))
+		assert.Expect(err).To(HaveOccurred())
+
+		_, err = backwards.ParseConfig(pipeline(`
+          - name: both
+            url: https://example.com/mcp
+            command: { path: npx }`))
+		assert.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("parses output_schema", func(t *testing.T) {
+		t.Parallel()
+
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfig([]byte(`
+jobs:
+  - name: review
+    plan:
+      - agent: my-agent
+        prompt: Review the diff
+        model: openrouter/google/gemini-3.1-flash-lite-preview
+        output_schema:
+          type: object
+          properties:
+            verdict: { type: string, enum: [approve, request_changes] }
+          required: [verdict]
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: node }
+          run:
+            path: echo
+`))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		schema := config.Jobs[0].Plan[0].AgentOutputSchema
+		assert.Expect(schema).To(HaveKeyWithValue("type", "object"))
+		assert.Expect(schema).To(HaveKeyWithValue("required", ConsistOf("verdict")))
+	})
+
+	t.Run("parses policy", func(t *testing.T) {
+		t.Parallel()
+
+		assert := NewGomegaWithT(t)
+
+		const pipeline = `
+jobs:
+  - name: deploy
+    plan:
+      - agent: my-agent
+        prompt: Deploy the service
+        model: openrouter/google/gemini-3.1-flash-lite-preview
+        policy:
+          - tool: run_*
+            when: command contains "kubectl"
+            action: %s
+            approvers: '"ops" in Groups'
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: node }
+          run:
+            path: echo
+`
+
+		config, err := backwards.ParseConfig(fmt.Appendf(nil, pipeline, "approve"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(config.Jobs[0].Plan[0].AgentPolicy).To(Equal([]backwards.AgentPolicyRule{{
+			Tool:      "run_*",
+			When:      `command contains "kubectl"`,
+			Action:    "approve",
+			Approvers: `"ops" in Groups`,
+		}}))
+
+		_, err = backwards.ParseConfig(fmt.Appendf(nil, pipeline, "maybe"))
+		assert.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("parses sub_agents", func(t *testing.T) {
+		t.Parallel()
+
+		assert := NewGomegaWithT(t)
+
+		const pipeline = `
+jobs:
+  - name: review
+    plan:
+      - agent: reviewer
+        prompt: Review the change
+        model: openrouter/google/gemini-3.1-flash-lite-preview
+        sub_agents:
+          - name: security
+            description: Checks for security problems
+            prompt: You are a security reviewer.
+            model: openai/gpt-5-mini
+            limits:
+              max_turns: 10
+          - name: %s
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: node }
+          run:
+            path: echo
+`
+
+		config, err := backwards.ParseConfig(fmt.Appendf(nil, pipeline, "tests\n            prompt: You review tests."))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		subAgents := config.Jobs[0].Plan[0].AgentSubAgents
+		assert.Expect(subAgents).To(HaveLen(2))
+		assert.Expect(subAgents[0].Name).To(Equal("security"))
+		assert.Expect(subAgents[0].Model).To(Equal("openai/gpt-5-mini"))
+		assert.Expect(subAgents[0].Limits.MaxTurns).To(Equal(10))
+		assert.Expect(subAgents[1].Prompt).To(Equal("You review tests."))
+
+		_, err = backwards.ParseConfig(fmt.Appendf(nil, pipeline, "tests"))
+		assert.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("parses memory", func(t *testing.T) {
+		t.Parallel()
+
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfig([]byte(`
+jobs:
+  - name: review
+    plan:
+      - agent: reviewer
+        prompt: Review the change
+        model: openrouter/google/gemini-3.1-flash-lite-preview
+        memory:
+          ttl: 168h
+          max_entries: 20
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: node }
+          run:
+            path: echo
+`))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		memory := config.Jobs[0].Plan[0].AgentMemory
+		assert.Expect(memory).NotTo(BeNil())
+		assert.Expect(memory.TTL).To(Equal("168h"))
+		assert.Expect(memory.MaxEntries).To(Equal(20))
+		assert.Expect(memory.MaxBytes).To(BeZero())
+	})
 }
@@ -17,6 +17,9 @@ import (
 	_ "github.com/jtarchie/pocketci/orchestra/docker"
 	_ "github.com/jtarchie/pocketci/orchestra/native"
 	_ "github.com/jtarchie/pocketci/resources/mock"
+	_ "github.com/jtarchie/pocketci/resources/s3"
+	_ "github.com/jtarchie/pocketci/resources/semver"
+	_ "github.com/jtarchie/pocketci/resources/time"
 	"github.com/jtarchie/pocketci/storage"
 	_ "github.com/jtarchie/pocketci/storage/sqlite"
 	"github.com/jtarchie/pocketci/testhelpers"
@@ -574,6 +577,76 @@ func TestBackwardsCompatibility(t *testing.T) {
 	})
 }
 
+func TestVersionEveryWithTime(t *testing.T) {
+	t.Parallel()
+
+	assert := NewGomegaWithT(t)
+
+	tempDir := t.TempDir()
+	storageURL := fmt.Sprintf("sqlite://%s", filepath.Join(tempDir, "test.db"))
+	pipelineFile := "versions/time-every.yml"
+
+	runner := testhelpers.Runner{
+		Pipeline: pipelineFile,
+		Driver:   "native",
+		Storage:  storageURL,
+	}
+	err := runner.Run(nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	pipelinePath, err := filepath.Abs(pipelineFile)
+	assert.Expect(err).NotTo(HaveOccurred())
+	runtimeID := youtubeIDStyle(pipelinePath)
+
+	initStorage, found := storage.GetFromDSN(storageURL)
+	assert.Expect(found).To(BeTrue())
+
+	store, err := initStorage(storageURL, runtimeID, nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = store.Close() }()
+
+	key := fmt.Sprintf("/rv/%s/clock/versions/%010d", runtimeID, 0)
+	payload, err := store.Get(context.Background(), key)
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	version := payload["version"].(map[string]interface{})
+	_, err = time.Parse(time.RFC3339, version["time"].(string))
+	assert.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestPutWithSemver(t *testing.T) {
+	t.Parallel()
+
+	assert := NewGomegaWithT(t)
+
+	tempDir := t.TempDir()
+	storageURL := fmt.Sprintf("sqlite://%s", filepath.Join(tempDir, "test.db"))
+	pipelineFile := "versions/semver-put.yml"
+
+	runner := testhelpers.Runner{
+		Pipeline: pipelineFile,
+		Driver:   "native",
+		Storage:  storageURL,
+	}
+	err := runner.Run(nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	pipelinePath, err := filepath.Abs(pipelineFile)
+	assert.Expect(err).NotTo(HaveOccurred())
+	runtimeID := youtubeIDStyle(pipelinePath)
+
+	initStorage, found := storage.GetFromDSN(storageURL)
+	assert.Expect(found).To(BeTrue())
+
+	store, err := initStorage(storageURL, runtimeID, nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = store.Close() }()
+
+	payload, err := store.Get(context.Background(), fmt.Sprintf("/semver/%s/release", runtimeID))
+	assert.Expect(err).NotTo(HaveOccurred())
+	assert.Expect(payload["number"]).To(Equal("2.0.0"))
+}
+
 func TestVersionEveryWithMock(t *testing.T) {
 	t.Parallel()
 
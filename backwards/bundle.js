@@ -1 +1 @@
-function D(i){return i==null?"success":i instanceof d?"failure":i instanceof b?"abort":"error"}function _(i){if(i==null)return"on_success";if(i instanceof d)return"on_failure";if(i instanceof v)return"on_error";if(i instanceof b)return"on_abort"}function k(i){let e=Date.now()-new Date(i).getTime(),t=Math.floor(e/1e3),s=Math.floor(t/3600),r=Math.floor(t%3600/60),n=t%60;return s>0?`${s}h ${r}m ${n}s`:r>0?`${r}m ${n}s`:`${n}s`}function P(i){try{return storage.get(i)}catch{return null}}function $(){return typeof pipelineContext<"u"&&pipelineContext.runID?pipelineContext.runID:String(Date.now())}function C(i){let e=[];for(let t of i)if("get"in t&&t.passed)for(let s of t.passed)e.includes(s)||e.push(s);return e}var N=class{constructor(e,t){this.taskNames=e;this.resources=t}knownMounts={};async runTask(e,t,s){let r=s,n=new Date().toISOString(),o=await this.prepareMounts(e);this.taskNames.push(e.task),storage.set(r,{status:"pending",started_at:n});let a,c;if(e.image){let u=this.resources.find(g=>g.name===e.image);if(!u)throw new Error(`Image resource '${e.image}' not found`);if(u.type!=="registry-image")throw new Error(`Image resource '${e.image}' must be of type 'registry-image', got '${u.type}'`);c=u.source.repository}else c=e.config?.image_resource.source.repository;let f=[];try{a=await runtime.run({command:{path:e.config.run.path,args:e.config.run.args||[],user:e.config.run.user},container_limits:e.config.container_limits,env:e.config.env,image:c,name:e.task,mounts:o,privileged:e.privileged??!1,stdin:t??"",timeout:e.timeout,storage_key:r,onOutput:(g,l)=>{f.push({type:g,content:l}),storage.set(r,{status:"running",started_at:n,logs:f.slice()})}});let u="success";return a.status=="abort"?u="abort":a.code!==0&&(u="failure"),storage.set(r,{status:u,code:a.code,started_at:n,elapsed:k(n),logs:f.slice()}),this.validateTaskResult(e,a,r),a}catch(u){throw storage.set(r,{status:"error",started_at:n,elapsed:k(n)}),new v(`Task ${e.task} errored with message ${u}`)}}getKnownMounts(){return this.knownMounts}async prepareMounts(e){let t={},s=e.config.inputs||[],r=e.config.outputs||[],n=e.config.caches||[];for(let o of s)this.knownMounts[o.name]||=await runtime.createVolume(),t[o.name]=this.knownMounts[o.name];for(let o of r)this.knownMounts[o.name]||=await runtime.createVolume(),t[o.name]=this.knownMounts[o.name];for(let o of n){let a=this.pathToCacheName(o.path);this.knownMounts[a]||=await runtime.createVolume({name:a});let c=o.path.replace(/^\/+/,"");t[c]=this.knownMounts[a]}return t}pathToCacheName(e){return"cache-"+e.replace(/^\/+/,"").replace(/[^a-zA-Z0-9]+/g,"-").replace(/-+/g,"-").replace(/-$/,"").toLowerCase()}validateTaskResult(e,t,s){e.assert?.stdout&&e.assert.stdout.trim()!==""&&this.assertOutputEventuallyContains("stdout",e.assert.stdout,t,s),e.assert?.stderr&&e.assert.stderr.trim()!==""&&this.assertOutputEventuallyContains("stderr",e.assert.stderr,t,s),typeof e.assert?.code=="number"&&assert.equal(e.assert.code,t.code)}assertOutputEventuallyContains(e,t,s,r){assert.eventuallyContainsString(()=>this.getLatestTaskOutput(e,s,r),t,1e3,50)}getLatestTaskOutput(e,t,s){let r=e==="stdout"?t.stdout:t.stderr,n=P(s);if(n?.logs&&Array.isArray(n.logs)){let o=n.logs.filter(a=>a?.type===e&&typeof a?.content=="string").map(a=>a.content).join("");o.length>r.length&&(r=o)}return r}},R=class extends Error{constructor(e){super(e),this.name=this.constructor.name}},d=class extends R{},v=class extends R{},b=class extends R{};var M=class{constructor(e,t){this.jobMaxInFlight=e;this.pipelineMaxInFlight=t}getDefaultMaxInFlight(){if(this.jobMaxInFlight&&this.jobMaxInFlight>0)return this.jobMaxInFlight;if(this.pipelineMaxInFlight&&this.pipelineMaxInFlight>0)return this.pipelineMaxInFlight}resolveMaxInFlight(e){let t=this.getDefaultMaxInFlight();return t&&t>0?t:e&&e>0?e:Number.MAX_SAFE_INTEGER}async runWithConcurrencyLimit(e,t,s,r=!1){if(e.length===0)return{failed:!1};let n=Math.max(1,Math.min(this.resolveMaxInFlight(s),e.length)),o=0,a=0,c=!1,f=[];await new Promise(g=>{let l=()=>{if(o>=e.length&&a===0){g();return}for(;a<n&&o<e.length&&!(r&&c);){let p=o;o+=1,a+=1,Promise.resolve(t(e[p],p)).catch(h=>{c=!0,f.push(h)}).finally(()=>{a-=1,l()})}(r&&c||o>=e.length)&&a===0&&g()};l()});let u=f.find(g=>g instanceof b)??f.find(g=>g instanceof v)??f.find(g=>g instanceof d)??f[0];return{failed:c,firstError:u}}};function Q(i,e){return String(i).padStart(e,"0")}function T(i,e){let t=String(e).split(".")[1]?.length||0;return Q(i,t)}var J=class{constructor(e,t){this.buildID=e;this.jobName=t}getBaseStorageKey(){return`/pipeline/${this.buildID}/jobs/${this.jobName}`}withAttemptPath(e,t){return t?`${e}/attempt/${t}`:e}};var A=class{jobParams={};setJobParams(e){this.jobParams=e}generateAcrossCombinations(e){if(e.length===0)return[{}];let[t,...s]=e,r=this.generateAcrossCombinations(s),n=[];for(let o of t.values)for(let a of r)n.push({[t.var]:o,...a});return n}injectAcrossVariables(e,t){let s={...e};if("task"in s&&s.config){let r=Object.values(t).join("-");s.task=`${s.task}-${r}`,s.config={...s.config,env:{...s.config.env,...t}}}return delete s.across,delete s.fail_fast,s}injectJobParams(e){if(Object.keys(this.jobParams).length===0)return e;let t={...e};return"task"in t&&t.config&&(t.config={...t.config,env:{...this.jobParams,...t.config.env}}),t}};var H=class{getIdentifier(e){return"across"}async process(e,t,s){let r=e.variableResolver.generateAcrossCombinations(t.across),n=`${e.paths.getBaseStorageKey()}/${s}/across`;storage.set(n,{status:"pending",total:r.length});let o=!1,a=t.fail_fast||!1,c=t.across.map(l=>l.max_in_flight).filter(l=>!!(l&&l>0)),f=c.length>0?Math.min(...c):1,u=a?1:f,g=await e.concurrency.runWithConcurrencyLimit(r,async(l,p)=>{let h=Object.entries(l).map(([w,I])=>`${w}_${I}`).join("_"),j=e.variableResolver.injectAcrossVariables(t,l);try{await e.processStepInternal(j,`${s}/across/${p}_${h}`)}catch(w){throw o=!0,console.error(`Across combination ${p} failed:`,w),w}},u,a);if(g.failed&&(o=!0,a))throw storage.set(n,{status:"failure"}),g.firstError??new d("One or more across combinations failed");if(o)throw storage.set(n,{status:"failure"}),new d("One or more across combinations failed");storage.set(n,{status:"success",total:r.length})}};var K=class{getIdentifier(e){return`agent/${e.agent}`}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n=`/agent-audit/${e.buildID}/jobs/${e.jobName}/${s}/events`,o=t.config?.image_resource?.source?.repository??"busybox",a={};for(let m of t.config?.inputs??[]){let y=e.taskRunner.getKnownMounts()[m.name];y&&(a[m.name]=y)}let c=t.config?.outputs??[];for(let m of c)e.taskRunner.getKnownMounts()[m.name]||=await runtime.createVolume({name:m.name}),a[m.name]=e.taskRunner.getKnownMounts()[m.name];let f=c.length>0?c[0].name:"",u="",g,l=[],p=new Date().toISOString();storage.set(r,{status:"pending",started_at:p});let h=!1,j=0,w=500,I=()=>{h=!1,j=Date.now(),storage.set(r,{status:"running",started_at:p,stdout:u,usage:g,audit_log:l})},Z=()=>{if(Date.now()-j<w){h=!0;return}I()};try{let m=await runtime.agent({name:t.agent,prompt:t.prompt,model:t.model,image:o,mounts:a,outputVolumePath:f,llm:t.llm,thinking:t.thinking,safety:t.safety,context_guard:t.context_guard,limits:t.limits,context:t.context,onUsage:y=>{g=y,Z()},onAuditEvent:y=>{l.push(y),storage.set(`${n}/${l.length-1}`,{...y,index:l.length-1}),Z()},onOutput:(y,re)=>{u+=re,Z()}});h&&I(),storage.set(r,{status:m.status==="limit_exceeded"?"limit_exceeded":"success",started_at:p,elapsed:k(p),stdout:m.text,usage:g??m.usage,audit_log:m.auditLog});for(let y of c)e.taskRunner.getKnownMounts()[y.name]=a[y.name]}catch(m){throw storage.set(r,{status:"failure",started_at:p,elapsed:k(p),stdout:u,error_message:String(m),usage:g,audit_log:l}),new d(`Agent ${t.agent} failed: ${m}`)}}};function V(i,e){return i.find(t=>t.name===e)}function O(i,e){return i.find(t=>t.name===e)}function x(i){return{ensure:i.ensure,on_success:i.on_success,on_failure:i.on_failure,on_error:i.on_error,on_abort:i.on_abort,timeout:i.timeout}}async function E(i,e,t,s,r){storage.set(s,{status:D(r)});let n=_(r);n&&e[n]&&await i.processStep(e[n],`${t}/${n}`),e.ensure&&await i.processStep(e.ensure,`${t}/ensure`)}var F=class{getIdentifier(e){return"do"}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n,o="try"in t;try{storage.set(r,{status:"pending"});let a=[];if("in_parallel"in t?a=t.in_parallel.steps:"do"in t?a=t.do:"try"in t&&(a=t.try),"in_parallel"in t){let c=await e.concurrency.runWithConcurrencyLimit(a,async(f,u)=>{await e.processStep(f,`${s}/${T(u,a.length)}`)},t.in_parallel.limit,t.in_parallel.fail_fast);if(c.failed)throw c.firstError}else for(let c=0;c<a.length;c++)await e.processStep(a[c],`${s}/${T(c,a.length)}`)}catch(a){n=a}if(await E(e,t,s,r,n),n&&!o)throw n}};function ne(i){let e=5381;for(let t=0;t<i.length;t++)e=Math.imul(e,31)^i.charCodeAt(t);return(e>>>0).toString(16)}function B(i){return`/rv/${i}/meta`}function L(i,e){return`/rv/${i}/versions/${Q(e,10)}`}function oe(i,e){return`/rv/${i}/v/${ne(e)}`}var S=P;function ee(i,e,t){let s=JSON.stringify(e),r=new Date().toISOString(),n=oe(i,s),o=S(n);if(o!=null&&o.version_json===s){let f=L(i,o.index),u=S(f);u&&storage.set(f,{...u,job_name:t,fetched_at:r});return}let c=S(B(i))?.count??0;storage.set(L(i,c),{version:e,job_name:t,fetched_at:r}),storage.set(n,{index:c,version_json:s}),storage.set(B(i),{count:c+1})}function te(i){let t=S(B(i))?.count??0;return t<=0?null:S(L(i,t-1))}function se(i,e){let s=S(B(i))?.count??0,r=e>0?Math.min(e,s):s,n=[];for(let o=0;o<r;o++){let a=S(L(i,o));a&&n.push(a)}return n}var G=class{getIdentifier(e){return`get/${e.get}`}async process(e,t,s){let r=V(e.resources,t.get),n=O(e.resourceTypes,r?.type),o=this.getVersionMode(t),c=typeof pipelineContext<"u"&&pipelineContext.driverName==="native"&&nativeResources.isNative(r?.type),f=this.getScopedResourceName(r.name),u=await this.resolveVersionToFetch(t,r,n,o,f,c,e,s);if(c){let g=await runtime.createVolume({name:r.name});e.taskRunner.getKnownMounts()[r.name]=g;let l=`${e.paths.getBaseStorageKey()}/${s}`;storage.set(l,{status:"pending",resource:r.name});try{nativeResources.fetch({type:r.type,source:r.source,version:u,params:t.params,destDir:g.path}),storage.set(l,{status:"success",version:u,resource:r.name})}catch(p){throw storage.set(l,{status:"error",resource:r.name,error:String(p)}),new Error(`Failed to fetch resource '${r.name}': ${p}`)}}else await e.runTask({task:`get-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/in",args:[`./${r.name}`]}},assert:{code:0},...x(t)},JSON.stringify({source:r.source,version:u}),`${s}/get`);ee(f,u,e.jobName)}getVersionMode(e){return e.version?typeof e.version=="string"?e.version==="every"?"every":"latest":"pinned":"latest"}getScopedResourceName(e){return`${typeof pipelineContext<"u"&&pipelineContext.pipelineID?pipelineContext.pipelineID:"default"}/${e}`}async resolveVersionToFetch(e,t,s,r,n,o,a,c){if(r==="pinned")return e.version;let f;r==="every"&&(f=te(n)?.version);let u;if(o)u=nativeResources.check({type:t.type,source:t.source,version:f}).versions;else{let g=await a.runTask({task:`check-${t.name}`,config:{image_resource:{type:"registry-image",source:{repository:s.source.repository}},run:{path:"/opt/resource/check"}},assert:{code:0},...x(e)},JSON.stringify({source:t.source,version:f}),`${c}/check`);u=JSON.parse(g.stdout)}if(u.length===0)throw new Error(`No versions found for resource ${t.name}`);if(r==="every"){let g=se(n,0),l=new Set(g.map(h=>JSON.stringify(h.version))),p=u.filter(h=>!l.has(JSON.stringify(h)));return p.length>0?p[0]:u[u.length-1]}return u[u.length-1]}};var W=class{getIdentifier(e){let t=e;return`notify/${Array.isArray(t.notify)?t.notify.join("-"):t.notify}`}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n;try{storage.set(r,{status:"pending"}),notify.updateJobName(e.jobName),notify.updateStatus("running");let o=Array.isArray(t.notify)?t.notify:[t.notify];if(t.async){for(let a of o)notify.send({name:a,message:t.message,async:!0});storage.set(r,{status:"success"})}else o.length===1?await notify.send({name:o[0],message:t.message,async:!1}):await notify.sendMultiple(o,t.message,!1),storage.set(r,{status:"success"})}catch(o){n=o,storage.set(r,{status:"failure"})}if(await E(e,t,s,r,n),n)throw new d(`Notification failed: ${n}`)}};var z=class{getIdentifier(e){return`put/${e.put}`}async process(e,t,s){let r=V(e.resources,t.put),n=O(e.resourceTypes,r?.type),o=x(t),a=await e.runTask({task:`put-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/out",args:[`./${r.name}`]}},assert:{code:0},...o},JSON.stringify({source:r.source,params:t.params}),`${s}/put`),c=JSON.parse(a.stdout).version;await e.runTask({task:`get-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/in",args:[`./${r.name}`]}},assert:{code:0},...o},JSON.stringify({source:r.source,version:c}),`${s}/get`)}};var q=class{getIdentifier(e){return`tasks/${e.task}`}async process(e,t,s){let r=t;if("file"in t){let f=await this.getFile(e,t.file,s),u=YAML.parse(f);r={task:t.task,parallelism:t.parallelism,config:u,assert:t.assert,ensure:t.ensure,on_success:t.on_success,on_failure:t.on_failure,on_error:t.on_error,on_abort:t.on_abort,timeout:t.timeout}}let n=r.parallelism||1;if(n<=1){await e.runTask(r,void 0,s);return}let o=`${e.paths.getBaseStorageKey()}/${s}/parallelism`;storage.set(o,{status:"pending",total:n});let a=Array.from({length:n},(f,u)=>u+1),c=await e.concurrency.runWithConcurrencyLimit(a,async f=>{let u={...r,task:`${r.task}-${f}`,config:{...r.config,env:{...r.config.env,CI_TASK_COUNT:String(n),CI_TASK_INDEX:String(f)}}};await e.runTask(u,void 0,`${s}/parallelism/${f}`)});if(c.failed)throw storage.set(o,{status:"failure",total:n}),c.firstError??new d("One or more parallel task instances failed");storage.set(o,{status:"success",total:n})}async getFile(e,t,s){let r=t.split("/")[0];return(await e.runTask({task:`get-file-${t}`,config:{image_resource:{type:"registry-image",source:{repository:"busybox"}},inputs:[{name:r}],run:{path:"sh",args:["-c",`cat ${t}`]}},assert:{code:0}},void 0,s)).stdout}};var U=class{doHandler;getIdentifier(e){return"try"}constructor(e){this.doHandler=e}async process(e,t,s){try{await this.doHandler.process(e,t,s)}catch{}finally{storage.set(s,{status:"success"})}}};var ie=$(),X=class{constructor(e,t,s,r){this.jobConfig=e;this.resources=t;this.resourceTypes=s;this.pipelineMaxInFlight=r;this.buildID=ie,this.taskRunner=new N(this.taskNames,this.resources),this.paths=new J(this.buildID,this.jobConfig.name),this.concurrency=new M(this.jobConfig.max_in_flight,this.pipelineMaxInFlight),this.variableResolver=new A,this.ctx={paths:this.paths,concurrency:this.concurrency,variableResolver:this.variableResolver,taskRunner:this.taskRunner,resources:this.resources,resourceTypes:this.resourceTypes,buildID:this.buildID,jobName:this.jobConfig.name,processStep:(n,o)=>this.processStep(n,o),processStepInternal:(n,o,a)=>this.processStepInternal(n,o,a),runTask:(n,o,a)=>this.runTask(n,o,a)}}taskNames=[];taskRunner;buildID;paths;concurrency;variableResolver;ctx;doHandler=new F;acrossHandler=new H;handlers=[["get",new G],["do",this.doHandler],["put",new z],["try",new U(this.doHandler)],["task",new q],["in_parallel",this.doHandler],["notify",new W],["agent",new K]];async run(){let e=this.paths.getBaseStorageKey(),t,s=C(this.jobConfig.plan),r=this.jobConfig.triggers?.webhook?.filter??this.jobConfig.webhook_trigger;if(r&&!webhookTrigger(r)){storage.set(e,{status:"skipped",dependsOn:s});return}let n=this.jobConfig.triggers?.webhook?.params;n&&this.variableResolver.setJobParams(webhookParams(n)),storage.set(e,{status:"pending",dependsOn:s});try{for(let o=0;o<this.jobConfig.plan.length;o++)await this.processStep(this.jobConfig.plan[o],T(o,this.jobConfig.plan.length));storage.set(e,{status:"success",dependsOn:s})}catch(o){console.error(o),t=o,storage.set(e,{status:D(t),dependsOn:s})}try{let o=_(t);o&&this.jobConfig[o]&&await this.processStep(this.jobConfig[o],`hooks/${o}`),this.jobConfig.ensure&&await this.processStep(this.jobConfig.ensure,"hooks/ensure")}catch(o){console.error(o)}this.jobConfig.assert?.execution&&assert.equal(this.taskNames,this.jobConfig.assert.execution)}async processStep(e,t){let s=e.attempts||1;if(s<=1){await this.processStepInternal(e,t);return}let{ensure:r,on_success:n,on_failure:o,on_error:a,on_abort:c,...f}=e,u=null,g=!1;for(let l=1;l<=s;l++)try{await this.processStepInternal(f,t,l),g=!0;break}catch(p){u=p,l<s&&console.log(`Attempt ${l}/${s} failed, retrying...`)}try{let l=_(g?void 0:u),p={on_success:n,on_failure:o,on_error:a,on_abort:c};l&&p[l]&&await this.processStep(p[l],`${t}/${l}`)}finally{r&&await this.processStep(r,`${t}/ensure`)}if(!g&&u)throw u}async processStepInternal(e,t,s){if(e=this.variableResolver.injectJobParams(e),e.across&&e.across.length>0){await this.acrossHandler.process(this.ctx,e,t);return}let r=this.getHandler(e);if(r){let n=this.paths.withAttemptPath(`${t}/${r.getIdentifier(e)}`,s);await r.process(this.ctx,e,n)}}getHandler(e){for(let[t,s]of this.handlers)if(t in e)return s}async runTask(e,t,s=""){let r=`${this.paths.getBaseStorageKey()}/${s}`,n;try{n=await this.taskRunner.runTask(e,t,r)}catch(o){throw e.on_error&&await this.processStep(e.on_error,`${s}/on_error`),new v(`Task ${e.task} errored with message ${o}`)}if(n.code===0&&n.status=="complete"&&e.on_success?await this.processStep(e.on_success,`${s}/on_success`):n.code!==0&&n.status=="complete"&&e.on_failure?await this.processStep(e.on_failure,`${s}/on_failure`):n.status=="abort"&&e.on_abort&&await this.processStep(e.on_abort,`${s}/on_abort`),e.ensure&&await this.processStep(e.ensure,`${s}/ensure`),n.code>0)throw new d(`Task ${e.task} failed with code ${n.code}`);if(n.status=="abort")throw new b(`Task ${e.task} aborted with message ${n.message}`);return n}};var Y=class{constructor(e){this.config=e;this.addBuiltInResourceTypes(),this.validatePipelineConfig(),this.initializeNotifications()}jobResults=new Map;executedJobs=[];addBuiltInResourceTypes(){let e={name:"registry-image",type:"registry-image",source:{repository:"concourse/registry-image-resource"}};this.config.resource_types.some(s=>s.name==="registry-image")||this.config.resource_types.push(e)}initializeNotifications(){this.config.notifications&&notify.setConfigs(this.config.notifications);let e=$();notify.setContext({pipelineName:this.config.jobs[0]?.name||"unknown",jobName:"",buildID:e,status:"pending",startTime:new Date().toISOString(),endTime:"",duration:"",environment:{},taskResults:{}})}validatePipelineConfig(){assert.truthy(this.config.jobs.length>0,"Pipeline must have at least one job"),assert.truthy(this.config.jobs.every(t=>t.plan.length>0),"Every job must have at least one step");let e=this.config.jobs.map(t=>t.name);assert.equal(e.length,new Set(e).size,"Job names must be unique"),this.config.jobs.length>1&&this.validateJobDependencies(),this.config.resources.length>0&&this.validateResources()}validateJobDependencies(){let e=new Set(this.config.jobs.map(t=>t.name));assert.truthy(this.config.jobs.every(t=>t.plan.every(s=>"get"in s&&s.passed?s.passed.every(r=>e.has(r)):!0)),"All passed constraints must reference existing jobs"),this.detectCircularDependencies()}detectCircularDependencies(){let e={};for(let n of this.config.jobs)e[n.name]=[];for(let n of this.config.jobs)for(let o of n.plan)if("get"in o&&o.passed)for(let a of o.passed)e[a].push(n.name);let t=new Set,s=new Set,r=n=>{if(!t.has(n)){t.add(n),s.add(n);for(let o of e[n]){if(!t.has(o)&&r(o))return!0;if(s.has(o))return!0}}return s.delete(n),!1};for(let n of this.config.jobs)!t.has(n.name)&&r(n.name)&&assert.truthy(!1,"Pipeline contains circular job dependencies")}validateResources(){assert.truthy(this.config.resources.every(e=>this.config.resource_types.some(t=>t.name===e.type)),"Every resource must have a valid resource type"),assert.truthy(this.config.jobs.every(e=>e.plan.every(t=>"get"in t?this.config.resources.some(s=>s.name===t.get):!0)),"Every get must have a resource reference")}async run(){this.writeAllJobsAsPending();let e=this.findJobsWithNoDependencies();for(let t of e)await this.runJob(t);this.config.assert?.execution&&assert.equal(this.executedJobs,this.config.assert.execution)}writeAllJobsAsPending(){let e=$();for(let t of this.config.jobs){let s=C(t.plan),r=`/pipeline/${e}/jobs/${t.name}`;storage.set(r,{status:"pending",dependsOn:s})}}findJobsWithNoDependencies(){return this.config.jobs.filter(e=>!e.plan.some(t=>!!("get"in t&&t.passed)))}async runJob(e){this.executedJobs.push(e.name);try{await new X(e,this.config.resources,this.config.resource_types,this.config.max_in_flight).run(),this.jobResults.set(e.name,!0),await this.runDependentJobs(e.name)}catch(t){throw this.jobResults.set(e.name,!1),t}}async runDependentJobs(e){let t=this.findDependentJobs(e);for(let s of t)this.canJobRun(s)&&await this.runJob(s)}findDependentJobs(e){return this.config.jobs.filter(t=>t.plan.some(s=>!!("get"in s&&s.passed&&s.passed.includes(e))))}canJobRun(e){for(let t of e.plan)if("get"in t&&t.passed&&t.passed.length>0&&!t.passed.every(r=>this.jobResults.get(r)===!0))return!1;return!0}};function ae(i){let e=new Y(i);return()=>e.run()}globalThis.createPipeline=ae;export{ae as createPipeline};
+function N(i){return i==null?"success":i instanceof m?"failure":i instanceof b?"abort":"error"}function R(i){if(i==null)return"on_success";if(i instanceof m)return"on_failure";if(i instanceof v)return"on_error";if(i instanceof b)return"on_abort"}function k(i){let e=Date.now()-new Date(i).getTime(),t=Math.floor(e/1e3),s=Math.floor(t/3600),r=Math.floor(t%3600/60),n=t%60;return s>0?`${s}h ${r}m ${n}s`:r>0?`${r}m ${n}s`:`${n}s`}function te(i){try{return storage.get(i)}catch{return null}}function _(){return typeof pipelineContext<"u"&&pipelineContext.runID?pipelineContext.runID:String(Date.now())}function j(i){let e=[];for(let t of i)if("get"in t&&t.passed)for(let s of t.passed)e.includes(s)||e.push(s);return e}var J=class{constructor(e,t){this.taskNames=e;this.resources=t}knownMounts={};async runTask(e,t,s){let r=s,n=new Date().toISOString(),o=await this.prepareMounts(e);this.taskNames.push(e.task),storage.set(r,{status:"pending",started_at:n});let a,c;if(e.image){let u=this.resources.find(l=>l.name===e.image);if(!u)throw new Error(`Image resource '${e.image}' not found`);if(u.type!=="registry-image")throw new Error(`Image resource '${e.image}' must be of type 'registry-image', got '${u.type}'`);c=u.source.repository}else c=e.config?.image_resource.source.repository;try{a=await runtime.run({command:{path:e.config.run.path,args:e.config.run.args||[],user:e.config.run.user},container_limits:e.config.container_limits,env:e.config.env,image:c,name:e.task,mounts:o,privileged:e.privileged??!1,stdin:t??"",timeout:e.timeout,storage_key:r});let u="success";return a.status=="abort"?u="abort":a.code!==0&&(u="failure"),storage.set(r,{status:u,code:a.code,started_at:n,elapsed:k(n)}),this.validateTaskResult(e,a),a}catch(u){throw storage.set(r,{status:"error",started_at:n,elapsed:k(n)}),new v(`Task ${e.task} errored with message ${u}`)}}getKnownMounts(){return this.knownMounts}async prepareMounts(e){let t={},s=e.config.inputs||[],r=e.config.outputs||[],n=e.config.caches||[];for(let o of s)this.knownMounts[o.name]||=await runtime.createVolume(),t[o.name]=this.knownMounts[o.name];for(let o of r)this.knownMounts[o.name]||=await runtime.createVolume(),t[o.name]=this.knownMounts[o.name];for(let o of n){let a=this.pathToCacheName(o.path);this.knownMounts[a]||=await runtime.createVolume({name:a});let c=o.path.replace(/^\/+/,"");t[c]=this.knownMounts[a]}return t}pathToCacheName(e){return"cache-"+e.replace(/^\/+/,"").replace(/[^a-zA-Z0-9]+/g,"-").replace(/-+/g,"-").replace(/-$/,"").toLowerCase()}validateTaskResult(e,t){e.assert?.stdout&&e.assert.stdout.trim()!==""&&this.assertOutputEventuallyContains("stdout",e.assert.stdout,t),e.assert?.stderr&&e.assert.stderr.trim()!==""&&this.assertOutputEventuallyContains("stderr",e.assert.stderr,t),typeof e.assert?.code=="number"&&assert.equal(e.assert.code,t.code)}assertOutputEventuallyContains(e,t,s){assert.eventuallyContainsString(()=>e==="stdout"?s.stdout:s.stderr,t,1e3,50)}},x=class extends Error{constructor(e){super(e),this.name=this.constructor.name}},m=class extends x{},v=class extends x{},b=class extends x{};var M=class{constructor(e,t){this.jobMaxInFlight=e;this.pipelineMaxInFlight=t}getDefaultMaxInFlight(){if(this.jobMaxInFlight&&this.jobMaxInFlight>0)return this.jobMaxInFlight;if(this.pipelineMaxInFlight&&this.pipelineMaxInFlight>0)return this.pipelineMaxInFlight}resolveMaxInFlight(e){let t=this.getDefaultMaxInFlight();return t&&t>0?t:e&&e>0?e:Number.MAX_SAFE_INTEGER}async runWithConcurrencyLimit(e,t,s,r=!1){if(e.length===0)return{failed:!1};let n=Math.max(1,Math.min(this.resolveMaxInFlight(s),e.length)),o=0,a=0,c=!1,u=[];await new Promise(f=>{let p=()=>{if(o>=e.length&&a===0){f();return}for(;a<n&&o<e.length&&!(r&&c);){let g=o;o+=1,a+=1,Promise.resolve(t(e[g],g)).catch(h=>{c=!0,u.push(h)}).finally(()=>{a-=1,p()})}(r&&c||o>=e.length)&&a===0&&f()};p()});let l=u.find(f=>f instanceof b)??u.find(f=>f instanceof v)??u.find(f=>f instanceof m)??u[0];return{failed:c,firstError:l}}};function ee(i,e){return String(i).padStart(e,"0")}function T(i,e){let t=String(e).split(".")[1]?.length||0;return ee(i,t)}var V=class{constructor(e,t){this.buildID=e;this.jobName=t}getBaseStorageKey(){return`/pipeline/${this.buildID}/jobs/${this.jobName}`}withAttemptPath(e,t){return t?`${e}/attempt/${t}`:e}};var se=/\(\(\s*\.:([-\/.\w"]+)\s*\)\)/g,A=class{jobParams={};localVars={};setJobParams(e){this.jobParams=e}setLocalVar(e,t){this.localVars[e]=t}interpolateLocalVars(e,t=this.localVars){return Object.keys(t).length===0?e:typeof e=="string"?this.interpolateString(e,t):Array.isArray(e)?e.map(s=>this.interpolateLocalVars(s,t)):e!==null&&typeof e=="object"?Object.fromEntries(Object.entries(e).map(([s,r])=>[s,this.interpolateLocalVars(r,t)])):e}interpolateString(e,t){let s=[...e.matchAll(se)];if(s.length===0)return e;if(s.length===1&&s[0][0]===e){let[r,n]=this.lookupLocalVar(s[0][1],t);return n?r:e}return e.replace(se,(r,n)=>{let[o,a]=this.lookupLocalVar(n,t);return a?typeof o=="string"?o:JSON.stringify(o):r})}lookupLocalVar(e,t){let s=(e.match(/"[^"]*"|[^.]+/g)??[]).map(a=>a.replace(/^"|"$/g,"")),[r,...n]=s;if(r===void 0||!(r in t))return[void 0,!1];let o=t[r];for(let a of n){if(o===null||typeof o!="object"||!(a in o))return[void 0,!1];o=o[a]}return[o,!0]}generateAcrossCombinations(e){if(e.length===0)return[{}];let[t,...s]=e,r=this.generateAcrossCombinations(s),n=[];for(let o of t.values)for(let a of r)n.push({[t.var]:o,...a});return n}injectAcrossVariables(e,t){let s={...e};if("task"in s&&s.config){let r=Object.values(t).join("-");s.task=`${s.task}-${r}`,s.config={...s.config,env:{...s.config.env,...t}}}return delete s.across,delete s.fail_fast,this.interpolateLocalVars(s,t)}injectJobParams(e){if(Object.keys(this.jobParams).length===0)return e;let t={...e};return"task"in t&&t.config&&(t.config={...t.config,env:{...this.jobParams,...t.config.env}}),t}};var H=class{getIdentifier(e){return"across"}async process(e,t,s){let r=e.variableResolver.generateAcrossCombinations(t.across),n=`${e.paths.getBaseStorageKey()}/${s}/across`;storage.set(n,{status:"pending",total:r.length});let o=!1,a=t.fail_fast||!1,c=t.across.map(p=>p.max_in_flight).filter(p=>!!(p&&p>0)),u=c.length>0?Math.min(...c):1,l=a?1:u,f=await e.concurrency.runWithConcurrencyLimit(r,async(p,g)=>{let h=Object.entries(p).map(([w,P])=>`${w}_${P}`).join("_"),I=e.variableResolver.injectAcrossVariables(t,p);try{await e.processStepInternal(I,`${s}/across/${g}_${h}`)}catch(w){throw o=!0,console.error(`Across combination ${g} failed:`,w),w}},l,a);if(f.failed&&(o=!0,a))throw storage.set(n,{status:"failure"}),f.firstError??new m("One or more across combinations failed");if(o)throw storage.set(n,{status:"failure"}),new m("One or more across combinations failed");storage.set(n,{status:"success",total:r.length})}};var K=class{getIdentifier(e){return`agent/${e.agent}`}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n=`/agent-audit/${e.buildID}/jobs/${e.jobName}/${s}/events`,o=t.config?.image_resource?.source?.repository??"busybox",a={};for(let d of t.config?.inputs??[]){let C=e.taskRunner.getKnownMounts()[d.name];C&&(a[d.name]=C)}let c=t.config?.outputs??[];for(let d of c)e.taskRunner.getKnownMounts()[d.name]||=await runtime.createVolume({name:d.name}),a[d.name]=e.taskRunner.getKnownMounts()[d.name];let u=c.length>0?c[0].name:"",l="",f,p=[],g=new Date().toISOString();storage.set(r,{status:"pending",started_at:g});let h=!1,I=0,w=500,P=()=>{h=!1,I=Date.now(),storage.set(r,{status:"running",started_at:g,stdout:l,usage:f,audit_log:p})},Q=()=>{if(Date.now()-I<w){h=!0;return}P()},y;try{y=await runtime.agent({name:t.agent,prompt:t.prompt,model:t.model,image:o,mounts:a,outputVolumePath:u,llm:t.llm,thinking:t.thinking,safety:t.safety,context_guard:t.context_guard,limits:t.limits,context:t.context,mcp_servers:t.mcp_servers,output_schema:t.output_schema,policy:t.policy,sub_agents:t.sub_agents,memory:t.memory,onUsage:d=>{f=d,Q()},onAuditEvent:d=>{p.push(d),storage.set(`${n}/${p.length-1}`,{...d,index:p.length-1}),Q()},onOutput:(d,C)=>{l+=C,Q()}}),h&&P(),storage.set(r,{status:y.status==="limit_exceeded"||y.status==="invalid_output"?y.status:"success",started_at:g,elapsed:k(g),stdout:y.text,output:y.output,usage:f??y.usage,audit_log:y.auditLog});for(let d of c)e.taskRunner.getKnownMounts()[d.name]=a[d.name]}catch(d){throw storage.set(r,{status:"failure",started_at:g,elapsed:k(g),stdout:l,error_message:String(d),usage:f,audit_log:p}),new m(`Agent ${t.agent} failed: ${d}`)}if(y.status==="invalid_output")throw new m(`Agent ${t.agent} did not return output matching output_schema`)}};function O(i,e){return i.find(t=>t.name===e)}function E(i,e){return i.find(t=>t.name===e)}function D(i){return{ensure:i.ensure,on_success:i.on_success,on_failure:i.on_failure,on_error:i.on_error,on_abort:i.on_abort,timeout:i.timeout}}async function $(i,e,t,s,r){storage.set(s,{status:N(r)});let n=R(r);n&&e[n]&&await i.processStep(e[n],`${t}/${n}`),e.ensure&&await i.processStep(e.ensure,`${t}/ensure`)}var F=class{getIdentifier(e){return"do"}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n,o="try"in t;try{storage.set(r,{status:"pending"});let a=[];if("in_parallel"in t?a=t.in_parallel.steps:"do"in t?a=t.do:"try"in t&&(a=t.try),"in_parallel"in t){let c=await e.concurrency.runWithConcurrencyLimit(a,async(u,l)=>{await e.processStep(u,`${s}/${T(l,a.length)}`)},t.in_parallel.limit,t.in_parallel.fail_fast);if(c.failed)throw c.firstError}else for(let c=0;c<a.length;c++)await e.processStep(a[c],`${s}/${T(c,a.length)}`)}catch(a){n=a}if(await $(e,t,s,r,n),n&&!o)throw n}};function ie(i){let e=5381;for(let t=0;t<i.length;t++)e=Math.imul(e,31)^i.charCodeAt(t);return(e>>>0).toString(16)}function L(i){return`/rv/${i}/meta`}function B(i,e){return`/rv/${i}/versions/${ee(e,10)}`}function ae(i,e){return`/rv/${i}/v/${ie(e)}`}var S=te;function re(i,e,t){let s=JSON.stringify(e),r=new Date().toISOString(),n=ae(i,s),o=S(n);if(o!=null&&o.version_json===s){let u=B(i,o.index),l=S(u);l&&storage.set(u,{...l,job_name:t,fetched_at:r});return}let c=S(L(i))?.count??0;storage.set(B(i,c),{version:e,job_name:t,fetched_at:r}),storage.set(n,{index:c,version_json:s}),storage.set(L(i),{count:c+1})}function ne(i){let t=S(L(i))?.count??0;for(let s=t-1;s>=0;s--){let r=S(B(i,s));if(r&&r.job_name)return r}return null}function oe(i,e){let s=S(L(i))?.count??0,r=e>0?Math.min(e,s):s,n=[];for(let o=0;o<r;o++){let a=S(B(i,o));a&&n.push(a)}return n}var W=class{getIdentifier(e){return`get/${e.get}`}async process(e,t,s){let r=O(e.resources,t.get),n=E(e.resourceTypes,r?.type),o=this.getVersionMode(t),c=typeof pipelineContext<"u"&&pipelineContext.driverName==="native"&&nativeResources.isNative(r?.type),u=this.getScopedResourceName(r.name),l=await this.resolveVersionToFetch(t,r,n,o,u,c,e,s);if(c){let f=await runtime.createVolume({name:r.name});e.taskRunner.getKnownMounts()[r.name]=f;let p=`${e.paths.getBaseStorageKey()}/${s}`;storage.set(p,{status:"pending",resource:r.name});try{nativeResources.fetch({type:r.type,source:r.source,version:l,params:t.params,destDir:f.path}),storage.set(p,{status:"success",version:l,resource:r.name})}catch(g){throw storage.set(p,{status:"error",resource:r.name,error:String(g)}),new Error(`Failed to fetch resource '${r.name}': ${g}`)}}else await e.runTask({task:`get-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/in",args:[`./${r.name}`]}},assert:{code:0},...D(t)},JSON.stringify({source:r.source,version:l}),`${s}/get`);re(u,l,e.jobName)}getVersionMode(e){return e.version?typeof e.version=="string"?e.version==="every"?"every":"latest":"pinned":"latest"}getScopedResourceName(e){return`${typeof pipelineContext<"u"&&pipelineContext.pipelineID?pipelineContext.pipelineID:"default"}/${e}`}async resolveVersionToFetch(e,t,s,r,n,o,a,c){if(r==="pinned")return e.version;let u;r==="every"&&(u=ne(n)?.version);let l;if(o)l=nativeResources.check({type:t.type,source:t.source,version:u}).versions;else{let f=await a.runTask({task:`check-${t.name}`,config:{image_resource:{type:"registry-image",source:{repository:s.source.repository}},run:{path:"/opt/resource/check"}},assert:{code:0},...D(e)},JSON.stringify({source:t.source,version:u}),`${c}/check`);l=JSON.parse(f.stdout)}if(l.length===0)throw new Error(`No versions found for resource ${t.name}`);if(r==="every"){let f=oe(n,0),p=new Set(f.filter(h=>h.job_name).map(h=>JSON.stringify(h.version))),g=l.filter(h=>!p.has(JSON.stringify(h)));return g.length>0?g[0]:l[l.length-1]}return l[l.length-1]}};var z=class{getIdentifier(e){return`load_var/${e.load_var}`}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n;try{let o=t.file.split("/")[0],a=await e.runTask({task:`load-var-${t.load_var}`,config:{image_resource:{type:"registry-image",source:{repository:"busybox"}},inputs:[{name:o}],run:{path:"cat",args:[t.file]}},assert:{code:0}},void 0,s);e.variableResolver.setLocalVar(t.load_var,this.parse(t,a.stdout))}catch(o){n=o}if(await $(e,t,s,r,n),n)throw n}parse(e,t){let s=e.format;switch(s||(e.file.endsWith(".json")?s="json":/\.ya?ml$/.test(e.file)?s="yaml":s="trim"),s){case"json":return JSON.parse(t);case"yaml":case"yml":return YAML.parse(t);case"raw":return t;default:return t.trim()}}};var G=class{getIdentifier(e){let t=e;return`notify/${Array.isArray(t.notify)?t.notify.join("-"):t.notify}`}async process(e,t,s){let r=`${e.paths.getBaseStorageKey()}/${s}`,n;try{storage.set(r,{status:"pending"}),notify.updateJobName(e.jobName),notify.updateStatus("running");let o=Array.isArray(t.notify)?t.notify:[t.notify];if(t.async){for(let a of o)notify.send({name:a,message:t.message,async:!0});storage.set(r,{status:"success"})}else o.length===1?await notify.send({name:o[0],message:t.message,async:!1}):await notify.sendMultiple(o,t.message,!1),storage.set(r,{status:"success"})}catch(o){n=o,storage.set(r,{status:"failure"})}if(await $(e,t,s,r,n),n)throw new m(`Notification failed: ${n}`)}};var q=class{getIdentifier(e){return`put/${e.put}`}async process(e,t,s){let r=O(e.resources,t.put),n=E(e.resourceTypes,r?.type),o=D(t);if(typeof pipelineContext<"u"&&pipelineContext.driverName==="native"&&nativeResources.isNative(r?.type)){await this.processNative(e,t,r,s);return}let c=await e.runTask({task:`put-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/out",args:[`./${r.name}`]}},assert:{code:0},...o},JSON.stringify({source:r.source,params:t.params}),`${s}/put`),u=JSON.parse(c.stdout).version;await e.runTask({task:`get-${r.name}`,config:{image_resource:{type:"registry-image",source:{repository:n.source.repository}},outputs:[{name:r.name}],run:{path:"/opt/resource/in",args:[`./${r.name}`]}},assert:{code:0},...o},JSON.stringify({source:r.source,version:u}),`${s}/get`)}async processNative(e,t,s,r){let n=`${e.paths.getBaseStorageKey()}/${r}`;storage.set(n,{status:"pending",resource:s.name});try{let o=e.taskRunner.getKnownMounts(),a={};for(let[l,f]of Object.entries(o))a[l]=f.path;let c=nativeResources.push({type:s.type,source:s.source,params:t.params,srcDir:"",mounts:a}),u=await runtime.createVolume({name:s.name});o[s.name]=u,nativeResources.fetch({type:s.type,source:s.source,version:c.version,destDir:u.path}),storage.set(n,{status:"success",version:c.version,resource:s.name})}catch(o){throw storage.set(n,{status:"error",resource:s.name,error:String(o)}),new Error(`Failed to put resource '${s.name}': ${o}`)}}};var U=class{getIdentifier(e){return`tasks/${e.task}`}async process(e,t,s){let r=t;if("file"in t){let u=await this.getFile(e,t.file,s),l=YAML.parse(u);r={task:t.task,parallelism:t.parallelism,config:l,assert:t.assert,ensure:t.ensure,on_success:t.on_success,on_failure:t.on_failure,on_error:t.on_error,on_abort:t.on_abort,timeout:t.timeout}}let n=r.parallelism||1;if(n<=1){await e.runTask(r,void 0,s);return}let o=`${e.paths.getBaseStorageKey()}/${s}/parallelism`;storage.set(o,{status:"pending",total:n});let a=Array.from({length:n},(u,l)=>l+1),c=await e.concurrency.runWithConcurrencyLimit(a,async u=>{let l={...r,task:`${r.task}-${u}`,config:{...r.config,env:{...r.config.env,CI_TASK_COUNT:String(n),CI_TASK_INDEX:String(u)}}};await e.runTask(l,void 0,`${s}/parallelism/${u}`)});if(c.failed)throw storage.set(o,{status:"failure",total:n}),c.firstError??new m("One or more parallel task instances failed");storage.set(o,{status:"success",total:n})}async getFile(e,t,s){let r=t.split("/")[0];return(await e.runTask({task:`get-file-${t}`,config:{image_resource:{type:"registry-image",source:{repository:"busybox"}},inputs:[{name:r}],run:{path:"sh",args:["-c",`cat ${t}`]}},assert:{code:0}},void 0,s)).stdout}};var X=class{doHandler;getIdentifier(e){return"try"}constructor(e){this.doHandler=e}async process(e,t,s){try{await this.doHandler.process(e,t,s)}catch{}finally{storage.set(s,{status:"success"})}}};var ue=_(),Y=class{constructor(e,t,s,r){this.jobConfig=e;this.resources=t;this.resourceTypes=s;this.pipelineMaxInFlight=r;this.buildID=ue,this.taskRunner=new J(this.taskNames,this.resources),this.paths=new V(this.buildID,this.jobConfig.name),this.concurrency=new M(this.jobConfig.max_in_flight,this.pipelineMaxInFlight),this.variableResolver=new A,this.ctx={paths:this.paths,concurrency:this.concurrency,variableResolver:this.variableResolver,taskRunner:this.taskRunner,resources:this.resources,resourceTypes:this.resourceTypes,buildID:this.buildID,jobName:this.jobConfig.name,processStep:(n,o)=>this.processStep(n,o),processStepInternal:(n,o,a)=>this.processStepInternal(n,o,a),runTask:(n,o,a)=>this.runTask(n,o,a)}}taskNames=[];taskRunner;buildID;paths;concurrency;variableResolver;ctx;doHandler=new F;acrossHandler=new H;handlers=[["get",new W],["do",this.doHandler],["put",new q],["try",new X(this.doHandler)],["task",new U],["in_parallel",this.doHandler],["notify",new G],["agent",new K],["load_var",new z]];async run(){let e=this.paths.getBaseStorageKey(),t,s=j(this.jobConfig.plan),r=this.jobConfig.triggers?.webhook?.filter??this.jobConfig.webhook_trigger;if(r&&!webhookTrigger(r)){storage.set(e,{status:"skipped",dependsOn:s});return}let n=this.jobConfig.triggers?.webhook?.params;n&&this.variableResolver.setJobParams(webhookParams(n)),storage.set(e,{status:"pending",dependsOn:s});try{for(let o=0;o<this.jobConfig.plan.length;o++)await this.processStep(this.jobConfig.plan[o],T(o,this.jobConfig.plan.length));storage.set(e,{status:"success",dependsOn:s})}catch(o){console.error(o),t=o,storage.set(e,{status:N(t),dependsOn:s})}try{let o=R(t);o&&this.jobConfig[o]&&await this.processStep(this.jobConfig[o],`hooks/${o}`),this.jobConfig.ensure&&await this.processStep(this.jobConfig.ensure,"hooks/ensure")}catch(o){console.error(o)}this.jobConfig.assert?.execution&&assert.equal(this.taskNames,this.jobConfig.assert.execution)}async processStep(e,t){let s=e.attempts||1;if(s<=1){await this.processStepInternal(e,t);return}let{ensure:r,on_success:n,on_failure:o,on_error:a,on_abort:c,...u}=e,l=null,f=!1;for(let p=1;p<=s;p++)try{await this.processStepInternal(u,t,p),f=!0;break}catch(g){l=g,p<s&&console.log(`Attempt ${p}/${s} failed, retrying...`)}try{let p=R(f?void 0:l),g={on_success:n,on_failure:o,on_error:a,on_abort:c};p&&g[p]&&await this.processStep(g[p],`${t}/${p}`)}finally{r&&await this.processStep(r,`${t}/ensure`)}if(!f&&l)throw l}async processStepInternal(e,t,s){if(e=this.variableResolver.injectJobParams(e),e=this.variableResolver.interpolateLocalVars(e),e.across&&e.across.length>0){await this.acrossHandler.process(this.ctx,e,t);return}let r=this.getHandler(e);if(r){let n=this.paths.withAttemptPath(`${t}/${r.getIdentifier(e)}`,s);await r.process(this.ctx,e,n)}}getHandler(e){for(let[t,s]of this.handlers)if(t in e)return s}async runTask(e,t,s=""){let r=`${this.paths.getBaseStorageKey()}/${s}`,n;try{n=await this.taskRunner.runTask(e,t,r)}catch(o){throw e.on_error&&await this.processStep(e.on_error,`${s}/on_error`),new v(`Task ${e.task} errored with message ${o}`)}if(n.code===0&&n.status=="complete"&&e.on_success?await this.processStep(e.on_success,`${s}/on_success`):n.code!==0&&n.status=="complete"&&e.on_failure?await this.processStep(e.on_failure,`${s}/on_failure`):n.status=="abort"&&e.on_abort&&await this.processStep(e.on_abort,`${s}/on_abort`),e.ensure&&await this.processStep(e.ensure,`${s}/ensure`),n.code>0)throw new m(`Task ${e.task} failed with code ${n.code}`);if(n.status=="abort")throw new b(`Task ${e.task} aborted with message ${n.message}`);return n}};var Z=class{constructor(e){this.config=e;this.addBuiltInResourceTypes(),this.validatePipelineConfig(),this.initializeNotifications()}jobResults=new Map;executedJobs=[];addBuiltInResourceTypes(){let e={name:"registry-image",type:"registry-image",source:{repository:"concourse/registry-image-resource"}};this.config.resource_types.some(s=>s.name==="registry-image")||this.config.resource_types.push(e)}initializeNotifications(){this.config.notifications&&notify.setConfigs(this.config.notifications);let e=_();notify.setContext({pipelineName:this.config.jobs[0]?.name||"unknown",jobName:"",buildID:e,status:"pending",startTime:new Date().toISOString(),endTime:"",duration:"",environment:{},taskResults:{}})}validatePipelineConfig(){assert.truthy(this.config.jobs.length>0,"Pipeline must have at least one job"),assert.truthy(this.config.jobs.every(t=>t.plan.length>0),"Every job must have at least one step");let e=this.config.jobs.map(t=>t.name);assert.equal(e.length,new Set(e).size,"Job names must be unique"),this.config.jobs.length>1&&this.validateJobDependencies(),this.config.resources.length>0&&this.validateResources()}validateJobDependencies(){let e=new Set(this.config.jobs.map(t=>t.name));assert.truthy(this.config.jobs.every(t=>t.plan.every(s=>"get"in s&&s.passed?s.passed.every(r=>e.has(r)):!0)),"All passed constraints must reference existing jobs"),this.detectCircularDependencies()}detectCircularDependencies(){let e={};for(let n of this.config.jobs)e[n.name]=[];for(let n of this.config.jobs)for(let o of n.plan)if("get"in o&&o.passed)for(let a of o.passed)e[a].push(n.name);let t=new Set,s=new Set,r=n=>{if(!t.has(n)){t.add(n),s.add(n);for(let o of e[n]){if(!t.has(o)&&r(o))return!0;if(s.has(o))return!0}}return s.delete(n),!1};for(let n of this.config.jobs)!t.has(n.name)&&r(n.name)&&assert.truthy(!1,"Pipeline contains circular job dependencies")}validateResources(){assert.truthy(this.config.resources.every(e=>this.config.resource_types.some(t=>t.name===e.type)),"Every resource must have a valid resource type"),assert.truthy(this.config.jobs.every(e=>e.plan.every(t=>"get"in t?this.config.resources.some(s=>s.name===t.get):!0)),"Every get must have a resource reference")}async run(){this.writeAllJobsAsPending();let e=this.findRequestedJobs(),t=e.length>0?e:this.findJobsWithNoDependencies();for(let s of t)await this.runJob(s);e.length>0&&this.writeUnexecutedJobsAsSkipped(),this.config.assert?.execution&&assert.equal(this.executedJobs,this.config.assert.execution)}writeAllJobsAsPending(){let e=_();for(let t of this.config.jobs){let s=j(t.plan),r=`/pipeline/${e}/jobs/${t.name}`;storage.set(r,{status:"pending",dependsOn:s})}}findRequestedJobs(){let e=typeof pipelineContext<"u"?pipelineContext.jobs??[]:[];return this.config.jobs.filter(t=>e.includes(t.name))}writeUnexecutedJobsAsSkipped(){let e=_();for(let t of this.config.jobs){if(this.executedJobs.includes(t.name))continue;let s=j(t.plan),r=`/pipeline/${e}/jobs/${t.name}`;storage.set(r,{status:"skipped",dependsOn:s})}}findJobsWithNoDependencies(){return this.config.jobs.filter(e=>!e.plan.some(t=>!!("get"in t&&t.passed)))}async runJob(e){this.executedJobs.push(e.name);try{await new Y(e,this.config.resources,this.config.resource_types,this.config.max_in_flight).run(),this.jobResults.set(e.name,!0),await this.runDependentJobs(e.name)}catch(t){throw this.jobResults.set(e.name,!1),t}}async runDependentJobs(e){let t=this.findDependentJobs(e);for(let s of t)this.canJobRun(s)&&await this.runJob(s)}findDependentJobs(e){return this.config.jobs.filter(t=>t.plan.some(s=>!!("get"in s&&s.passed&&s.passed.includes(e))))}canJobRun(e){for(let t of e.plan)if("get"in t&&t.passed&&t.passed.length>0&&!t.passed.every(r=>this.jobResults.get(r)===!0))return!1;return!0}};function ce(i){let e=new Z(i);return()=>e.run()}globalThis.createPipeline=ce;export{ce as createPipeline};
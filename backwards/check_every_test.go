@@ -0,0 +1,105 @@
+package backwards_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/backwards"
+	. "github.com/onsi/gomega"
+)
+
+const checkEveryYAML = `
+resource_types:
+  - name: git
+    type: registry-image
+    source:
+      repository: concourse/git-resource
+
+resources:
+  - name: repo
+    type: git
+    check_every: 5m
+    source:
+      uri: https://example.com/repo.git
+  - name: docs
+    type: git
+    check_every: never
+    source:
+      uri: https://example.com/docs.git
+
+jobs:
+  - name: build
+    plan:
+      - in_parallel:
+          steps:
+            - get: repo
+              trigger: true
+            - get: docs
+  - name: deploy
+    plan:
+      - get: source
+        resource: repo
+        trigger: true
+        passed: [build]
+  - name: manual
+    plan:
+      - get: repo
+`
+
+func TestCheckEvery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parses check intervals", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfig([]byte(checkEveryYAML))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		interval, err := config.Resources[0].CheckInterval(time.Minute)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(interval).To(Equal(5 * time.Minute))
+
+		interval, err = config.Resources[1].CheckInterval(time.Minute)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(interval).To(BeZero())
+
+		unset := backwards.Resource{Name: "unset", Type: "git"}
+		interval, err = unset.CheckInterval(time.Minute)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(interval).To(Equal(time.Minute))
+	})
+
+	t.Run("finds jobs that trigger on a resource", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfig([]byte(checkEveryYAML))
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(config.TriggeringJobs("repo")).To(Equal([]string{"build", "deploy"}))
+		assert.Expect(config.TriggeringJobs("docs")).To(BeEmpty())
+	})
+
+	t.Run("rejects an invalid check_every", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		err := backwards.ValidatePipeline([]byte(`
+resource_types:
+  - name: git
+    type: registry-image
+    source:
+      repository: concourse/git-resource
+resources:
+  - name: repo
+    type: git
+    check_every: sometimes
+jobs:
+  - name: build
+    plan:
+      - get: repo
+`))
+		assert.Expect(err).To(MatchError(ContainSubstring(`resource "repo" has invalid check_every`)))
+	})
+}
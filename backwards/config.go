@@ -1,6 +1,9 @@
 package backwards
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // https://github.com/concourse/concourse/blob/master/atc/config.go
 type ImageResource struct {
@@ -165,6 +168,58 @@ type AgentContext struct {
 	MaxBytes int                `yaml:"max_bytes,omitempty" json:"max_bytes,omitempty"`
 }
 
+// AgentMCPCommand starts a stdio MCP server inside the agent's sandbox.
+type AgentMCPCommand struct {
+	Path    string            `validate:"required" yaml:"path"               json:"path"`
+	Args    []string          `yaml:"args,omitempty"     json:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"      json:"env,omitempty"`
+	WorkDir string            `yaml:"work_dir,omitempty" json:"work_dir,omitempty"`
+}
+
+// AgentMCPServer exposes the tools of an MCP server to an agent step, either
+// a stdio server started in the sandbox (command) or a streamable HTTP
+// server (url). Allow and Deny filter its tools by name or glob.
+type AgentMCPServer struct {
+	Name    string            `validate:"required"                               yaml:"name"              json:"name"`
+	Command *AgentMCPCommand  `validate:"required_without=URL,excluded_with=URL" yaml:"command,omitempty" json:"command,omitempty"`
+	URL     string            `yaml:"url,omitempty"     json:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Allow   []string          `yaml:"allow,omitempty"   json:"allow,omitempty"`
+	Deny    []string          `yaml:"deny,omitempty"    json:"deny,omitempty"`
+}
+
+type AgentPolicyRule struct {
+	Tool      string `yaml:"tool,omitempty"      json:"tool,omitempty"`
+	When      string `yaml:"when,omitempty"      json:"when,omitempty"`
+	Action    string `validate:"required,oneof=allow deny approve" yaml:"action" json:"action"`
+	Approvers string `yaml:"approvers,omitempty" json:"approvers,omitempty"`
+	Reason    string `yaml:"reason,omitempty"    json:"reason,omitempty"`
+}
+
+// AgentSubAgent is an agent the step's agent delegates tasks to as a tool.
+// It shares the step's image and inputs.
+type AgentSubAgent struct {
+	Name         string               `validate:"required" yaml:"name"                    json:"name"`
+	Description  string               `yaml:"description,omitempty"   json:"description,omitempty"`
+	Prompt       string               `validate:"required" yaml:"prompt"                  json:"prompt"`
+	Model        string               `yaml:"model,omitempty"         json:"model,omitempty"`
+	LLM          *AgentLLMConfig      `yaml:"llm,omitempty"           json:"llm,omitempty"`
+	Thinking     *AgentThinkingConfig `yaml:"thinking,omitempty"      json:"thinking,omitempty"`
+	Limits       *AgentLimitsConfig   `yaml:"limits,omitempty"        json:"limits,omitempty"`
+	Context      *AgentContext        `yaml:"context,omitempty"       json:"context,omitempty"`
+	MCPServers   []AgentMCPServer     `validate:"dive" yaml:"mcp_servers,omitempty"   json:"mcp_servers,omitempty"`
+	OutputSchema map[string]any       `yaml:"output_schema,omitempty" json:"output_schema,omitempty"`
+	SubAgents    []AgentSubAgent      `validate:"dive" yaml:"sub_agents,omitempty"    json:"sub_agents,omitempty"`
+}
+
+// AgentMemoryConfig gives the step's agent notes that persist across the
+// pipeline's runs, through its remember and recall tools.
+type AgentMemoryConfig struct {
+	TTL        string `yaml:"ttl,omitempty"         json:"ttl,omitempty"`         // Go duration a note is kept (default: 720h)
+	MaxEntries int    `yaml:"max_entries,omitempty" json:"max_entries,omitempty"` // oldest notes are forgotten first (default: 100)
+	MaxBytes   int    `yaml:"max_bytes,omitempty"   json:"max_bytes,omitempty"`   // size limit of a note (default: 2048)
+}
+
 type Step struct {
 	Assert *struct {
 		Code   *int   `yaml:"code,omitempty"`
@@ -190,6 +245,11 @@ type Step struct {
 	AgentContextGuard *AgentContextGuardConfig `yaml:"context_guard,omitempty"`
 	AgentLimits       *AgentLimitsConfig       `yaml:"limits,omitempty"`
 	AgentContext      *AgentContext            `yaml:"context,omitempty"`
+	AgentMCPServers   []AgentMCPServer         `validate:"dive" yaml:"mcp_servers,omitempty"`
+	AgentOutputSchema map[string]any           `yaml:"output_schema,omitempty"`
+	AgentPolicy       []AgentPolicyRule        `validate:"dive" yaml:"policy,omitempty"`
+	AgentSubAgents    []AgentSubAgent          `validate:"dive" yaml:"sub_agents,omitempty"`
+	AgentMemory       *AgentMemoryConfig       `yaml:"memory,omitempty"`
 
 	Get       string    `yaml:"get,omitempty"`
 	GetConfig GetConfig `yaml:",inline,omitempty"`
@@ -197,6 +257,11 @@ type Step struct {
 	Put       string     `yaml:"put,omitempty"`
 	PutConfig *PutConfig `yaml:",inline,omitempty"`
 
+	// LoadVar reads File from an artifact into the local var ((.:name)).
+	// Format is json, yaml, trim or raw, and defaults from the extension.
+	LoadVar string `yaml:"load_var,omitempty"`
+	Format  string `yaml:"format,omitempty"`
+
 	Do        Steps `yaml:"do,omitempty"`
 	Ensure    *Step `yaml:"ensure,omitempty"`
 	OnAbort   *Step `yaml:"on_abort,omitempty"`
@@ -218,6 +283,26 @@ type Step struct {
 	Timeout  time.Duration `yaml:"timeout,omitempty"`
 }
 
+// GetResource returns the resource fetched by a get step. The step name is
+// the resource name unless `resource` is set.
+func (s *Step) GetResource() string {
+	if s.GetConfig.Resource != "" {
+		return s.GetConfig.Resource
+	}
+
+	return s.Get
+}
+
+// PutResource returns the resource updated by a put step. The step name is
+// the resource name unless `resource` is set.
+func (s *Step) PutResource() string {
+	if s.PutConfig != nil && s.PutConfig.Resource != "" {
+		return s.PutConfig.Resource
+	}
+
+	return s.Put
+}
+
 type Steps []Step
 
 // WebhookTriggerConfig holds the filter expression and optional parameter
@@ -227,9 +312,18 @@ type WebhookTriggerConfig struct {
 	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty"`
 }
 
+// ScheduleTriggerConfig holds a cron schedule for time-based job triggers.
+// CatchUp decides what happens to schedules missed while the server was down.
+type ScheduleTriggerConfig struct {
+	Cron     string `validate:"required"        yaml:"cron,omitempty"     json:"cron,omitempty"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	CatchUp  string `yaml:"catch_up,omitempty" json:"catch_up,omitempty"`
+}
+
 // Triggers holds the set of trigger configurations for a job.
 type Triggers struct {
-	Webhook *WebhookTriggerConfig `yaml:"webhook,omitempty" json:"webhook,omitempty"`
+	Webhook  *WebhookTriggerConfig  `yaml:"webhook,omitempty"  json:"webhook,omitempty"`
+	Schedule *ScheduleTriggerConfig `yaml:"schedule,omitempty" json:"schedule,omitempty"`
 }
 
 type Job struct {
@@ -267,10 +361,33 @@ type ResourceType struct {
 type ResourceTypes []ResourceType
 
 type Resource struct {
-	Name   string         `validate:"required"     yaml:"name,omitempty"`
-	Icon   string         `yaml:"icon,omitempty"`
-	Source map[string]any `yaml:"source,omitempty"`
-	Type   string         `validate:"required"     yaml:"type,omitempty"`
+	Name       string         `validate:"required"     yaml:"name,omitempty"`
+	Icon       string         `yaml:"icon,omitempty"`
+	Source     map[string]any `yaml:"source,omitempty"`
+	Type       string         `validate:"required"     yaml:"type,omitempty"`
+	CheckEvery string         `yaml:"check_every,omitempty"`
+}
+
+// CheckInterval returns how often the resource should be checked.
+// An unset check_every returns defaultInterval, and "never" returns zero.
+func (r *Resource) CheckInterval(defaultInterval time.Duration) (time.Duration, error) {
+	switch r.CheckEvery {
+	case "":
+		return defaultInterval, nil
+	case "never":
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(r.CheckEvery)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse duration: %w", err)
+	}
+
+	if interval <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", r.CheckEvery)
+	}
+
+	return interval, nil
 }
 
 type Resources []Resource
@@ -284,3 +401,49 @@ type Config struct {
 	Resources     Resources     `yaml:"resources"`
 	ResourceTypes ResourceTypes `yaml:"resource_types"`
 }
+
+// TriggeringJobs returns the names of jobs that get the named resource with
+// trigger: true, in pipeline order.
+func (c *Config) TriggeringJobs(resourceName string) []string {
+	var jobs []string
+
+	for _, job := range c.Jobs {
+		steps := append(Steps{}, job.Plan...)
+
+		for _, hook := range []*Step{job.Ensure, job.OnAbort, job.OnError, job.OnSuccess, job.OnFailure} {
+			if hook != nil {
+				steps = append(steps, *hook)
+			}
+		}
+
+		if stepsTrigger(steps, resourceName) {
+			jobs = append(jobs, job.Name)
+		}
+	}
+
+	return jobs
+}
+
+func stepsTrigger(steps Steps, resourceName string) bool {
+	for _, step := range steps {
+		if step.Get != "" && step.GetConfig.Trigger && step.GetResource() == resourceName {
+			return true
+		}
+
+		nested := append(Steps{}, step.Do...)
+		nested = append(nested, step.Try...)
+		nested = append(nested, step.InParallel.Steps...)
+
+		for _, hook := range []*Step{step.Ensure, step.OnAbort, step.OnError, step.OnSuccess, step.OnFailure} {
+			if hook != nil {
+				nested = append(nested, *hook)
+			}
+		}
+
+		if stepsTrigger(nested, resourceName) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,325 @@
+package backwards
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// Issue is a problem found in a pipeline. Line and Column are 1-based
+// positions in the (template rendered) YAML, or zero when unknown.
+type Issue struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// Lint validates YAML pipeline content like ParseConfig and additionally
+// checks references between jobs, resources and task inputs:
+//   - get and put steps must name a defined resource
+//   - passed constraints must name existing jobs that get or put the resource
+//   - task and agent inputs must be produced by a get step or an output in the job
+//   - load_var files must be in an artifact produced the same way
+//
+// It returns every issue found instead of stopping at the first one.
+func Lint(content []byte) []Issue {
+	processed, err := preprocessYAML(content)
+	if err != nil {
+		return []Issue{{Message: err.Error()}}
+	}
+
+	file, err := parser.ParseBytes(processed, 0)
+	if err != nil {
+		return []Issue{yamlIssue(err)}
+	}
+
+	l := &linter{file: file}
+
+	config, err := ParseConfig(content)
+	if err != nil {
+		return l.configIssues(err)
+	}
+
+	l.checkReferences(config)
+
+	return l.issues
+}
+
+type linter struct {
+	file   *ast.File
+	issues []Issue
+}
+
+// addf records an issue positioned at the YAML path, or at its closest
+// existing parent when the path is missing from the document.
+func (l *linter) addf(path string, format string, args ...any) {
+	line, column := l.position(path)
+
+	l.issues = append(l.issues, Issue{
+		Line:    line,
+		Column:  column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) position(path string) (int, int) {
+	for path != "$" {
+		query, err := yaml.PathString(path)
+		if err == nil {
+			node, err := query.FilterFile(l.file)
+			if err == nil && node != nil && node.GetToken() != nil {
+				position := node.GetToken().Position
+
+				return position.Line, position.Column
+			}
+		}
+
+		index := strings.LastIndexAny(path, ".[")
+		if index <= 0 {
+			break
+		}
+
+		path = path[:index]
+	}
+
+	return 0, 0
+}
+
+// configIssues converts a ParseConfig error into issues, positioning YAML
+// decoding and struct validation errors where possible.
+func (l *linter) configIssues(err error) []Issue {
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		return []Issue{yamlIssue(yamlErr)}
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		for _, fieldErr := range validationErrs {
+			path := namespacePath(fieldErr.Namespace())
+			l.addf(path, "%s failed the %q validation", strings.TrimPrefix(path, "$."), fieldErr.Tag())
+		}
+
+		return l.issues
+	}
+
+	return []Issue{{Message: err.Error()}}
+}
+
+func yamlIssue(err error) Issue {
+	var yamlErr yaml.Error
+	if !errors.As(err, &yamlErr) || yamlErr.GetToken() == nil {
+		return Issue{Message: err.Error()}
+	}
+
+	position := yamlErr.GetToken().Position
+
+	return Issue{
+		Line:    position.Line,
+		Column:  position.Column,
+		Message: yamlErr.GetMessage(),
+	}
+}
+
+// namespacePath converts a validator namespace such as "Config.Jobs[0].Plan"
+// into the YAML path "$.jobs[0].plan" using the yaml struct tags.
+func namespacePath(namespace string) string {
+	path := "$"
+	typ := reflect.TypeFor[Config]()
+
+	segments := strings.Split(namespace, ".")
+	for _, segment := range segments[1:] {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+
+		if typ.Kind() != reflect.Struct {
+			break
+		}
+
+		name, index, hasIndex := strings.Cut(segment, "[")
+
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			break
+		}
+
+		tag, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !strings.Contains(options, "inline") {
+			if tag == "" {
+				tag = strings.ToLower(name)
+			}
+
+			path += "." + tag
+		}
+
+		typ = field.Type
+		if hasIndex && typ.Kind() == reflect.Slice {
+			path += "[" + index
+			typ = typ.Elem()
+		}
+	}
+
+	return path
+}
+
+func (l *linter) checkReferences(config *Config) {
+	resources := map[string]bool{}
+	for _, resource := range config.Resources {
+		resources[resource.Name] = true
+	}
+
+	// resources each job gets or puts, for passed constraints
+	jobResources := map[string]map[string]bool{}
+
+	for _, job := range config.Jobs {
+		used := map[string]bool{}
+
+		eachStep(job, "", func(step *Step, _ string) {
+			if step.Get != "" {
+				used[step.GetResource()] = true
+			}
+
+			if step.Put != "" {
+				used[step.PutResource()] = true
+			}
+		})
+
+		jobResources[job.Name] = used
+	}
+
+	for jobIndex, job := range config.Jobs {
+		// artifacts that get steps and task or agent outputs make available
+		produced := map[string]bool{}
+
+		eachStep(job, "", func(step *Step, _ string) {
+			if step.Get != "" {
+				produced[step.Get] = true
+			}
+
+			if step.TaskConfig != nil {
+				for _, output := range step.TaskConfig.Outputs {
+					produced[output.Name] = true
+				}
+			}
+		})
+
+		jobPath := fmt.Sprintf("$.jobs[%d]", jobIndex)
+
+		eachStep(job, jobPath, func(step *Step, path string) {
+			l.checkStep(job, step, path, resources, jobResources, produced)
+		})
+	}
+}
+
+func (l *linter) checkStep(
+	job Job,
+	step *Step,
+	path string,
+	resources map[string]bool,
+	jobResources map[string]map[string]bool,
+	produced map[string]bool,
+) {
+	if step.Get != "" && !resources[step.GetResource()] {
+		l.addf(path+".get", "job %q gets undefined resource %q", job.Name, step.GetResource())
+	}
+
+	if step.Put != "" && !resources[step.PutResource()] {
+		l.addf(path+".put", "job %q puts undefined resource %q", job.Name, step.PutResource())
+	}
+
+	if step.Get != "" {
+		for index, passed := range step.GetConfig.Passed {
+			passedPath := fmt.Sprintf("%s.passed[%d]", path, index)
+
+			used, ok := jobResources[passed]
+
+			switch {
+			case !ok:
+				l.addf(passedPath, "job %q has passed constraint on undefined job %q", job.Name, passed)
+			case passed == job.Name:
+				l.addf(passedPath, "job %q has passed constraint on itself", job.Name)
+			case !used[step.GetResource()]:
+				l.addf(passedPath, "job %q has passed constraint on job %q, which does not get or put resource %q", job.Name, passed, step.GetResource())
+			}
+		}
+	}
+
+	if step.TaskConfig != nil && (step.Task != "" || step.Agent != "") {
+		for index, input := range step.TaskConfig.Inputs {
+			if !produced[input.Name] {
+				l.addf(
+					fmt.Sprintf("%s.config.inputs[%d].name", path, index),
+					"job %q step %q has input %q that no get step or output produces",
+					job.Name, step.Task+step.Agent, input.Name,
+				)
+			}
+		}
+	}
+
+	if step.LoadVar != "" {
+		artifact, _, _ := strings.Cut(step.File, "/")
+		if !produced[artifact] {
+			l.addf(
+				path+".file",
+				"job %q load_var %q reads %q from artifact %q that no get step or output produces",
+				job.Name, step.LoadVar, step.File, artifact,
+			)
+		}
+	}
+}
+
+// eachStep calls fn for every step of a job, including hooks and nested
+// steps, with the step's YAML path under jobPath.
+func eachStep(job Job, jobPath string, fn func(step *Step, path string)) {
+	for index := range job.Plan {
+		walkStep(&job.Plan[index], fmt.Sprintf("%s.plan[%d]", jobPath, index), fn)
+	}
+
+	walkHooks(jobPath, job.Ensure, job.OnAbort, job.OnError, job.OnSuccess, job.OnFailure, fn)
+}
+
+func walkStep(step *Step, path string, fn func(step *Step, path string)) {
+	fn(step, path)
+
+	for index := range step.Do {
+		walkStep(&step.Do[index], fmt.Sprintf("%s.do[%d]", path, index), fn)
+	}
+
+	for index := range step.Try {
+		walkStep(&step.Try[index], fmt.Sprintf("%s.try[%d]", path, index), fn)
+	}
+
+	for index := range step.InParallel.Steps {
+		walkStep(&step.InParallel.Steps[index], fmt.Sprintf("%s.in_parallel.steps[%d]", path, index), fn)
+	}
+
+	walkHooks(path, step.Ensure, step.OnAbort, step.OnError, step.OnSuccess, step.OnFailure, fn)
+}
+
+func walkHooks(path string, ensure, onAbort, onError, onSuccess, onFailure *Step, fn func(step *Step, path string)) {
+	hooks := []struct {
+		name string
+		step *Step
+	}{
+		{"ensure", ensure},
+		{"on_abort", onAbort},
+		{"on_error", onError},
+		{"on_success", onSuccess},
+		{"on_failure", onFailure},
+	}
+
+	for _, hook := range hooks {
+		if hook.step != nil {
+			walkStep(hook.step, path+"."+hook.name, fn)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package backwards_test
+
+import (
+	"testing"
+
+	"github.com/jtarchie/pocketci/backwards"
+	. "github.com/onsi/gomega"
+)
+
+const lintYAML = `resources:
+  - name: repo
+    type: registry-image
+    source:
+      repository: busybox
+
+jobs:
+  - name: build
+    plan:
+      - get: repo
+      - task: compile
+        config:
+          platform: linux
+          inputs:
+            - name: repo
+          outputs:
+            - name: binary
+          run:
+            path: make
+  - name: deploy
+    plan:
+      - get: repo
+        passed: [build, missing]
+      - get: artifacts
+      - task: ship
+        config:
+          platform: linux
+          inputs:
+            - name: binary
+          run:
+            path: ship
+      - put: release
+  - name: notify
+    plan:
+      - get: repo
+        passed: [cleanup]
+  - name: cleanup
+    plan:
+      - task: clean
+        config:
+          platform: linux
+          run:
+            path: rm
+`
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports cross-reference issues with positions", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		issues := backwards.Lint([]byte(lintYAML))
+		assert.Expect(issues).To(ConsistOf(
+			backwards.Issue{Line: 23, Column: 25, Path: "$.jobs[1].plan[0].passed[1]", Message: `job "deploy" has passed constraint on undefined job "missing"`},
+			backwards.Issue{Line: 24, Column: 14, Path: "$.jobs[1].plan[1].get", Message: `job "deploy" gets undefined resource "artifacts"`},
+			backwards.Issue{Line: 29, Column: 21, Path: "$.jobs[1].plan[2].config.inputs[0].name", Message: `job "deploy" step "ship" has input "binary" that no get step or output produces`},
+			backwards.Issue{Line: 32, Column: 14, Path: "$.jobs[1].plan[3].put", Message: `job "deploy" puts undefined resource "release"`},
+			backwards.Issue{Line: 36, Column: 18, Path: "$.jobs[2].plan[0].passed[0]", Message: `job "notify" has passed constraint on job "cleanup", which does not get or put resource "repo"`},
+		))
+	})
+
+	t.Run("honours resource aliases", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		issues := backwards.Lint([]byte(checkEveryYAML))
+		assert.Expect(issues).To(BeEmpty())
+	})
+
+	t.Run("positions validation errors", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		issues := backwards.Lint([]byte(`jobs:
+  - name: ab
+    plan:
+      - task: echo
+        config:
+          platform: linux
+          run:
+            path: echo
+`))
+		assert.Expect(issues).To(HaveLen(1))
+		assert.Expect(issues[0].Path).To(Equal("$.jobs[0].name"))
+		assert.Expect(issues[0].Line).To(Equal(2))
+		assert.Expect(issues[0].Message).To(ContainSubstring(`"min"`))
+	})
+
+	t.Run("positions YAML syntax errors", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		issues := backwards.Lint([]byte("jobs:\n  - name: build\n    plan: [\n"))
+		assert.Expect(issues).To(HaveLen(1))
+		assert.Expect(issues[0].Line).To(BeNumerically(">", 0))
+	})
+}
@@ -5,12 +5,14 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"text/template"
 
 	"github.com/go-playground/validator/v10"
 	sprig "github.com/go-task/slim-sprig/v3"
 	"github.com/goccy/go-yaml"
+	"github.com/jtarchie/pocketci/schedule"
 )
 
 //go:generate go run github.com/evanw/esbuild/... --minify --tree-shaking=true --platform=neutral --bundle --outfile=bundle.js src/index.ts
@@ -58,35 +60,14 @@ func NewPipeline(filename string) (string, error) {
 // pipeline definition that can be executed by the JS runtime. Unlike NewPipeline
 // it accepts content directly instead of reading from a file.
 func NewPipelineFromContent(content string) (string, error) {
-	var config Config
-
-	// Preprocess YAML templates if opted in
-	processed, err := preprocessYAML([]byte(content))
-	if err != nil {
-		return "", err
-	}
-
-	err = yaml.Unmarshal(processed, &config)
-	if err != nil {
-		return "", fmt.Errorf("could not unmarshal pipeline: %w", err)
-	}
-
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	return NewPipelineFromContentWithVars(content, nil)
+}
 
-	err = validate.Struct(config)
+// NewPipelineFromContentWithVars is like NewPipelineFromContent, but first
+// interpolates the pipeline's ((vars)) as ParseConfigWithVars does.
+func NewPipelineFromContentWithVars(content string, vars Variables) (string, error) {
+	config, err := ParseConfigWithVars([]byte(content), vars)
 	if err != nil {
-		return "", fmt.Errorf("could not validate pipeline: %w", err)
-	}
-
-	if err := validateResourceTypes(&config); err != nil {
-		return "", err
-	}
-
-	if err := validateSteps(config.Jobs); err != nil {
-		return "", err
-	}
-
-	if err := validateConcurrency(&config); err != nil {
 		return "", err
 	}
 
@@ -106,38 +87,78 @@ func NewPipelineFromContent(content string) (string, error) {
 // pipeline definition without producing any output. It is suitable for early
 // error checking at set-pipeline time without performing transpilation.
 func ValidatePipeline(content []byte) error {
+	return ValidatePipelineWithVars(content, nil)
+}
+
+// ValidatePipelineWithVars is like ValidatePipeline, but also checks that
+// vars defines every ((var)) the pipeline references.
+func ValidatePipelineWithVars(content []byte, vars Variables) error {
+	_, err := ParseConfigWithVars(content, vars)
+
+	return err
+}
+
+// ParseConfig preprocesses, unmarshals, and validates YAML pipeline content.
+// It is used by the server to inspect stored pipelines (e.g. resources and
+// triggers) without transpiling them. ((var)) references are left as they are.
+func ParseConfig(content []byte) (*Config, error) {
+	return ParseConfigWithVars(content, nil)
+}
+
+// ParseConfigWithVars is like ParseConfig, but interpolates ((var))
+// references with vars after rendering templates. References that vars does
+// not define fail with an *UndefinedVarsError naming them. Local vars, such
+// as ((.:name)) set by a load_var step, are resolved when the job runs.
+func ParseConfigWithVars(content []byte, vars Variables) (*Config, error) {
 	var config Config
 
 	// Preprocess YAML templates if opted in
 	processed, err := preprocessYAML(content)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	processed, err = interpolateVars(processed, vars)
+	if err != nil {
+		return nil, err
 	}
 
 	err = yaml.Unmarshal(processed, &config)
 	if err != nil {
-		return fmt.Errorf("could not unmarshal pipeline: %w", err)
+		return nil, fmt.Errorf("could not unmarshal pipeline: %w", err)
 	}
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 
 	if err := validate.Struct(config); err != nil {
-		return fmt.Errorf("could not validate pipeline: %w", err)
+		return nil, fmt.Errorf("could not validate pipeline: %w", err)
 	}
 
 	if err := validateResourceTypes(&config); err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := validateResources(&config); err != nil {
+		return nil, err
 	}
 
 	if err := validateSteps(config.Jobs); err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := validateLoadVars(config.Jobs); err != nil {
+		return nil, err
+	}
+
+	if err := validateSchedules(config.Jobs); err != nil {
+		return nil, err
 	}
 
 	if err := validateConcurrency(&config); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &config, nil
 }
 
 // validateSteps checks that task steps have a required run.path field (unless using file:).
@@ -155,6 +176,69 @@ func validateSteps(jobs Jobs) error {
 	return nil
 }
 
+// validateLoadVars checks load_var steps and that every local var, like
+// ((.:name)), is set by a load_var step or across var in the same job.
+func validateLoadVars(jobs Jobs) error {
+	for _, job := range jobs {
+		defined := map[string]bool{}
+
+		var err error
+
+		eachStep(job, "", func(step *Step, _ string) {
+			for _, acrossVar := range step.Across {
+				defined[acrossVar.Var] = true
+			}
+
+			if step.LoadVar == "" || err != nil {
+				return
+			}
+
+			defined[step.LoadVar] = true
+
+			switch {
+			case step.File == "":
+				err = fmt.Errorf("load_var step %q in job %q requires file", step.LoadVar, job.Name)
+			case !slices.Contains([]string{"", "json", "yaml", "yml", "trim", "raw"}, step.Format):
+				err = fmt.Errorf("load_var step %q in job %q has invalid format %q, expected json, yaml, trim or raw", step.LoadVar, job.Name, step.Format)
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+
+		contents, err := yaml.Marshal(job)
+		if err != nil {
+			return fmt.Errorf("could not marshal job %q: %w", job.Name, err)
+		}
+
+		for _, name := range localVarRefs(contents) {
+			if !defined[name] {
+				return fmt.Errorf("job %q references undefined local var ((.:%s)), it must be set by a load_var step", job.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSchedules checks that job schedule triggers have a valid cron, timezone and catch_up.
+func validateSchedules(jobs Jobs) error {
+	for _, job := range jobs {
+		if job.Triggers == nil || job.Triggers.Schedule == nil {
+			continue
+		}
+
+		trigger := job.Triggers.Schedule
+
+		if _, err := schedule.Parse(trigger.Cron, trigger.Timezone, trigger.CatchUp); err != nil {
+			return fmt.Errorf("job %q has invalid schedule: %w", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
 func validateConcurrency(config *Config) error {
 	if config.MaxInFlight < 0 {
 		return fmt.Errorf("pipeline max_in_flight must be greater than 0 when set")
@@ -235,3 +319,14 @@ func validateResourceTypes(config *Config) error {
 
 	return nil
 }
+
+// validateResources checks resource level settings such as check_every.
+func validateResources(config *Config) error {
+	for _, resource := range config.Resources {
+		if _, err := resource.CheckInterval(0); err != nil {
+			return fmt.Errorf("resource %q has invalid check_every: %w", resource.Name, err)
+		}
+	}
+
+	return nil
+}
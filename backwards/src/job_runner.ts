@@ -21,6 +21,7 @@ import { AcrossStepHandler } from "./step_handlers/across_step.ts";
 import { AgentStepHandler } from "./step_handlers/agent_step.ts";
 import { DoStepHandler } from "./step_handlers/do_step.ts";
 import { GetStepHandler } from "./step_handlers/get_step.ts";
+import { LoadVarStepHandler } from "./step_handlers/load_var_step.ts";
 import { NotifyStepHandler } from "./step_handlers/notify_step.ts";
 import { PutStepHandler } from "./step_handlers/put_step.ts";
 import { TaskStepHandler } from "./step_handlers/task_step.ts";
@@ -48,6 +49,7 @@ export class JobRunner {
     ["in_parallel", this.doHandler],
     ["notify", new NotifyStepHandler()],
     ["agent", new AgentStepHandler()],
+    ["load_var", new LoadVarStepHandler()],
   ];
 
   constructor(
@@ -189,6 +191,7 @@ export class JobRunner {
     attempt?: number,
   ): Promise<void> {
     step = this.variableResolver.injectJobParams(step);
+    step = this.variableResolver.interpolateLocalVars(step);
 
     if (step.across && step.across.length > 0) {
       await this.acrossHandler.process(this.ctx, step, pathContext);
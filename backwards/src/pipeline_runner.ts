@@ -178,14 +178,21 @@ export class PipelineRunner {
     // Pre-write all jobs as pending for graph visualization
     this.writeAllJobsAsPending();
 
-    // Find jobs with no dependencies
-    const jobsWithNoDeps = this.findJobsWithNoDependencies();
+    // Find jobs to start from: the requested jobs, or those with no dependencies
+    const requestedJobs = this.findRequestedJobs();
+    const startingJobs = requestedJobs.length > 0
+      ? requestedJobs
+      : this.findJobsWithNoDependencies();
 
     // Run jobs in dependency order
-    for (const job of jobsWithNoDeps) {
+    for (const job of startingJobs) {
       await this.runJob(job);
     }
 
+    if (requestedJobs.length > 0) {
+      this.writeUnexecutedJobsAsSkipped();
+    }
+
     if (this.config.assert?.execution) {
       // this assures that the outputs are in the same order as the job
       assert.equal(this.executedJobs, this.config.assert.execution);
@@ -201,6 +208,27 @@ export class PipelineRunner {
     }
   }
 
+  private findRequestedJobs(): Job[] {
+    const names = typeof pipelineContext !== "undefined"
+      ? pipelineContext.jobs ?? []
+      : [];
+
+    return this.config.jobs.filter((job) => names.includes(job.name));
+  }
+
+  private writeUnexecutedJobsAsSkipped(): void {
+    const buildID = getBuildID();
+    for (const job of this.config.jobs) {
+      if (this.executedJobs.includes(job.name)) {
+        continue;
+      }
+
+      const dependsOn = extractJobDependencies(job.plan);
+      const storageKey = `/pipeline/${buildID}/jobs/${job.name}`;
+      storage.set(storageKey, { status: "skipped", dependsOn });
+    }
+  }
+
   private findJobsWithNoDependencies(): Job[] {
     return this.config.jobs.filter((job) => {
       return !job.plan.some((step) => {
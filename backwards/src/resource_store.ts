@@ -87,6 +87,22 @@ export function getLatestResourceVersion(name: string): StoredVersion | null {
   return safeGet(versionKey(name, count - 1)) as StoredVersion | null;
 }
 
+// Returns the newest version that was fetched by a job.  Versions recorded by
+// the server's resource checker have an empty job_name until a job fetches them.
+export function getLatestFetchedResourceVersion(
+  name: string,
+): StoredVersion | null {
+  const metaData = safeGet(metaKey(name)) as { count: number } | null;
+  const count = metaData?.count ?? 0;
+  for (let i = count - 1; i >= 0; i--) {
+    const v = safeGet(versionKey(name, i)) as StoredVersion | null;
+    if (v && v.job_name) {
+      return v;
+    }
+  }
+  return null;
+}
+
 export function listResourceVersions(
   name: string,
   limit: number,
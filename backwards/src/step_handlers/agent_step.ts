@@ -71,8 +71,10 @@ export class AgentStepHandler implements StepHandler {
       doPersist();
     };
 
+    let result: AgentResult;
+
     try {
-      const result = await runtime.agent({
+      result = await runtime.agent({
         name: step.agent,
         prompt: step.prompt,
         model: step.model,
@@ -85,6 +87,11 @@ export class AgentStepHandler implements StepHandler {
         context_guard: step.context_guard,
         limits: step.limits,
         context: step.context,
+        mcp_servers: step.mcp_servers,
+        output_schema: step.output_schema,
+        policy: step.policy,
+        sub_agents: step.sub_agents,
+        memory: step.memory,
         onUsage: (usage: AgentUsage) => {
           latestUsage = usage;
           persistRunningState();
@@ -106,12 +113,14 @@ export class AgentStepHandler implements StepHandler {
       if (persistPending) doPersist();
 
       storage.set(storageKey, {
-        status: result.status === "limit_exceeded"
-          ? "limit_exceeded"
+        status: result.status === "limit_exceeded" ||
+            result.status === "invalid_output"
+          ? result.status
           : "success",
         started_at: startedAt,
         elapsed: formatElapsed(startedAt),
         stdout: result.text,
+        output: result.output,
         usage: latestUsage ?? result.usage,
         audit_log: result.auditLog,
       });
@@ -131,5 +140,12 @@ export class AgentStepHandler implements StepHandler {
       });
       throw new TaskFailure(`Agent ${step.agent} failed: ${error}`);
     }
+
+    // A pipeline gating on the agent's verdict cannot continue without one.
+    if (result.status === "invalid_output") {
+      throw new TaskFailure(
+        `Agent ${step.agent} did not return output matching output_schema`,
+      );
+    }
   }
 }
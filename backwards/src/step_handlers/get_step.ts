@@ -1,7 +1,7 @@
 /// <reference path="../../../packages/pocketci/src/global.d.ts" />
 
 import {
-  getLatestResourceVersion,
+  getLatestFetchedResourceVersion,
   listResourceVersions,
   saveResourceVersion,
 } from "../resource_store.ts";
@@ -132,7 +132,7 @@ export class GetStepHandler implements StepHandler {
 
     let lastKnownVersion: ResourceVersion | undefined;
     if (versionMode === "every") {
-      const stored = getLatestResourceVersion(scopedResourceName);
+      const stored = getLatestFetchedResourceVersion(scopedResourceName);
       lastKnownVersion = stored?.version;
     }
 
@@ -174,8 +174,12 @@ export class GetStepHandler implements StepHandler {
 
     if (versionMode === "every") {
       const storedVersions = listResourceVersions(scopedResourceName, 0);
+      // Versions recorded by the resource checker but not yet fetched by a
+      // job have an empty job_name and still need to be processed.
       const processedSet = new Set(
-        storedVersions.map((sv) => JSON.stringify(sv.version)),
+        storedVersions
+          .filter((sv) => sv.job_name)
+          .map((sv) => JSON.stringify(sv.version)),
       );
       const newVersions = versions.filter(
         (v) => !processedSet.has(JSON.stringify(v)),
@@ -5,6 +5,7 @@ export { AcrossStepHandler } from "./across_step.ts";
 export { AgentStepHandler } from "./agent_step.ts";
 export { DoStepHandler } from "./do_step.ts";
 export { GetStepHandler } from "./get_step.ts";
+export { LoadVarStepHandler } from "./load_var_step.ts";
 export { NotifyStepHandler } from "./notify_step.ts";
 export { PutStepHandler } from "./put_step.ts";
 export { TaskStepHandler } from "./task_step.ts";
@@ -0,0 +1,80 @@
+/// <reference path="../../../packages/pocketci/src/global.d.ts" />
+
+import type { StepContext } from "./step_context.ts";
+import type { StepHandler } from "./step_handler.ts";
+import { processHooks } from "./resource_helpers.ts";
+
+export class LoadVarStepHandler implements StepHandler {
+  getIdentifier(step: Step): string {
+    return `load_var/${(step as LoadVarStep).load_var}`;
+  }
+
+  async process(
+    ctx: StepContext,
+    step: LoadVarStep,
+    pathContext: string,
+  ): Promise<void> {
+    const storageKey = `${ctx.paths.getBaseStorageKey()}/${pathContext}`;
+    let failure: unknown = undefined;
+
+    try {
+      const mountName = step.file.split("/")[0];
+      const result = await ctx.runTask(
+        {
+          task: `load-var-${step.load_var}`,
+          config: {
+            image_resource: {
+              type: "registry-image",
+              source: { repository: "busybox" },
+            },
+            inputs: [{ name: mountName }],
+            run: { path: "cat", args: [step.file] },
+          },
+          assert: { code: 0 },
+        },
+        undefined,
+        pathContext,
+      );
+
+      ctx.variableResolver.setLocalVar(
+        step.load_var,
+        this.parse(step, result.stdout),
+      );
+    } catch (error) {
+      failure = error;
+    }
+
+    await processHooks(ctx, step, pathContext, storageKey, failure);
+
+    if (failure) {
+      throw failure;
+    }
+  }
+
+  // parse decodes the file's contents by format, which defaults to json or
+  // yaml from the file's extension and otherwise trims whitespace.
+  private parse(step: LoadVarStep, contents: string): unknown {
+    let format = step.format;
+    if (!format) {
+      if (step.file.endsWith(".json")) {
+        format = "json";
+      } else if (/\.ya?ml$/.test(step.file)) {
+        format = "yaml";
+      } else {
+        format = "trim";
+      }
+    }
+
+    switch (format) {
+      case "json":
+        return JSON.parse(contents);
+      case "yaml":
+      case "yml":
+        return YAML.parse(contents);
+      case "raw":
+        return contents;
+      default:
+        return contents.trim();
+    }
+  }
+}
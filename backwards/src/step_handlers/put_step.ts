@@ -22,6 +22,13 @@ export class PutStepHandler implements StepHandler {
     const resourceType = findResourceType(ctx.resourceTypes, resource?.type);
     const hooks = stepHooks(step);
 
+    const isNativeDriver = typeof pipelineContext !== "undefined" &&
+      pipelineContext.driverName === "native";
+    if (isNativeDriver && nativeResources.isNative(resource?.type)) {
+      await this.processNative(ctx, step, resource, pathContext);
+      return;
+    }
+
     const putResponse = await ctx.runTask(
       {
         task: `put-${resource.name}`,
@@ -60,4 +67,55 @@ export class PutStepHandler implements StepHandler {
       `${pathContext}/get`,
     );
   }
+
+  // processNative pushes with the native resource, exposing every volume known
+  // to the job under its name, then fetches the new version like a get step.
+  private async processNative(
+    ctx: StepContext,
+    step: Put,
+    resource: Resource,
+    pathContext: string,
+  ): Promise<void> {
+    const storageKey = `${ctx.paths.getBaseStorageKey()}/${pathContext}`;
+    storage.set(storageKey, { status: "pending", resource: resource.name });
+
+    try {
+      const knownMounts = ctx.taskRunner.getKnownMounts();
+      const mounts: { [name: string]: string } = {};
+      for (const [name, volume] of Object.entries(knownMounts)) {
+        mounts[name] = volume.path;
+      }
+
+      const pushed = nativeResources.push({
+        type: resource.type!,
+        source: resource.source!,
+        params: step.params as { [key: string]: unknown },
+        srcDir: "",
+        mounts,
+      });
+
+      const volume = await runtime.createVolume({ name: resource.name });
+      knownMounts[resource.name!] = volume;
+
+      nativeResources.fetch({
+        type: resource.type!,
+        source: resource.source!,
+        version: pushed.version,
+        destDir: volume.path,
+      });
+
+      storage.set(storageKey, {
+        status: "success",
+        version: pushed.version,
+        resource: resource.name,
+      });
+    } catch (error) {
+      storage.set(storageKey, {
+        status: "error",
+        resource: resource.name,
+        error: String(error),
+      });
+      throw new Error(`Failed to put resource '${resource.name}': ${error}`);
+    }
+  }
 }
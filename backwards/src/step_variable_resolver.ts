@@ -1,12 +1,99 @@
 /// <reference path="../../packages/pocketci/src/global.d.ts" />
 
+// Matches local var references, such as ((.:version)) or ((.:build.tag)).
+const localVarPattern = /\(\(\s*\.:([-\/.\w"]+)\s*\)\)/g;
+
 export class StepVariableResolver {
   private jobParams: Record<string, string> = {};
+  private localVars: Record<string, unknown> = {};
 
   setJobParams(jobParams: Record<string, string>): void {
     this.jobParams = jobParams;
   }
 
+  setLocalVar(name: string, value: unknown): void {
+    this.localVars[name] = value;
+  }
+
+  // interpolateLocalVars replaces the ((.:name)) references set by load_var
+  // steps throughout a step. A reference making up a whole value keeps the
+  // var's type; references that are not set yet are left as they are.
+  interpolateLocalVars<T>(
+    value: T,
+    vars: Record<string, unknown> = this.localVars,
+  ): T {
+    if (Object.keys(vars).length === 0) {
+      return value;
+    }
+
+    if (typeof value === "string") {
+      return this.interpolateString(value, vars) as T;
+    }
+
+    if (Array.isArray(value)) {
+      return value.map((item) => this.interpolateLocalVars(item, vars)) as T;
+    }
+
+    if (value !== null && typeof value === "object") {
+      return Object.fromEntries(
+        Object.entries(value).map(([key, item]) => [
+          key,
+          this.interpolateLocalVars(item, vars),
+        ]),
+      ) as T;
+    }
+
+    return value;
+  }
+
+  private interpolateString(
+    value: string,
+    vars: Record<string, unknown>,
+  ): unknown {
+    const matches = [...value.matchAll(localVarPattern)];
+    if (matches.length === 0) {
+      return value;
+    }
+
+    if (matches.length === 1 && matches[0][0] === value) {
+      const [resolved, found] = this.lookupLocalVar(matches[0][1], vars);
+      return found ? resolved : value;
+    }
+
+    return value.replace(localVarPattern, (match, ref: string) => {
+      const [resolved, found] = this.lookupLocalVar(ref, vars);
+      if (!found) {
+        return match;
+      }
+
+      return typeof resolved === "string" ? resolved : JSON.stringify(resolved);
+    });
+  }
+
+  private lookupLocalVar(
+    ref: string,
+    vars: Record<string, unknown>,
+  ): [unknown, boolean] {
+    const segments = (ref.match(/"[^"]*"|[^.]+/g) ?? []).map((segment) =>
+      segment.replace(/^"|"$/g, "")
+    );
+    const [name, ...fields] = segments;
+
+    if (name === undefined || !(name in vars)) {
+      return [undefined, false];
+    }
+
+    let value = vars[name];
+    for (const field of fields) {
+      if (value === null || typeof value !== "object" || !(field in value)) {
+        return [undefined, false];
+      }
+      value = (value as Record<string, unknown>)[field];
+    }
+
+    return [value, true];
+  }
+
   generateAcrossCombinations(
     acrossVars: AcrossVar[],
   ): Record<string, string>[] {
@@ -53,7 +140,7 @@ export class StepVariableResolver {
     delete (clonedStep as Record<string, unknown>).across;
     delete (clonedStep as Record<string, unknown>).fail_fast;
 
-    return clonedStep;
+    return this.interpolateLocalVars(clonedStep, variables);
   }
 
   injectJobParams(step: Step): Step {
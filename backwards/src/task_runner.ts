@@ -1,7 +1,6 @@
 /// <reference path="../../packages/pocketci/src/global.d.ts" />
 
 import { formatElapsed } from "./utils.ts";
-import { safeStorageGet } from "./utils.ts";
 
 export class TaskRunner {
   private knownMounts: KnownMounts = {};
@@ -50,8 +49,6 @@ export class TaskRunner {
       image = step.config?.image_resource.source.repository!;
     }
 
-    const logs: Array<{ type: "stdout" | "stderr"; content: string }> = [];
-
     try {
       result = await runtime.run({
         command: {
@@ -67,16 +64,9 @@ export class TaskRunner {
         privileged: step.privileged ?? false,
         stdin: stdin ?? "",
         timeout: step.timeout,
+        // The runtime appends the task's output to the log store under
+        // storage_key as it streams.
         storage_key: taskStorageKey,
-        onOutput: (stream: "stdout" | "stderr", data: string) => {
-          logs.push({ type: stream, content: data });
-
-          storage.set(taskStorageKey, {
-            status: "running",
-            started_at: startedAt,
-            logs: logs.slice(),
-          });
-        },
       });
 
       let status = "success";
@@ -93,11 +83,10 @@ export class TaskRunner {
           code: result.code,
           started_at: startedAt,
           elapsed: formatElapsed(startedAt),
-          logs: logs.slice(),
         },
       );
 
-      this.validateTaskResult(step, result, taskStorageKey);
+      this.validateTaskResult(step, result);
 
       return result;
     } catch (error) {
@@ -161,17 +150,12 @@ export class TaskRunner {
       .toLowerCase();
   }
 
-  private validateTaskResult(
-    step: Task,
-    result: RunTaskResult,
-    taskStorageKey: string,
-  ): void {
+  private validateTaskResult(step: Task, result: RunTaskResult): void {
     if (step.assert?.stdout && step.assert.stdout.trim() !== "") {
       this.assertOutputEventuallyContains(
         "stdout",
         step.assert.stdout,
         result,
-        taskStorageKey,
       );
     }
 
@@ -180,7 +164,6 @@ export class TaskRunner {
         "stderr",
         step.assert.stderr,
         result,
-        taskStorageKey,
       );
     }
 
@@ -193,42 +176,14 @@ export class TaskRunner {
     stream: "stdout" | "stderr",
     expected: string,
     result: RunTaskResult,
-    taskStorageKey: string,
   ) {
     assert.eventuallyContainsString(
-      () => this.getLatestTaskOutput(stream, result, taskStorageKey),
+      () => stream === "stdout" ? result.stdout : result.stderr,
       expected,
       1000,
       50,
     );
   }
-
-  private getLatestTaskOutput(
-    stream: "stdout" | "stderr",
-    result: RunTaskResult,
-    taskStorageKey: string,
-  ): string {
-    let output = stream === "stdout" ? result.stdout : result.stderr;
-
-    const taskStatus = safeStorageGet(taskStorageKey) as {
-      logs?: Array<{ type?: string; content?: string }>;
-    } | null;
-
-    if (taskStatus?.logs && Array.isArray(taskStatus.logs)) {
-      const buffered = taskStatus.logs
-        .filter((entry) =>
-          entry?.type === stream && typeof entry?.content === "string"
-        )
-        .map((entry) => entry.content as string)
-        .join("");
-
-      if (buffered.length > output.length) {
-        output = buffered;
-      }
-    }
-
-    return output;
-  }
 }
 
 class CustomError extends Error {
@@ -0,0 +1,387 @@
+package backwards
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jtarchie/pocketci/runtime/support"
+	"github.com/jtarchie/pocketci/secrets"
+)
+
+// varPattern matches Concourse style ((var)) references, such as ((name)),
+// ((name.field)) and ((source:path.field)).
+var varPattern = regexp.MustCompile(`\(\(\s*([-/.:\w\pL"]+)\s*\)\)`)
+
+// LocalVarSource is the var source of values set by load_var steps and
+// across, e.g. ((.:version)). Local vars are resolved while the job runs.
+const LocalVarSource = "."
+
+// VarRef is a parsed ((source:path.field)) reference.
+type VarRef struct {
+	Source string
+	Path   string
+	Fields []string
+}
+
+// ParseVarRef parses the contents of a ((var)) reference. Path segments
+// that contain dots can be quoted, e.g. (("my.var".field)).
+func ParseVarRef(raw string) VarRef {
+	var ref VarRef
+
+	raw = strings.TrimSpace(raw)
+	if source, path, found := strings.Cut(raw, ":"); found {
+		ref.Source = source
+		raw = path
+	}
+
+	var (
+		segments []string
+		segment  strings.Builder
+		quoted   bool
+	)
+
+	for _, char := range raw {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == '.' && !quoted:
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteRune(char)
+		}
+	}
+
+	segments = append(segments, segment.String())
+
+	ref.Path = segments[0]
+	ref.Fields = segments[1:]
+
+	return ref
+}
+
+func (r VarRef) String() string {
+	name := r.Path
+	if r.Source != "" {
+		name = r.Source + ":" + name
+	}
+
+	if len(r.Fields) > 0 {
+		name += "." + strings.Join(r.Fields, ".")
+	}
+
+	return "((" + name + "))"
+}
+
+// Variables resolves ((var)) references. Get returns false when the var is
+// not defined, so that another source can be tried.
+type Variables interface {
+	Get(ref VarRef) (any, bool, error)
+}
+
+// StaticVariables are vars given when the pipeline is set, such as with
+// `--var` and `--load-vars-from`.
+type StaticVariables map[string]any
+
+func (v StaticVariables) Get(ref VarRef) (any, bool, error) {
+	if ref.Source != "" {
+		return nil, false, nil
+	}
+
+	value, ok := v[ref.Path]
+	if !ok {
+		return nil, false, nil
+	}
+
+	value, ok = lookupFields(value, ref.Fields)
+
+	return value, ok, nil
+}
+
+// MultiVariables resolves a var from the first of its sources defining it.
+type MultiVariables []Variables
+
+func (m MultiVariables) Get(ref VarRef) (any, bool, error) {
+	for _, vars := range m {
+		if vars == nil {
+			continue
+		}
+
+		value, ok, err := vars.Get(ref)
+		if err != nil || ok {
+			return value, ok, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// SecretVariables resolves vars from the secrets manager, trying the
+// pipeline's scope before the global scope. Fields of a var, such as
+// ((db.password)), are read from a secret holding a JSON object. A named var
+// source prefixes the secret's key, so ((vault:db.password)) reads the
+// password field of the secret vault/db.
+type SecretVariables struct {
+	ctx        context.Context //nolint: containedctx
+	manager    secrets.Manager
+	pipelineID string
+	resolved   []string
+}
+
+// NewSecretVariables creates SecretVariables for a pipeline's secrets.
+func NewSecretVariables(ctx context.Context, manager secrets.Manager, pipelineID string) *SecretVariables {
+	return &SecretVariables{
+		ctx:        ctx,
+		manager:    manager,
+		pipelineID: pipelineID,
+	}
+}
+
+// Resolved returns the secrets read so far, so they can be redacted from
+// task output.
+func (v *SecretVariables) Resolved() []string {
+	return v.resolved
+}
+
+func (v *SecretVariables) Get(ref VarRef) (any, bool, error) {
+	if ref.Source == LocalVarSource || v.manager == nil {
+		return nil, false, nil
+	}
+
+	key := ref.Path
+	if ref.Source != "" {
+		key = ref.Source + "/" + ref.Path
+	}
+
+	secret, _, err := support.ResolveSecretString(v.ctx, v.manager, v.pipelineID, support.SecretPrefix+key)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	v.resolved = append(v.resolved, secret)
+
+	if len(ref.Fields) == 0 {
+		return secret, true, nil
+	}
+
+	var value any
+
+	err = json.Unmarshal([]byte(secret), &value)
+	if err != nil {
+		return nil, false, fmt.Errorf("secret %q is not a JSON object, so %s has no fields: %w", key, ref, err)
+	}
+
+	value, ok := lookupFields(value, ref.Fields)
+	if text, isString := value.(string); isString {
+		v.resolved = append(v.resolved, text)
+	}
+
+	return value, ok, nil
+}
+
+func lookupFields(value any, fields []string) (any, bool) {
+	for _, field := range fields {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = object[field]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// UndefinedVarsError is returned when a pipeline references vars that none
+// of its var sources define.
+type UndefinedVarsError struct {
+	Refs []string
+}
+
+func (e *UndefinedVarsError) Error() string {
+	noun, pronoun, secret := "var", "it", "a secret"
+	if len(e.Refs) > 1 {
+		noun, pronoun, secret = "vars", "them", "secrets"
+	}
+
+	return fmt.Sprintf(
+		"pipeline references undefined %s %s: set %s with --var or --load-vars-from, or store %s as %s",
+		noun, strings.Join(e.Refs, ", "), pronoun, pronoun, secret,
+	)
+}
+
+// interpolateVars replaces the ((var)) references in YAML content with their
+// values. A reference making up a whole value keeps the var's type, so a var
+// can hold a number or a map; references within a string are replaced by
+// the var's text, or its JSON for other types. Local vars, like ((.:name)),
+// and shell arithmetic, like $((n)), are left for the job to resolve.
+func interpolateVars(content []byte, vars Variables) ([]byte, error) {
+	if vars == nil || !bytes.Contains(content, []byte("((")) {
+		return content, nil
+	}
+
+	var document any
+
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal pipeline: %w", err)
+	}
+
+	i := &interpolator{vars: vars, seen: map[string]bool{}}
+
+	document, err = i.value(document)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(i.missing) > 0 {
+		slices.Sort(i.missing)
+
+		return nil, &UndefinedVarsError{Refs: i.missing}
+	}
+
+	interpolated, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal pipeline: %w", err)
+	}
+
+	return interpolated, nil
+}
+
+type interpolator struct {
+	vars    Variables
+	missing []string
+	seen    map[string]bool
+}
+
+func (i *interpolator) value(node any) (any, error) {
+	var err error
+
+	switch typed := node.(type) {
+	case string:
+		return i.string(typed)
+	case map[string]any:
+		for key, value := range typed {
+			typed[key], err = i.value(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for index, value := range typed {
+			typed[index], err = i.value(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return node, nil
+}
+
+func (i *interpolator) string(value string) (any, error) {
+	matches := varPattern.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) {
+		resolved, ok, err := i.resolve(value[matches[0][2]:matches[0][3]])
+		if err != nil || !ok {
+			return value, err
+		}
+
+		return resolved, nil
+	}
+
+	var builder strings.Builder
+
+	last := 0
+
+	for _, match := range matches {
+		builder.WriteString(value[last:match[0]])
+		last = match[1]
+
+		if match[0] > 0 && value[match[0]-1] == '$' {
+			builder.WriteString(value[match[0]:match[1]])
+
+			continue
+		}
+
+		resolved, ok, err := i.resolve(value[match[2]:match[3]])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			builder.WriteString(value[match[0]:match[1]])
+
+			continue
+		}
+
+		if text, isString := resolved.(string); isString {
+			builder.WriteString(text)
+
+			continue
+		}
+
+		encoded, err := json.Marshal(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("could not interpolate var ((%s)): %w", value[match[2]:match[3]], err)
+		}
+
+		builder.Write(encoded)
+	}
+
+	builder.WriteString(value[last:])
+
+	return builder.String(), nil
+}
+
+func (i *interpolator) resolve(raw string) (any, bool, error) {
+	ref := ParseVarRef(raw)
+	if ref.Source == LocalVarSource {
+		return nil, false, nil
+	}
+
+	value, ok, err := i.vars.Get(ref)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not resolve var %s: %w", ref, err)
+	}
+
+	if !ok && !i.seen[ref.String()] {
+		i.seen[ref.String()] = true
+		i.missing = append(i.missing, ref.String())
+	}
+
+	return value, ok, nil
+}
+
+// localVarRefs returns the names of the local vars, like ((.:name)),
+// referenced in YAML content.
+func localVarRefs(content []byte) []string {
+	var names []string
+
+	for _, match := range varPattern.FindAllSubmatch(content, -1) {
+		ref := ParseVarRef(string(match[1]))
+		if ref.Source == LocalVarSource {
+			names = append(names, ref.Path)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,234 @@
+package backwards_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jtarchie/pocketci/backwards"
+	"github.com/jtarchie/pocketci/secrets"
+	_ "github.com/jtarchie/pocketci/secrets/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+const varsYAML = `
+resources:
+  - name: repo
+    type: registry-image
+    source:
+      repository: ((registry.host))/((image))
+      password: ((password))
+
+jobs:
+  - name: build
+    plan:
+      - get: repo
+      - task: compile
+        config:
+          platform: linux
+          env:
+            TAG: v((version))
+          run:
+            path: sh
+            args: ["-c", "echo $((1+2)) $((count))"]
+          outputs:
+            - name: out
+      - load_var: version
+        file: out/version
+        format: trim
+      - task: release
+        config:
+          platform: linux
+          env:
+            VERSION: ((.:version))
+          run:
+            path: echo
+`
+
+func TestVars(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parses references", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(backwards.ParseVarRef("name")).To(Equal(backwards.VarRef{Path: "name", Fields: []string{}}))
+		assert.Expect(backwards.ParseVarRef(" creds.user ")).To(Equal(backwards.VarRef{Path: "creds", Fields: []string{"user"}}))
+		assert.Expect(backwards.ParseVarRef(".:version")).To(Equal(backwards.VarRef{Source: ".", Path: "version", Fields: []string{}}))
+		assert.Expect(backwards.ParseVarRef(`vault:"my.path".key`)).To(Equal(backwards.VarRef{Source: "vault", Path: "my.path", Fields: []string{"key"}}))
+		assert.Expect(backwards.ParseVarRef("creds.user").String()).To(Equal("((creds.user))"))
+	})
+
+	t.Run("interpolates set-time vars", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfigWithVars([]byte("max_in_flight: ((max_in_flight))"+varsYAML), backwards.StaticVariables{
+			"max_in_flight": 2,
+			"registry":      map[string]any{"host": "registry.example.com"},
+			"image":         "app",
+			"password":      "hunter2",
+			"version":       "1.2.3",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(config.MaxInFlight).To(Equal(2))
+		assert.Expect(config.Resources[0].Source).To(HaveKeyWithValue("repository", "registry.example.com/app"))
+		assert.Expect(config.Resources[0].Source).To(HaveKeyWithValue("password", "hunter2"))
+
+		compile := config.Jobs[0].Plan[1].TaskConfig
+		assert.Expect(compile.Env).To(HaveKeyWithValue("TAG", "v1.2.3"))
+		assert.Expect(compile.Run.Args).To(Equal([]string{"-c", "echo $((1+2)) $((count))"}))
+
+		release := config.Jobs[0].Plan[3].TaskConfig
+		assert.Expect(release.Env).To(HaveKeyWithValue("VERSION", "((.:version))"))
+	})
+
+	t.Run("reports every undefined var", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		err := backwards.ValidatePipelineWithVars([]byte(varsYAML), backwards.StaticVariables{
+			"image": "app",
+		})
+
+		var undefined *backwards.UndefinedVarsError
+		assert.Expect(errors.As(err, &undefined)).To(BeTrue())
+		assert.Expect(undefined.Refs).To(ConsistOf("((registry.host))", "((password))", "((version))"))
+		assert.Expect(err).To(MatchError(ContainSubstring("pipeline references undefined vars")))
+	})
+
+	t.Run("resolves vars from named var sources as prefixed secrets", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		ctx := context.Background()
+
+		manager, err := secrets.GetFromDSN("sqlite://:memory:?key=test-key", slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = manager.Close() }()
+
+		assert.Expect(manager.Set(ctx, secrets.GlobalScope, "vault/deploy", `{"token":"global"}`)).To(Succeed())
+		assert.Expect(manager.Set(ctx, secrets.PipelineScope("pipeline-id"), "vault/deploy", `{"token":"scoped"}`)).To(Succeed())
+
+		content := []byte(varsYAML + `
+  - name: deploy
+    plan:
+      - task: deploy
+        config:
+          platform: linux
+          env:
+            TOKEN: ((vault:deploy.token))
+          run:
+            path: echo
+`)
+
+		static := backwards.StaticVariables{
+			"registry": map[string]any{"host": "registry.example.com"},
+			"image":    "app",
+			"password": "hunter2",
+			"version":  "1.2.3",
+			"deploy":   map[string]any{"token": "static"},
+		}
+
+		secretVars := backwards.NewSecretVariables(ctx, manager, "pipeline-id")
+		config, err := backwards.ParseConfigWithVars(content, backwards.MultiVariables{static, secretVars})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(config.Jobs[1].Plan[0].TaskConfig.Env).To(HaveKeyWithValue("TOKEN", "scoped"))
+		assert.Expect(secretVars.Resolved()).To(ContainElement("scoped"))
+
+		// Set-time vars never define a var from a named source.
+		err = backwards.ValidatePipelineWithVars(content, static)
+
+		var undefined *backwards.UndefinedVarsError
+		assert.Expect(errors.As(err, &undefined)).To(BeTrue())
+		assert.Expect(undefined.Refs).To(ConsistOf("((vault:deploy.token))"))
+	})
+
+	t.Run("leaves references without vars", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		config, err := backwards.ParseConfig([]byte(varsYAML))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(config.Resources[0].Source).To(HaveKeyWithValue("password", "((password))"))
+	})
+
+	t.Run("resolves secrets from the pipeline scope before the global scope", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		ctx := context.Background()
+
+		manager, err := secrets.GetFromDSN("sqlite://:memory:?key=test-key", slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = manager.Close() }()
+
+		assert.Expect(manager.Set(ctx, secrets.GlobalScope, "password", "global")).To(Succeed())
+		assert.Expect(manager.Set(ctx, secrets.GlobalScope, "registry", `{"host":"registry.example.com"}`)).To(Succeed())
+		assert.Expect(manager.Set(ctx, secrets.PipelineScope("pipeline-id"), "password", "scoped")).To(Succeed())
+
+		secretVars := backwards.NewSecretVariables(ctx, manager, "pipeline-id")
+		config, err := backwards.ParseConfigWithVars([]byte(varsYAML), backwards.MultiVariables{
+			backwards.StaticVariables{"image": "app", "version": "1.0.0"},
+			secretVars,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(config.Resources[0].Source).To(HaveKeyWithValue("repository", "registry.example.com/app"))
+		assert.Expect(config.Resources[0].Source).To(HaveKeyWithValue("password", "scoped"))
+		assert.Expect(secretVars.Resolved()).To(ContainElements("scoped", "registry.example.com"))
+	})
+
+	t.Run("validates load_var steps and local vars", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		pipeline := func(plan string) []byte {
+			return []byte(`
+jobs:
+  - name: build
+    plan:
+` + plan)
+		}
+
+		err := backwards.ValidatePipeline(pipeline(`
+      - load_var: version
+        file: out/version
+        format: xml
+`))
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid format "xml"`)))
+
+		err = backwards.ValidatePipeline(pipeline(`
+      - load_var: version
+        format: json
+`))
+		assert.Expect(err).To(MatchError(ContainSubstring(`load_var step "version" in job "build" requires file`)))
+
+		err = backwards.ValidatePipeline(pipeline(`
+      - task: release
+        config:
+          platform: linux
+          env:
+            VERSION: ((.:version))
+          run:
+            path: echo
+`))
+		assert.Expect(err).To(MatchError(ContainSubstring(`job "build" references undefined local var ((.:version))`)))
+
+		err = backwards.ValidatePipeline(pipeline(`
+      - task: release
+        across:
+          - var: version
+            values: ["1", "2"]
+        config:
+          platform: linux
+          env:
+            VERSION: ((.:version))
+          run:
+            path: echo
+`))
+		assert.Expect(err).NotTo(HaveOccurred())
+	})
+}
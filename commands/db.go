@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"time"
+
+	sqlite "github.com/jtarchie/pocketci/storage/sqlite"
+)
+
+// DB is the `pocketci db` command group. It manages the schema of a SQLite
+// store, which the server otherwise migrates when it starts.
+type DB struct {
+	Migrate DBMigrate `cmd:"" help:"Apply pending schema migrations"`
+	Status  DBStatus  `cmd:"" help:"List schema migrations and whether each is applied"`
+}
+
+// DBMigrate is the `pocketci db migrate` command.
+type DBMigrate struct {
+	Storage string `default:"sqlite://test.db" env:"CI_STORAGE" help:"Path to storage file"`
+
+	// Stdout receives the report. It defaults to the process's stdout.
+	Stdout io.Writer `kong:"-"`
+}
+
+// DBStatus is the `pocketci db status` command.
+type DBStatus struct {
+	Storage string `default:"sqlite://test.db" env:"CI_STORAGE" help:"Path to storage file"`
+
+	// Stdout receives the report. It defaults to the process's stdout.
+	Stdout io.Writer `kong:"-"`
+}
+
+var ErrUnsupportedStorage = errors.New("schema migrations are only supported for sqlite:// storage")
+
+func (c *DBMigrate) Run(logger *slog.Logger) error {
+	logger = logger.WithGroup("db.migrate")
+
+	err := requireSqlite(c.Storage)
+	if err != nil {
+		return err
+	}
+
+	applied, err := sqlite.Migrate(context.Background(), c.Storage)
+	for _, migration := range applied {
+		logger.Info("migration.applied", "version", migration.Version, "name", migration.Name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not migrate: %w", err)
+	}
+
+	stdout := c.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	_, err = fmt.Fprintf(stdout, "applied %d migration(s)\n", len(applied))
+	if err != nil {
+		return fmt.Errorf("could not write report: %w", err)
+	}
+
+	return nil
+}
+
+func (c *DBStatus) Run(_ *slog.Logger) error {
+	err := requireSqlite(c.Storage)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := sqlite.Status(context.Background(), c.Storage)
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	stdout := c.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+
+		_, err := fmt.Fprintf(stdout, "%04d %-45s %s\n", status.Version, status.Name, state)
+		if err != nil {
+			return fmt.Errorf("could not write report: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func requireSqlite(dsn string) error {
+	uri, err := url.Parse(dsn)
+	if err != nil || uri.Scheme != "sqlite" {
+		return fmt.Errorf("%w: got %q", ErrUnsupportedStorage, dsn)
+	}
+
+	return nil
+}
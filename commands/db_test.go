@@ -0,0 +1,56 @@
+package commands_test
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtarchie/pocketci/commands"
+	. "github.com/onsi/gomega"
+)
+
+func TestDB(t *testing.T) {
+	t.Parallel()
+
+	t.Run("migrates and reports the status", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		dsn := "sqlite://" + filepath.Join(t.TempDir(), "pocketci.db")
+
+		var status bytes.Buffer
+
+		err := (&commands.DBStatus{Storage: dsn, Stdout: &status}).Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(status.String()).To(ContainSubstring("0001 initial schema"))
+		assert.Expect(status.String()).To(ContainSubstring("pending"))
+		assert.Expect(status.String()).NotTo(ContainSubstring("applied"))
+
+		var migrate bytes.Buffer
+
+		err = (&commands.DBMigrate{Storage: dsn, Stdout: &migrate}).Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(migrate.String()).To(Equal("applied 6 migration(s)\n"))
+
+		migrate.Reset()
+
+		err = (&commands.DBMigrate{Storage: dsn, Stdout: &migrate}).Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(migrate.String()).To(Equal("applied 0 migration(s)\n"))
+
+		status.Reset()
+
+		err = (&commands.DBStatus{Storage: dsn, Stdout: &status}).Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(status.String()).NotTo(ContainSubstring("pending"))
+	})
+
+	t.Run("rejects other storage", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		err := (&commands.DBMigrate{Storage: "s3://bucket"}).Run(slog.Default())
+		assert.Expect(err).To(MatchError(commands.ErrUnsupportedStorage))
+	})
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/jtarchie/pocketci/backwards"
+	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/orchestra/cache"
+	"github.com/jtarchie/pocketci/runtime"
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/jtarchie/pocketci/runtime/support"
+	"github.com/jtarchie/pocketci/secrets"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// Runner is the `pocketci runner` command. It executes a pipeline file
+// locally with the chosen driver, storage and secrets backend, without a
+// server. Task output is streamed to the terminal.
+type Runner struct {
+	Storage            string        `default:"sqlite://test.db"                                    env:"CI_STORAGE"              help:"Path to storage file"                                                                                                                                      required:""`
+	Pipeline           string        `arg:""                                                        help:"Path to pipeline file (JS, TS, or YAML)"                                                                                                                   type:"existingfile"`
+	Args               []string      `arg:""                                                        help:"Arguments passed to the pipeline via pipelineContext.args"                                                                                                  optional:"" passthrough:""`
+	Driver             string        `default:"native"                                              env:"CI_DRIVER"               help:"Orchestrator driver DSN (e.g., 'k8s:namespace=my-ns', 'k8s://my-ns', 'docker', 'native')"`
+	Timeout            time.Duration `env:"CI_TIMEOUT"                                              help:"timeout for the pipeline, will cause abort if exceeded"`
+	Resume             bool          `help:"Resume from last checkpoint if pipeline was interrupted"`
+	RunID              string        `help:"Unique run ID for resume support (auto-generated if not provided)"`
+	Secrets            string        `default:"" env:"CI_SECRETS" help:"Secrets backend DSN (e.g., 'sqlite://secrets.db?key=my-passphrase)')" `
+	Secret             []string      `help:"Set a pipeline-scoped secret as KEY=VALUE (can be repeated)" short:"e"`
+	GlobalSecret       []string      `help:"Set a global secret as KEY=VALUE (can be repeated)"`
+	FetchTimeout       time.Duration `default:"30s"                                              env:"CI_FETCH_TIMEOUT"            help:"Timeout for fetch() requests in pipelines"`
+	FetchMaxResponseMB int           `default:"10"                                               env:"CI_FETCH_MAX_RESPONSE_MB"    help:"Maximum response size in MB for fetch() requests"`
+	AgentProviders     string        `env:"CI_AGENT_PROVIDERS"                                   help:"Path to a YAML file declaring agent model providers"                                                                                      type:"existingfile"`
+	AgentPrices        string        `env:"CI_AGENT_PRICES"                                      help:"Path to a YAML file declaring agent model prices in USD per million tokens"                                                               type:"existingfile"`
+
+	// Stdout and Stderr receive streamed task output. They default to the
+	// process's stdout and stderr.
+	Stdout io.Writer `kong:"-"`
+	Stderr io.Writer `kong:"-"`
+}
+
+var (
+	ErrCouldNotBundle = errors.New("could not bundle pipeline")
+	ErrPipelineFailed = errors.New("pipeline failed")
+)
+
+// Run executes the pipeline and returns ErrPipelineFailed when any job
+// finished with a failure, error or abort status.
+func (c *Runner) Run(logger *slog.Logger) error {
+	return c.execute(logger, true)
+}
+
+// Execute executes the pipeline, only returning an error when the pipeline
+// itself could not run. Failed jobs are left in storage for the caller to
+// inspect.
+func (c *Runner) Execute(logger *slog.Logger) error {
+	return c.execute(logger, false)
+}
+
+func youtubeIDStyle(input string) string {
+	hash := sha256.Sum256([]byte(input))
+
+	encoded := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	const maxLength = 11
+
+	if len(encoded) > maxLength {
+		return encoded[:maxLength] // YouTube IDs are 11 chars
+	}
+
+	return encoded
+}
+
+func (c *Runner) execute(logger *slog.Logger, checkJobs bool) error {
+	initStorage, found := storage.GetFromDSN(c.Storage)
+	if !found {
+		return fmt.Errorf("could not get storage driver: %w", errors.ErrUnsupported)
+	}
+
+	pipelinePath, err := filepath.Abs(c.Pipeline)
+	if err != nil {
+		return fmt.Errorf("could not get absolute path to pipeline: %w", err)
+	}
+
+	runtimeID := youtubeIDStyle(pipelinePath)
+
+	if c.AgentProviders != "" {
+		err = agent.LoadProviders(c.AgentProviders)
+		if err != nil {
+			return fmt.Errorf("could not load agent providers: %w", err)
+		}
+	}
+
+	if c.AgentPrices != "" {
+		err = agent.LoadPrices(c.AgentPrices)
+		if err != nil {
+			return fmt.Errorf("could not load agent prices: %w", err)
+		}
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger = logger.WithGroup("runner.run").With(
+		"id", runtimeID,
+		"pipeline", c.Pipeline,
+		"orchestrator", c.Driver,
+	)
+
+	// Create a context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if c.Timeout > 0 {
+		// Create a context with timeout
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Set up signal handling
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	// Handle signals in a separate goroutine
+	go func() {
+		select {
+		case sig := <-sigs:
+			logger.Debug("execution.canceled", "signal", sig)
+			cancel() // Cancel the context when signal is received
+		case <-ctx.Done():
+		}
+	}()
+
+	pipeline, err := bundlePipeline(pipelinePath)
+	if err != nil {
+		return err
+	}
+
+	driverConfig, orchestrator, err := orchestra.GetFromDSN(c.Driver)
+	if err != nil {
+		return fmt.Errorf("could not parse driver DSN (%q): %w", c.Driver, err)
+	}
+
+	// Use namespace from DSN if provided, otherwise use generated ID
+	namespace := driverConfig.Namespace
+	if namespace == "" {
+		namespace = "ci-" + runtimeID
+	}
+
+	driver, err := orchestrator(namespace, logger, driverConfig.Params)
+	if err != nil {
+		return fmt.Errorf("could not create orchestrator client: %w", err)
+	}
+	defer func() { _ = driver.Close() }()
+
+	// Wrap driver with caching if cache parameters are present
+	driver, err = cache.WrapWithCaching(driver, driverConfig.Params, logger)
+	if err != nil {
+		return fmt.Errorf("could not initialize cache layer: %w", err)
+	}
+
+	store, err := initStorage(c.Storage, runtimeID, logger)
+	if err != nil {
+		return fmt.Errorf("could not create storage client: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	secretsManager, err := c.secretsManager(ctx, runtimeID, logger)
+	if err != nil {
+		return err
+	}
+
+	if secretsManager != nil {
+		defer func() { _ = secretsManager.Close() }()
+	}
+
+	runID := c.RunID
+	if runID == "" {
+		// If resuming without a run ID, use the runtime ID for consistency
+		runID = support.UniqueID()
+		if c.Resume {
+			runID = runtimeID
+		}
+	}
+
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	err = runtime.ExecutePipeline(ctx, pipeline, c.Driver, store, logger, runtime.ExecutorOptions{
+		Resume:                c.Resume,
+		RunID:                 runID,
+		PipelineID:            runtimeID,
+		SecretsManager:        secretsManager,
+		FetchTimeout:          c.FetchTimeout,
+		FetchMaxResponseBytes: int64(c.FetchMaxResponseMB) * 1024 * 1024,
+		Args:                  c.Args,
+		Driver:                driver,
+		OutputCallback: func(stream, data string) {
+			if stream == "stderr" {
+				_, _ = io.WriteString(stderr, data)
+
+				return
+			}
+
+			_, _ = io.WriteString(stdout, data)
+		},
+	})
+	if err != nil {
+		// Check if the error was due to context cancellation
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("execution cancelled: %w", err)
+		}
+
+		return err
+	}
+
+	if !checkJobs {
+		return nil
+	}
+
+	return failedJobs(ctx, store, runID)
+}
+
+// bundlePipeline returns executable JS for a pipeline file. YAML pipelines are
+// transpiled and JS/TS files are bundled with their imports.
+func bundlePipeline(pipelinePath string) (string, error) {
+	extension := filepath.Ext(pipelinePath)
+	if extension == ".yml" || extension == ".yaml" {
+		pipeline, err := backwards.NewPipeline(pipelinePath)
+		if err != nil {
+			return "", fmt.Errorf("could not create pipeline from YAML: %w", err)
+		}
+
+		return pipeline, nil
+	}
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints:      []string{pipelinePath},
+		Bundle:           true,
+		Sourcemap:        api.SourceMapInline,
+		Platform:         api.PlatformNeutral,
+		PreserveSymlinks: true,
+		AbsWorkingDir:    filepath.Dir(pipelinePath),
+	})
+	if len(result.Errors) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrCouldNotBundle, result.Errors[0].Text)
+	}
+
+	return string(result.OutputFiles[0].Contents), nil
+}
+
+// secretsManager opens the secrets backend, if configured, and stores the
+// secrets passed with --secret and --global-secret.
+func (c *Runner) secretsManager(ctx context.Context, runtimeID string, logger *slog.Logger) (secrets.Manager, error) {
+	if c.Secrets == "" {
+		return nil, nil //nolint: nilnil
+	}
+
+	secretsManager, err := secrets.GetFromDSN(c.Secrets, logger)
+	if err != nil {
+		return nil, fmt.Errorf("could not create secrets manager: %w", err)
+	}
+
+	// Store any secrets provided via --secret flags (pipeline scope)
+	for _, s := range c.Secret {
+		key, value, found := parseSecretFlag(s)
+		if !found {
+			_ = secretsManager.Close()
+
+			return nil, fmt.Errorf("invalid --secret flag %q: expected KEY=VALUE format", s)
+		}
+
+		err = secretsManager.Set(ctx, secrets.PipelineScope(runtimeID), key, value)
+		if err != nil {
+			_ = secretsManager.Close()
+
+			return nil, fmt.Errorf("could not set secret %q: %w", key, err)
+		}
+	}
+
+	// Store any secrets provided via --global-secret flags (global scope)
+	for _, s := range c.GlobalSecret {
+		key, value, found := parseSecretFlag(s)
+		if !found {
+			_ = secretsManager.Close()
+
+			return nil, fmt.Errorf("invalid --global-secret flag %q: expected KEY=VALUE format", s)
+		}
+
+		err = secretsManager.Set(ctx, secrets.GlobalScope, key, value)
+		if err != nil {
+			_ = secretsManager.Close()
+
+			return nil, fmt.Errorf("could not set global secret %q: %w", key, err)
+		}
+	}
+
+	return secretsManager, nil
+}
+
+// failedJobs returns ErrPipelineFailed naming the jobs of a run that failed.
+// Only YAML pipelines record job statuses; JS and TS pipelines fail by
+// throwing, which ExecutePipeline already reports.
+func failedJobs(ctx context.Context, store storage.Driver, runID string) error {
+	results, err := store.GetAll(ctx, "/pipeline/"+runID+"/jobs", []string{"status"})
+	if err != nil {
+		return fmt.Errorf("could not get job statuses: %w", err)
+	}
+
+	var failed []string
+
+	for _, result := range results {
+		// skip task records nested under a job
+		_, name, _ := strings.Cut(result.Path, "/pipeline/"+runID+"/jobs/")
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		status, _ := result.Payload["status"].(string)
+
+		switch status {
+		case "failure", "error", "abort":
+			if !slices.Contains(failed, name) {
+				failed = append(failed, name)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: jobs %s did not succeed", ErrPipelineFailed, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package commands_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jtarchie/pocketci/commands"
+	_ "github.com/jtarchie/pocketci/orchestra/native"
+	. "github.com/onsi/gomega"
+)
+
+const passingYAML = `
+jobs:
+  - name: greet
+    plan:
+      - task: hello
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["hello from the runner"]
+`
+
+const failingYAML = `
+jobs:
+  - name: broken
+    plan:
+      - task: fail
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: sh
+            args: ["-c", "exit 1"]
+`
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs a pipeline and streams task output", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		stdout := &bytes.Buffer{}
+		runner := commands.Runner{
+			Pipeline: writePipeline(t, t.TempDir(), "passing.yml", passingYAML),
+			Driver:   "native",
+			Storage:  "sqlite://:memory:",
+			Stdout:   stdout,
+			Stderr:   &bytes.Buffer{},
+		}
+
+		err := runner.Run(nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(stdout.String()).To(ContainSubstring("hello from the runner"))
+	})
+
+	t.Run("fails when a job fails", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		runner := commands.Runner{
+			Pipeline: writePipeline(t, t.TempDir(), "failing.yml", failingYAML),
+			Driver:   "native",
+			Storage:  "sqlite://:memory:",
+			Stdout:   &bytes.Buffer{},
+			Stderr:   &bytes.Buffer{},
+		}
+
+		err := runner.Run(nil)
+		assert.Expect(err).To(MatchError(commands.ErrPipelineFailed))
+		assert.Expect(err).To(MatchError(ContainSubstring("broken")))
+
+		// Execute leaves failed jobs for the caller to inspect
+		err = runner.Execute(nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("fails when a JS pipeline throws", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		runner := commands.Runner{
+			Pipeline: writePipeline(t, t.TempDir(), "throws.js", `
+const pipeline = async () => { throw new Error("boom"); };
+export { pipeline };
+`),
+			Driver:  "native",
+			Storage: "sqlite://:memory:",
+		}
+
+		err := runner.Run(nil)
+		assert.Expect(err).To(MatchError(ContainSubstring("boom")))
+	})
+}
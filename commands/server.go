@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/secrets"
 	"github.com/jtarchie/pocketci/server"
 	"github.com/jtarchie/pocketci/server/auth"
@@ -30,6 +31,17 @@ type Server struct {
 	FetchMaxResponseMB int           `default:"10"               env:"CI_FETCH_MAX_RESPONSE_MB" help:"Maximum response body size in MB for fetch() calls"`
 	Secrets            string        `default:"sqlite://test.db?key=testing"                 env:"CI_SECRETS"              help:"Secrets backend DSN (e.g., 'sqlite://secrets.db?key=my-passphrase')"`
 	Secret             []string      `help:"Set a global secret as KEY=VALUE (can be repeated)" short:"e"`
+	AgentProviders     string        `env:"CI_AGENT_PROVIDERS"    help:"Path to a YAML file declaring agent model providers (e.g., self-hosted OpenAI-compatible endpoints)" type:"existingfile"`
+	AgentPrices        string        `env:"CI_AGENT_PRICES"       help:"Path to a YAML file declaring agent model prices in USD per million tokens" type:"existingfile"`
+
+	ResourceCheckInterval time.Duration `default:"1m"   env:"CI_RESOURCE_CHECK_INTERVAL" help:"Default interval for checking pipeline resources without check_every (0 disables resource checking)"`
+	SchedulePollInterval  time.Duration `default:"10s"  env:"CI_SCHEDULE_POLL_INTERVAL"  help:"How often cron schedules are evaluated (0 disables schedules)"`
+	ScheduleCatchUp       string        `default:"skip" env:"CI_SCHEDULE_CATCH_UP"       help:"Default policy for schedules missed while the server was down (skip, run-once)" enum:"skip,run-once"`
+
+	RetainRuns        int           `default:"0"   env:"CI_RETAIN_RUNS"         help:"Runs to keep per pipeline without its own retention (0 keeps every run)"`
+	RetainDays        int           `default:"0"   env:"CI_RETAIN_DAYS"         help:"Days to keep runs of pipelines without their own retention (0 keeps runs regardless of age)"`
+	RetentionInterval time.Duration `default:"1h"  env:"CI_RETENTION_INTERVAL"  help:"How often runs past their retention are deleted (0 disables retention)"`
+	VacuumInterval    time.Duration `default:"24h" env:"CI_VACUUM_INTERVAL"     help:"How often storage is vacuumed to reclaim the space of deleted runs (0 disables vacuuming)"`
 
 	// OAuth provider configuration
 	OAuthGithubClientID        string `env:"CI_OAUTH_GITHUB_CLIENT_ID"        help:"GitHub OAuth application client ID"`
@@ -48,6 +60,20 @@ type Server struct {
 }
 
 func (c *Server) Run(logger *slog.Logger) error {
+	if c.AgentProviders != "" {
+		err := agent.LoadProviders(c.AgentProviders)
+		if err != nil {
+			return fmt.Errorf("could not load agent providers: %w", err)
+		}
+	}
+
+	if c.AgentPrices != "" {
+		err := agent.LoadPrices(c.AgentPrices)
+		if err != nil {
+			return fmt.Errorf("could not load agent prices: %w", err)
+		}
+	}
+
 	initStorage, found := storage.GetFromDSN(c.Storage)
 	if !found {
 		return fmt.Errorf("could not get storage driver: %w", errors.ErrUnsupported)
@@ -144,6 +170,13 @@ func (c *Server) Run(logger *slog.Logger) error {
 		FetchTimeout:          c.FetchTimeout,
 		FetchMaxResponseBytes: int64(c.FetchMaxResponseMB) * 1024 * 1024,
 		AuthConfig:            authConfig,
+		ResourceCheckInterval: c.ResourceCheckInterval,
+		SchedulePollInterval:  c.SchedulePollInterval,
+		ScheduleCatchUp:       c.ScheduleCatchUp,
+		RetainRuns:            c.RetainRuns,
+		RetainDays:            c.RetainDays,
+		RetentionInterval:     c.RetentionInterval,
+		VacuumInterval:        c.VacuumInterval,
 	})
 	if err != nil {
 		return fmt.Errorf("could not create router: %w", err)
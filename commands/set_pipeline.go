@@ -3,42 +3,57 @@ package commands
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/goccy/go-yaml"
 	"github.com/jtarchie/pocketci/backwards"
 	"github.com/jtarchie/pocketci/runtime"
 	"github.com/jtarchie/pocketci/storage"
 )
 
 type SetPipeline struct {
-	Pipeline      string   `arg:""                  help:"Path to pipeline file (JS, TS, or YAML)"  required:"" type:"existingfile"`
-	Name          string   `help:"Name for the pipeline (defaults to filename without extension)" short:"n"`
-	ServerURL     string   `env:"CI_SERVER_URL"      help:"URL of the CI server"                                           required:"" short:"s"`
-	Driver        string   `env:"CI_DRIVER"          help:"Orchestrator driver DSN (e.g., 'docker', 'native', 'k8s')"      short:"d"`
-	WebhookSecret string   `env:"CI_WEBHOOK_SECRET"  help:"Secret for webhook signature validation"                        short:"w"`
-	Secret        []string `help:"Set a pipeline-scoped secret as KEY=VALUE (can be repeated)" short:"e"`
-	SecretFile    string   `help:"Path to a file containing secrets in KEY=VALUE format (one per line)" type:"existingfile"`
-	Resume        bool     `help:"Enable automatic resume for this pipeline" default:"false"`
-	RBAC          string   `help:"RBAC expression to control access to this pipeline (expr-lang)" env:"CI_PIPELINE_RBAC"`
-	AuthToken     string   `env:"CI_AUTH_TOKEN"      help:"Bearer token for OAuth-authenticated servers"                   short:"t"`
-	ConfigFile    string   `env:"CI_AUTH_CONFIG"     help:"Path to auth config file (default: ~/.pocketci/auth.config)"   short:"c"`
+	Pipeline         string   `arg:""                  help:"Path to pipeline file (JS, TS, or YAML)"  required:"" type:"existingfile"`
+	Name             string   `help:"Name for the pipeline (defaults to filename without extension)" short:"n"`
+	ServerURL        string   `env:"CI_SERVER_URL"      help:"URL of the CI server"                                           required:"" short:"s"`
+	Driver           string   `env:"CI_DRIVER"          help:"Orchestrator driver DSN (e.g., 'docker', 'native', 'k8s')"      short:"d"`
+	WebhookSecret    string   `env:"CI_WEBHOOK_SECRET"  help:"Secret for webhook signature validation"                        short:"w"`
+	Secret           []string `help:"Set a pipeline-scoped secret as KEY=VALUE (can be repeated)" short:"e"`
+	SecretFile       string   `help:"Path to a file containing secrets in KEY=VALUE format (one per line)" type:"existingfile"`
+	Var              []string `help:"Set a ((var)) for a YAML pipeline as KEY=VALUE (can be repeated)" short:"v"`
+	LoadVarsFrom     []string `help:"Path to a YAML file of ((var)) values for a YAML pipeline (can be repeated)" short:"l" type:"existingfile"`
+	Resume           bool     `help:"Enable automatic resume for this pipeline" default:"false"`
+	RBAC             string   `help:"RBAC expression to control access to this pipeline (expr-lang)" env:"CI_PIPELINE_RBAC"`
+	Schedule         string   `help:"Cron schedule that triggers the whole pipeline (e.g., '0 2 * * *'); omit to remove"`
+	ScheduleTimezone string   `help:"Timezone for --schedule (e.g., 'America/New_York'; defaults to UTC)"`
+	ScheduleCatchUp  string   `help:"Policy for schedules missed while the server was down (skip, run-once; defaults to the server setting)"`
+	MonthlyBudget    float64  `help:"Monthly agent budget in USD; agent steps fail once it is spent (omit to remove)"`
+	RetainRuns       int      `help:"Number of runs to keep; older runs and their logs are deleted (omit to use the server default)"`
+	RetainDays       int      `help:"Days to keep runs; older runs and their logs are deleted (omit to use the server default)"`
+	AuthToken        string   `env:"CI_AUTH_TOKEN"      help:"Bearer token for OAuth-authenticated servers"                   short:"t"`
+	ConfigFile       string   `env:"CI_AUTH_CONFIG"     help:"Path to auth config file (default: ~/.pocketci/auth.config)"   short:"c"`
 }
 
 // pipelineRequest matches the server's expected JSON body for PUT /api/pipelines/:name.
 type pipelineRequest struct {
-	Content        string            `json:"content"`
-	ContentType    string            `json:"content_type"`
-	DriverDSN      string            `json:"driver_dsn"`
-	WebhookSecret  string            `json:"webhook_secret"`
-	Secrets        map[string]string `json:"secrets,omitempty"`
-	ResumeEnabled  *bool             `json:"resume_enabled,omitempty"`
-	RBACExpression *string           `json:"rbac_expression,omitempty"`
+	Content        string                     `json:"content"`
+	ContentType    string                     `json:"content_type"`
+	DriverDSN      string                     `json:"driver_dsn"`
+	WebhookSecret  string                     `json:"webhook_secret"`
+	Secrets        map[string]string          `json:"secrets,omitempty"`
+	Vars           map[string]any             `json:"vars,omitempty"`
+	ResumeEnabled  *bool                      `json:"resume_enabled,omitempty"`
+	RBACExpression *string                    `json:"rbac_expression,omitempty"`
+	Schedule       *storage.PipelineSchedule  `json:"schedule,omitempty"`
+	MonthlyBudget  *float64                   `json:"monthly_budget,omitempty"`
+	Retention      *storage.PipelineRetention `json:"retention,omitempty"`
 }
 
 func (c *SetPipeline) Run(logger *slog.Logger) error {
@@ -65,14 +80,24 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 
 	var contentType string
 
+	vars, err := c.parseVars()
+	if err != nil {
+		return err
+	}
+
 	switch ext {
 	case ".yml", ".yaml":
 		// Validate YAML structure and semantics, but do NOT transpile.
 		// Transpilation happens lazily at pipeline trigger time so that
 		// the latest pipeline_runner.ts bundle is always used.
+		// Vars that are not given here may be secrets, which only the
+		// server can resolve, so they are left for it to check.
 		logger.Info("pipeline.validate")
 
-		if err := backwards.ValidatePipeline(content); err != nil {
+		var undefined *backwards.UndefinedVarsError
+
+		err = backwards.ValidatePipelineWithVars(content, backwards.StaticVariables(vars))
+		if err != nil && !errors.As(err, &undefined) {
 			return fmt.Errorf("pipeline validation failed: %w", err)
 		}
 
@@ -104,6 +129,10 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 		return fmt.Errorf("unsupported file extension %q: expected .js, .ts, .yml, or .yaml", ext)
 	}
 
+	if len(vars) > 0 && contentType != "yaml" {
+		return fmt.Errorf("--var and --load-vars-from are only supported for YAML pipelines")
+	}
+
 	logger.Info("pipeline.validate.success")
 
 	// Parse secrets from --secret-file and --secret flags
@@ -124,6 +153,7 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 		DriverDSN:     c.Driver,
 		WebhookSecret: c.WebhookSecret,
 		Secrets:       secretsMap,
+		Vars:          vars,
 		ResumeEnabled: &c.Resume,
 	}
 
@@ -131,6 +161,22 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 		reqBody.RBACExpression = &c.RBAC
 	}
 
+	// The schedule is always sent so that omitting --schedule removes it.
+	reqBody.Schedule = &storage.PipelineSchedule{
+		Cron:     c.Schedule,
+		Timezone: c.ScheduleTimezone,
+		CatchUp:  c.ScheduleCatchUp,
+	}
+
+	// Likewise, omitting --monthly-budget removes the budget.
+	reqBody.MonthlyBudget = &c.MonthlyBudget
+
+	// And omitting --retain-runs and --retain-days falls back to the server's retention.
+	reqBody.Retention = &storage.PipelineRetention{
+		Runs: c.RetainRuns,
+		Days: c.RetainDays,
+	}
+
 	client := resty.New()
 
 	// Extract basic auth from URL if present and strip it from the endpoint.
@@ -184,11 +230,16 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 	logger.Info("pipeline.upload.success",
 		"id", pipeline.ID,
 		"name", pipeline.Name,
+		"version", pipeline.Version,
 	)
 
 	fmt.Printf("Pipeline '%s' uploaded successfully!\n", pipeline.Name)
 	fmt.Printf("  ID: %s\n", pipeline.ID)
 
+	if pipeline.Version > 0 {
+		fmt.Printf("  Version: %d\n", pipeline.Version)
+	}
+
 	displayURL := c.ServerURL
 	if parsed, err := url.Parse(c.ServerURL); err == nil && parsed.User != nil {
 		parsed.User = nil
@@ -205,6 +256,10 @@ func (c *SetPipeline) Run(logger *slog.Logger) error {
 		fmt.Printf("  Secrets: %d key(s) set\n", len(secretsMap))
 	}
 
+	if len(vars) > 0 {
+		fmt.Printf("  Vars: %d key(s) set\n", len(vars))
+	}
+
 	if c.WebhookSecret != "" {
 		fmt.Printf("  Webhook URL: %s/api/webhooks/%s\n", displayURL, pipeline.ID)
 	}
@@ -275,3 +330,40 @@ func parseSecretFlag(s string) (string, string, bool) {
 
 	return key, value, true
 }
+
+// parseVars merges vars from --load-vars-from files, in order, and --var
+// flags. Flag values take precedence over file values on key collision.
+func (c *SetPipeline) parseVars() (map[string]any, error) {
+	result := make(map[string]any)
+
+	for _, path := range c.LoadVarsFrom {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read vars file: %w", err)
+		}
+
+		var fileVars map[string]any
+
+		err = yaml.Unmarshal(contents, &fileVars)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vars file %q: %w", path, err)
+		}
+
+		maps.Copy(result, fileVars)
+	}
+
+	for _, v := range c.Var {
+		key, value, found := parseSecretFlag(v)
+		if !found {
+			return nil, fmt.Errorf("invalid --var flag %q: expected KEY=VALUE format", v)
+		}
+
+		result[key] = value
+	}
+
+	if len(result) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	return result, nil
+}
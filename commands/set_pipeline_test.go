@@ -365,6 +365,60 @@ jobs:
 		assert.Expect(err.Error()).To(ContainSubstring("pipeline template parse failed"))
 	})
 
+	t.Run("sends vars for ((var)) references in YAML", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		secretsManager, err := secrets.GetFromDSN("sqlite://:memory:?key=test-key", slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = secretsManager.Close() })
+
+		client, ts := newTestServer(t, server.RouterOptions{SecretsManager: secretsManager})
+
+		dir := t.TempDir()
+		pipelineFile := writePipeline(t, dir, "vars.yml", `
+jobs:
+  - name: ((job))
+    max_in_flight: ((parallel))
+    plan:
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source: { repository: ((image)) }
+          run:
+            path: echo
+            args: ["((greeting))"]
+`)
+
+		cmd := commands.SetPipeline{
+			Pipeline:  pipelineFile,
+			ServerURL: ts.URL,
+		}
+
+		err = cmd.Run(slog.Default())
+		assert.Expect(err).To(MatchError(ContainSubstring("pipeline references undefined vars ((greeting)), ((image)), ((job)), ((parallel))")))
+
+		cmd.LoadVarsFrom = []string{writePipeline(t, dir, "vars-file.yml", "job: build\nparallel: 2\nimage: alpine\ngreeting: hi\n")}
+		cmd.Var = []string{"greeting=hello"}
+
+		err = cmd.Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		result, err := client.SearchPipelines(context.Background(), "", 1, 100)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Items).To(HaveLen(1))
+
+		stored, err := secretsManager.Get(context.Background(), secrets.PipelineScope(result.Items[0].ID), "pipeline_vars")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(stored).To(MatchJSON(`{"job":"build","parallel":2,"image":"alpine","greeting":"hello"}`))
+
+		cmd.Var = []string{"invalid"}
+		err = cmd.Run(slog.Default())
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid --var flag "invalid"`)))
+	})
+
 	t.Run("credentials are redacted from the server URL in output", func(t *testing.T) {
 		// Not parallel — captures os.Stdout, which is not goroutine-safe.
 		assert := NewGomegaWithT(t)
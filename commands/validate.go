@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jtarchie/pocketci/backwards"
+	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/runtime"
+)
+
+// Validate is the `pocketci validate` command. It lints pipeline files offline,
+// without a server or driver, and reports every issue with its position.
+type Validate struct {
+	Pipelines []string `arg:""         help:"Paths to pipeline files (JS, TS, or YAML)"                                type:"existingfile"`
+	Driver    string   `env:"CI_DRIVER" help:"Orchestrator driver DSN the pipelines will run on (e.g., 'docker', 'native')" short:"d"`
+	Format    string   `default:"text"  enum:"text,json"                                                                 help:"Output format (text, json)"`
+
+	// Stdout receives the report. It defaults to the process's stdout.
+	Stdout io.Writer `kong:"-"`
+}
+
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// ValidationResult is the outcome of validating a single pipeline file.
+type ValidationResult struct {
+	File   string            `json:"file"`
+	Valid  bool              `json:"valid"`
+	Issues []backwards.Issue `json:"issues"`
+}
+
+func (c *Validate) Run(logger *slog.Logger) error {
+	logger = logger.WithGroup("validate")
+
+	driverIssues := c.driverIssues()
+
+	results := make([]ValidationResult, 0, len(c.Pipelines))
+	invalid := 0
+
+	for _, pipelinePath := range c.Pipelines {
+		logger.Debug("pipeline.validate", "pipeline", pipelinePath)
+
+		issues := append(slices.Clone(driverIssues), validatePipelineFile(pipelinePath)...)
+		if issues == nil {
+			issues = []backwards.Issue{}
+		}
+
+		if len(issues) > 0 {
+			invalid++
+		}
+
+		results = append(results, ValidationResult{
+			File:   pipelinePath,
+			Valid:  len(issues) == 0,
+			Issues: issues,
+		})
+	}
+
+	stdout := c.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	err := c.report(stdout, results)
+	if err != nil {
+		return fmt.Errorf("could not write report: %w", err)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d of %d pipeline(s) have issues", ErrInvalidPipeline, invalid, len(results))
+	}
+
+	return nil
+}
+
+// driverIssues checks that the --driver DSN names a registered driver.
+func (c *Validate) driverIssues() []backwards.Issue {
+	if c.Driver == "" {
+		return nil
+	}
+
+	config, err := orchestra.ParseDriverDSN(c.Driver)
+	if err != nil {
+		return []backwards.Issue{{Message: err.Error()}}
+	}
+
+	if _, found := orchestra.Get(config.Name); found {
+		return nil
+	}
+
+	drivers := orchestra.ListDrivers()
+	slices.Sort(drivers)
+
+	return []backwards.Issue{{
+		Message: fmt.Sprintf("unknown driver %q, expected one of: %s", config.Name, strings.Join(drivers, ", ")),
+	}}
+}
+
+func validatePipelineFile(pipelinePath string) []backwards.Issue {
+	content, err := os.ReadFile(pipelinePath)
+	if err != nil {
+		return []backwards.Issue{{Message: fmt.Sprintf("could not read pipeline: %s", err)}}
+	}
+
+	switch ext := filepath.Ext(pipelinePath); ext {
+	case ".yml", ".yaml":
+		issues := backwards.Lint(content)
+		if len(issues) > 0 {
+			return issues
+		}
+
+		_, err = backwards.NewPipelineFromContent(string(content))
+		if err != nil {
+			return []backwards.Issue{{Message: err.Error()}}
+		}
+
+		return nil
+
+	case ".ts", ".js":
+		_, err = runtime.TranspileAndValidate(string(content))
+		if err == nil {
+			return nil
+		}
+
+		var syntaxErr *runtime.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return []backwards.Issue{{Line: syntaxErr.Line, Column: syntaxErr.Column, Message: syntaxErr.Error()}}
+		}
+
+		return []backwards.Issue{{Message: err.Error()}}
+
+	default:
+		return []backwards.Issue{{
+			Message: fmt.Sprintf("unsupported file extension %q: expected .js, .ts, .yml, or .yaml", ext),
+		}}
+	}
+}
+
+func (c *Validate) report(w io.Writer, results []ValidationResult) error {
+	if c.Format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(results)
+	}
+
+	for _, result := range results {
+		if result.Valid {
+			_, err := fmt.Fprintf(w, "%s: ok\n", result.File)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, issue := range result.Issues {
+			position := result.File
+			if issue.Line > 0 {
+				position = fmt.Sprintf("%s:%d:%d", result.File, issue.Line, issue.Column)
+			}
+
+			_, err := fmt.Fprintf(w, "%s: %s\n", position, issue.Message)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
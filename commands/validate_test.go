@@ -0,0 +1,94 @@
+package commands_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jtarchie/pocketci/commands"
+	. "github.com/onsi/gomega"
+)
+
+const undefinedResourceYAML = `
+jobs:
+  - name: deploy
+    plan:
+      - get: repo
+      - task: ship
+        config:
+          platform: linux
+          run:
+            path: echo
+`
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports valid pipelines", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		dir := t.TempDir()
+		stdout := &bytes.Buffer{}
+		validate := commands.Validate{
+			Pipelines: []string{
+				writePipeline(t, dir, "pipeline.yml", passingYAML),
+				writePipeline(t, dir, "pipeline.js", minimalJS),
+			},
+			Driver: "native",
+			Format: "text",
+			Stdout: stdout,
+		}
+
+		err := validate.Run(slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(stdout.String()).To(ContainSubstring("pipeline.yml: ok"))
+		assert.Expect(stdout.String()).To(ContainSubstring("pipeline.js: ok"))
+	})
+
+	t.Run("reports issues with file and line", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		path := writePipeline(t, t.TempDir(), "pipeline.yml", undefinedResourceYAML)
+		stdout := &bytes.Buffer{}
+		validate := commands.Validate{
+			Pipelines: []string{path},
+			Format:    "text",
+			Stdout:    stdout,
+		}
+
+		err := validate.Run(slog.Default())
+		assert.Expect(err).To(MatchError(commands.ErrInvalidPipeline))
+		assert.Expect(stdout.String()).To(Equal(path + `:5:14: job "deploy" gets undefined resource "repo"` + "\n"))
+	})
+
+	t.Run("reports JSON with syntax error positions and unknown drivers", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		path := writePipeline(t, t.TempDir(), "pipeline.ts", "const pipeline = async () => {\n  const = 1;\n};\nexport { pipeline };\n")
+		stdout := &bytes.Buffer{}
+		validate := commands.Validate{
+			Pipelines: []string{path},
+			Driver:    "nonexistent://namespace",
+			Format:    "json",
+			Stdout:    stdout,
+		}
+
+		err := validate.Run(slog.Default())
+		assert.Expect(err).To(MatchError(commands.ErrInvalidPipeline))
+
+		var results []commands.ValidationResult
+		err = json.Unmarshal(stdout.Bytes(), &results)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(results).To(HaveLen(1))
+		assert.Expect(results[0].File).To(Equal(path))
+		assert.Expect(results[0].Valid).To(BeFalse())
+		assert.Expect(results[0].Issues).To(HaveLen(2))
+		assert.Expect(results[0].Issues[0].Message).To(ContainSubstring(`unknown driver "nonexistent"`))
+		assert.Expect(results[0].Issues[1].Line).To(Equal(2))
+		assert.Expect(results[0].Issues[1].Message).To(HavePrefix("syntax error:"))
+	})
+}
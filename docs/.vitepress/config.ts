@@ -23,6 +23,8 @@ export default defineConfig({
         { text: "Overview", link: "/guides/" },
         { text: "Run Pipelines", link: "run" },
         { text: "Webhooks", link: "webhooks" },
+        { text: "Schedules", link: "schedules" },
+        { text: "Vars", link: "vars" },
         { text: "MCP", link: "mcp" },
       ],
       "/operations/": [
@@ -30,6 +32,7 @@ export default defineConfig({
         { text: "Authentication", link: "authentication" },
         { text: "Authorization (RBAC)", link: "rbac" },
         { text: "Storage", link: "storage" },
+        { text: "Retention", link: "retention" },
         { text: "Secrets", link: "secrets" },
         { text: "Caching", link: "caching" },
         { text: "Feature Gates", link: "feature-gates" },
@@ -39,9 +42,11 @@ export default defineConfig({
         { text: "Runner", link: "runner" },
         { text: "Server", link: "server" },
         { text: "Login", link: "login" },
+        { text: "Validate", link: "validate" },
         { text: "Set Pipeline", link: "set-pipeline" },
         { text: "Run", link: "run" },
         { text: "Delete Pipeline", link: "delete-pipeline" },
+        { text: "DB", link: "db" },
       ],
       "/drivers/": [
         { text: "Overview", link: "/drivers/" },
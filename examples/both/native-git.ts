@@ -27,35 +27,51 @@ const pipeline = async () => {
     console.log(`Found ${checkResult.versions.length} version(s)`);
 
     if (checkResult.versions.length > 0) {
-      const latestVersion = checkResult.versions[0];
+      const latestVersion =
+        checkResult.versions[checkResult.versions.length - 1];
       console.log(`Latest version: ${latestVersion.ref}`);
 
       // Create a volume for the git checkout
       const volume = await runtime.createVolume({ name: "git-checkout" });
 
-      // For a real implementation, you'd use the native In operation
-      // For now, we'll just verify we can run a container with the volume
-      const result = await runtime.run({
-        name: "verify-git",
-        image: "alpine/git:latest",
-        command: {
-          path: "git",
-          args: [
-            "clone",
-            "--branch",
-            "master",
-            "--depth",
-            "1",
-            "https://github.com/octocat/Hello-World.git",
-            "/workspace",
-          ],
-        },
-        mounts: {
-          "/workspace": volume,
-        },
-      });
+      if (pipelineContext.driverName === "native") {
+        // The native driver shares the host filesystem, so the resource can
+        // clone straight into the volume without a container.
+        const fetchResult = nativeResources.fetch({
+          type: "git",
+          source: {
+            uri: "https://github.com/octocat/Hello-World.git",
+            branch: "master",
+          },
+          version: latestVersion,
+          params: { depth: 1 },
+          destDir: volume.path,
+        });
+
+        assert.equal(fetchResult.version.ref, latestVersion.ref);
+      } else {
+        const result = await runtime.run({
+          name: "verify-git",
+          image: "alpine/git:latest",
+          command: {
+            path: "git",
+            args: [
+              "clone",
+              "--branch",
+              "master",
+              "--depth",
+              "1",
+              "https://github.com/octocat/Hello-World.git",
+              "/workspace",
+            ],
+          },
+          mounts: {
+            "/workspace": volume,
+          },
+        });
 
-      assert.equal(result.code, 0, "Git clone should succeed");
+        assert.equal(result.code, 0, "Git clone should succeed");
+      }
 
       // Verify the clone worked
       const verifyResult = await runtime.run({
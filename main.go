@@ -13,9 +13,15 @@ import (
 	_ "github.com/jtarchie/pocketci/orchestra/k8s"
 	_ "github.com/jtarchie/pocketci/orchestra/native"
 	_ "github.com/jtarchie/pocketci/orchestra/qemu"
+	_ "github.com/jtarchie/pocketci/resources/git"
 	_ "github.com/jtarchie/pocketci/resources/mock"
+	_ "github.com/jtarchie/pocketci/resources/registryimage"
+	_ "github.com/jtarchie/pocketci/resources/s3"
+	_ "github.com/jtarchie/pocketci/resources/semver"
+	_ "github.com/jtarchie/pocketci/resources/time"
 	_ "github.com/jtarchie/pocketci/secrets/s3"
 	_ "github.com/jtarchie/pocketci/secrets/sqlite"
+	_ "github.com/jtarchie/pocketci/storage/postgres"
 	_ "github.com/jtarchie/pocketci/storage/s3"
 	_ "github.com/jtarchie/pocketci/storage/sqlite"
 	_ "github.com/jtarchie/pocketci/webhooks/generic"
@@ -27,11 +33,14 @@ import (
 
 type CLI struct {
 	Run            commands.Run            `cmd:"" help:"Run a stored pipeline by name on a server"`
+	Runner         commands.Runner         `cmd:"" help:"Execute a pipeline file locally without a server"`
 	Resource       commands.Resource       `cmd:"" help:"Execute a native resource operation"`
 	Server         commands.Server         `cmd:"" help:"Run a server"`
 	SetPipeline    commands.SetPipeline    `cmd:"" help:"Upload a pipeline to the server"  name:"set-pipeline"`
+	Validate       commands.Validate       `cmd:"" help:"Validate pipeline files without a server"`
 	DeletePipeline commands.DeletePipeline `cmd:"" help:"Delete a pipeline from the server" name:"delete-pipeline"`
 	Login          commands.Login          `cmd:"" help:"Authenticate with a CI server via browser-based OAuth"`
+	DB             commands.DB             `cmd:"" help:"Manage the schema of the storage database" name:"db"`
 
 	LogLevel  slog.Level `default:"info"             env:"CI_LOG_LEVEL"   help:"Set the log level (debug, info, warn, error)"`
 	AddSource bool       `env:"CI_ADD_SOURCE"        help:"Add source code location to log messages"`
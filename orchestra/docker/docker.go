@@ -148,8 +148,9 @@ func (d *Docker) Name() string {
 
 // GetContainer finds and returns an existing container by its ID.
 // Returns ErrContainerNotFound if the container does not exist.
+// A running sandbox container is returned as a *Sandbox.
 func (d *Docker) GetContainer(ctx context.Context, containerID string) (orchestra.Container, error) {
-	_, err := d.client.ContainerInspect(ctx, containerID)
+	inspect, err := d.client.ContainerInspect(ctx, containerID)
 	if err != nil {
 		if errdefs.IsNotFound(err) {
 			return nil, orchestra.ErrContainerNotFound
@@ -157,6 +158,17 @@ func (d *Docker) GetContainer(ctx context.Context, containerID string) (orchestr
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
+	if inspect.Config != nil && inspect.Config.Labels[sandboxLabel] == "true" &&
+		inspect.State != nil && inspect.State.Running {
+		d.logger.Debug("sandbox.reattached", "containerID", inspect.ID)
+
+		return &Sandbox{
+			id:   inspect.ID,
+			d:    d,
+			task: orchestra.Task{WorkDir: inspect.Config.WorkingDir},
+		}, nil
+	}
+
 	return &Container{
 		id:     containerID,
 		client: d.client,
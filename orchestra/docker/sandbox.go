@@ -13,6 +13,10 @@ import (
 	"github.com/jtarchie/pocketci/orchestra"
 )
 
+// sandboxLabel marks the containers started by StartSandbox, so that
+// GetContainer can return them as sandboxes.
+const sandboxLabel = "orchestra.sandbox"
+
 // Sandbox is a long-lived Docker container kept alive with "tail -f /dev/null".
 // Commands are dispatched via ContainerExecCreate / ContainerExecAttach.
 type Sandbox struct {
@@ -21,7 +25,10 @@ type Sandbox struct {
 	task orchestra.Task
 }
 
-var _ orchestra.Sandbox = (*Sandbox)(nil)
+var (
+	_ orchestra.Sandbox   = (*Sandbox)(nil)
+	_ orchestra.Container = (*Sandbox)(nil)
+)
 
 // ID returns the Docker container ID.
 func (s *Sandbox) ID() string {
@@ -94,6 +101,25 @@ func (s *Sandbox) Exec(
 	}, nil
 }
 
+// Status returns the state of the sandbox container.
+func (s *Sandbox) Status(ctx context.Context) (orchestra.ContainerStatus, error) {
+	return s.container().Status(ctx)
+}
+
+// Logs returns the output of the sandbox container's idle process. The
+// output of each Exec call goes to that call's writers instead.
+func (s *Sandbox) Logs(ctx context.Context, stdout, stderr io.Writer, follow bool) error {
+	return s.container().Logs(ctx, stdout, stderr, follow)
+}
+
+func (s *Sandbox) container() *Container {
+	return &Container{
+		id:     s.id,
+		client: s.d.client,
+		task:   s.task,
+	}
+}
+
 // Cleanup removes the sandbox container forcibly.
 func (s *Sandbox) Cleanup(ctx context.Context) error {
 	err := s.d.client.ContainerRemove(ctx, s.id, container.RemoveOptions{Force: true})
@@ -168,6 +194,7 @@ func (d *Docker) StartSandbox(ctx context.Context, task orchestra.Task) (orchest
 			User:       task.User,
 			Labels: map[string]string{
 				"orchestra.namespace": d.namespace,
+				sandboxLabel:          "true",
 			},
 		},
 		&container.HostConfig{
@@ -522,6 +522,54 @@ func TestSandboxDrivers(t *testing.T) {
 				err = sandbox.Cleanup(context.Background())
 				assert.Expect(err).NotTo(HaveOccurred())
 			})
+
+			t.Run("reattaches to a running sandbox", func(t *testing.T) {
+				t.Parallel()
+
+				assert := NewGomegaWithT(t)
+
+				if name != "docker" && name != "native" {
+					t.Skipf("driver %q does not reattach to sandboxes", name)
+				}
+
+				sandboxDriver := newSandboxDriver(t)
+				driver, _ := sandboxDriver.(orchestra.Driver)
+
+				task := orchestra.Task{
+					ID:    gonanoid.Must(),
+					Image: "busybox",
+				}
+
+				sandbox, err := sandboxDriver.StartSandbox(context.Background(), task)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				stdout, stderr := &strings.Builder{}, &strings.Builder{}
+				status, err := sandbox.Exec(context.Background(),
+					[]string{"sh", "-c", "echo kept > reattach.txt"},
+					nil, "", nil, stdout, stderr)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(status.ExitCode()).To(Equal(0))
+
+				container, err := driver.GetContainer(context.Background(), sandbox.ID())
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(container).To(BeAssignableToTypeOf(sandbox))
+
+				reattached, _ := container.(orchestra.Sandbox)
+
+				stdout.Reset()
+				status, err = reattached.Exec(context.Background(),
+					[]string{"cat", "reattach.txt"},
+					nil, "", nil, stdout, stderr)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(status.ExitCode()).To(Equal(0))
+				assert.Expect(stdout.String()).To(ContainSubstring("kept"))
+
+				err = reattached.Cleanup(context.Background())
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				_, err = driver.GetContainer(context.Background(), sandbox.ID())
+				assert.Expect(err).To(MatchError(orchestra.ErrContainerNotFound))
+			})
 		})
 	})
 }
@@ -44,9 +44,15 @@ func (n *Native) Name() string {
 
 // GetContainer attempts to find an existing container.
 // Native driver does not support container reattachment since processes are not persistent.
-// Always returns ErrContainerNotFound.
-func (n *Native) GetContainer(_ context.Context, _ string) (orchestra.Container, error) {
-	return nil, orchestra.ErrContainerNotFound
+// Only sandboxes, whose working directory outlives the process, are returned;
+// any other ID returns ErrContainerNotFound.
+func (n *Native) GetContainer(_ context.Context, containerID string) (orchestra.Container, error) {
+	sandbox, ok := n.getSandbox(containerID)
+	if !ok {
+		return nil, orchestra.ErrContainerNotFound
+	}
+
+	return sandbox, nil
 }
 
 func init() {
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/jtarchie/pocketci/orchestra"
 )
@@ -21,7 +22,10 @@ type NativeSandbox struct {
 	n       *Native
 }
 
-var _ orchestra.Sandbox = (*NativeSandbox)(nil)
+var (
+	_ orchestra.Sandbox   = (*NativeSandbox)(nil)
+	_ orchestra.Container = (*NativeSandbox)(nil)
+)
 
 // ID returns the sandbox working directory path as its identifier.
 func (s *NativeSandbox) ID() string {
@@ -89,6 +93,18 @@ func (s *NativeSandbox) Exec(
 	}, nil
 }
 
+// Status reports the sandbox as running, since it has no idle process to
+// finish.
+func (s *NativeSandbox) Status(_ context.Context) (orchestra.ContainerStatus, error) {
+	return &Status{}, nil
+}
+
+// Logs writes nothing. The output of each Exec call goes to that call's
+// writers instead.
+func (s *NativeSandbox) Logs(_ context.Context, _, _ io.Writer, _ bool) error {
+	return nil
+}
+
 // Cleanup removes the sandbox working directory.
 func (s *NativeSandbox) Cleanup(_ context.Context) error {
 	err := os.RemoveAll(s.dir)
@@ -138,3 +154,22 @@ func (n *Native) StartSandbox(ctx context.Context, task orchestra.Task) (orchest
 		n:       n,
 	}, nil
 }
+
+// getSandbox reuses the working directory of a sandbox started by an earlier
+// driver in the same namespace, if that directory still exists. The sandbox's
+// environment is not kept, so callers pass it to each Exec call.
+func (n *Native) getSandbox(sandboxID string) (*NativeSandbox, bool) {
+	if !filepath.IsAbs(sandboxID) || !strings.HasPrefix(filepath.Base(filepath.Dir(sandboxID)), n.namespace) {
+		return nil, false
+	}
+
+	info, err := os.Stat(sandboxID)
+	if err != nil || !info.IsDir() {
+		return nil, false
+	}
+
+	return &NativeSandbox{
+		dir: sandboxID,
+		n:   n,
+	}, true
+}
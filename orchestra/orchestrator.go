@@ -39,7 +39,9 @@ type Driver interface {
 	Name() string
 	RunContainer(ctx context.Context, task Task) (Container, error)
 	// GetContainer attempts to find and return an existing container by its ID.
-	// Returns ErrContainerNotFound if the container does not exist.
+	// Returns ErrContainerNotFound if the container does not exist. For the ID
+	// of a running sandbox, the returned Container also implements Sandbox, so
+	// that a later process can reattach to it.
 	GetContainer(ctx context.Context, containerID string) (Container, error)
 }
 
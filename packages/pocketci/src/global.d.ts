@@ -141,9 +141,15 @@ declare global {
     runID?: string;
     pipelineID?: string;
     driverName?: string;
+    /** How the run was started: "manual", "webhook", "resource", or "schedule". */
     triggeredBy?: string;
     /** Arguments passed from `ci run <name> [args...]` */
     args: string[];
+    /**
+     * Jobs to run for YAML pipelines. When non-empty, only these jobs and the
+     * jobs that depend on them (via `passed`) are run; the rest are skipped.
+     */
+    jobs?: string[];
   }
   /**
    * Metadata about the current run, injected by the runtime.
@@ -230,14 +236,17 @@ declare global {
     totalTokens: number;
     llmRequests: number;
     toolCallCount: number;
+    cost?: number; // USD, when the server has a price for the model
   }
 
   // Result returned by runtime.agent().
   interface AgentResult {
     text: string;
-    status: "success" | "failure" | "limit_exceeded";
+    status: "success" | "failure" | "limit_exceeded" | "invalid_output";
     usage: AgentUsage;
     auditLog: AuditEvent[];
+    // Parsed final answer, set when output_schema is given and it validates.
+    output?: unknown;
   }
 
   // Token counts for a single LLM event.
@@ -249,6 +258,8 @@ declare global {
 
   // A single entry in the agent audit log.
   // type values: "pre_context" | "user_message" | "tool_call" | "tool_response" | "model_text" | "model_final"
+  //   | "output_invalid" | "policy_denied" | "approval_requested" | "approval_decision" | "sub_agent"
+  //   | "resumed"
   interface AuditEvent {
     timestamp?: string;
     invocationId?: string;
@@ -260,6 +271,15 @@ declare global {
     toolArgs?: { [key: string]: unknown };
     toolResult?: { [key: string]: unknown };
     usage?: AuditUsage;
+    approval?: AuditApproval;
+    events?: AuditEvent[]; // a sub_agent event's own audit log
+  }
+
+  // Identifies the approval of an approval_requested or approval_decision event.
+  interface AuditApproval {
+    id: string;
+    decision?: "approved" | "rejected";
+    decidedBy?: string;
   }
 
   // Language model generation parameters for agent steps.
@@ -319,6 +339,99 @@ declare global {
     max_bytes?: number;
   }
 
+  // Runs an executable in the agent's sandbox. path and args are Go templates
+  // rendered with the tool's arguments, e.g. "{{ .package }}".
+  interface AgentToolCommand {
+    path: string;
+    args?: string[];
+    env?: { [key: string]: string };
+    work_dir?: string;
+    timeout?: string;
+  }
+
+  // A pipeline-defined tool offered to the model alongside the built-in ones.
+  // Exactly one of command or handler is required.
+  interface AgentTool {
+    /** Letters, digits, "_" and "-"; must not shadow a built-in tool. */
+    name: string;
+    description: string;
+    /** JSON schema of the arguments. Defaults to an empty object schema. */
+    parameters?: { [key: string]: unknown };
+    command?: AgentToolCommand;
+    /** Called with the model's arguments; may return a promise. */
+    handler?: (
+      args: { [key: string]: unknown },
+    ) => unknown | Promise<unknown>;
+  }
+
+  // Starts a stdio MCP server inside the agent's sandbox.
+  interface AgentMCPCommand {
+    path: string;
+    args?: string[];
+    /** Values may be "secret:<key>" references. */
+    env?: { [key: string]: string };
+    work_dir?: string;
+  }
+
+  // An MCP server whose tools are offered to the model. Exactly one of command
+  // or url is required.
+  interface AgentMCPServer {
+    name: string;
+    command?: AgentMCPCommand;
+    /** Streamable HTTP endpoint. */
+    url?: string;
+    /** Sent with every request; values may be "secret:<key>" references. */
+    headers?: { [key: string]: string };
+    /** Tool names or globs to offer; all tools when empty. */
+    allow?: string[];
+    /** Tool names or globs to hide; wins over allow. */
+    deny?: string[];
+  }
+
+  // Allows, denies, or requires human approval of tool calls. Rules are
+  // checked in order; the first match applies and unmatched calls are allowed.
+  interface AgentPolicyRule {
+    /** Tool name or glob, e.g. "run_*"; every tool when empty. */
+    tool?: string;
+    /** expr expression over tool, args, and command; always true when empty. */
+    when?: string;
+    action: "allow" | "deny" | "approve";
+    /** expr expression over the answering user, like pipeline RBAC. */
+    approvers?: string;
+    /** Shown to approvers, and to the model when a call is denied. */
+    reason?: string;
+  }
+
+  // An agent the coordinator delegates tasks to by calling it as a tool named
+  // name. Unset model, image and mounts fall back to the coordinator's.
+  interface AgentSubAgent {
+    name: string;
+    description?: string;
+    /** The sub-agent's role; the coordinator's task is appended to it. */
+    prompt: string;
+    model?: string;
+    image?: string;
+    mounts?: KnownMounts;
+    llm?: AgentLLMConfig;
+    thinking?: AgentThinkingConfig;
+    limits?: AgentLimitsConfig;
+    context?: AgentContext;
+    mcp_servers?: AgentMCPServer[];
+    output_schema?: { [key: string]: unknown };
+    sub_agents?: AgentSubAgent[];
+  }
+
+  // Notes an agent keeps for its pipeline across runs, through its remember
+  // and recall tools.
+  interface AgentMemoryConfig {
+    /** Go duration a note is kept; defaults to "720h". */
+    ttl?: string;
+    /** Oldest notes are forgotten first; defaults to 100. */
+    max_entries?: number;
+    /** Size limit of a note; defaults to 2048. */
+    max_bytes?: number;
+  }
+
   // Input to runtime.agent().
   interface AgentRunConfig {
     name: string;
@@ -336,6 +449,13 @@ declare global {
     context_guard?: AgentContextGuardConfig;
     limits?: AgentLimitsConfig;
     context?: AgentContext;
+    tools?: AgentTool[];
+    mcp_servers?: AgentMCPServer[];
+    // JSON schema the final answer must match; see AgentResult.output.
+    output_schema?: { [key: string]: unknown };
+    policy?: AgentPolicyRule[];
+    sub_agents?: AgentSubAgent[];
+    memory?: AgentMemoryConfig;
   }
 
   /**
@@ -611,6 +731,8 @@ declare global {
     source: { [key: string]: unknown };
     params?: { [key: string]: unknown };
     srcDir: string;
+    // Volume paths by name, exposed as srcDir when srcDir is empty.
+    mounts?: { [name: string]: string };
   }
 
   interface ResourcePushResult {
@@ -651,6 +773,8 @@ declare global {
     name: string;
     type: string;
     source: SourceConfig;
+    /** How often the server checks the resource (e.g. "5m", "never"). */
+    check_every?: string;
   }
 
   interface ImageResource {
@@ -756,12 +880,36 @@ declare global {
     context_guard?: AgentContextGuardConfig;
     limits?: AgentLimitsConfig;
     context?: AgentContext;
+    mcp_servers?: AgentMCPServer[];
+    output_schema?: { [key: string]: unknown }; // JSON schema of the final answer
+    policy?: AgentPolicyRule[]; // Allow, deny, or require approval of tool calls
+    sub_agents?: AgentSubAgent[]; // Agents delegated to as tools
+    memory?: AgentMemoryConfig; // Notes kept across runs
     attempts?: number;
     across?: AcrossVar[];
     fail_fast?: boolean;
   }
 
-  type Step = Task | Get | Put | Do | Try | InParallel | NotifyStep | AgentStep;
+  // Load var step: reads a file from an artifact into the local var ((.:name)).
+  interface LoadVarStep extends StepHooks {
+    load_var: string;
+    file: string; // Path as "artifact/relative/path"
+    format?: "json" | "yaml" | "yml" | "trim" | "raw"; // Defaults from the file extension, else trim
+    attempts?: number;
+    across?: AcrossVar[];
+    fail_fast?: boolean;
+  }
+
+  type Step =
+    | Task
+    | Get
+    | Put
+    | Do
+    | Try
+    | InParallel
+    | NotifyStep
+    | AgentStep
+    | LoadVarStep;
 
   // Pipeline configuration
   interface Job extends StepHooks {
@@ -785,7 +933,7 @@ declare global {
     };
     /**
      * Structured trigger configuration. Namespaces trigger types under a
-     * single field. Currently supports `webhook` triggers.
+     * single field. Supports `webhook` and `schedule` triggers.
      *
      * @example
      * triggers:
@@ -794,8 +942,23 @@ declare global {
      *     params:
      *       PR_NUMBER: 'string(payload.number)'
      *       PR_REPO: "'https://github.com/' + payload.pull_request.head.repo.full_name + '.git'"
+     *   schedule:
+     *     cron: "0 2 * * *"
+     *     timezone: America/New_York
      */
     triggers?: {
+      schedule?: {
+        /** Standard 5-field cron expression, or a descriptor such as `@daily`. */
+        cron: string;
+        /** IANA timezone the cron expression is evaluated in. Defaults to UTC. */
+        timezone?: string;
+        /**
+         * What to do with schedules missed while the server was down:
+         * `skip` waits for the next fire time, `run-once` runs the job once.
+         * Defaults to the server's `--schedule-catch-up`.
+         */
+        catch_up?: "skip" | "run-once";
+      };
       webhook?: {
         /** Boolean expr-lang expression. Same variables as `webhook_trigger`. */
         filter?: string;
@@ -0,0 +1,147 @@
+package resources
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Untar extracts a tar stream into dir. The os.Root rejects entries and links
+// that would escape it.
+func Untar(reader io.Reader, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", dir, err)
+	}
+	defer func() { _ = root.Close() }()
+
+	tr := tar.NewReader(reader)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		name := archivePath(header.Name)
+		if name == "." {
+			continue
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+
+		err = mkdirParent(root, name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = root.MkdirAll(name, mode)
+		case tar.TypeReg:
+			err = writeFile(root, name, tr, mode)
+		case tar.TypeSymlink:
+			err = root.Symlink(header.Linkname, name)
+		case tar.TypeLink:
+			err = root.Link(archivePath(header.Linkname), name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", header.Name, err)
+		}
+	}
+}
+
+// Unzip extracts the zip archive at archive into dir, rejecting entries that
+// would escape it.
+func Unzip(archive, dir string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", dir, err)
+	}
+	defer func() { _ = root.Close() }()
+
+	for _, entry := range reader.File {
+		name := archivePath(entry.Name)
+		if name == "." {
+			continue
+		}
+
+		err = mkdirParent(root, name)
+		if err != nil {
+			return err
+		}
+
+		if entry.FileInfo().IsDir() {
+			err = root.MkdirAll(name, 0o755)
+			if err != nil {
+				return fmt.Errorf("failed to extract %q: %w", entry.Name, err)
+			}
+
+			continue
+		}
+
+		contents, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %q in zip: %w", entry.Name, err)
+		}
+
+		err = writeFile(root, name, contents, entry.Mode().Perm())
+		_ = contents.Close()
+
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// archivePath cleans an archive entry name into a path relative to the root.
+func archivePath(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "/"))
+}
+
+func mkdirParent(root *os.Root, name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+
+	err := root.MkdirAll(parent, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", parent, err)
+	}
+
+	return nil
+}
+
+func writeFile(root *os.Root, name string, reader io.Reader, mode os.FileMode) error {
+	file, err := root.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = io.Copy(file, reader) //nolint: gosec
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
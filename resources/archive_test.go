@@ -0,0 +1,105 @@
+package resources_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtarchie/pocketci/resources"
+	. "github.com/onsi/gomega"
+)
+
+func TestArchive(t *testing.T) {
+	t.Run("Untar extracts files and directories", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		var buf bytes.Buffer
+
+		tw := tar.NewWriter(&buf)
+		assert.Expect(tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755})).To(Succeed())
+		assert.Expect(tw.WriteHeader(&tar.Header{Name: "dir/file.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})).To(Succeed())
+		_, err := tw.Write([]byte("hello"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(tw.Close()).To(Succeed())
+
+		dir := t.TempDir()
+		assert.Expect(resources.Untar(&buf, dir)).To(Succeed())
+
+		contents, err := os.ReadFile(filepath.Join(dir, "dir", "file.txt"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("hello"))
+	})
+
+	t.Run("Untar rejects entries escaping the directory", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		var buf bytes.Buffer
+
+		tw := tar.NewWriter(&buf)
+		assert.Expect(tw.WriteHeader(&tar.Header{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1})).To(Succeed())
+		_, err := tw.Write([]byte("x"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(tw.Close()).To(Succeed())
+
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "dest")
+		assert.Expect(os.Mkdir(dir, 0o755)).To(Succeed())
+
+		assert.Expect(resources.Untar(&buf, dir)).NotTo(Succeed())
+		assert.Expect(filepath.Join(parent, "escape.txt")).NotTo(BeAnExistingFile())
+	})
+
+	t.Run("Unzip extracts files", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		archive := filepath.Join(t.TempDir(), "archive.zip")
+
+		file, err := os.Create(archive)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		zw := zip.NewWriter(file)
+		writer, err := zw.Create("nested/file.txt")
+		assert.Expect(err).NotTo(HaveOccurred())
+		_, err = writer.Write([]byte("zipped"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(zw.Close()).To(Succeed())
+		assert.Expect(file.Close()).To(Succeed())
+
+		dir := t.TempDir()
+		assert.Expect(resources.Unzip(archive, dir)).To(Succeed())
+
+		contents, err := os.ReadFile(filepath.Join(dir, "nested", "file.txt"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("zipped"))
+	})
+}
+
+func TestParams(t *testing.T) {
+	t.Run("Decode accepts string and bool params", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		var params struct {
+			Unpack resources.BoolParam `json:"unpack"`
+			Skip   resources.BoolParam `json:"skip"`
+		}
+
+		err := resources.Decode(map[string]any{"unpack": "true", "skip": true}, &params)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(bool(params.Unpack)).To(BeTrue())
+		assert.Expect(bool(params.Skip)).To(BeTrue())
+	})
+
+	t.Run("Decode rejects invalid booleans", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		var params struct {
+			Unpack resources.BoolParam `json:"unpack"`
+		}
+
+		err := resources.Decode(map[string]any{"unpack": "maybe"}, &params)
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid boolean "maybe"`)))
+	})
+}
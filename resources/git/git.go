@@ -0,0 +1,521 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtarchie/pocketci/resources"
+)
+
+// maxPushAttempts bounds how many times Out will rebase and retry a rejected push.
+const maxPushAttempts = 3
+
+// Git implements the git resource by shelling out to the git CLI.
+// Versions are commits on a single branch, represented as {"ref": "<sha>"}.
+type Git struct{}
+
+// Source is the configuration accepted in a resource's source block.
+type Source struct {
+	URI         string   `json:"uri"`
+	Branch      string   `json:"branch,omitempty"`
+	Paths       []string `json:"paths,omitempty"`
+	IgnorePaths []string `json:"ignore_paths,omitempty"`
+	PrivateKey  string   `json:"private_key,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+}
+
+// InParams are the params accepted by a get step.
+type InParams struct {
+	Depth      int `json:"depth,omitempty"`
+	Submodules any `json:"submodules,omitempty"`
+}
+
+// OutParams are the params accepted by a put step.
+type OutParams struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	TagPrefix  string `json:"tag_prefix,omitempty"`
+	Annotate   string `json:"annotate,omitempty"`
+	Rebase     bool   `json:"rebase,omitempty"`
+	Force      bool   `json:"force,omitempty"`
+}
+
+func (g *Git) Name() string {
+	return "git"
+}
+
+// Check lists commits on the configured branch.
+// Without a version only the latest matching commit is returned. With a version,
+// the given ref (if it still exists) and every matching commit after it are returned, oldest first.
+func (g *Git) Check(ctx context.Context, req resources.CheckRequest) (resources.CheckResponse, error) {
+	source, err := parseSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, cleanup, err := newCommand(source)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	repoDir, err := os.MkdirTemp("", "git-check-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	defer func() {
+		_ = os.RemoveAll(repoDir)
+	}()
+
+	args := []string{"clone", "--quiet", "--bare", "--single-branch"}
+	if source.Branch != "" {
+		args = append(args, "--branch", source.Branch)
+	}
+
+	args = append(args, cmd.remote, repoDir)
+
+	_, err = cmd.run(ctx, "", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone: %w", err)
+	}
+
+	pathspec := source.pathspec()
+
+	ref := req.Version["ref"]
+	if ref != "" {
+		_, err = cmd.run(ctx, repoDir, "cat-file", "-e", ref+"^{commit}")
+		if err != nil {
+			// the ref no longer exists (e.g. force push), fall back to the latest commit
+			ref = ""
+		}
+	}
+
+	if ref == "" {
+		output, err := cmd.run(ctx, repoDir, append([]string{"log", "--format=%H", "-1", "HEAD", "--"}, pathspec...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits: %w", err)
+		}
+
+		if output == "" {
+			return resources.CheckResponse{}, nil
+		}
+
+		return resources.CheckResponse{{"ref": output}}, nil
+	}
+
+	output, err := cmd.run(ctx, repoDir, append([]string{"log", "--format=%H", "--reverse", ref + "..HEAD", "--"}, pathspec...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	versions := resources.CheckResponse{{"ref": ref}}
+
+	for _, sha := range strings.Fields(output) {
+		versions = append(versions, resources.Version{"ref": sha})
+	}
+
+	return versions, nil
+}
+
+// In clones the repository into destDir and checks out the requested ref.
+func (g *Git) In(ctx context.Context, destDir string, req resources.InRequest) (resources.InResponse, error) {
+	ref := req.Version["ref"]
+	if ref == "" {
+		return resources.InResponse{}, errors.New("version ref is required")
+	}
+
+	source, err := parseSource(req.Source)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	var params InParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	cmd, cleanup, err := newCommand(source)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+	defer cleanup()
+
+	args := []string{"clone", "--quiet"}
+	if source.Branch != "" {
+		args = append(args, "--single-branch", "--branch", source.Branch)
+	}
+
+	if params.Depth > 0 {
+		args = append(args, "--depth", fmt.Sprintf("%d", params.Depth))
+	}
+
+	args = append(args, cmd.remote, destDir)
+
+	_, err = cmd.run(ctx, "", args...)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to clone: %w", err)
+	}
+
+	// never leave credentials behind in the checked out repository
+	_, err = cmd.run(ctx, destDir, "remote", "set-url", "origin", source.URI)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to reset remote: %w", err)
+	}
+
+	_, err = cmd.run(ctx, destDir, "checkout", "--quiet", ref)
+	if err != nil && params.Depth > 0 {
+		// the ref is older than the shallow clone, fetch the full history and retry
+		_, err = cmd.run(ctx, destDir, "fetch", "--quiet", "--unshallow", cmd.remote)
+		if err != nil {
+			return resources.InResponse{}, fmt.Errorf("failed to unshallow: %w", err)
+		}
+
+		_, err = cmd.run(ctx, destDir, "checkout", "--quiet", ref)
+	}
+
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to checkout %q: %w", ref, err)
+	}
+
+	err = updateSubmodules(ctx, cmd, destDir, params.Submodules)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	err = os.WriteFile(filepath.Join(destDir, ".git", "ref"), []byte(ref+"\n"), 0o600)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to write ref file: %w", err)
+	}
+
+	metadata, err := commitMetadata(ctx, cmd, destDir, ref)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	if source.Branch != "" {
+		metadata = append(metadata, resources.MetadataField{Name: "branch", Value: source.Branch})
+	}
+
+	return resources.InResponse{
+		Version:  resources.Version{"ref": ref},
+		Metadata: metadata,
+	}, nil
+}
+
+// Out pushes the HEAD of a repository directory to the configured branch,
+// optionally tagging it and rebasing on top of the remote when the push is rejected.
+func (g *Git) Out(ctx context.Context, srcDir string, req resources.OutRequest) (resources.OutResponse, error) {
+	source, err := parseSource(req.Source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	if source.Branch == "" {
+		return resources.OutResponse{}, errors.New("source branch is required for put")
+	}
+
+	var params OutParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if params.Repository == "" {
+		return resources.OutResponse{}, errors.New("params repository is required")
+	}
+
+	cmd, cleanup, err := newCommand(source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+	defer cleanup()
+
+	repoDir := filepath.Join(srcDir, params.Repository)
+
+	// tags and rebases need a committer identity, supply one if the repository has none
+	_, err = cmd.run(ctx, repoDir, "config", "user.email")
+	if err != nil {
+		cmd.env = append(cmd.env,
+			"GIT_COMMITTER_NAME=pocketci",
+			"GIT_COMMITTER_EMAIL=pocketci@localhost",
+		)
+	}
+
+	tag := ""
+
+	if params.Tag != "" {
+		contents, err := os.ReadFile(filepath.Join(srcDir, params.Tag))
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("failed to read tag file: %w", err)
+		}
+
+		tag = params.TagPrefix + strings.TrimSpace(string(contents))
+	}
+
+	refspecs := []string{"HEAD:refs/heads/" + source.Branch}
+	if tag != "" {
+		refspecs = append(refspecs, "refs/tags/"+tag)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if tag != "" {
+			err = createTag(ctx, cmd, srcDir, repoDir, tag, params.Annotate)
+			if err != nil {
+				return resources.OutResponse{}, err
+			}
+		}
+
+		args := []string{"push", "--quiet"}
+		if params.Force {
+			args = append(args, "--force")
+		}
+
+		args = append(args, cmd.remote)
+
+		_, err = cmd.run(ctx, repoDir, append(args, refspecs...)...)
+		if err == nil {
+			break
+		}
+
+		if !params.Rebase || attempt >= maxPushAttempts {
+			return resources.OutResponse{}, fmt.Errorf("failed to push: %w", err)
+		}
+
+		_, err = cmd.run(ctx, repoDir, "fetch", "--quiet", cmd.remote, "refs/heads/"+source.Branch)
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("failed to fetch for rebase: %w", err)
+		}
+
+		_, err = cmd.run(ctx, repoDir, "rebase", "--quiet", "FETCH_HEAD")
+		if err != nil {
+			_, _ = cmd.run(ctx, repoDir, "rebase", "--abort")
+
+			return resources.OutResponse{}, fmt.Errorf("failed to rebase: %w", err)
+		}
+	}
+
+	ref, err := cmd.run(ctx, repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	metadata, err := commitMetadata(ctx, cmd, repoDir, ref)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	metadata = append(metadata, resources.MetadataField{Name: "branch", Value: source.Branch})
+	if tag != "" {
+		metadata = append(metadata, resources.MetadataField{Name: "tag", Value: tag})
+	}
+
+	return resources.OutResponse{
+		Version:  resources.Version{"ref": ref},
+		Metadata: metadata,
+	}, nil
+}
+
+// command runs git with a fixed environment and an authenticated remote URL.
+type command struct {
+	env    []string
+	remote string
+}
+
+// newCommand prepares the environment needed to talk to the source's remote.
+// The returned cleanup func removes any temporary key material.
+func newCommand(source Source) (*command, func(), error) {
+	cmd := &command{
+		env:    append(os.Environ(), "GIT_TERMINAL_PROMPT=0"),
+		remote: source.URI,
+	}
+	cleanup := func() {}
+
+	if source.Username != "" || source.Password != "" {
+		remote, err := url.Parse(source.URI)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse uri: %w", err)
+		}
+
+		if remote.Scheme == "http" || remote.Scheme == "https" {
+			remote.User = url.UserPassword(source.Username, source.Password)
+			cmd.remote = remote.String()
+		}
+	}
+
+	if source.PrivateKey != "" {
+		keyFile, err := os.CreateTemp("", "git-key-*")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create key file: %w", err)
+		}
+
+		cleanup = func() {
+			_ = os.Remove(keyFile.Name())
+		}
+
+		privateKey := strings.TrimSpace(source.PrivateKey) + "\n"
+
+		_, err = keyFile.WriteString(privateKey)
+		if closeErr := keyFile.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			cleanup()
+
+			return nil, nil, fmt.Errorf("failed to write key file: %w", err)
+		}
+
+		cmd.env = append(cmd.env, "GIT_SSH_COMMAND=ssh -i "+keyFile.Name()+" -o IdentitiesOnly=yes -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null")
+	}
+
+	return cmd, cleanup, nil
+}
+
+// run executes git in dir and returns its trimmed stdout.
+func (c *command) run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = c.env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		// avoid leaking credentials embedded in the remote URL
+		message := strings.ReplaceAll(strings.TrimSpace(stderr.String()), c.remote, "<remote>")
+
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, message)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// pathspec converts paths and ignore_paths into git pathspec arguments.
+func (s Source) pathspec() []string {
+	if len(s.Paths) == 0 && len(s.IgnorePaths) == 0 {
+		return nil
+	}
+
+	pathspec := append([]string{}, s.Paths...)
+	if len(pathspec) == 0 {
+		pathspec = append(pathspec, ":/")
+	}
+
+	for _, path := range s.IgnorePaths {
+		pathspec = append(pathspec, ":(exclude)"+path)
+	}
+
+	return pathspec
+}
+
+func updateSubmodules(ctx context.Context, cmd *command, repoDir string, submodules any) error {
+	args := []string{"submodule", "update", "--quiet", "--init", "--recursive"}
+
+	switch value := submodules.(type) {
+	case nil:
+	case string:
+		switch value {
+		case "none":
+			return nil
+		case "all":
+		default:
+			return fmt.Errorf("invalid submodules value %q: expected \"all\", \"none\", or a list of paths", value)
+		}
+	case []any:
+		if len(value) == 0 {
+			return nil
+		}
+
+		args = append(args, "--")
+
+		for _, path := range value {
+			args = append(args, fmt.Sprintf("%v", path))
+		}
+	default:
+		return fmt.Errorf("invalid submodules value %v: expected \"all\", \"none\", or a list of paths", value)
+	}
+
+	_, err := cmd.run(ctx, repoDir, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update submodules: %w", err)
+	}
+
+	return nil
+}
+
+func createTag(ctx context.Context, cmd *command, srcDir, repoDir, tag, annotate string) error {
+	args := []string{"tag", "--force"}
+
+	if annotate != "" {
+		contents, err := os.ReadFile(filepath.Join(srcDir, annotate))
+		if err != nil {
+			return fmt.Errorf("failed to read annotate file: %w", err)
+		}
+
+		args = append(args, "--annotate", "--message", strings.TrimSpace(string(contents)))
+	}
+
+	_, err := cmd.run(ctx, repoDir, append(args, tag, "HEAD")...)
+	if err != nil {
+		return fmt.Errorf("failed to create tag %q: %w", tag, err)
+	}
+
+	return nil
+}
+
+func commitMetadata(ctx context.Context, cmd *command, repoDir, ref string) (resources.Metadata, error) {
+	output, err := cmd.run(ctx, repoDir, "log", "-1", "--format=%H%x00%an <%ae>%x00%aI%x00%cn <%ce>%x00%B", ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit: %w", err)
+	}
+
+	fields := strings.SplitN(output, "\x00", 5)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected commit format for %q", ref)
+	}
+
+	return resources.Metadata{
+		{Name: "commit", Value: fields[0]},
+		{Name: "author", Value: fields[1]},
+		{Name: "author_date", Value: fields[2]},
+		{Name: "committer", Value: fields[3]},
+		{Name: "message", Value: strings.TrimSpace(fields[4])},
+	}, nil
+}
+
+func parseSource(raw map[string]any) (Source, error) {
+	var source Source
+
+	err := resources.Decode(raw, &source)
+	if err != nil {
+		return source, fmt.Errorf("invalid source: %w", err)
+	}
+
+	if source.URI == "" {
+		return source, errors.New("source uri is required")
+	}
+
+	return source, nil
+}
+
+func init() {
+	resources.Register("git", func() resources.Resource {
+		return &Git{}
+	})
+}
+
+var _ resources.Resource = &Git{}
@@ -0,0 +1,278 @@
+package git_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtarchie/pocketci/resources"
+	_ "github.com/jtarchie/pocketci/resources/git"
+	. "github.com/onsi/gomega"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func commit(t *testing.T, dir, path, contents string) string {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, path), []byte(contents), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "update "+path)
+
+	return git(t, dir, "rev-parse", "HEAD")
+}
+
+// setupRepo creates a bare remote and a working clone pushing to it.
+func setupRepo(t *testing.T) (string, string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	git(t, "", "init", "-q", "--bare", remote)
+
+	work := filepath.Join(t.TempDir(), "work")
+	git(t, "", "clone", "-q", remote, work)
+	git(t, work, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	return remote, work
+}
+
+func TestGitResource(t *testing.T) {
+	t.Run("is registered", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(resources.IsNative("git")).To(BeTrue())
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(res.Name()).To(Equal("git"))
+	})
+
+	t.Run("check requires a uri", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = res.Check(context.Background(), resources.CheckRequest{Source: map[string]any{}})
+		assert.Expect(err).To(MatchError(ContainSubstring("uri is required")))
+	})
+
+	t.Run("check returns the latest commit and newer commits", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		first := commit(t, work, "README.md", "one")
+		second := commit(t, work, "README.md", "two")
+		third := commit(t, work, "README.md", "three")
+		git(t, work, "push", "-q", "origin", "main")
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		source := map[string]any{"uri": remote, "branch": "main"}
+
+		resp, err := res.Check(context.Background(), resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{{"ref": third}}))
+
+		resp, err = res.Check(context.Background(), resources.CheckRequest{
+			Source:  source,
+			Version: resources.Version{"ref": first},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{
+			{"ref": first},
+			{"ref": second},
+			{"ref": third},
+		}))
+	})
+
+	t.Run("check filters by paths and ignore_paths", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		first := commit(t, work, "src/main.go", "one")
+		docs := commit(t, work, "docs/README.md", "docs")
+		code := commit(t, work, "src/main.go", "two")
+		_ = commit(t, work, "docs/README.md", "more docs")
+		git(t, work, "push", "-q", "origin", "main")
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		resp, err := res.Check(context.Background(), resources.CheckRequest{
+			Source:  map[string]any{"uri": remote, "paths": []any{"src"}},
+			Version: resources.Version{"ref": first},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{{"ref": first}, {"ref": code}}))
+
+		resp, err = res.Check(context.Background(), resources.CheckRequest{
+			Source: map[string]any{"uri": remote, "ignore_paths": []any{"src"}},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(HaveLen(1))
+		assert.Expect(resp[0]["ref"]).NotTo(Equal(code))
+		assert.Expect(resp[0]["ref"]).NotTo(Equal(docs))
+	})
+
+	t.Run("check falls back to latest when the version is gone", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		latest := commit(t, work, "README.md", "one")
+		git(t, work, "push", "-q", "origin", "main")
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		resp, err := res.Check(context.Background(), resources.CheckRequest{
+			Source:  map[string]any{"uri": remote},
+			Version: resources.Version{"ref": strings.Repeat("a", 40)},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{{"ref": latest}}))
+	})
+
+	t.Run("in checks out the requested ref", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		first := commit(t, work, "README.md", "one")
+		_ = commit(t, work, "README.md", "two")
+		git(t, work, "push", "-q", "origin", "main")
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		destDir := t.TempDir()
+
+		resp, err := res.In(context.Background(), destDir, resources.InRequest{
+			Source:  map[string]any{"uri": "file://" + remote, "branch": "main"},
+			Version: resources.Version{"ref": first},
+			Params:  map[string]any{"depth": 1},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp.Version).To(Equal(resources.Version{"ref": first}))
+		assert.Expect(resp.Metadata).To(ContainElement(resources.MetadataField{Name: "commit", Value: first}))
+		assert.Expect(resp.Metadata).To(ContainElement(resources.MetadataField{Name: "message", Value: "update README.md"}))
+		assert.Expect(resp.Metadata).To(ContainElement(resources.MetadataField{Name: "branch", Value: "main"}))
+
+		contents, err := os.ReadFile(filepath.Join(destDir, "README.md"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("one"))
+
+		ref, err := os.ReadFile(filepath.Join(destDir, ".git", "ref"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(strings.TrimSpace(string(ref))).To(Equal(first))
+	})
+
+	t.Run("in requires a version", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = res.In(context.Background(), t.TempDir(), resources.InRequest{
+			Source: map[string]any{"uri": "/does/not/matter"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring("ref is required")))
+	})
+
+	t.Run("out pushes and tags the repository", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		_ = commit(t, work, "README.md", "one")
+		git(t, work, "push", "-q", "origin", "main")
+
+		srcDir := t.TempDir()
+		repoDir := filepath.Join(srcDir, "repo")
+		git(t, "", "clone", "-q", remote, repoDir)
+		pushed := commit(t, repoDir, "README.md", "two")
+
+		err := os.WriteFile(filepath.Join(srcDir, "version"), []byte("1.2.3\n"), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		resp, err := res.Out(context.Background(), srcDir, resources.OutRequest{
+			Source: map[string]any{"uri": remote, "branch": "main"},
+			Params: map[string]any{
+				"repository": "repo",
+				"tag":        "version",
+				"tag_prefix": "v",
+			},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp.Version).To(Equal(resources.Version{"ref": pushed}))
+		assert.Expect(resp.Metadata).To(ContainElement(resources.MetadataField{Name: "tag", Value: "v1.2.3"}))
+
+		assert.Expect(git(t, remote, "rev-parse", "refs/heads/main")).To(Equal(pushed))
+		assert.Expect(git(t, remote, "rev-parse", "refs/tags/v1.2.3")).To(Equal(pushed))
+	})
+
+	t.Run("out rebases when the push is rejected", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		remote, work := setupRepo(t)
+		_ = commit(t, work, "README.md", "one")
+		git(t, work, "push", "-q", "origin", "main")
+
+		srcDir := t.TempDir()
+		repoDir := filepath.Join(srcDir, "repo")
+		git(t, "", "clone", "-q", remote, repoDir)
+		_ = commit(t, repoDir, "local.txt", "local")
+
+		upstream := commit(t, work, "upstream.txt", "upstream")
+		git(t, work, "push", "-q", "origin", "main")
+
+		res, err := resources.Get("git")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		source := map[string]any{"uri": remote, "branch": "main"}
+
+		_, err = res.Out(context.Background(), srcDir, resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"repository": "repo"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring("failed to push")))
+
+		resp, err := res.Out(context.Background(), srcDir, resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"repository": "repo", "rebase": true},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(git(t, remote, "rev-parse", "refs/heads/main")).To(Equal(resp.Version["ref"]))
+		assert.Expect(git(t, remote, "rev-parse", resp.Version["ref"]+"^")).To(Equal(upstream))
+	})
+}
@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Decode converts a loosely typed source or params map into a typed struct via
+// JSON. A nil map leaves target unchanged.
+func Decode(raw map[string]any, target any) error {
+	if raw == nil {
+		return nil
+	}
+
+	contents, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("could not marshal: %w", err)
+	}
+
+	err = json.Unmarshal(contents, target)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// BoolParam is a bool that also accepts "true" and "false" strings, as YAML
+// pipelines pass every param value as a string.
+type BoolParam bool
+
+func (b *BoolParam) UnmarshalJSON(data []byte) error {
+	var value any
+
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = BoolParam(v)
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q: %w", v, err)
+		}
+
+		*b = BoolParam(parsed)
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+
+	return nil
+}
@@ -0,0 +1,356 @@
+package registryimage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/jtarchie/pocketci/resources"
+)
+
+// RegistryImage implements a resource for a tag in an OCI registry.
+// Versions are the manifest digests the tag points to, {"digest": "sha256:..."}.
+type RegistryImage struct{}
+
+// Source is the configuration accepted in a resource's source block.
+// Username and password are usually `secret:` references.
+type Source struct {
+	Repository string              `json:"repository"`
+	Tag        string              `json:"tag,omitempty"`
+	Username   string              `json:"username,omitempty"`
+	Password   string              `json:"password,omitempty"`
+	Insecure   resources.BoolParam `json:"insecure,omitempty"`
+}
+
+// InParams are the params accepted by a get step.
+// Format is one of "oci" (default), "oci-layout" or "rootfs".
+type InParams struct {
+	Format       string              `json:"format,omitempty"`
+	SkipDownload resources.BoolParam `json:"skip_download,omitempty"`
+}
+
+// OutParams are the params accepted by a put step.
+// Image is a glob matching the image tarball to push, and AdditionalTags is
+// an optional file of whitespace separated tags to also push.
+type OutParams struct {
+	Image          string `json:"image"`
+	AdditionalTags string `json:"additional_tags,omitempty"`
+}
+
+// source is a parsed Source.
+type source struct {
+	repository  name.Repository
+	tag         name.Tag
+	nameOptions []name.Option
+	options     []remote.Option
+}
+
+func (r *RegistryImage) Name() string {
+	return "registry-image"
+}
+
+// Check returns the digest the tag currently points to. An older version is
+// not returned, as a registry only knows the current digest of a tag.
+func (r *RegistryImage) Check(ctx context.Context, req resources.CheckRequest) (resources.CheckResponse, error) {
+	src, err := parseSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := remote.Head(src.tag, src.withContext(ctx)...)
+	if err != nil {
+		if isNotFound(err) {
+			return resources.CheckResponse{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to check %q: %w", src.tag, err)
+	}
+
+	return resources.CheckResponse{{"digest": descriptor.Digest.String()}}, nil
+}
+
+// In fetches the image at the version's digest into destDir and writes the
+// "repository", "tag" and "digest" files. The image is written as an
+// "image.tar" tarball, an "oci" layout directory or a "rootfs" directory
+// depending on the format param.
+func (r *RegistryImage) In(ctx context.Context, destDir string, req resources.InRequest) (resources.InResponse, error) {
+	src, err := parseSource(req.Source)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	var params InParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if params.Format == "" {
+		params.Format = "oci"
+	}
+
+	if params.Format != "oci" && params.Format != "oci-layout" && params.Format != "rootfs" {
+		return resources.InResponse{}, fmt.Errorf("invalid format %q, expected oci, oci-layout or rootfs", params.Format)
+	}
+
+	digest := req.Version["digest"]
+	if digest == "" {
+		return resources.InResponse{}, errors.New("version digest is required")
+	}
+
+	_, err = v1.NewHash(digest)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid version digest: %w", err)
+	}
+
+	ref := src.repository.Digest(digest)
+
+	files := map[string]string{
+		"repository": src.repository.Name(),
+		"tag":        src.tag.TagStr(),
+		"digest":     digest,
+	}
+
+	for filename, contents := range files {
+		err = os.WriteFile(filepath.Join(destDir, filename), []byte(contents), 0o600)
+		if err != nil {
+			return resources.InResponse{}, fmt.Errorf("failed to write %s file: %w", filename, err)
+		}
+	}
+
+	if !params.SkipDownload {
+		img, err := remote.Image(ref, src.withContext(ctx)...)
+		if err != nil {
+			return resources.InResponse{}, fmt.Errorf("failed to fetch %q: %w", ref, err)
+		}
+
+		switch params.Format {
+		case "oci":
+			err = tarball.WriteToFile(filepath.Join(destDir, "image.tar"), src.tag, img)
+		case "oci-layout":
+			err = writeLayout(filepath.Join(destDir, "oci"), img)
+		case "rootfs":
+			err = writeRootfs(destDir, img)
+		}
+
+		if err != nil {
+			return resources.InResponse{}, fmt.Errorf("failed to write image %q: %w", ref, err)
+		}
+	}
+
+	return resources.InResponse{
+		Version:  req.Version,
+		Metadata: metadata(src, digest),
+	}, nil
+}
+
+// Out pushes the single image tarball in srcDir matching the params image glob
+// to the tag and any additional tags.
+func (r *RegistryImage) Out(ctx context.Context, srcDir string, req resources.OutRequest) (resources.OutResponse, error) {
+	src, err := parseSource(req.Source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	var params OutParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if params.Image == "" {
+		return resources.OutResponse{}, errors.New("params image is required")
+	}
+
+	files, err := doublestar.Glob(os.DirFS(srcDir), params.Image, doublestar.WithFilesOnly())
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid image glob %q: %w", params.Image, err)
+	}
+
+	if len(files) != 1 {
+		return resources.OutResponse{}, fmt.Errorf("image glob %q must match exactly one file, matched %d", params.Image, len(files))
+	}
+
+	img, err := tarball.ImageFromPath(filepath.Join(srcDir, files[0]), nil)
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("failed to load image %q: %w", files[0], err)
+	}
+
+	tags := []name.Tag{src.tag}
+
+	if params.AdditionalTags != "" {
+		contents, err := fs.ReadFile(os.DirFS(srcDir), params.AdditionalTags)
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("failed to read additional tags: %w", err)
+		}
+
+		for _, identifier := range strings.Fields(string(contents)) {
+			tag, err := name.NewTag(src.repository.Name()+":"+identifier, src.nameOptions...)
+			if err != nil {
+				return resources.OutResponse{}, fmt.Errorf("invalid additional tag %q: %w", identifier, err)
+			}
+
+			tags = append(tags, tag)
+		}
+	}
+
+	for _, tag := range tags {
+		err = remote.Write(tag, img, src.withContext(ctx)...)
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("failed to push %q: %w", tag, err)
+		}
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("failed to compute digest: %w", err)
+	}
+
+	return resources.OutResponse{
+		Version:  resources.Version{"digest": digest.String()},
+		Metadata: metadata(src, digest.String()),
+	}, nil
+}
+
+func (src *source) withContext(ctx context.Context) []remote.Option {
+	return append([]remote.Option{remote.WithContext(ctx)}, src.options...)
+}
+
+// writeLayout writes the image as an OCI image layout directory.
+func writeLayout(dir string, img v1.Image) error {
+	index, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to create layout: %w", err)
+	}
+
+	err = index.AppendImage(img)
+	if err != nil {
+		return fmt.Errorf("failed to append image: %w", err)
+	}
+
+	return nil
+}
+
+// writeRootfs extracts the flattened image filesystem into a "rootfs"
+// directory and writes the image's user and env to "metadata.json", so it
+// can be used as a task image.
+func writeRootfs(destDir string, img v1.Image) error {
+	config, err := img.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	contents, err := json.Marshal(map[string]any{
+		"user": config.Config.User,
+		"env":  config.Config.Env,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(destDir, "metadata.json"), contents, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	rootfs := filepath.Join(destDir, "rootfs")
+
+	err = os.MkdirAll(rootfs, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create rootfs: %w", err)
+	}
+
+	reader := mutate.Extract(img)
+	defer func() { _ = reader.Close() }()
+
+	return resources.Untar(reader, rootfs)
+}
+
+func metadata(src *source, digest string) resources.Metadata {
+	return resources.Metadata{
+		{Name: "repository", Value: src.repository.Name()},
+		{Name: "tag", Value: src.tag.TagStr()},
+		{Name: "digest", Value: digest},
+	}
+}
+
+// isNotFound reports whether the registry does not know the tag.
+func isNotFound(err error) bool {
+	var transportErr *transport.Error
+
+	return errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound
+}
+
+func parseSource(raw map[string]any) (*source, error) {
+	var src Source
+
+	err := resources.Decode(raw, &src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+
+	if src.Repository == "" {
+		return nil, errors.New("source repository is required")
+	}
+
+	if src.Tag == "" {
+		src.Tag = "latest"
+	}
+
+	var nameOptions []name.Option
+	if src.Insecure {
+		nameOptions = append(nameOptions, name.Insecure)
+	}
+
+	repository, err := name.NewRepository(src.Repository, nameOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source repository: %w", err)
+	}
+
+	tag, err := name.NewTag(repository.Name()+":"+src.Tag, nameOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source tag: %w", err)
+	}
+
+	parsed := &source{
+		repository:  repository,
+		tag:         tag,
+		nameOptions: nameOptions,
+		options:     []remote.Option{remote.WithAuth(authn.Anonymous)},
+	}
+
+	if src.Username != "" {
+		parsed.options = []remote.Option{remote.WithAuth(&authn.Basic{
+			Username: src.Username,
+			Password: src.Password,
+		})}
+	}
+
+	return parsed, nil
+}
+
+func init() {
+	resources.Register("registry-image", func() resources.Resource {
+		return &RegistryImage{}
+	})
+}
+
+var _ resources.Resource = &RegistryImage{}
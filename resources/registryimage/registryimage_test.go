@@ -0,0 +1,155 @@
+package registryimage_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/jtarchie/pocketci/resources"
+	_ "github.com/jtarchie/pocketci/resources/registryimage"
+	. "github.com/onsi/gomega"
+)
+
+func TestRegistryImageResource(t *testing.T) {
+	t.Run("is registered", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(resources.IsNative("registry-image")).To(BeTrue())
+
+		res, err := resources.Get("registry-image")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(res.Name()).To(Equal("registry-image"))
+	})
+
+	t.Run("validates the source", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("registry-image")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{}})
+		assert.Expect(err).To(MatchError(ContainSubstring("source repository is required")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"repository": "UPPER/case"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("invalid source repository")))
+
+		_, err = res.In(ctx, t.TempDir(), resources.InRequest{
+			Source:  map[string]any{"repository": "busybox"},
+			Version: resources.Version{"digest": "sha256:abc"},
+			Params:  map[string]any{"format": "docker"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid format "docker"`)))
+	})
+
+	t.Run("pushes, checks and gets an image", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		server := httptest.NewServer(registry.New())
+		t.Cleanup(server.Close)
+
+		res, err := resources.Get("registry-image")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+		repository := strings.TrimPrefix(server.URL, "http://") + "/pocketci/app"
+		source := map[string]any{
+			"repository": repository,
+			"tag":        "stable",
+			"username":   "user",
+			"password":   "pass",
+		}
+
+		check, err := res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(BeEmpty())
+
+		img, err := random.Image(1024, 2)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		digest, err := img.Digest()
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		srcDir := t.TempDir()
+		tag, err := name.NewTag(repository + ":build")
+		assert.Expect(err).NotTo(HaveOccurred())
+		err = tarball.WriteToFile(filepath.Join(srcDir, "image.tar"), tag, img)
+		assert.Expect(err).NotTo(HaveOccurred())
+		err = os.WriteFile(filepath.Join(srcDir, "tags"), []byte("v1.0.0\nv1\n"), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		out, err := res.Out(ctx, srcDir, resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"image": "*.tar", "additional_tags": "tags"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(out.Version).To(Equal(resources.Version{"digest": digest.String()}))
+
+		for _, tag := range []string{"stable", "v1.0.0", "v1"} {
+			check, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"repository": repository, "tag": tag}})
+			assert.Expect(err).NotTo(HaveOccurred())
+			assert.Expect(check).To(Equal(resources.CheckResponse{{"digest": digest.String()}}))
+		}
+
+		destDir := t.TempDir()
+		in, err := res.In(ctx, destDir, resources.InRequest{
+			Source:  source,
+			Version: out.Version,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(in.Metadata).To(ContainElement(resources.MetadataField{Name: "digest", Value: digest.String()}))
+
+		contents, err := os.ReadFile(filepath.Join(destDir, "digest"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal(digest.String()))
+
+		pulled, err := tarball.ImageFromPath(filepath.Join(destDir, "image.tar"), nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		pulledDigest, err := pulled.Digest()
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(pulledDigest).To(Equal(digest))
+
+		layoutDir := t.TempDir()
+		_, err = res.In(ctx, layoutDir, resources.InRequest{
+			Source:  source,
+			Version: out.Version,
+			Params:  map[string]any{"format": "oci-layout"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(filepath.Join(layoutDir, "oci", "index.json")).To(BeAnExistingFile())
+
+		rootfsDir := t.TempDir()
+		_, err = res.In(ctx, rootfsDir, resources.InRequest{
+			Source:  source,
+			Version: out.Version,
+			Params:  map[string]any{"format": "rootfs"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(filepath.Join(rootfsDir, "metadata.json")).To(BeAnExistingFile())
+
+		entries, err := os.ReadDir(filepath.Join(rootfsDir, "rootfs"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(entries).NotTo(BeEmpty())
+	})
+
+	t.Run("put requires the glob to match one file", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("registry-image")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = res.Out(context.Background(), t.TempDir(), resources.OutRequest{
+			Source: map[string]any{"repository": "localhost:1/app"},
+			Params: map[string]any{"image": "*.tar"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring("must match exactly one file, matched 0")))
+	})
+}
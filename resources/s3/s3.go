@@ -0,0 +1,473 @@
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/jtarchie/pocketci/resources"
+	"github.com/jtarchie/pocketci/s3config"
+)
+
+// S3 implements a resource for files in an S3 bucket. Versions are either
+// files whose key matches a regexp, {"path": "<key>"}, ordered by the semver
+// captured from the key, or the object versions of a single file in a
+// versioned bucket, {"version_id": "<id>"}.
+type S3 struct{}
+
+// Source is the configuration accepted in a resource's source block.
+// URI is an S3 DSN in the same format as the storage and cache drivers, and
+// keys are relative to its prefix.
+type Source struct {
+	URI             string `json:"uri"`
+	Regexp          string `json:"regexp,omitempty"`
+	VersionedFile   string `json:"versioned_file,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// InParams are the params accepted by a get step.
+type InParams struct {
+	Unpack       resources.BoolParam `json:"unpack,omitempty"`
+	SkipDownload resources.BoolParam `json:"skip_download,omitempty"`
+}
+
+// OutParams are the params accepted by a put step.
+type OutParams struct {
+	File        string `json:"file"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// source is a parsed Source.
+type source struct {
+	client        *s3config.Client
+	config        *s3config.Config
+	regexp        string
+	pattern       *regexp.Regexp
+	literalPrefix string
+	versionedFile string
+}
+
+// match is a key matching the regexp and the version captured from it.
+type match struct {
+	key     string
+	version *semver.Version
+}
+
+func (s *S3) Name() string {
+	return "s3"
+}
+
+// Check lists the files matching the regexp or the versions of the versioned file.
+// Without a version only the latest is returned. With a version, it (if it still
+// exists) and every newer version are returned, oldest first.
+func (s *S3) Check(ctx context.Context, req resources.CheckRequest) (resources.CheckResponse, error) {
+	src, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if src.versionedFile != "" {
+		return src.checkVersionedFile(ctx, req.Version["version_id"])
+	}
+
+	return src.checkRegexp(ctx, req.Version["path"])
+}
+
+func (src *source) checkRegexp(ctx context.Context, current string) (resources.CheckResponse, error) {
+	matches, err := src.matches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return resources.CheckResponse{}, nil
+	}
+
+	latest := resources.CheckResponse{{"path": matches[len(matches)-1].key}}
+
+	if current == "" {
+		return latest, nil
+	}
+
+	currentVersion, err := src.extractVersion(current)
+	if err != nil {
+		// the previous version no longer matches the regexp
+		return latest, nil //nolint: nilerr
+	}
+
+	versions := resources.CheckResponse{}
+
+	for _, m := range matches {
+		if m.version.Compare(currentVersion) >= 0 {
+			versions = append(versions, resources.Version{"path": m.key})
+		}
+	}
+
+	if len(versions) == 0 {
+		return latest, nil
+	}
+
+	return versions, nil
+}
+
+func (src *source) checkVersionedFile(ctx context.Context, current string) (resources.CheckResponse, error) {
+	objectVersions, err := src.client.ListVersions(ctx, src.client.FullKey(src.versionedFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objectVersions) == 0 {
+		return resources.CheckResponse{}, nil
+	}
+
+	latest := resources.CheckResponse{{"version_id": objectVersions[len(objectVersions)-1].VersionID}}
+
+	index := slices.IndexFunc(objectVersions, func(v s3config.ObjectVersion) bool {
+		return v.VersionID == current
+	})
+	if current == "" || index < 0 {
+		return latest, nil
+	}
+
+	versions := resources.CheckResponse{}
+	for _, v := range objectVersions[index:] {
+		versions = append(versions, resources.Version{"version_id": v.VersionID})
+	}
+
+	return versions, nil
+}
+
+// In downloads the file into destDir, optionally unpacking archives, and
+// writes the captured version to a "version" file and its location to a "url" file.
+func (s *S3) In(ctx context.Context, destDir string, req resources.InRequest) (resources.InResponse, error) {
+	src, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	var params InParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	key, versionID, version := src.versionedFile, "", ""
+
+	if key != "" {
+		versionID = req.Version["version_id"]
+		if versionID == "" {
+			return resources.InResponse{}, errors.New("version version_id is required")
+		}
+
+		version = versionID
+	} else {
+		key = req.Version["path"]
+		if key == "" {
+			return resources.InResponse{}, errors.New("version path is required")
+		}
+
+		extracted, err := src.extractVersion(key)
+		if err != nil {
+			return resources.InResponse{}, err
+		}
+
+		version = extracted.Original()
+	}
+
+	filename := path.Base(key)
+	fullKey := src.client.FullKey(key)
+	url := "s3://" + src.config.Bucket + "/" + fullKey
+
+	err = os.WriteFile(filepath.Join(destDir, "version"), []byte(version), 0o600)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to write version file: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(destDir, "url"), []byte(url), 0o600)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to write url file: %w", err)
+	}
+
+	if !params.SkipDownload {
+		err = src.download(ctx, fullKey, versionID, filepath.Join(destDir, filename))
+		if err != nil {
+			return resources.InResponse{}, err
+		}
+
+		if params.Unpack {
+			err = unpack(filepath.Join(destDir, filename), destDir)
+			if err != nil {
+				return resources.InResponse{}, err
+			}
+		}
+	}
+
+	return resources.InResponse{
+		Version:  req.Version,
+		Metadata: metadata(filename, url, version),
+	}, nil
+}
+
+// Out uploads the single file in srcDir matching the params file glob. With a
+// regexp it is stored next to the other matches, otherwise as a new version of
+// the versioned file.
+func (s *S3) Out(ctx context.Context, srcDir string, req resources.OutRequest) (resources.OutResponse, error) {
+	src, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	var params OutParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if params.File == "" {
+		return resources.OutResponse{}, errors.New("params file is required")
+	}
+
+	files, err := doublestar.Glob(os.DirFS(srcDir), params.File, doublestar.WithFilesOnly())
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid file glob %q: %w", params.File, err)
+	}
+
+	if len(files) != 1 {
+		return resources.OutResponse{}, fmt.Errorf("file glob %q must match exactly one file, matched %d", params.File, len(files))
+	}
+
+	filename := path.Base(files[0])
+
+	key, version := src.versionedFile, ""
+	if key == "" {
+		// regexps like "releases/app-(.*).tgz" upload into their directory
+		key = path.Join(path.Dir(src.regexp), filename)
+
+		extracted, err := src.extractVersion(key)
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("file %q cannot be versioned: %w", filename, err)
+		}
+
+		version = extracted.Original()
+	}
+
+	file, err := os.Open(filepath.Join(srcDir, files[0]))
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	fullKey := src.client.FullKey(key)
+
+	versionID, err := src.client.PutVersioned(ctx, fullKey, file, params.ContentType)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	url := "s3://" + src.config.Bucket + "/" + fullKey
+
+	if src.versionedFile != "" {
+		if versionID == "" {
+			return resources.OutResponse{}, fmt.Errorf("bucket %q did not return a version ID, is versioning enabled?", src.config.Bucket)
+		}
+
+		return resources.OutResponse{
+			Version:  resources.Version{"version_id": versionID},
+			Metadata: metadata(filename, url, versionID),
+		}, nil
+	}
+
+	return resources.OutResponse{
+		Version:  resources.Version{"path": key},
+		Metadata: metadata(filename, url, version),
+	}, nil
+}
+
+// matches lists the keys matching the regexp, ordered by their captured version.
+// Keys whose captured version is not a semantic version are skipped.
+func (src *source) matches(ctx context.Context) ([]match, error) {
+	keys, err := src.client.ListKeys(ctx, src.client.FullKey(src.literalPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []match
+
+	for _, fullKey := range keys {
+		key := src.client.StripPrefix(fullKey)
+
+		version, err := src.extractVersion(key)
+		if err != nil {
+			continue
+		}
+
+		matches = append(matches, match{key: key, version: version})
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int {
+		if compared := a.version.Compare(b.version); compared != 0 {
+			return compared
+		}
+
+		return strings.Compare(a.key, b.key)
+	})
+
+	return matches, nil
+}
+
+// extractVersion returns the version captured from a key by the regexp, using
+// the "version" named group when present and the first group otherwise.
+func (src *source) extractVersion(key string) (*semver.Version, error) {
+	submatches := src.pattern.FindStringSubmatch(key)
+	if submatches == nil {
+		return nil, fmt.Errorf("key %q does not match regexp %q", key, src.pattern)
+	}
+
+	group := 1
+	if index := src.pattern.SubexpIndex("version"); index > 0 {
+		group = index
+	}
+
+	version, err := semver.NewVersion(submatches[group])
+	if err != nil {
+		return nil, fmt.Errorf("key %q has invalid version %q: %w", key, submatches[group], err)
+	}
+
+	return version, nil
+}
+
+func (src *source) download(ctx context.Context, fullKey, versionID, target string) error {
+	result, err := src.client.GetVersionStream(ctx, fullKey, versionID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = result.Body.Close() }()
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = io.Copy(file, result.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download %q: %w", fullKey, err)
+	}
+
+	return nil
+}
+
+func metadata(filename, url, version string) resources.Metadata {
+	return resources.Metadata{
+		{Name: "filename", Value: filename},
+		{Name: "url", Value: url},
+		{Name: "version", Value: version},
+	}
+}
+
+// unpack extracts tar, gzipped tar and zip archives into destDir. Other files
+// are left as they are.
+func unpack(archive, destDir string) error {
+	switch name := strings.ToLower(archive); {
+	case strings.HasSuffix(name, ".zip"):
+		return resources.Unzip(archive, destDir)
+	case strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tar.gz"):
+		file, err := os.Open(archive)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip: %w", err)
+		}
+
+		return resources.Untar(reader, destDir)
+	case strings.HasSuffix(name, ".tar"):
+		file, err := os.Open(archive)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+
+		return resources.Untar(file, destDir)
+	}
+
+	return nil
+}
+
+func parseSource(ctx context.Context, raw map[string]any) (*source, error) {
+	var src Source
+
+	err := resources.Decode(raw, &src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+
+	if src.URI == "" {
+		return nil, errors.New("source uri is required")
+	}
+
+	if (src.Regexp == "") == (src.VersionedFile == "") {
+		return nil, errors.New("source requires exactly one of regexp or versioned_file")
+	}
+
+	config, err := s3config.ParseDSN(src.URI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source uri: %w", err)
+	}
+
+	if src.AccessKeyID != "" {
+		config.AccessKeyID = src.AccessKeyID
+		config.SecretAccessKey = src.SecretAccessKey
+	}
+
+	parsed := &source{
+		config:        config,
+		versionedFile: src.VersionedFile,
+	}
+
+	if src.Regexp != "" {
+		unanchored, err := regexp.Compile(src.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source regexp: %w", err)
+		}
+
+		if unanchored.NumSubexp() == 0 {
+			return nil, errors.New("source regexp must capture the version in a group")
+		}
+
+		parsed.regexp = src.Regexp
+		parsed.literalPrefix, _ = unanchored.LiteralPrefix()
+		parsed.pattern = regexp.MustCompile("^(?:" + src.Regexp + ")$")
+	}
+
+	parsed.client, err = s3config.NewClient(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+
+	return parsed, nil
+}
+
+func init() {
+	resources.Register("s3", func() resources.Resource {
+		return &S3{}
+	})
+}
+
+var _ resources.Resource = &S3{}
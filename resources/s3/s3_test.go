@@ -0,0 +1,226 @@
+package s3_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtarchie/pocketci/resources"
+	_ "github.com/jtarchie/pocketci/resources/s3"
+	"github.com/jtarchie/pocketci/testhelpers"
+	. "github.com/onsi/gomega"
+)
+
+func writeTarball(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	for name, contents := range files {
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tw.Write([]byte(contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = tw.Close()
+	_ = gz.Close()
+}
+
+func TestS3Resource(t *testing.T) {
+	t.Run("is registered", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(resources.IsNative("s3")).To(BeTrue())
+
+		res, err := resources.Get("s3")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(res.Name()).To(Equal("s3"))
+	})
+
+	t.Run("validates the source", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("s3")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"regexp": "app-(.*).tgz"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("source uri is required")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"uri": "s3://localhost/bucket"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("exactly one of regexp or versioned_file")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"uri": "s3://localhost/bucket", "regexp": "app.tgz"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("must capture the version")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"uri": "http://localhost/bucket", "regexp": "app-(.*).tgz"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("invalid source uri")))
+	})
+
+	t.Run("puts, checks and gets files by regexp", func(t *testing.T) {
+		if _, err := exec.LookPath("minio"); err != nil {
+			t.Skip("minio not installed, skipping S3 resource test")
+		}
+
+		assert := NewGomegaWithT(t)
+
+		server := testhelpers.StartMinIO(t)
+		t.Cleanup(server.Stop)
+
+		res, err := resources.Get("s3")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+		source := map[string]any{
+			"uri":    server.CacheURL(),
+			"regexp": `releases/app-(?P<version>[0-9.]+)\.tgz`,
+		}
+
+		check, err := res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(BeEmpty())
+
+		// semver ordering puts 1.10.0 after 1.9.0
+		for _, version := range []string{"1.10.0", "1.2.0", "1.9.0"} {
+			srcDir := t.TempDir()
+			writeTarball(t, filepath.Join(srcDir, "app-"+version+".tgz"), map[string]string{"bin/app": "app " + version})
+
+			out, err := res.Out(ctx, srcDir, resources.OutRequest{
+				Source: source,
+				Params: map[string]any{"file": "app-*.tgz"},
+			})
+			assert.Expect(err).NotTo(HaveOccurred())
+			assert.Expect(out.Version).To(Equal(resources.Version{"path": "releases/app-" + version + ".tgz"}))
+		}
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"path": "releases/app-1.10.0.tgz"}}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source, Version: resources.Version{"path": "releases/app-1.2.0.tgz"}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{
+			{"path": "releases/app-1.2.0.tgz"},
+			{"path": "releases/app-1.9.0.tgz"},
+			{"path": "releases/app-1.10.0.tgz"},
+		}))
+
+		destDir := t.TempDir()
+		in, err := res.In(ctx, destDir, resources.InRequest{
+			Source:  source,
+			Version: resources.Version{"path": "releases/app-1.9.0.tgz"},
+			Params:  map[string]any{"unpack": "true"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(in.Version).To(Equal(resources.Version{"path": "releases/app-1.9.0.tgz"}))
+		assert.Expect(in.Metadata).To(ContainElement(resources.MetadataField{Name: "version", Value: "1.9.0"}))
+
+		contents, err := os.ReadFile(filepath.Join(destDir, "bin", "app"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("app 1.9.0"))
+
+		contents, err = os.ReadFile(filepath.Join(destDir, "version"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("1.9.0"))
+		assert.Expect(filepath.Join(destDir, "app-1.9.0.tgz")).To(BeAnExistingFile())
+	})
+
+	t.Run("puts, checks and gets a versioned file", func(t *testing.T) {
+		if _, err := exec.LookPath("minio"); err != nil {
+			t.Skip("minio not installed, skipping S3 resource test")
+		}
+
+		assert := NewGomegaWithT(t)
+
+		server := testhelpers.StartMinIO(t)
+		t.Cleanup(server.Stop)
+		server.EnableVersioning(t)
+
+		res, err := resources.Get("s3")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+		source := map[string]any{
+			"uri":            server.CacheURL(),
+			"versioned_file": "releases/app.txt",
+		}
+
+		// Versions put within the same second keep the order they were put in.
+		var versionIDs []string
+
+		for _, contents := range []string{"first", "second", "third"} {
+			srcDir := t.TempDir()
+			err := os.WriteFile(filepath.Join(srcDir, "app.txt"), []byte(contents), 0o600)
+			assert.Expect(err).NotTo(HaveOccurred())
+
+			out, err := res.Out(ctx, srcDir, resources.OutRequest{
+				Source: source,
+				Params: map[string]any{"file": "app.txt"},
+			})
+			assert.Expect(err).NotTo(HaveOccurred())
+			assert.Expect(out.Version["version_id"]).NotTo(BeEmpty())
+
+			versionIDs = append(versionIDs, out.Version["version_id"])
+		}
+
+		check, err := res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"version_id": versionIDs[2]}}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source, Version: resources.Version{"version_id": versionIDs[0]}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{
+			{"version_id": versionIDs[0]},
+			{"version_id": versionIDs[1]},
+			{"version_id": versionIDs[2]},
+		}))
+
+		destDir := t.TempDir()
+		in, err := res.In(ctx, destDir, resources.InRequest{
+			Source:  source,
+			Version: resources.Version{"version_id": versionIDs[1]},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(in.Version).To(Equal(resources.Version{"version_id": versionIDs[1]}))
+
+		contents, err := os.ReadFile(filepath.Join(destDir, "app.txt"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("second"))
+
+		contents, err = os.ReadFile(filepath.Join(destDir, "version"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal(versionIDs[1]))
+	})
+
+	t.Run("put requires the glob to match one file", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("s3")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		srcDir := t.TempDir()
+		_, err = res.Out(context.Background(), srcDir, resources.OutRequest{
+			Source: map[string]any{"uri": "s3://http://localhost:1/bucket?region=us-east-1", "versioned_file": "app.tgz"},
+			Params: map[string]any{"file": "*.tgz"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring("must match exactly one file, matched 0")))
+	})
+}
@@ -0,0 +1,359 @@
+package semver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jtarchie/pocketci/resources"
+	"github.com/jtarchie/pocketci/s3config"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// Semver implements a resource for a semantic version number, such as the
+// version of the next release. The number is kept in the pipeline's storage
+// or in an S3 object, and versions are {"number": "1.2.3"}.
+type Semver struct{}
+
+// Source is the configuration accepted in a resource's source block.
+// Driver is "storage" (default) or "s3". Key names the version within the
+// pipeline's storage, or is the object key relative to the URI prefix for s3.
+type Source struct {
+	Driver          string `json:"driver,omitempty"`
+	Key             string `json:"key"`
+	InitialVersion  string `json:"initial_version,omitempty"`
+	URI             string `json:"uri,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// BumpParams are the params that change a version. Bump is one of major,
+// minor, patch or final, and Pre is a prerelease type such as "rc".
+type BumpParams struct {
+	Bump string `json:"bump,omitempty"`
+	Pre  string `json:"pre,omitempty"`
+}
+
+// OutParams are the params accepted by a put step. File is the path of a
+// file containing the version to set; otherwise the bump params are applied
+// to the current version.
+type OutParams struct {
+	BumpParams
+
+	File string `json:"file,omitempty"`
+}
+
+// store reads and writes the current version number.
+type store interface {
+	get(ctx context.Context) (string, bool, error)
+	set(ctx context.Context, number string) error
+}
+
+func (s *Semver) Name() string {
+	return "semver"
+}
+
+// Check returns the current version, or the initial version when none has
+// been set yet.
+func (s *Semver) Check(ctx context.Context, req resources.CheckRequest) (resources.CheckResponse, error) {
+	src, versionStore, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := currentVersion(ctx, versionStore, src.InitialVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return resources.CheckResponse{{"number": current.String()}}, nil
+}
+
+// In writes the version, with any bump params applied, to "version" and
+// "number" files in destDir. The stored version is not changed.
+func (s *Semver) In(ctx context.Context, destDir string, req resources.InRequest) (resources.InResponse, error) {
+	_, _, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	var params BumpParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	version, err := semver.StrictNewVersion(req.Version["number"])
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("invalid version number %q: %w", req.Version["number"], err)
+	}
+
+	bumped, err := bump(version, params)
+	if err != nil {
+		return resources.InResponse{}, err
+	}
+
+	for _, filename := range []string{"version", "number"} {
+		err = os.WriteFile(filepath.Join(destDir, filename), []byte(bumped.String()), 0o600)
+		if err != nil {
+			return resources.InResponse{}, fmt.Errorf("failed to write %s file: %w", filename, err)
+		}
+	}
+
+	return resources.InResponse{
+		Version:  req.Version,
+		Metadata: resources.Metadata{{Name: "number", Value: bumped.String()}},
+	}, nil
+}
+
+// Out stores the version read from the file param, or the current version
+// with the bump params applied.
+func (s *Semver) Out(ctx context.Context, srcDir string, req resources.OutRequest) (resources.OutResponse, error) {
+	src, versionStore, err := parseSource(ctx, req.Source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	var params OutParams
+
+	err = resources.Decode(req.Params, &params)
+	if err != nil {
+		return resources.OutResponse{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	var next *semver.Version
+
+	switch {
+	case params.File != "":
+		contents, err := fs.ReadFile(os.DirFS(srcDir), params.File)
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("failed to read version file: %w", err)
+		}
+
+		next, err = semver.StrictNewVersion(strings.TrimSpace(string(contents)))
+		if err != nil {
+			return resources.OutResponse{}, fmt.Errorf("invalid version in %q: %w", params.File, err)
+		}
+	case params.Bump != "" || params.Pre != "":
+		current, err := currentVersion(ctx, versionStore, src.InitialVersion)
+		if err != nil {
+			return resources.OutResponse{}, err
+		}
+
+		next, err = bump(current, params.BumpParams)
+		if err != nil {
+			return resources.OutResponse{}, err
+		}
+	default:
+		return resources.OutResponse{}, errors.New("params require file, bump or pre")
+	}
+
+	err = versionStore.set(ctx, next.String())
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	return resources.OutResponse{
+		Version:  resources.Version{"number": next.String()},
+		Metadata: resources.Metadata{{Name: "number", Value: next.String()}},
+	}, nil
+}
+
+func currentVersion(ctx context.Context, versionStore store, initialVersion string) (*semver.Version, error) {
+	number, ok, err := versionStore.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		number = initialVersion
+	}
+
+	version, err := semver.StrictNewVersion(number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored version %q: %w", number, err)
+	}
+
+	return version, nil
+}
+
+// bump applies the bump and pre params the way Concourse's semver resource
+// does. A pre bump of a matching prerelease increments its number, otherwise
+// the prerelease starts at 1, e.g. 1.2.3 with pre "rc" becomes 1.2.3-rc.1.
+func bump(version *semver.Version, params BumpParams) (*semver.Version, error) {
+	major, minor, patch := version.Major(), version.Minor(), version.Patch()
+	prerelease := version.Prerelease()
+
+	switch params.Bump {
+	case "":
+	case "major":
+		major, minor, patch, prerelease = major+1, 0, 0, ""
+	case "minor":
+		minor, patch, prerelease = minor+1, 0, ""
+	case "patch":
+		patch, prerelease = patch+1, ""
+	case "final":
+		prerelease = ""
+	default:
+		return nil, fmt.Errorf("invalid bump %q, expected major, minor, patch or final", params.Bump)
+	}
+
+	if params.Pre != "" {
+		prerelease = bumpPrerelease(prerelease, params.Pre)
+	}
+
+	number := fmt.Sprintf("%d.%d.%d", major, minor, patch)
+	if prerelease != "" {
+		number += "-" + prerelease
+	}
+
+	bumped, err := semver.StrictNewVersion(number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bumped version %q: %w", number, err)
+	}
+
+	return bumped, nil
+}
+
+func bumpPrerelease(prerelease, pre string) string {
+	name, counter, found := strings.Cut(prerelease, ".")
+	if found && name == pre {
+		if value, err := strconv.ParseUint(counter, 10, 64); err == nil {
+			return pre + "." + strconv.FormatUint(value+1, 10)
+		}
+	}
+
+	return pre + ".1"
+}
+
+// storageStore keeps the version in the pipeline's storage driver.
+type storageStore struct {
+	driver storage.Driver
+	path   string
+}
+
+func (s *storageStore) get(ctx context.Context) (string, bool, error) {
+	payload, err := s.driver.Get(ctx, s.path)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("could not get version: %w", err)
+	}
+
+	number, ok := payload["number"].(string)
+
+	return number, ok, nil
+}
+
+func (s *storageStore) set(ctx context.Context, number string) error {
+	err := s.driver.Set(ctx, s.path, map[string]any{"number": number})
+	if err != nil {
+		return fmt.Errorf("could not set version: %w", err)
+	}
+
+	return nil
+}
+
+// s3Store keeps the version as the contents of an S3 object.
+type s3Store struct {
+	client *s3config.Client
+	key    string
+}
+
+func (s *s3Store) get(ctx context.Context) (string, bool, error) {
+	contents, err := s.client.GetBytes(ctx, s.key)
+	if s3config.IsNotFound(err) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("could not get version: %w", err)
+	}
+
+	return strings.TrimSpace(string(contents)), true, nil
+}
+
+func (s *s3Store) set(ctx context.Context, number string) error {
+	err := s.client.PutBytes(ctx, s.key, []byte(number), "text/plain")
+	if err != nil {
+		return fmt.Errorf("could not set version: %w", err)
+	}
+
+	return nil
+}
+
+func parseSource(ctx context.Context, raw map[string]any) (*Source, store, error) {
+	var src Source
+
+	err := resources.Decode(raw, &src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source: %w", err)
+	}
+
+	if src.Key == "" {
+		return nil, nil, errors.New("source key is required")
+	}
+
+	if src.InitialVersion == "" {
+		src.InitialVersion = "0.0.0"
+	}
+
+	_, err = semver.StrictNewVersion(src.InitialVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source initial_version: %w", err)
+	}
+
+	switch src.Driver {
+	case "", "storage":
+		driver, pipelineID, ok := resources.StorageFromContext(ctx)
+		if !ok {
+			return nil, nil, errors.New("the storage driver is only available inside a pipeline, use the s3 driver instead")
+		}
+
+		if pipelineID == "" {
+			pipelineID = "default"
+		}
+
+		return &src, &storageStore{driver: driver, path: "/semver/" + pipelineID + "/" + src.Key}, nil
+	case "s3":
+		if src.URI == "" {
+			return nil, nil, errors.New("source uri is required for the s3 driver")
+		}
+
+		config, err := s3config.ParseDSN(src.URI)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid source uri: %w", err)
+		}
+
+		if src.AccessKeyID != "" {
+			config.AccessKeyID = src.AccessKeyID
+			config.SecretAccessKey = src.SecretAccessKey
+		}
+
+		client, err := s3config.NewClient(ctx, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create client: %w", err)
+		}
+
+		return &src, &s3Store{client: client, key: client.FullKey(src.Key)}, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid source driver %q, expected storage or s3", src.Driver)
+	}
+}
+
+func init() {
+	resources.Register("semver", func() resources.Resource {
+		return &Semver{}
+	})
+}
+
+var _ resources.Resource = &Semver{}
@@ -0,0 +1,194 @@
+package semver_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtarchie/pocketci/resources"
+	_ "github.com/jtarchie/pocketci/resources/semver"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	"github.com/jtarchie/pocketci/testhelpers"
+	. "github.com/onsi/gomega"
+)
+
+func storageContext(t *testing.T) context.Context {
+	t.Helper()
+
+	assert := NewGomegaWithT(t)
+
+	initStorage, found := storage.GetFromDSN("sqlite://:memory:")
+	assert.Expect(found).To(BeTrue())
+
+	store, err := initStorage("sqlite://:memory:", "semver", slog.Default())
+	assert.Expect(err).NotTo(HaveOccurred())
+	t.Cleanup(func() { _ = store.Close() })
+
+	return resources.WithStorage(context.Background(), store, "pipeline-id")
+}
+
+func TestSemverResource(t *testing.T) {
+	t.Run("is registered", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(resources.IsNative("semver")).To(BeTrue())
+
+		res, err := resources.Get("semver")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(res.Name()).To(Equal("semver"))
+	})
+
+	t.Run("validates the source", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("semver")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := storageContext(t)
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{}})
+		assert.Expect(err).To(MatchError(ContainSubstring("source key is required")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"key": "version", "initial_version": "1.0"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("invalid source initial_version")))
+
+		_, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"key": "version", "driver": "git"}})
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid source driver "git"`)))
+
+		_, err = res.Check(context.Background(), resources.CheckRequest{Source: map[string]any{"key": "version"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("only available inside a pipeline")))
+	})
+
+	t.Run("bumps versions", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("semver")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		examples := []struct {
+			version string
+			params  map[string]any
+			bumped  string
+		}{
+			{"1.2.3", map[string]any{"bump": "major"}, "2.0.0"},
+			{"1.2.3", map[string]any{"bump": "minor"}, "1.3.0"},
+			{"1.2.3", map[string]any{"bump": "patch"}, "1.2.4"},
+			{"1.2.3-rc.2", map[string]any{"bump": "patch"}, "1.2.4"},
+			{"1.2.3-rc.2", map[string]any{"bump": "final"}, "1.2.3"},
+			{"1.2.3", map[string]any{"pre": "rc"}, "1.2.3-rc.1"},
+			{"1.2.3-rc.1", map[string]any{"pre": "rc"}, "1.2.3-rc.2"},
+			{"1.2.3-alpha.4", map[string]any{"pre": "beta"}, "1.2.3-beta.1"},
+			{"1.2.3", map[string]any{"bump": "minor", "pre": "rc"}, "1.3.0-rc.1"},
+			{"1.2.3", nil, "1.2.3"},
+		}
+
+		ctx := storageContext(t)
+
+		for _, example := range examples {
+			destDir := t.TempDir()
+			in, err := res.In(ctx, destDir, resources.InRequest{
+				Source:  map[string]any{"key": "version"},
+				Version: resources.Version{"number": example.version},
+				Params:  example.params,
+			})
+			assert.Expect(err).NotTo(HaveOccurred())
+			assert.Expect(in.Version).To(Equal(resources.Version{"number": example.version}))
+
+			contents, err := os.ReadFile(filepath.Join(destDir, "version"))
+			assert.Expect(err).NotTo(HaveOccurred())
+			assert.Expect(string(contents)).To(Equal(example.bumped), "%s with %v", example.version, example.params)
+		}
+
+		_, err = res.In(ctx, t.TempDir(), resources.InRequest{
+			Source:  map[string]any{"key": "version"},
+			Version: resources.Version{"number": "1.2.3"},
+			Params:  map[string]any{"bump": "huge"},
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring(`invalid bump "huge"`)))
+	})
+
+	t.Run("keeps the version in storage", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res, err := resources.Get("semver")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := storageContext(t)
+		source := map[string]any{"key": "release", "initial_version": "0.1.0"}
+
+		check, err := res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "0.1.0"}}))
+
+		out, err := res.Out(ctx, t.TempDir(), resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"bump": "minor"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(out.Version).To(Equal(resources.Version{"number": "0.2.0"}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source, Version: resources.Version{"number": "0.1.0"}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "0.2.0"}}))
+
+		srcDir := t.TempDir()
+		err = os.MkdirAll(filepath.Join(srcDir, "build"), 0o755)
+		assert.Expect(err).NotTo(HaveOccurred())
+		err = os.WriteFile(filepath.Join(srcDir, "build", "version"), []byte("3.0.0-rc.1\n"), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		out, err = res.Out(ctx, srcDir, resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"file": "build/version"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(out.Version).To(Equal(resources.Version{"number": "3.0.0-rc.1"}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "3.0.0-rc.1"}}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: map[string]any{"key": "other"}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "0.0.0"}}))
+
+		_, err = res.Out(ctx, srcDir, resources.OutRequest{Source: source})
+		assert.Expect(err).To(MatchError(ContainSubstring("params require file, bump or pre")))
+	})
+
+	t.Run("keeps the version in s3", func(t *testing.T) {
+		if _, err := exec.LookPath("minio"); err != nil {
+			t.Skip("minio not installed, skipping semver s3 driver test")
+		}
+
+		assert := NewGomegaWithT(t)
+
+		server := testhelpers.StartMinIO(t)
+		t.Cleanup(server.Stop)
+
+		res, err := resources.Get("semver")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+		source := map[string]any{"driver": "s3", "uri": server.CacheURL(), "key": "versions/app"}
+
+		check, err := res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "0.0.0"}}))
+
+		out, err := res.Out(ctx, t.TempDir(), resources.OutRequest{
+			Source: source,
+			Params: map[string]any{"bump": "major", "pre": "rc"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(out.Version).To(Equal(resources.Version{"number": "1.0.0-rc.1"}))
+
+		check, err = res.Check(ctx, resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(check).To(Equal(resources.CheckResponse{{"number": "1.0.0-rc.1"}}))
+	})
+}
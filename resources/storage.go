@@ -0,0 +1,36 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/jtarchie/pocketci/storage"
+)
+
+type storageContextKey struct{}
+
+type pipelineStorage struct {
+	driver     storage.Driver
+	pipelineID string
+}
+
+// WithStorage returns a context carrying the pipeline's storage driver.
+// Resources that keep their own state, like semver, read it back with
+// StorageFromContext instead of needing an external service.
+func WithStorage(ctx context.Context, driver storage.Driver, pipelineID string) context.Context {
+	return context.WithValue(ctx, storageContextKey{}, pipelineStorage{
+		driver:     driver,
+		pipelineID: pipelineID,
+	})
+}
+
+// StorageFromContext returns the storage driver and pipeline ID set by
+// WithStorage. The boolean is false when no storage is available, such as
+// when a resource runs from the `resource` command inside a container.
+func StorageFromContext(ctx context.Context) (storage.Driver, string, bool) {
+	value, ok := ctx.Value(storageContextKey{}).(pipelineStorage)
+	if !ok || value.driver == nil {
+		return nil, "", false
+	}
+
+	return value.driver, value.pipelineID, true
+}
@@ -0,0 +1,283 @@
+package time
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	gotime "time"
+
+	"github.com/jtarchie/pocketci/resources"
+)
+
+// Time implements a resource that emits a new version when an interval has
+// elapsed or a time window has opened. Versions are {"time": "<RFC3339>"}.
+type Time struct {
+	// Now returns the current time. It defaults to time.Now.
+	Now func() gotime.Time
+}
+
+// Source is the configuration accepted in a resource's source block.
+type Source struct {
+	Interval       string   `json:"interval,omitempty"`
+	Start          string   `json:"start,omitempty"`
+	Stop           string   `json:"stop,omitempty"`
+	Location       string   `json:"location,omitempty"`
+	Days           []string `json:"days,omitempty"`
+	InitialVersion bool     `json:"initial_version,omitempty"`
+}
+
+// timeOfDayFormats are the accepted layouts for start and stop.
+var timeOfDayFormats = []string{
+	"15:04",
+	"1504",
+	"3:04 PM",
+	"3:04PM",
+	"3 PM",
+	"3PM",
+}
+
+// schedule is a parsed Source.
+type schedule struct {
+	interval gotime.Duration
+	start    gotime.Duration
+	stop     gotime.Duration
+	windowed bool
+	location *gotime.Location
+	days     map[gotime.Weekday]bool
+	initial  bool
+}
+
+func (t *Time) Name() string {
+	return "time"
+}
+
+func (t *Time) now() gotime.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+
+	return gotime.Now()
+}
+
+// Check emits the current time as a new version when the interval has elapsed
+// since the given version, or when the given version predates the current window.
+func (t *Time) Check(_ context.Context, req resources.CheckRequest) (resources.CheckResponse, error) {
+	sched, err := parseSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	now := t.now().In(sched.location)
+	current := resources.Version{"time": now.UTC().Format(gotime.RFC3339)}
+
+	previous := req.Version["time"]
+	if previous == "" {
+		if sched.initial || sched.eligible(now) {
+			return resources.CheckResponse{current}, nil
+		}
+
+		return resources.CheckResponse{}, nil
+	}
+
+	previousTime, err := gotime.Parse(gotime.RFC3339, previous)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version time %q: %w", previous, err)
+	}
+
+	if !sched.eligible(now) {
+		return resources.CheckResponse{req.Version}, nil
+	}
+
+	var elapsed bool
+	if sched.interval > 0 {
+		elapsed = now.Sub(previousTime) >= sched.interval
+	} else {
+		elapsed = previousTime.Before(sched.windowStart(now))
+	}
+
+	if elapsed {
+		return resources.CheckResponse{req.Version, current}, nil
+	}
+
+	return resources.CheckResponse{req.Version}, nil
+}
+
+// In writes the version's timestamp to an `input` file in the destination directory.
+func (t *Time) In(_ context.Context, destDir string, req resources.InRequest) (resources.InResponse, error) {
+	version := req.Version["time"]
+	if version == "" {
+		return resources.InResponse{}, errors.New("version time is required")
+	}
+
+	err := os.WriteFile(filepath.Join(destDir, "input"), []byte(version), 0o600)
+	if err != nil {
+		return resources.InResponse{}, fmt.Errorf("failed to write input file: %w", err)
+	}
+
+	return resources.InResponse{
+		Version: resources.Version{"time": version},
+		Metadata: resources.Metadata{
+			{Name: "time", Value: version},
+		},
+	}, nil
+}
+
+// Out produces a new version for the current time.
+func (t *Time) Out(_ context.Context, _ string, req resources.OutRequest) (resources.OutResponse, error) {
+	sched, err := parseSource(req.Source)
+	if err != nil {
+		return resources.OutResponse{}, err
+	}
+
+	version := t.now().In(sched.location).UTC().Format(gotime.RFC3339)
+
+	return resources.OutResponse{
+		Version: resources.Version{"time": version},
+		Metadata: resources.Metadata{
+			{Name: "time", Value: version},
+		},
+	}, nil
+}
+
+// eligible reports whether now falls on an allowed day and inside the window.
+func (s schedule) eligible(now gotime.Time) bool {
+	if len(s.days) > 0 && !s.days[now.Weekday()] {
+		return false
+	}
+
+	if !s.windowed {
+		return true
+	}
+
+	offset := sinceMidnight(now)
+	if s.start <= s.stop {
+		return s.start <= offset && offset < s.stop
+	}
+
+	// the window wraps around midnight
+	return offset >= s.start || offset < s.stop
+}
+
+// windowStart returns when the window containing now opened.
+// Without a window, each day is treated as its own window.
+func (s schedule) windowStart(now gotime.Time) gotime.Time {
+	midnight := gotime.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if s.windowed && s.start > s.stop && sinceMidnight(now) < s.stop {
+		return midnight.AddDate(0, 0, -1).Add(s.start)
+	}
+
+	return midnight.Add(s.start)
+}
+
+func sinceMidnight(t gotime.Time) gotime.Duration {
+	return gotime.Duration(t.Hour())*gotime.Hour +
+		gotime.Duration(t.Minute())*gotime.Minute +
+		gotime.Duration(t.Second())*gotime.Second
+}
+
+func parseSource(raw map[string]any) (schedule, error) {
+	var source Source
+
+	sched := schedule{location: gotime.UTC}
+
+	err := resources.Decode(raw, &source)
+	if err != nil {
+		return sched, fmt.Errorf("invalid source: %w", err)
+	}
+
+	sched.initial = source.InitialVersion
+
+	if source.Interval != "" {
+		interval, err := gotime.ParseDuration(source.Interval)
+		if err != nil {
+			return sched, fmt.Errorf("invalid interval %q: %w", source.Interval, err)
+		}
+
+		if interval <= 0 {
+			return sched, fmt.Errorf("interval %q must be positive", source.Interval)
+		}
+
+		sched.interval = interval
+	}
+
+	if source.Location != "" {
+		location, err := gotime.LoadLocation(source.Location)
+		if err != nil {
+			return sched, fmt.Errorf("invalid location %q: %w", source.Location, err)
+		}
+
+		sched.location = location
+	}
+
+	if (source.Start == "") != (source.Stop == "") {
+		return sched, errors.New("start and stop must be configured together")
+	}
+
+	if source.Start != "" {
+		start, err := parseTimeOfDay(source.Start)
+		if err != nil {
+			return sched, fmt.Errorf("invalid start: %w", err)
+		}
+
+		stop, err := parseTimeOfDay(source.Stop)
+		if err != nil {
+			return sched, fmt.Errorf("invalid stop: %w", err)
+		}
+
+		if start != stop {
+			sched.start = start
+			sched.stop = stop
+			sched.windowed = true
+		}
+	}
+
+	if len(source.Days) > 0 {
+		sched.days = map[gotime.Weekday]bool{}
+
+		for _, day := range source.Days {
+			weekday, err := parseWeekday(day)
+			if err != nil {
+				return sched, err
+			}
+
+			sched.days[weekday] = true
+		}
+	}
+
+	return sched, nil
+}
+
+func parseTimeOfDay(value string) (gotime.Duration, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	for _, format := range timeOfDayFormats {
+		parsed, err := gotime.Parse(format, value)
+		if err == nil {
+			return sinceMidnight(parsed), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognized time of day %q", value)
+}
+
+func parseWeekday(value string) (gotime.Weekday, error) {
+	for day := gotime.Sunday; day <= gotime.Saturday; day++ {
+		if strings.EqualFold(day.String(), value) || strings.EqualFold(day.String()[:3], value) {
+			return day, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognized day %q", value)
+}
+
+func init() {
+	resources.Register("time", func() resources.Resource {
+		return &Time{}
+	})
+}
+
+var _ resources.Resource = &Time{}
@@ -0,0 +1,176 @@
+package time_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	gotime "time"
+
+	"github.com/jtarchie/pocketci/resources"
+	timeresource "github.com/jtarchie/pocketci/resources/time"
+	. "github.com/onsi/gomega"
+)
+
+func at(value string) func() gotime.Time {
+	return func() gotime.Time {
+		parsed, err := gotime.Parse(gotime.RFC3339, value)
+		if err != nil {
+			panic(err)
+		}
+
+		return parsed
+	}
+}
+
+func TestTimeResource(t *testing.T) {
+	t.Run("is registered", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(resources.IsNative("time")).To(BeTrue())
+
+		res, err := resources.Get("time")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(res.Name()).To(Equal("time"))
+	})
+
+	t.Run("check without a version returns now", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res := &timeresource.Time{Now: at("2024-01-01T12:00:00Z")}
+
+		resp, err := res.Check(context.Background(), resources.CheckRequest{
+			Source: map[string]any{"interval": "1h"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{{"time": "2024-01-01T12:00:00Z"}}))
+	})
+
+	t.Run("check emits a new version once the interval elapses", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		source := map[string]any{"interval": "1h"}
+		previous := resources.Version{"time": "2024-01-01T12:00:00Z"}
+
+		res := &timeresource.Time{Now: at("2024-01-01T12:30:00Z")}
+		resp, err := res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{previous}))
+
+		res = &timeresource.Time{Now: at("2024-01-01T13:00:00Z")}
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{previous, {"time": "2024-01-01T13:00:00Z"}}))
+	})
+
+	t.Run("check only emits inside the window", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		source := map[string]any{
+			"start":    "9:00 AM",
+			"stop":     "5:00 PM",
+			"location": "America/New_York",
+		}
+
+		// 08:00 in New York
+		res := &timeresource.Time{Now: at("2024-01-02T13:00:00Z")}
+		resp, err := res.Check(context.Background(), resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(BeEmpty())
+
+		// 10:00 in New York, previous version was yesterday
+		previous := resources.Version{"time": "2024-01-01T15:00:00Z"}
+		res = &timeresource.Time{Now: at("2024-01-02T15:00:00Z")}
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{previous, {"time": "2024-01-02T15:00:00Z"}}))
+
+		// 11:00 in New York, already triggered in this window
+		previous = resources.Version{"time": "2024-01-02T15:00:00Z"}
+		res = &timeresource.Time{Now: at("2024-01-02T16:00:00Z")}
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{previous}))
+	})
+
+	t.Run("check handles windows wrapping midnight", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		source := map[string]any{"start": "22:00", "stop": "02:00"}
+		previous := resources.Version{"time": "2024-01-01T22:30:00Z"}
+
+		res := &timeresource.Time{Now: at("2024-01-02T01:00:00Z")}
+		resp, err := res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{previous}))
+
+		res = &timeresource.Time{Now: at("2024-01-02T22:00:00Z")}
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source, Version: previous})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(HaveLen(2))
+	})
+
+	t.Run("check respects days", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		source := map[string]any{"interval": "1m", "days": []any{"Saturday", "sun"}}
+
+		// 2024-01-01 was a Monday
+		res := &timeresource.Time{Now: at("2024-01-01T12:00:00Z")}
+		resp, err := res.Check(context.Background(), resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(BeEmpty())
+
+		source["initial_version"] = true
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(HaveLen(1))
+
+		res = &timeresource.Time{Now: at("2024-01-06T12:00:00Z")}
+		resp, err = res.Check(context.Background(), resources.CheckRequest{Source: source})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp).To(Equal(resources.CheckResponse{{"time": "2024-01-06T12:00:00Z"}}))
+	})
+
+	t.Run("check rejects invalid source", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res := &timeresource.Time{}
+
+		_, err := res.Check(context.Background(), resources.CheckRequest{Source: map[string]any{"interval": "soon"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("invalid interval")))
+
+		_, err = res.Check(context.Background(), resources.CheckRequest{Source: map[string]any{"start": "9AM"}})
+		assert.Expect(err).To(MatchError(ContainSubstring("start and stop")))
+
+		_, err = res.Check(context.Background(), resources.CheckRequest{Source: map[string]any{"days": []any{"Funday"}}})
+		assert.Expect(err).To(MatchError(ContainSubstring("unrecognized day")))
+	})
+
+	t.Run("in writes the input file", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		destDir := t.TempDir()
+		res := &timeresource.Time{}
+
+		resp, err := res.In(context.Background(), destDir, resources.InRequest{
+			Version: resources.Version{"time": "2024-01-01T12:00:00Z"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp.Version).To(Equal(resources.Version{"time": "2024-01-01T12:00:00Z"}))
+
+		contents, err := os.ReadFile(filepath.Join(destDir, "input"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(string(contents)).To(Equal("2024-01-01T12:00:00Z"))
+	})
+
+	t.Run("out returns the current time", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		res := &timeresource.Time{Now: at("2024-01-01T12:00:00Z")}
+
+		resp, err := res.Out(context.Background(), t.TempDir(), resources.OutRequest{})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(resp.Version).To(Equal(resources.Version{"time": "2024-01-01T12:00:00Z"}))
+	})
+}
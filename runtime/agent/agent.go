@@ -21,10 +21,12 @@ import (
 	"google.golang.org/adk/tool/functiontool"
 	"google.golang.org/genai"
 
-	genaianthropic "github.com/achetronic/adk-utils-go/genai/anthropic"
-	genaiopenai "github.com/achetronic/adk-utils-go/genai/openai"
 	"github.com/achetronic/adk-utils-go/plugin/contextguard"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	openaioption "github.com/openai/openai-go/v3/option"
 
+	genaianthropic "github.com/jtarchie/pocketci/runtime/agent/genai/anthropic"
+	genaiopenai "github.com/jtarchie/pocketci/runtime/agent/genai/openai"
 	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
 	"github.com/jtarchie/pocketci/secrets"
 	"github.com/jtarchie/pocketci/storage"
@@ -71,6 +73,20 @@ type AgentConfig struct {
 	ContextGuard     *AgentContextGuardConfig               `json:"context_guard,omitempty"`
 	Limits           *AgentLimitsConfig                     `json:"limits,omitempty"`
 	Context          *AgentContext                          `json:"context,omitempty"`
+	Tools            []AgentTool                            `json:"tools,omitempty"`
+	MCPServers       []AgentMCPServer                       `json:"mcp_servers,omitempty"`
+	// OutputSchema is a JSON schema the final answer must match. The parsed
+	// answer is returned as AgentResult.Output.
+	OutputSchema map[string]any `json:"output_schema,omitempty"`
+	// Policy allows, denies, or requires approval of tool calls.
+	Policy []AgentPolicyRule `json:"policy,omitempty"`
+	// SubAgents are offered to the model as tools it delegates tasks to.
+	SubAgents []AgentSubAgent `json:"sub_agents,omitempty"`
+	// Memory gives the agent notes that persist across the pipeline's runs.
+	Memory *AgentMemoryConfig `json:"memory,omitempty"`
+	// Checkpoint saves the conversation after each completed turn so an
+	// interrupted run can continue from there. Set by the resumable runner.
+	Checkpoint *pipelinerunner.AgentCheckpoint `json:"checkpoint,omitempty"`
 	// OnOutput is called with streaming chunks. Not serialised from JS.
 	OnOutput pipelinerunner.OutputCallback `json:"-"`
 	// OnAuditEvent is called every time an audit event is appended.
@@ -78,19 +94,28 @@ type AgentConfig struct {
 	// OnUsage is called whenever cumulative usage changes.
 	OnUsage func(AgentUsage) `json:"-"`
 	// Internal fields populated by Runtime.Agent() — not exposed to JS.
-	Storage     storage.Driver
+	Storage     storage.Driver `json:"-"`
 	Namespace   string
 	RunID       string
 	PipelineID  string
 	TriggeredBy string
+	Approvals   *Approvals `json:"-"`
+	// Costs checks the pipeline's budget before the agent starts and
+	// between its turns, and records what it spent. Sub-agents' costs are
+	// recorded by their coordinator.
+	Costs CostTracker `json:"-"`
+	// budget is shared with sub-agents; nil for a top-level agent.
+	budget *agentBudget
 }
 
 // AgentResult is returned to JavaScript after the agent completes.
 type AgentResult struct {
 	Text     string       `json:"text"`
-	Status   string       `json:"status"` // "success", "failure", or "limit_exceeded"
+	Status   string       `json:"status"` // "success", "failure", "limit_exceeded", or "invalid_output"
 	Usage    AgentUsage   `json:"usage"`
 	AuditLog []AuditEvent `json:"auditLog"`
+	// Output is the parsed final answer when an output schema is set.
+	Output any `json:"output,omitempty"`
 }
 
 // AgentUsage tracks cumulative token counts and request stats.
@@ -100,6 +125,9 @@ type AgentUsage struct {
 	TotalTokens      int32 `json:"totalTokens"`
 	LLMRequests      int   `json:"llmRequests"`
 	ToolCallCount    int   `json:"toolCallCount"`
+	// Cost is in USD, from the server's model prices. It is zero when the
+	// model has no price.
+	Cost float64 `json:"cost,omitempty"`
 }
 
 // AuditUsage holds per-event token counts reported by the LLM.
@@ -117,6 +145,12 @@ type AuditUsage struct {
 //   - "tool_response" — the result returned to the model
 //   - "model_text"    — an intermediate model text chunk
 //   - "model_final"   — the final model response
+//   - "output_invalid" — the final response did not match the output schema
+//   - "policy_denied" — a tool call was refused by the policy
+//   - "approval_requested" — a tool call is waiting for human approval
+//   - "approval_decision" — a human approved or rejected a tool call
+//   - "sub_agent" — a sub-agent finished; Events holds its audit log
+//   - "resumed" — an interrupted run continued from its last checkpoint
 type AuditEvent struct {
 	Timestamp    string         `json:"timestamp,omitempty"`
 	InvocationID string         `json:"invocationId,omitempty"`
@@ -128,6 +162,16 @@ type AuditEvent struct {
 	ToolArgs     map[string]any `json:"toolArgs,omitempty"`
 	ToolResult   map[string]any `json:"toolResult,omitempty"`
 	Usage        *AuditUsage    `json:"usage,omitempty"`
+	Approval     *AuditApproval `json:"approval,omitempty"`
+	Events       []AuditEvent   `json:"events,omitempty"`
+}
+
+// AuditApproval identifies the approval of an approval_requested or
+// approval_decision event.
+type AuditApproval struct {
+	ID        string `json:"id"`
+	Decision  string `json:"decision,omitempty"` // "approved" or "rejected"
+	DecidedBy string `json:"decidedBy,omitempty"`
 }
 
 // runCommandInput is the tool schema for run_command.
@@ -218,13 +262,6 @@ type getTaskResultOutput struct {
 	Truncated bool   `json:"truncated"`
 }
 
-// defaultBaseURLs maps providers (that use the OpenAI-compatible API) to their base URLs.
-var defaultBaseURLs = map[string]string{
-	"openrouter": "https://openrouter.ai/api/v1",
-	"ollama":     "http://localhost:11434/v1",
-	"openai":     "https://api.openai.com/v1",
-}
-
 const (
 	defaultContextGuardMaxTurns  = 30
 	defaultContextGuardMaxTokens = 128000
@@ -243,6 +280,30 @@ func splitModel(model string) (provider, modelName string) {
 	return model[:idx], model[idx+1:]
 }
 
+// lookupModel finds the registered provider for a "provider/model-name"
+// string. A bare provider name uses the provider's default model.
+func lookupModel(model string) (Provider, string, error) {
+	providerName, modelName := splitModel(model)
+
+	provider, ok := GetProvider(providerName)
+	if !ok {
+		return Provider{}, "", fmt.Errorf(
+			"unknown provider %q: declare it in the server's agent providers or use one of %s",
+			providerName, strings.Join(ListProviders(), ", "),
+		)
+	}
+
+	if !strings.Contains(model, "/") {
+		if provider.DefaultModel == "" {
+			return Provider{}, "", fmt.Errorf("model %q has no model name and provider %q has no default_model", model, providerName)
+		}
+
+		modelName = provider.DefaultModel
+	}
+
+	return provider, modelName, nil
+}
+
 // resolveSecret looks up a secret key in pipeline → global scope order.
 // Falls back to the corresponding environment variable (PROVIDER_API_KEY) if not found.
 func resolveSecret(ctx context.Context, sm secrets.Manager, pipelineID, key string) string {
@@ -266,14 +327,28 @@ func resolveSecret(ctx context.Context, sm secrets.Manager, pipelineID, key stri
 // resolveModel builds an adk-compatible LLM model from provider + name + key.
 // llmCfg sets temperature and output token limit for all providers.
 // thinkingCfg provides Anthropic-specific extended thinking budget.
-func resolveModel(provider, modelName, apiKey string, llmCfg *AgentLLMConfig, thinkingCfg *AgentThinkingConfig) (adkmodel.LLM, error) {
-	switch provider {
-	case "anthropic":
+func resolveModel(provider Provider, modelName, apiKey string, llmCfg *AgentLLMConfig, thinkingCfg *AgentThinkingConfig) (adkmodel.LLM, error) {
+	headers, useKey := provider.authHeaders(apiKey)
+	if !useKey {
+		apiKey = ""
+	}
+
+	switch provider.API {
+	case ProviderAPIAnthropic:
 		cfg := genaianthropic.Config{
 			APIKey:    apiKey,
+			BaseURL:   provider.BaseURL,
 			ModelName: modelName,
 		}
 
+		if !useKey {
+			cfg.HTTPOptions.Options = authOptions(headers, anthropicoption.WithHeader, anthropicoption.WithHeaderDel)
+		}
+
+		if provider.MaxTokens > 0 {
+			cfg.MaxOutputTokens = provider.MaxTokens
+		}
+
 		if llmCfg != nil && llmCfg.MaxTokens > 0 {
 			cfg.MaxOutputTokens = int(llmCfg.MaxTokens)
 		}
@@ -288,27 +363,23 @@ func resolveModel(provider, modelName, apiKey string, llmCfg *AgentLLMConfig, th
 		}
 
 		return genaianthropic.New(cfg), nil
-	default:
-		// openrouter, openai, ollama, etc. all speak OpenAI-compatible API.
-		baseURL := defaultBaseURLs[provider]
-		if baseURL == "" {
-			return nil, fmt.Errorf("unknown provider %q: set a base URL or use anthropic/openai/openrouter/ollama", provider)
-		}
-
-		return genaiopenai.New(genaiopenai.Config{
+	case ProviderAPIOpenAI:
+		// openrouter, openai, ollama, vLLM, etc. all speak OpenAI-compatible API.
+		cfg := genaiopenai.Config{
 			APIKey:    apiKey,
-			BaseURL:   baseURL,
+			BaseURL:   provider.BaseURL,
 			ModelName: modelName,
-		}), nil
-	}
-}
+		}
 
-// simpleRegistry is a fallback ModelRegistry for contextguard that returns
-// conservative defaults when the model is not in a curated database.
-type simpleRegistry struct{}
+		if !useKey {
+			cfg.HTTPOptions.Options = authOptions(headers, openaioption.WithHeader, openaioption.WithHeaderDel)
+		}
 
-func (simpleRegistry) ContextWindow(_ string) int    { return 128000 }
-func (simpleRegistry) DefaultMaxTokens(_ string) int { return 4096 }
+		return genaiopenai.New(cfg), nil
+	default:
+		return nil, fmt.Errorf("provider %q has unsupported api %q", provider.Name, provider.API)
+	}
+}
 
 // harmCategoryFromString maps a YAML harm category key to a genai.HarmCategory.
 func harmCategoryFromString(s string) genai.HarmCategory {
@@ -330,7 +401,7 @@ func harmThresholdFromString(s string) genai.HarmBlockThreshold {
 
 // buildGenerateContentConfig constructs a genai.GenerateContentConfig from the
 // agent config fields. Returns nil when no tuning is requested.
-func buildGenerateContentConfig(provider string, llmCfg *AgentLLMConfig, thinkingCfg *AgentThinkingConfig, safety map[string]string) *genai.GenerateContentConfig {
+func buildGenerateContentConfig(api string, llmCfg *AgentLLMConfig, thinkingCfg *AgentThinkingConfig, safety map[string]string) *genai.GenerateContentConfig {
 	var gcc genai.GenerateContentConfig
 	has := false
 
@@ -347,7 +418,7 @@ func buildGenerateContentConfig(provider string, llmCfg *AgentLLMConfig, thinkin
 	}
 
 	// For non-Anthropic providers, wire thinking via GenerateContentConfig.
-	if thinkingCfg != nil && provider != "anthropic" {
+	if thinkingCfg != nil && api != ProviderAPIAnthropic {
 		budget := thinkingCfg.Budget
 		tc := &genai.ThinkingConfig{ThinkingBudget: &budget}
 
@@ -785,21 +856,102 @@ func RunAgent(
 	pipelineID string,
 	config AgentConfig,
 ) (*AgentResult, error) {
-	provider, modelName := splitModel(config.Model)
+	cassetteMode, cassettePath, err := cassetteFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	var (
+		provider  Provider
+		modelName string
+		replay    *Cassette
+	)
+
+	if cassetteMode == cassetteReplay {
+		replay, err = LoadCassette(cassettePath)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+
+		provider = Provider{Name: replayProvider, API: replay.API}
+	} else {
+		provider, modelName, err = lookupModel(config.Model)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+	}
 
 	// Resolve API key: secrets (pipeline → global) then env var fallback.
-	apiKey := resolveSecret(ctx, sm, pipelineID, "agent/"+provider)
+	apiKey := resolveSecret(ctx, sm, pipelineID, "agent/"+provider.Name)
 	if apiKey == "" {
-		envKey := strings.ToUpper(strings.ReplaceAll(provider, "-", "_")) + "_API_KEY"
+		envKey := strings.ToUpper(strings.ReplaceAll(provider.Name, "-", "_")) + "_API_KEY"
 		apiKey = os.Getenv(envKey)
 	}
 
-	// Start the sandbox container.
-	sandbox, err := sandboxRunner.StartSandbox(pipelinerunner.SandboxInput{
+	err = validateTools(config.Tools)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	pipelineToolNames := make([]string, 0, len(config.Tools))
+	for _, tool := range config.Tools {
+		pipelineToolNames = append(pipelineToolNames, tool.Name)
+	}
+
+	err = validateSubAgents(config.SubAgents, pipelineToolNames)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	err = validateMemory(config)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	err = validateMCPServers(config.MCPServers)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	outputSchema, err := compileOutputSchema(config.OutputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	err = validatePolicy(config.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	if config.Costs != nil {
+		err = config.Costs.Check(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+	}
+
+	// Continue an interrupted run from its last completed turn.
+	checkpointing := config.Checkpoint != nil && config.Checkpoint.Key != "" && config.Storage != nil
+
+	var restored *agentCheckpoint
+	if checkpointing && config.Checkpoint.Resume {
+		restored, err = loadCheckpoint(ctx, config.Storage, config.Checkpoint.Key)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+	}
+
+	sandboxInput := pipelinerunner.SandboxInput{
 		Image:  config.Image,
 		Name:   config.Name,
 		Mounts: config.Mounts,
-	})
+	}
+	if restored != nil {
+		sandboxInput.ReattachID = restored.SandboxID
+	}
+
+	// Start the sandbox container.
+	sandbox, err := sandboxRunner.StartSandbox(sandboxInput)
 	if err != nil {
 		return nil, fmt.Errorf("agent: failed to start sandbox: %w", err)
 	}
@@ -915,10 +1067,20 @@ func RunAgent(
 		return nil, fmt.Errorf("agent: failed to create read_file tool: %w", err)
 	}
 
-	// Resolve the LLM model.
-	llmModel, err := resolveModel(provider, modelName, apiKey, config.LLM, config.Thinking)
-	if err != nil {
-		return nil, fmt.Errorf("agent: %w", err)
+	// Resolve the LLM model, or the stand-in replaying a cassette.
+	var llmModel adkmodel.LLM
+
+	if replay != nil {
+		llmModel = newReplayModel(replay, cassettePath)
+	} else {
+		llmModel, err = resolveModel(provider, modelName, apiKey, config.LLM, config.Thinking)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+
+		if cassetteMode == cassetteRecord {
+			llmModel = newRecordingModel(llmModel, provider, config.Model, cassettePath)
+		}
 	}
 
 	// Build the system instruction describing the agent's environment (not the task).
@@ -961,6 +1123,21 @@ func RunAgent(
 	instrBuilder.WriteString("  - list_tasks: list all tasks in the current run with their statuses (pre-fetched at start)\n")
 	instrBuilder.WriteString("  - get_task_result: retrieve stdout, stderr, and exit code for a specific task by name\n")
 
+	// Memory needs somewhere to keep notes between runs.
+	memoryEnabled := config.Memory != nil && config.Storage != nil && pipelineID != ""
+	if memoryEnabled {
+		instrBuilder.WriteString("  - remember: save a note for future runs of this pipeline\n")
+		instrBuilder.WriteString("  - recall: search the notes saved by earlier runs of this pipeline\n")
+	}
+
+	for _, tool := range config.Tools {
+		fmt.Fprintf(&instrBuilder, "  - %s: %s\n", tool.Name, tool.Description)
+	}
+
+	for _, server := range config.MCPServers {
+		fmt.Fprintf(&instrBuilder, "  - tools of the %q MCP server\n", server.Name)
+	}
+
 	instrBuilder.WriteString("\nEfficiency rules:\n")
 	instrBuilder.WriteString("  - Each tool call costs one full LLM round-trip. Minimise calls.\n")
 	instrBuilder.WriteString("  - When you need multiple sequential shell steps, combine them into ONE run_script call (use 'set -e' so failures abort early).\n")
@@ -971,6 +1148,24 @@ func RunAgent(
 	maxTurns, maxTotalTokens := effectiveLimits(config.Limits)
 	fmt.Fprintf(&instrBuilder, "\nYou have a budget of %d turns. Use run_script to combine steps and finish well within this limit.\n", maxTurns)
 
+	if config.OutputSchema != nil {
+		schemaJSON, err := json.Marshal(config.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("agent: invalid output_schema: %w", err)
+		}
+
+		instrBuilder.WriteString("\nYour final response must be only a JSON value, without prose or code fences, matching this JSON schema:\n")
+		fmt.Fprintf(&instrBuilder, "%s\n", schemaJSON)
+	}
+
+	if memoryEnabled {
+		instrBuilder.WriteString("\nYou have memory that persists across runs of this pipeline. Call recall early to check for relevant notes, and remember durable lessons (project conventions, recurring failures), not details of this run.\n")
+	}
+
+	if len(config.Policy) > 0 {
+		instrBuilder.WriteString("\nSome tool calls are restricted by policy and may be denied or wait for human approval. A refused call returns an error explaining why; do not retry it unchanged.\n")
+	}
+
 	instruction := instrBuilder.String()
 
 	// Build list_tasks tool — zero input, returns all tasks for the current run.
@@ -1071,16 +1266,128 @@ func RunAgent(
 		return nil, fmt.Errorf("agent: failed to create get_task_result tool: %w", err)
 	}
 
+	customTools, err := buildTools(config.Tools, sandbox, config.OnOutput)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	// Initialise the audit log and usage; sub-agents and the policy callback
+	// add to them while tools run.
+	var auditEvents []AuditEvent
+	var usage AgentUsage
+
+	// Price model calls by the model that was recorded when replaying.
+	pricedModel := provider.Name + "/" + modelName
+	if replay != nil {
+		pricedModel = replay.Model
+	}
+
+	price, priced := GetPrice(pricedModel)
+
+	// recordedCost is the part of usage.Cost already added to the pipeline's
+	// spend; checkpoints record it as they go.
+	var recordedCost float64
+
+	recordCost := func(ctx context.Context) {
+		if config.Costs != nil && usage.Cost > recordedCost {
+			config.Costs.Record(ctx, usage.Cost-recordedCost)
+			recordedCost = usage.Cost
+		}
+	}
+
+	defer func() { recordCost(context.WithoutCancel(ctx)) }()
+
+	budget := config.budget
+	if budget == nil {
+		budget = newAgentBudget(config.Limits)
+	}
+
+	if restored != nil {
+		usage = restored.Usage
+		recordedCost = restored.Usage.Cost
+		budget.restore(restored.Turns, restored.Usage.TotalTokens)
+	}
+
+	subAgentTools, err := buildSubAgentTools(sandboxRunner, sm, pipelineID, config, budget,
+		func(subAgent AgentSubAgent, callID string, result *AgentResult) {
+			usage.PromptTokens += result.Usage.PromptTokens
+			usage.CompletionTokens += result.Usage.CompletionTokens
+			usage.TotalTokens += result.Usage.TotalTokens
+			usage.LLMRequests += result.Usage.LLMRequests
+			usage.ToolCallCount += result.Usage.ToolCallCount
+			usage.Cost += result.Usage.Cost
+			emitUsageSnapshot(config.OnUsage, usage)
+
+			appendAuditEvent(&auditEvents, AuditEvent{
+				Timestamp:  time.Now().UTC().Format(time.RFC3339),
+				Author:     config.Name,
+				Type:       "sub_agent",
+				Text:       result.Text,
+				ToolName:   subAgent.Name,
+				ToolCallID: callID,
+				Usage: &AuditUsage{
+					PromptTokens:     result.Usage.PromptTokens,
+					CompletionTokens: result.Usage.CompletionTokens,
+					TotalTokens:      result.Usage.TotalTokens,
+				},
+				Events: result.AuditLog,
+			}, config.OnAuditEvent)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	tools := append([]adktool.Tool{runCmd, runScript, readFileTool, listTasksTool, getTaskResultTool}, customTools...)
+	tools = append(tools, subAgentTools...)
+
+	if memoryEnabled {
+		memoryTools, err := buildMemoryTools(ctx, config, pipelineID)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+
+		tools = append(tools, memoryTools...)
+	}
+
+	reservedToolNames := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		reservedToolNames = append(reservedToolNames, tool.Name())
+	}
+
+	mcpToolsets, mcpConns, err := buildMCPToolsets(
+		ctx,
+		config.MCPServers,
+		sandbox,
+		func(key string) string { return resolveSecret(ctx, sm, pipelineID, key) },
+		reservedToolNames,
+		config.OnOutput,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+
+	defer mcpConns.Close()
+
+	var beforeToolCallbacks []llmagent.BeforeToolCallback
+	if len(config.Policy) > 0 {
+		beforeToolCallbacks = append(beforeToolCallbacks, policyCallback(config, func(event AuditEvent) {
+			appendAuditEvent(&auditEvents, event, config.OnAuditEvent)
+		}))
+	}
+
 	// Create the ADK agent.
-	genCfg := buildGenerateContentConfig(provider, config.LLM, config.Thinking, config.Safety)
+	genCfg := buildGenerateContentConfig(provider.API, config.LLM, config.Thinking, config.Safety)
 
 	myAgent, err := llmagent.New(llmagent.Config{
 		Name:                  config.Name,
 		Model:                 llmModel,
 		Description:           "An agent running in a CI/CD system with access to a containerized environment.",
 		Instruction:           instruction,
-		Tools:                 []adktool.Tool{runCmd, runScript, readFileTool, listTasksTool, getTaskResultTool},
+		Tools:                 tools,
+		Toolsets:              mcpToolsets,
 		GenerateContentConfig: genCfg,
+		BeforeToolCallbacks:   beforeToolCallbacks,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("agent: failed to create agent: %w", err)
@@ -1108,7 +1415,7 @@ func RunAgent(
 
 	// Wire context guard plugin when requested.
 	if config.ContextGuard != nil {
-		guard := contextguard.New(simpleRegistry{})
+		guard := contextguard.New(providerRegistry{provider: provider})
 
 		opts, optionsErr := resolveContextGuardOptions(config.ContextGuard)
 		if optionsErr != nil {
@@ -1129,10 +1436,35 @@ func RunAgent(
 		}
 	}
 
-	// Initialise audit log and base timestamp for pre-context entries.
-	var auditEvents []AuditEvent
+	// Base timestamp for pre-context entries.
 	now := time.Now().UTC()
 
+	// A resumed run replays the saved conversation in place of the prompt
+	// and pre-context, then marks where it picked up in the audit log.
+	if restored != nil {
+		err = restoreCheckpoint(ctx, sessionService, sessResp.Session, restored)
+		if err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+
+		for _, event := range restored.AuditLog {
+			appendAuditEvent(&auditEvents, event, config.OnAuditEvent)
+		}
+
+		sandboxNote := "in a new sandbox"
+		if sandbox.Reattached() {
+			sandboxNote = "reusing its sandbox"
+		}
+
+		appendAuditEvent(&auditEvents, AuditEvent{
+			Timestamp: now.Format(time.RFC3339),
+			Author:    "system",
+			Type:      "resumed",
+			Text:      fmt.Sprintf("Resumed after an interruption at turn %d, %s.", restored.Turns, sandboxNote),
+		}, config.OnAuditEvent)
+		emitUsageSnapshot(config.OnUsage, usage)
+	}
+
 	// Add the user message to the session first so that pre-context synthetic
 	// tool calls appear AFTER it in conversation history — the LLM sees:
 	//   1. User: "Review this PR..."
@@ -1147,18 +1479,20 @@ func RunAgent(
 		Content: genai.NewContentFromText(config.Prompt, genai.RoleUser),
 	}
 
-	_ = sessionService.AppendEvent(ctx, sessResp.Session, userEvent)
+	if restored == nil {
+		_ = sessionService.AppendEvent(ctx, sessResp.Session, userEvent)
 
-	appendAuditEvent(&auditEvents, AuditEvent{
-		Timestamp: now.Format(time.RFC3339),
-		Author:    "user",
-		Type:      "user_message",
-		Text:      config.Prompt,
-	}, config.OnAuditEvent)
+		appendAuditEvent(&auditEvents, AuditEvent{
+			Timestamp: now.Format(time.RFC3339),
+			Author:    "user",
+			Type:      "user_message",
+			Text:      config.Prompt,
+		}, config.OnAuditEvent)
+	}
 
 	// Pre-inject a synthetic list_tasks result so the agent knows the run
 	// state from turn 0 without spending a tool-call turn on orientation.
-	if config.Storage != nil && config.RunID != "" {
+	if restored == nil && config.Storage != nil && config.RunID != "" {
 		summaries, err := loadTaskSummaries(ctx, config.Storage, config.RunID)
 		if err == nil && len(summaries) > 0 {
 			taskMaps := make([]any, len(summaries))
@@ -1187,7 +1521,7 @@ func RunAgent(
 	}
 
 	// Pre-inject explicitly declared context tasks as get_task_result results.
-	if config.Context != nil && config.Storage != nil && config.RunID != "" {
+	if restored == nil && config.Context != nil && config.Storage != nil && config.RunID != "" {
 		maxBytes := config.Context.MaxBytes
 		if maxBytes <= 0 {
 			maxBytes = 4096
@@ -1274,7 +1608,7 @@ func RunAgent(
 	// Pre-inject declared context files as synthetic read_file results.
 	// We use sandbox.Exec (cat) so this works for all drivers — Docker volumes
 	// have no accessible host path from the agent process.
-	if config.Context != nil && len(config.Context.Files) > 0 {
+	if restored == nil && config.Context != nil && len(config.Context.Files) > 0 {
 		for _, cf := range config.Context.Files {
 			var execInput pipelinerunner.ExecInput
 			execInput.Command.Path = "/bin/sh"
@@ -1329,167 +1663,290 @@ func RunAgent(
 	// so we pass nil here — runnr.Run early-returns from appendMessageToSession
 	// when msg is nil, avoiding a duplicate turn.
 	var textBuilder strings.Builder
-	var usage AgentUsage
+
+	// Only the final response is checked against the output schema, not text
+	// the model writes alongside its tool calls.
+	var finalBuilder strings.Builder
 
 	// Wrap context so we can cancel on hard limit.
 	runCtx, cancelRun := context.WithCancel(ctx)
 	defer cancelRun()
 
 	var turnCount int
+	if restored != nil {
+		turnCount = restored.Turns
+	}
+
 	limitExceeded := false
 	warningInjected := false
 
 	var runErr error
 
-	for event, err := range runnr.Run(runCtx, "pipeline", sessResp.Session.ID(), nil, agent.RunConfig{}) {
-		if err != nil {
-			if !errors.Is(err, context.Canceled) {
-				runErr = err
-			}
+	// A final answer that does not match the output schema is sent back to
+	// the model with the validation error, a bounded number of times.
+	var message *genai.Content
+	var output any
+	outputInvalid := false
 
-			break
-		}
-
-		// Accumulate token usage from every LLM response.
-		var eventUsage *AuditUsage
-		if event.UsageMetadata != nil {
-			usage.PromptTokens += event.UsageMetadata.PromptTokenCount
-			usage.CompletionTokens += event.UsageMetadata.CandidatesTokenCount
-			usage.TotalTokens += event.UsageMetadata.TotalTokenCount
-			usage.LLMRequests++
-			turnCount++
-			emitUsageSnapshot(config.OnUsage, usage)
-			eventUsage = &AuditUsage{
-				PromptTokens:     event.UsageMetadata.PromptTokenCount,
-				CompletionTokens: event.UsageMetadata.CandidatesTokenCount,
-				TotalTokens:      event.UsageMetadata.TotalTokenCount,
-			}
+	for attempt := 0; ; attempt++ {
+		textBuilder.Reset()
+		finalBuilder.Reset()
 
-			// Check hard token limit.
-			if maxTotalTokens > 0 && usage.TotalTokens >= maxTotalTokens {
-				appendAuditEvent(&auditEvents, AuditEvent{
-					Timestamp: time.Now().UTC().Format(time.RFC3339),
-					Author:    "system",
-					Type:      "limit_warning",
-					Text:      fmt.Sprintf("Total token budget exhausted (%d/%d tokens used). Stopping agent.", usage.TotalTokens, maxTotalTokens),
-				}, config.OnAuditEvent)
-
-				limitExceeded = true
-				cancelRun()
+		for event, err := range runnr.Run(runCtx, "pipeline", sessResp.Session.ID(), message, agent.RunConfig{}) {
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					runErr = err
+				}
 
 				break
 			}
 
-			// Inject a warning when approaching the turn limit.
-			if !warningInjected && turnCount == maxTurns-limitWarningTurnsBefore {
-				warningMsg := fmt.Sprintf(
-					"You are approaching your turn limit (%d/%d turns used). "+
-						"Please wrap up your current task and provide a final response within the next %d turn(s).",
-					turnCount, maxTurns, limitWarningTurnsBefore,
-				)
-
-				appendAuditEvent(&auditEvents, AuditEvent{
-					Timestamp: time.Now().UTC().Format(time.RFC3339),
-					Author:    "system",
-					Type:      "limit_warning",
-					Text:      warningMsg,
-				}, config.OnAuditEvent)
-
-				warningInjected = true
-			}
-
-			// Check hard turn limit.
-			if turnCount >= maxTurns {
-				appendAuditEvent(&auditEvents, AuditEvent{
-					Timestamp: time.Now().UTC().Format(time.RFC3339),
-					Author:    "system",
-					Type:      "limit_warning",
-					Text:      fmt.Sprintf("Turn limit reached (%d/%d). Stopping agent.", turnCount, maxTurns),
-				}, config.OnAuditEvent)
+			// Accumulate token usage from every LLM response.
+			var eventUsage *AuditUsage
+			if event.UsageMetadata != nil {
+				usage.PromptTokens += event.UsageMetadata.PromptTokenCount
+				usage.CompletionTokens += event.UsageMetadata.CandidatesTokenCount
+				usage.TotalTokens += event.UsageMetadata.TotalTokenCount
+				usage.LLMRequests++
 
-				limitExceeded = true
-				cancelRun()
+				if priced {
+					usage.Cost += price.Cost(event.UsageMetadata.PromptTokenCount, event.UsageMetadata.CandidatesTokenCount)
+				}
 
-				break
+				turnCount++
+				treeTurns, treeTokens := budget.spend(event.UsageMetadata.TotalTokenCount)
+				emitUsageSnapshot(config.OnUsage, usage)
+				eventUsage = &AuditUsage{
+					PromptTokens:     event.UsageMetadata.PromptTokenCount,
+					CompletionTokens: event.UsageMetadata.CandidatesTokenCount,
+					TotalTokens:      event.UsageMetadata.TotalTokenCount,
+				}
+
+				// Check hard token limit, of this agent and of the coordinator
+				// whose limits cover its sub-agents.
+				tokensUsed, tokenLimit := usage.TotalTokens, maxTotalTokens
+				if budget.maxTotalTokens > 0 && treeTokens >= budget.maxTotalTokens {
+					tokensUsed, tokenLimit = treeTokens, budget.maxTotalTokens
+				}
+
+				if tokenLimit > 0 && tokensUsed >= tokenLimit {
+					appendAuditEvent(&auditEvents, AuditEvent{
+						Timestamp: time.Now().UTC().Format(time.RFC3339),
+						Author:    "system",
+						Type:      "limit_warning",
+						Text:      fmt.Sprintf("Total token budget exhausted (%d/%d tokens used). Stopping agent.", tokensUsed, tokenLimit),
+					}, config.OnAuditEvent)
+
+					limitExceeded = true
+					cancelRun()
+
+					break
+				}
+
+				// Inject a warning when approaching the turn limit.
+				if !warningInjected && turnCount == maxTurns-limitWarningTurnsBefore {
+					warningMsg := fmt.Sprintf(
+						"You are approaching your turn limit (%d/%d turns used). "+
+							"Please wrap up your current task and provide a final response within the next %d turn(s).",
+						turnCount, maxTurns, limitWarningTurnsBefore,
+					)
+
+					appendAuditEvent(&auditEvents, AuditEvent{
+						Timestamp: time.Now().UTC().Format(time.RFC3339),
+						Author:    "system",
+						Type:      "limit_warning",
+						Text:      warningMsg,
+					}, config.OnAuditEvent)
+
+					warningInjected = true
+				}
+
+				// Check hard turn limit, likewise.
+				turnsUsed, turnLimit := turnCount, maxTurns
+				if treeTurns >= budget.maxTurns {
+					turnsUsed, turnLimit = treeTurns, budget.maxTurns
+				}
+
+				if turnsUsed >= turnLimit {
+					appendAuditEvent(&auditEvents, AuditEvent{
+						Timestamp: time.Now().UTC().Format(time.RFC3339),
+						Author:    "system",
+						Type:      "limit_warning",
+						Text:      fmt.Sprintf("Turn limit reached (%d/%d). Stopping agent.", turnsUsed, turnLimit),
+					}, config.OnAuditEvent)
+
+					limitExceeded = true
+					cancelRun()
+
+					break
+				}
+
+				// Check the pipeline's budget before the next turn, with what
+				// this agent has spent so far recorded.
+				if config.Costs != nil && !event.IsFinalResponse() {
+					recordCost(ctx)
+
+					err := config.Costs.Check(ctx)
+					if errors.Is(err, ErrBudgetExceeded) {
+						appendAuditEvent(&auditEvents, AuditEvent{
+							Timestamp: time.Now().UTC().Format(time.RFC3339),
+							Author:    "system",
+							Type:      "limit_warning",
+							Text:      fmt.Sprintf("%s. Stopping agent.", err),
+						}, config.OnAuditEvent)
+
+						limitExceeded = true
+						cancelRun()
+
+						break
+					}
+
+					if err != nil {
+						runErr = err
+						cancelRun()
+
+						break
+					}
+				}
 			}
-		}
 
-		if event.Content == nil {
-			continue
-		}
-
-		// Compute timestamp and finality for audit events.
-		ts := now.Format(time.RFC3339)
-		if !event.Timestamp.IsZero() {
-			ts = event.Timestamp.UTC().Format(time.RFC3339)
-		}
-
-		isFinal := event.IsFinalResponse()
-		usageAttached := false
+			if event.Content == nil {
+				continue
+			}
 
-		for _, part := range event.Content.Parts {
-			// Track function calls (tool invocations by the model).
-			if part.FunctionCall != nil {
-				fc := part.FunctionCall
-				usage.ToolCallCount++
+			// Compute timestamp and finality for audit events.
+			ts := now.Format(time.RFC3339)
+			if !event.Timestamp.IsZero() {
+				ts = event.Timestamp.UTC().Format(time.RFC3339)
+			}
 
-				appendAuditEvent(&auditEvents, AuditEvent{
+			isFinal := event.IsFinalResponse()
+			usageAttached := false
+
+			for _, part := range event.Content.Parts {
+				// Track function calls (tool invocations by the model).
+				if part.FunctionCall != nil {
+					fc := part.FunctionCall
+					usage.ToolCallCount++
+
+					appendAuditEvent(&auditEvents, AuditEvent{
+						Timestamp:    ts,
+						InvocationID: event.InvocationID,
+						Author:       event.Author,
+						Type:         "tool_call",
+						ToolName:     fc.Name,
+						ToolCallID:   fc.ID,
+						ToolArgs:     fc.Args,
+					}, config.OnAuditEvent)
+					emitUsageSnapshot(config.OnUsage, usage)
+				}
+
+				// Track function responses (tool results).
+				if part.FunctionResponse != nil {
+					fr := part.FunctionResponse
+
+					appendAuditEvent(&auditEvents, AuditEvent{
+						Timestamp:    ts,
+						InvocationID: event.InvocationID,
+						Author:       event.Author,
+						Type:         "tool_response",
+						ToolName:     fr.Name,
+						ToolCallID:   fr.ID,
+						ToolResult:   fr.Response,
+					}, config.OnAuditEvent)
+				}
+
+				if part.Text == "" {
+					continue
+				}
+
+				textBuilder.WriteString(part.Text)
+
+				if isFinal {
+					finalBuilder.WriteString(part.Text)
+				}
+
+				if config.OnOutput != nil {
+					config.OnOutput("stdout", part.Text)
+				}
+
+				eventType := "model_text"
+				if isFinal {
+					eventType = "model_final"
+				}
+
+				ae := AuditEvent{
 					Timestamp:    ts,
 					InvocationID: event.InvocationID,
 					Author:       event.Author,
-					Type:         "tool_call",
-					ToolName:     fc.Name,
-					ToolCallID:   fc.ID,
-					ToolArgs:     fc.Args,
-				}, config.OnAuditEvent)
-				emitUsageSnapshot(config.OnUsage, usage)
-			}
+					Type:         eventType,
+					Text:         part.Text,
+				}
 
-			// Track function responses (tool results).
-			if part.FunctionResponse != nil {
-				fr := part.FunctionResponse
+				if !usageAttached {
+					ae.Usage = eventUsage
+					usageAttached = true
+				}
 
-				appendAuditEvent(&auditEvents, AuditEvent{
-					Timestamp:    ts,
-					InvocationID: event.InvocationID,
-					Author:       event.Author,
-					Type:         "tool_response",
-					ToolName:     fr.Name,
-					ToolCallID:   fr.ID,
-					ToolResult:   fr.Response,
-				}, config.OnAuditEvent)
+				appendAuditEvent(&auditEvents, ae, config.OnAuditEvent)
 			}
 
-			if part.Text == "" {
-				continue
+			// Save the conversation once the model has its tool results. A
+			// failed save only loses the chance to resume from this turn.
+			if checkpointing && isCompletedTurn(event) {
+				recordCost(ctx)
+
+				_ = saveCheckpoint(ctx, config.Storage, config.Checkpoint.Key, sessionService, sessResp.Session, agentCheckpoint{
+					SandboxID: sandbox.ID(),
+					Turns:     turnCount,
+					Usage:     usage,
+					AuditLog:  auditEvents,
+				})
 			}
+		}
 
-			textBuilder.WriteString(part.Text)
+		if runErr != nil || limitExceeded || outputSchema == nil {
+			break
+		}
 
-			if config.OnOutput != nil {
-				config.OnOutput("stdout", part.Text)
-			}
+		parsed, parseErr := parseOutput(outputSchema, finalBuilder.String())
+		if parseErr == nil {
+			output = parsed
 
-			eventType := "model_text"
-			if isFinal {
-				eventType = "model_final"
-			}
+			break
+		}
 
-			ae := AuditEvent{
-				Timestamp:    ts,
-				InvocationID: event.InvocationID,
-				Author:       event.Author,
-				Type:         eventType,
-				Text:         part.Text,
-			}
+		appendAuditEvent(&auditEvents, AuditEvent{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Author:    "system",
+			Type:      "output_invalid",
+			Text:      parseErr.Error(),
+		}, config.OnAuditEvent)
 
-			if !usageAttached {
-				ae.Usage = eventUsage
-				usageAttached = true
-			}
+		if attempt >= outputSchemaRetries {
+			outputInvalid = true
 
-			appendAuditEvent(&auditEvents, ae, config.OnAuditEvent)
+			break
 		}
+
+		feedback := fmt.Sprintf(
+			"Your final response was rejected: %s. Respond again with only a JSON value matching the required schema.",
+			parseErr,
+		)
+		message = genai.NewContentFromText(feedback, genai.RoleUser)
+
+		appendAuditEvent(&auditEvents, AuditEvent{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Author:    "user",
+			Type:      "user_message",
+			Text:      feedback,
+		}, config.OnAuditEvent)
+	}
+
+	// An interrupted run is an error rather than a partial answer, so a
+	// resumable runner can continue it later.
+	if ctx.Err() != nil && !limitExceeded {
+		return nil, fmt.Errorf("agent: run interrupted: %w", ctx.Err())
 	}
 
 	if runErr != nil {
@@ -1503,10 +1960,18 @@ func RunAgent(
 		status = "limit_exceeded"
 	}
 
+	if outputInvalid {
+		status = "invalid_output"
+	}
+
 	// Write result.json to the output path inside the sandbox if configured.
 	outputMountPath := resolveOutputMountPath(config)
 	if outputMountPath != "" {
-		resultData := map[string]string{"status": status, "text": finalText}
+		resultData := map[string]any{"status": status, "text": finalText}
+		if output != nil {
+			resultData["output"] = output
+		}
+
 		data, err := json.Marshal(resultData)
 		if err != nil {
 			return nil, fmt.Errorf("agent: marshal output result: %w", err)
@@ -1535,5 +2000,6 @@ func RunAgent(
 		Status:   status,
 		Usage:    usage,
 		AuditLog: auditEvents,
+		Output:   output,
 	}, nil
 }
@@ -307,6 +307,11 @@ func TestAuditEventTypes(t *testing.T) {
 		"tool_response",
 		"model_text",
 		"model_final",
+		"output_invalid",
+		"policy_denied",
+		"approval_requested",
+		"approval_decision",
+		"sub_agent",
 	}
 
 	for _, typ := range knownTypes {
@@ -93,6 +93,22 @@ func (f *fakeStorage) UpdateStatusForPrefix(_ context.Context, _ string, _ []str
 	return nil
 }
 
+func (f *fakeStorage) Delete(_ context.Context, _ string) error {
+	return nil
+}
+
+func (f *fakeStorage) AppendLogs(_ context.Context, _ string, _ []storage.LogChunk) error {
+	return nil
+}
+
+func (f *fakeStorage) GetLogs(_ context.Context, _ string, _, _ int) ([]storage.LogChunk, error) {
+	return nil, nil
+}
+
+func (f *fakeStorage) CloseLogs(_ context.Context, _ string) error {
+	return nil
+}
+
 func (f *fakeStorage) SavePipeline(_ context.Context, _ string, _ string, _ string, _ string) (*storage.Pipeline, error) {
 	return nil, fmt.Errorf("not implemented")
 }
@@ -125,6 +141,10 @@ func (f *fakeStorage) UpdateRunStatus(_ context.Context, _ string, _ storage.Run
 	return fmt.Errorf("not implemented")
 }
 
+func (f *fakeStorage) DeleteRun(_ context.Context, _ string) error {
+	return fmt.Errorf("not implemented")
+}
+
 func (f *fakeStorage) SearchPipelines(_ context.Context, _ string, _, _ int) (*storage.PaginationResult[storage.Pipeline], error) {
 	return nil, fmt.Errorf("not implemented")
 }
@@ -141,6 +161,42 @@ func (f *fakeStorage) UpdatePipelineRBACExpression(_ context.Context, _ string,
 	return fmt.Errorf("not implemented")
 }
 
+func (f *fakeStorage) UpdatePipelineSchedule(_ context.Context, _ string, _ *storage.PipelineSchedule) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) UpdatePipelineMonthlyBudget(_ context.Context, _ string, _ float64) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) UpdatePipelineRetention(_ context.Context, _ string, _ *storage.PipelineRetention) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) SavePipelineVersion(_ context.Context, _ storage.PipelineVersion) (*storage.PipelineVersion, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) GetPipelineVersion(_ context.Context, _ string, _ int) (*storage.PipelineVersion, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) ListPipelineVersions(_ context.Context, _ string, _, _ int) (*storage.PaginationResult[storage.PipelineVersion], error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) UpdateRunPipelineVersion(_ context.Context, _ string, _ int) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) AddRunCost(_ context.Context, _ string, _ float64) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) GetPipelineCost(_ context.Context, _ string, _ time.Time) (float64, error) {
+	return 0, fmt.Errorf("not implemented")
+}
+
 func (f *fakeStorage) GetRunsByStatus(_ context.Context, _ storage.RunStatus) ([]storage.PipelineRun, error) {
 	return nil, fmt.Errorf("not implemented")
 }
@@ -177,9 +233,13 @@ func newSequencedLLMServer(t *testing.T, responses []string) (*httptest.Server,
 func configureFakeOpenAI(t *testing.T, baseURL string) {
 	t.Helper()
 
-	origBaseURL := defaultBaseURLs["openai"]
-	defaultBaseURLs["openai"] = baseURL + "/v1"
-	t.Cleanup(func() { defaultBaseURLs["openai"] = origBaseURL })
+	original, _ := GetProvider("openai")
+	err := RegisterProvider(Provider{Name: "openai", BaseURL: baseURL + "/v1"})
+	if err != nil {
+		t.Fatalf("register provider: %v", err)
+	}
+
+	t.Cleanup(func() { _ = RegisterProvider(original) })
 	t.Setenv("OPENAI_API_KEY", "test-key")
 }
 
@@ -0,0 +1,450 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/google/uuid"
+	"google.golang.org/adk/agent/llmagent"
+	adktool "google.golang.org/adk/tool"
+
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// ErrApprovalNotFound is returned when deciding an approval that is not
+// pending, because it was never requested or was already answered.
+var ErrApprovalNotFound = errors.New("approval not found")
+
+// AgentPolicyRule decides what happens when the model calls a tool. Rules are
+// checked in order and the first one matching applies; calls matching no rule
+// are allowed.
+type AgentPolicyRule struct {
+	// Tool is a path.Match pattern of tool names, e.g. "run_*". Empty matches
+	// every tool.
+	Tool string `json:"tool,omitempty"`
+	// When is an expr expression over PolicyEnv, e.g.
+	// `command contains "kubectl"`. Empty always matches.
+	When string `json:"when,omitempty"`
+	// Action is "allow", "deny" or "approve".
+	Action string `json:"action"`
+	// Approvers restricts who may answer an "approve" rule with an expr
+	// expression over the user, like pipeline RBAC. Empty lets anyone with
+	// access to the run answer.
+	Approvers string `json:"approvers,omitempty"`
+	// Reason is shown to approvers, and to the model when a call is denied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyEnv is the expression environment of a rule's When.
+type PolicyEnv struct {
+	Tool string         `expr:"tool"`
+	Args map[string]any `expr:"args"`
+	// Command is the command line of run_command or the script of
+	// run_script, and empty for other tools.
+	Command string `expr:"command"`
+}
+
+var policyActions = []string{"allow", "deny", "approve"}
+
+// validatePolicy checks policy rules before the sandbox is started.
+func validatePolicy(rules []AgentPolicyRule) error {
+	for i, rule := range rules {
+		if !slices.Contains(policyActions, rule.Action) {
+			return fmt.Errorf("policy rule %d has action %q, must be one of %s", i, rule.Action, strings.Join(policyActions, ", "))
+		}
+
+		_, err := path.Match(rule.Tool, "")
+		if err != nil {
+			return fmt.Errorf("policy rule %d has invalid tool pattern %q: %w", i, rule.Tool, err)
+		}
+
+		if rule.When != "" {
+			_, err = expr.Compile(rule.When, expr.Env(PolicyEnv{}), expr.AsBool())
+			if err != nil {
+				return fmt.Errorf("policy rule %d has invalid when expression: %w", i, err)
+			}
+		}
+
+		if rule.Approvers != "" && rule.Action != "approve" {
+			return fmt.Errorf("policy rule %d sets approvers, which requires action \"approve\"", i)
+		}
+	}
+
+	return nil
+}
+
+func policyEnv(tool string, args map[string]any) PolicyEnv {
+	env := PolicyEnv{Tool: tool, Args: args}
+
+	switch tool {
+	case "run_command":
+		command, _ := args["command"].(string)
+		parts := []string{command}
+
+		list, _ := args["args"].([]any)
+		for _, arg := range list {
+			parts = append(parts, fmt.Sprint(arg))
+		}
+
+		env.Command = strings.Join(parts, " ")
+	case "run_script":
+		env.Command, _ = args["script"].(string)
+	}
+
+	return env
+}
+
+// matchPolicy returns the first rule matching a tool call.
+func matchPolicy(rules []AgentPolicyRule, tool string, args map[string]any) (AgentPolicyRule, bool, error) {
+	env := policyEnv(tool, args)
+
+	for _, rule := range rules {
+		if rule.Tool != "" {
+			matched, _ := path.Match(rule.Tool, tool)
+			if !matched {
+				continue
+			}
+		}
+
+		if rule.When == "" {
+			return rule, true, nil
+		}
+
+		program, err := expr.Compile(rule.When, expr.Env(PolicyEnv{}), expr.AsBool())
+		if err != nil {
+			return AgentPolicyRule{}, false, fmt.Errorf("policy compile error: %w", err)
+		}
+
+		result, err := expr.Run(program, env)
+		if err != nil {
+			return AgentPolicyRule{}, false, fmt.Errorf("policy eval error: %w", err)
+		}
+
+		if result.(bool) { //nolint:forcetypeassert
+			return rule, true, nil
+		}
+	}
+
+	return AgentPolicyRule{}, false, nil
+}
+
+// ApprovalRequest is a tool call waiting for a human decision.
+type ApprovalRequest struct {
+	ID          string         `json:"id"`
+	RunID       string         `json:"run_id"`
+	PipelineID  string         `json:"pipeline_id,omitempty"`
+	Agent       string         `json:"agent"`
+	ToolName    string         `json:"tool_name"`
+	ToolArgs    map[string]any `json:"tool_args,omitempty"`
+	Reason      string         `json:"reason,omitempty"`
+	Approvers   string         `json:"approvers,omitempty"`
+	RequestedAt time.Time      `json:"requested_at"`
+}
+
+// ApprovalDecision answers an ApprovalRequest.
+type ApprovalDecision struct {
+	Approved  bool   `json:"approved"`
+	DecidedBy string `json:"decided_by,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Approvals holds the approval requests of agent tool calls. Requests wait
+// in storage until they are decided, so every server sharing the storage can
+// list and decide the requests of runs executing on any of them. Requests
+// block until they are decided or their run stops.
+type Approvals struct {
+	store storage.Driver
+
+	// PollInterval is how often a waiting request checks storage for a
+	// decision made by another server.
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	waiting map[string]chan ApprovalDecision
+}
+
+// approvalRecord is an approval request as kept in storage, with its decision
+// once it is made.
+type approvalRecord struct {
+	Request  ApprovalRequest   `json:"request"`
+	Decision *ApprovalDecision `json:"decision,omitempty"`
+}
+
+// NewApprovals creates approvals kept in store.
+func NewApprovals(store storage.Driver) *Approvals {
+	return &Approvals{
+		store:        store,
+		PollInterval: time.Second,
+		waiting:      map[string]chan ApprovalDecision{},
+	}
+}
+
+func approvalsPrefix(runID string) string {
+	return "/approvals/" + runID
+}
+
+// Request stores an approval request and waits for its decision. It returns
+// the context's error when ctx ends first. The request is removed from
+// storage once it returns.
+func (a *Approvals) Request(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	if request.ID == "" {
+		request.ID = uuid.NewString()
+	}
+
+	if request.RequestedAt.IsZero() {
+		request.RequestedAt = time.Now().UTC()
+	}
+
+	key := approvalsPrefix(request.RunID) + "/" + request.ID
+	decided := make(chan ApprovalDecision, 1)
+
+	a.mu.Lock()
+	a.waiting[request.ID] = decided
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.waiting, request.ID)
+		a.mu.Unlock()
+
+		_ = a.store.Delete(context.WithoutCancel(ctx), key)
+	}()
+
+	err := a.store.Set(ctx, key, approvalRecord{Request: request})
+	if err != nil {
+		return ApprovalDecision{}, fmt.Errorf("could not store approval request: %w", err)
+	}
+
+	ticker := time.NewTicker(a.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case decision := <-decided:
+			return decision, nil
+		case <-ticker.C:
+			record, err := a.load(ctx, request.RunID, request.ID)
+			if err == nil && record.Decision != nil {
+				return *record.Decision, nil
+			}
+		case <-ctx.Done():
+			return ApprovalDecision{}, ctx.Err()
+		}
+	}
+}
+
+// Pending returns the requests of a run that are waiting for a decision,
+// oldest first.
+func (a *Approvals) Pending(ctx context.Context, runID string) ([]ApprovalRequest, error) {
+	prefix := approvalsPrefix(runID)
+
+	results, err := a.store.GetAll(ctx, prefix, []string{"*"})
+	if err != nil {
+		return nil, fmt.Errorf("could not load approvals: %w", err)
+	}
+
+	requests := []ApprovalRequest{}
+
+	for _, result := range results {
+		// The prefix also matches run IDs that start with this one.
+		if !strings.HasSuffix(path.Dir(result.Path), prefix) {
+			continue
+		}
+
+		record, err := decodeApproval(result.Payload)
+		if err != nil || record.Decision != nil {
+			continue
+		}
+
+		requests = append(requests, record.Request)
+	}
+
+	slices.SortFunc(requests, func(a, b ApprovalRequest) int {
+		return a.RequestedAt.Compare(b.RequestedAt)
+	})
+
+	return requests, nil
+}
+
+// Get returns a pending request of a run.
+func (a *Approvals) Get(ctx context.Context, runID, id string) (ApprovalRequest, error) {
+	record, err := a.load(ctx, runID, id)
+	if err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	if record.Decision != nil {
+		return ApprovalRequest{}, ErrApprovalNotFound
+	}
+
+	return record.Request, nil
+}
+
+// Decide answers a pending request of a run, resuming the waiting tool call.
+func (a *Approvals) Decide(ctx context.Context, runID, id string, decision ApprovalDecision) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record, err := a.load(ctx, runID, id)
+	if err != nil {
+		return err
+	}
+
+	if record.Decision != nil {
+		return ErrApprovalNotFound
+	}
+
+	record.Decision = &decision
+
+	err = a.store.Set(ctx, approvalsPrefix(runID)+"/"+id, record)
+	if err != nil {
+		return fmt.Errorf("could not store approval decision: %w", err)
+	}
+
+	// A request waiting in this process is resumed now, others when they
+	// next check storage.
+	if decided, ok := a.waiting[id]; ok {
+		decided <- decision
+	}
+
+	return nil
+}
+
+// Clear removes the requests of a run, such as when it stopped without
+// the requests being able to remove themselves.
+func (a *Approvals) Clear(ctx context.Context, runID string) error {
+	err := a.store.Delete(ctx, approvalsPrefix(runID))
+	if err != nil {
+		return fmt.Errorf("could not clear approvals: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Approvals) load(ctx context.Context, runID, id string) (approvalRecord, error) {
+	if id == "" || id == ".." || path.Base(id) != id {
+		return approvalRecord{}, ErrApprovalNotFound
+	}
+
+	payload, err := a.store.Get(ctx, approvalsPrefix(runID)+"/"+id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return approvalRecord{}, ErrApprovalNotFound
+	}
+
+	if err != nil {
+		return approvalRecord{}, fmt.Errorf("could not load approval: %w", err)
+	}
+
+	record, err := decodeApproval(payload)
+	if err != nil || record.Request.RunID != runID {
+		return approvalRecord{}, ErrApprovalNotFound
+	}
+
+	return record, nil
+}
+
+func decodeApproval(payload storage.Payload) (approvalRecord, error) {
+	contents, err := json.Marshal(payload)
+	if err != nil {
+		return approvalRecord{}, fmt.Errorf("could not encode approval: %w", err)
+	}
+
+	var record approvalRecord
+
+	err = json.Unmarshal(contents, &record)
+	if err != nil {
+		return approvalRecord{}, fmt.Errorf("could not decode approval: %w", err)
+	}
+
+	return record, nil
+}
+
+// policyCallback enforces the agent's policy before each tool call. Denied
+// and rejected calls are not run; the model receives the reason as the
+// tool's error instead.
+func policyCallback(config AgentConfig, audit func(AuditEvent)) llmagent.BeforeToolCallback {
+	return func(toolCtx adktool.Context, tool adktool.Tool, args map[string]any) (map[string]any, error) {
+		rule, matched, err := matchPolicy(config.Policy, tool.Name(), args)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched || rule.Action == "allow" {
+			return nil, nil //nolint: nilnil
+		}
+
+		event := AuditEvent{
+			Timestamp:    time.Now().UTC().Format(time.RFC3339),
+			InvocationID: toolCtx.InvocationID(),
+			Author:       "system",
+			ToolName:     tool.Name(),
+			ToolCallID:   toolCtx.FunctionCallID(),
+			ToolArgs:     args,
+			Text:         rule.Reason,
+		}
+
+		if rule.Action == "deny" || config.Approvals == nil {
+			event.Type = "policy_denied"
+			audit(event)
+
+			if rule.Action == "approve" {
+				return nil, fmt.Errorf("tool call %q requires approval, which is not available for this run", tool.Name())
+			}
+
+			if rule.Reason != "" {
+				return nil, fmt.Errorf("tool call %q denied by policy: %s", tool.Name(), rule.Reason)
+			}
+
+			return nil, fmt.Errorf("tool call %q denied by policy", tool.Name())
+		}
+
+		request := ApprovalRequest{
+			ID:         uuid.NewString(),
+			RunID:      config.RunID,
+			PipelineID: config.PipelineID,
+			Agent:      config.Name,
+			ToolName:   tool.Name(),
+			ToolArgs:   args,
+			Reason:     rule.Reason,
+			Approvers:  rule.Approvers,
+		}
+
+		event.Type = "approval_requested"
+		event.Approval = &AuditApproval{ID: request.ID}
+		audit(event)
+
+		decision, err := config.Approvals.Request(toolCtx, request)
+		if err != nil {
+			return nil, fmt.Errorf("tool call %q was not approved: %w", tool.Name(), err)
+		}
+
+		event.Type = "approval_decision"
+		event.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		event.Text = decision.Reason
+		event.Approval = &AuditApproval{ID: request.ID, Decision: "rejected", DecidedBy: decision.DecidedBy}
+
+		if decision.Approved {
+			event.Approval.Decision = "approved"
+		}
+
+		audit(event)
+
+		if !decision.Approved {
+			if decision.Reason != "" {
+				return nil, fmt.Errorf("tool call %q rejected by %s: %s", tool.Name(), decision.DecidedBy, decision.Reason)
+			}
+
+			return nil, fmt.Errorf("tool call %q rejected by %s", tool.Name(), decision.DecidedBy)
+		}
+
+		return nil, nil //nolint: nilnil
+	}
+}
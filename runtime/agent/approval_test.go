@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates rules", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(validatePolicy([]AgentPolicyRule{
+			{Tool: "run_*", When: `command contains "kubectl"`, Action: "approve", Approvers: `"ops" in Groups`},
+			{Tool: "read_file", Action: "allow"},
+			{Action: "deny"},
+		})).To(Succeed())
+
+		assert.Expect(validatePolicy([]AgentPolicyRule{{Action: "maybe"}})).
+			To(MatchError(ContainSubstring(`policy rule 0 has action "maybe"`)))
+		assert.Expect(validatePolicy([]AgentPolicyRule{{Tool: "[run", Action: "deny"}})).
+			To(MatchError(ContainSubstring(`policy rule 0 has invalid tool pattern "[run"`)))
+		assert.Expect(validatePolicy([]AgentPolicyRule{{When: `command +`, Action: "deny"}})).
+			To(MatchError(ContainSubstring("policy rule 0 has invalid when expression")))
+		assert.Expect(validatePolicy([]AgentPolicyRule{{Action: "deny", Approvers: "true"}})).
+			To(MatchError(ContainSubstring(`requires action "approve"`)))
+	})
+
+	t.Run("applies the first matching rule", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		rules := []AgentPolicyRule{
+			{Tool: "run_*", When: `command matches "^rm "`, Action: "deny"},
+			{Tool: "run_command", When: `args.command == "kubectl"`, Action: "approve"},
+			{Tool: "run_*", Action: "allow"},
+		}
+
+		rule, matched, err := matchPolicy(rules, "run_command", map[string]any{"command": "rm", "args": []any{"-rf", "/"}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(matched).To(BeTrue())
+		assert.Expect(rule.Action).To(Equal("deny"))
+
+		rule, matched, err = matchPolicy(rules, "run_script", map[string]any{"script": "rm -rf /"})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(matched).To(BeTrue())
+		assert.Expect(rule.Action).To(Equal("deny"))
+
+		rule, matched, err = matchPolicy(rules, "run_command", map[string]any{"command": "kubectl", "args": []any{"apply"}})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(matched).To(BeTrue())
+		assert.Expect(rule.Action).To(Equal("approve"))
+
+		rule, matched, err = matchPolicy(rules, "run_command", map[string]any{"command": "ls"})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(matched).To(BeTrue())
+		assert.Expect(rule.Action).To(Equal("allow"))
+
+		_, matched, err = matchPolicy(rules, "read_file", map[string]any{"path": "README.md"})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(matched).To(BeFalse())
+	})
+}
+
+func TestApprovals(t *testing.T) {
+	t.Parallel()
+
+	t.Run("resumes the request with its decision", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		approvals := NewApprovals(newMemoryStorage(t))
+		decided := make(chan ApprovalDecision, 1)
+
+		go func() {
+			decision, _ := approvals.Request(context.Background(), ApprovalRequest{ID: "a1", RunID: "run-1", ToolName: "run_command"})
+			decided <- decision
+		}()
+
+		assert.Eventually(func() ([]ApprovalRequest, error) { return approvals.Pending(context.Background(), "run-1") }).Should(HaveLen(1))
+		assert.Expect(approvals.Pending(context.Background(), "run-2")).To(BeEmpty())
+
+		_, err := approvals.Get(context.Background(), "run-2", "a1")
+		assert.Expect(err).To(MatchError(ErrApprovalNotFound))
+		assert.Expect(approvals.Decide(context.Background(), "run-2", "a1", ApprovalDecision{Approved: true})).To(MatchError(ErrApprovalNotFound))
+
+		request, err := approvals.Get(context.Background(), "run-1", "a1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(request.ToolName).To(Equal("run_command"))
+
+		assert.Expect(approvals.Decide(context.Background(), "run-1", "a1", ApprovalDecision{Approved: true, DecidedBy: "ops@example.com"})).To(Succeed())
+		assert.Eventually(decided).Should(Receive(Equal(ApprovalDecision{Approved: true, DecidedBy: "ops@example.com"})))
+
+		assert.Expect(approvals.Pending(context.Background(), "run-1")).To(BeEmpty())
+		assert.Expect(approvals.Decide(context.Background(), "run-1", "a1", ApprovalDecision{})).To(MatchError(ErrApprovalNotFound))
+	})
+
+	t.Run("gives up when the context ends", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		approvals := NewApprovals(newMemoryStorage(t))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := approvals.Request(ctx, ApprovalRequest{RunID: "run-1"})
+		assert.Expect(err).To(MatchError(context.DeadlineExceeded))
+		assert.Expect(approvals.Pending(context.Background(), "run-1")).To(BeEmpty())
+	})
+
+	t.Run("is decided by another server sharing the storage", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		waiting, deciding := NewApprovals(store), NewApprovals(store)
+		waiting.PollInterval = 10 * time.Millisecond
+
+		decided := make(chan ApprovalDecision, 1)
+
+		go func() {
+			decision, _ := waiting.Request(context.Background(), ApprovalRequest{ID: "a1", RunID: "run-1", ToolName: "run_command"})
+			decided <- decision
+		}()
+
+		assert.Eventually(func() ([]ApprovalRequest, error) { return deciding.Pending(context.Background(), "run-1") }).Should(HaveLen(1))
+		assert.Expect(deciding.Pending(context.Background(), "run-10")).To(BeEmpty())
+
+		request, err := deciding.Get(context.Background(), "run-1", "a1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(request.ToolName).To(Equal("run_command"))
+
+		assert.Expect(deciding.Decide(context.Background(), "run-1", "a1", ApprovalDecision{Approved: true, DecidedBy: "ops@example.com"})).To(Succeed())
+		assert.Eventually(decided).Should(Receive(Equal(ApprovalDecision{Approved: true, DecidedBy: "ops@example.com"})))
+
+		assert.Expect(deciding.Pending(context.Background(), "run-1")).To(BeEmpty())
+		assert.Expect(deciding.Decide(context.Background(), "run-1", "a1", ApprovalDecision{})).To(MatchError(ErrApprovalNotFound))
+	})
+}
+
+func toolCallCompletion(id, name, arguments string) string {
+	return fmt.Sprintf(`{
+		"id":"chatcmpl-%s",
+		"object":"chat.completion",
+		"created":1730000000,
+		"model":"fake-model",
+		"choices":[{
+			"index":0,
+			"message":{"role":"assistant","content":"","tool_calls":[{"id":%q,"type":"function","function":{"name":%q,"arguments":%q}}]},
+			"finish_reason":"tool_calls"
+		}],
+		"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}
+	}`, id, id, name, arguments)
+}
+
+func TestRunAgent_Policy(t *testing.T) {
+	policy := []AgentPolicyRule{
+		{Tool: "run_command", When: `command contains "deploy"`, Action: "approve", Reason: "deploys need sign-off"},
+		{Tool: "run_script", Action: "deny", Reason: "scripts are not allowed"},
+	}
+
+	auditTypes := func(result *AgentResult) []string {
+		types := []string{}
+		for _, event := range result.AuditLog {
+			types = append(types, event.Type)
+		}
+
+		return types
+	}
+
+	t.Run("denies tool calls without running them", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_script", "run_script", `{"script":"echo hi"}`),
+			chatCompletion("done"),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-policy-deny"), nil, "", AgentConfig{
+			Name:   "deployer",
+			Prompt: "Deploy.",
+			Model:  "openai/fake-model",
+			Policy: policy,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(auditTypes(result)).To(Equal([]string{"user_message", "tool_call", "policy_denied", "tool_response", "model_final"}))
+		assert.Expect(result.AuditLog[3].ToolResult).To(HaveKeyWithValue("error", ContainSubstring("denied by policy: scripts are not allowed")))
+	})
+
+	t.Run("waits for an approver and records the decision", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_deploy", "run_command", `{"command":"echo","args":["deploy"]}`),
+			chatCompletion("done"),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		approvals := NewApprovals(newMemoryStorage(t))
+
+		go func() {
+			for {
+				pending, _ := approvals.Pending(context.Background(), "run-policy")
+				if len(pending) > 0 {
+					_ = approvals.Decide(context.Background(), "run-policy", pending[0].ID, ApprovalDecision{DecidedBy: "ops@example.com", Reason: "not today"})
+
+					return
+				}
+
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-policy-approve"), nil, "", AgentConfig{
+			Name:      "deployer",
+			Prompt:    "Deploy.",
+			Model:     "openai/fake-model",
+			Policy:    policy,
+			RunID:     "run-policy",
+			Approvals: approvals,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(auditTypes(result)).To(Equal([]string{"user_message", "tool_call", "approval_requested", "approval_decision", "tool_response", "model_final"}))
+
+		requested, decided := result.AuditLog[2], result.AuditLog[3]
+		assert.Expect(requested.Text).To(Equal("deploys need sign-off"))
+		assert.Expect(decided.Approval.ID).To(Equal(requested.Approval.ID))
+		assert.Expect(decided.Approval.Decision).To(Equal("rejected"))
+		assert.Expect(decided.Approval.DecidedBy).To(Equal("ops@example.com"))
+		assert.Expect(result.AuditLog[4].ToolResult).To(HaveKeyWithValue("error", ContainSubstring("rejected by ops@example.com: not today")))
+	})
+}
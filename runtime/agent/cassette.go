@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	adkmodel "google.golang.org/adk/model"
+)
+
+// Cassettes record an agent's model exchange so it can be replayed without
+// reaching the provider. CI_AGENT_REPLAY or CI_AGENT_RECORD name a directory
+// of cassettes, one per agent name, to replay from or record to. A
+// "replay/<path>" model string replays the cassette at path within the
+// CI_AGENT_REPLAY directory instead.
+const (
+	replayProvider    = "replay"
+	cassetteReplayEnv = "CI_AGENT_REPLAY"
+	cassetteRecordEnv = "CI_AGENT_RECORD"
+
+	cassetteReplay = "replay"
+	cassetteRecord = "record"
+)
+
+// Cassette is a recorded exchange between an agent and its model. Model calls
+// are replayed in the order they were recorded.
+type Cassette struct {
+	// Model is the "provider/model-name" string the cassette was recorded with.
+	Model string `json:"model"`
+	// API is the recorded provider's API, so replays build the same
+	// generation config.
+	API          string                `json:"api"`
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// CassetteInteraction is one model call: the request sent and the responses
+// or error that came back.
+type CassetteInteraction struct {
+	Request   json.RawMessage   `json:"request"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+var unsafeCassetteChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// cassetteFor returns whether an agent run replays or records a cassette,
+// and the cassette's path. The mode is empty when no cassette is used. The
+// path of a "replay/<path>" model string must stay within the CI_AGENT_REPLAY
+// directory, so that pipelines cannot read other files of the server.
+func cassetteFor(config AgentConfig) (string, string, error) {
+	replayDir := os.Getenv(cassetteReplayEnv)
+
+	providerName, path := splitModel(config.Model)
+	if providerName == replayProvider {
+		if replayDir == "" {
+			return "", "", fmt.Errorf("model %q replays a cassette, which requires %s to be set", config.Model, cassetteReplayEnv)
+		}
+
+		if !filepath.IsLocal(path) {
+			return "", "", fmt.Errorf("model %q must name a cassette within the %s directory", config.Model, cassetteReplayEnv)
+		}
+
+		return cassetteReplay, filepath.Join(replayDir, path), nil
+	}
+
+	name := unsafeCassetteChars.ReplaceAllString(config.Name, "_") + ".json"
+
+	if replayDir != "" {
+		return cassetteReplay, filepath.Join(replayDir, name), nil
+	}
+
+	if dir := os.Getenv(cassetteRecordEnv); dir != "" {
+		return cassetteRecord, filepath.Join(dir, name), nil
+	}
+
+	return "", "", nil
+}
+
+// LoadCassette reads a recorded cassette.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cassette: %w", err)
+	}
+
+	var cassette Cassette
+
+	err = json.Unmarshal(data, &cassette)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse cassette %s: %w", path, err)
+	}
+
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, creating its directory. Cassettes hold
+// the agent's prompts and tool output, so only their owner may read them.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal cassette: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return fmt.Errorf("could not create cassette directory: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// recordingModel passes model calls through to the provider and saves each
+// one to the cassette as it completes, so a failed run is still recorded.
+type recordingModel struct {
+	adkmodel.LLM
+
+	mu       sync.Mutex
+	path     string
+	cassette Cassette
+}
+
+func newRecordingModel(llm adkmodel.LLM, provider Provider, model, path string) *recordingModel {
+	return &recordingModel{
+		LLM:      llm,
+		path:     path,
+		cassette: Cassette{Model: model, API: provider.API, Interactions: []CassetteInteraction{}},
+	}
+}
+
+func (m *recordingModel) GenerateContent(ctx context.Context, req *adkmodel.LLMRequest, stream bool) iter.Seq2[*adkmodel.LLMResponse, error] {
+	return func(yield func(*adkmodel.LLMResponse, error) bool) {
+		// Snapshot the request and responses as they are seen, since the
+		// agent keeps mutating them.
+		request, err := json.Marshal(req)
+		if err != nil {
+			yield(nil, fmt.Errorf("could not record model request: %w", err))
+
+			return
+		}
+
+		interaction := CassetteInteraction{Request: request}
+
+		record := func() error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.cassette.Interactions = append(m.cassette.Interactions, interaction)
+
+			return m.cassette.Save(m.path)
+		}
+
+		for resp, err := range m.LLM.GenerateContent(ctx, req, stream) {
+			if err != nil {
+				interaction.Error = err.Error()
+			} else {
+				data, marshalErr := json.Marshal(resp)
+				if marshalErr != nil {
+					yield(nil, fmt.Errorf("could not record model response: %w", marshalErr))
+
+					return
+				}
+
+				interaction.Responses = append(interaction.Responses, data)
+			}
+
+			if !yield(resp, err) {
+				_ = record()
+
+				return
+			}
+		}
+
+		err = record()
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// replayModel stands in for a provider, answering model calls with the
+// cassette's recorded responses in order.
+type replayModel struct {
+	mu       sync.Mutex
+	path     string
+	cassette *Cassette
+	next     int
+}
+
+func newReplayModel(cassette *Cassette, path string) *replayModel {
+	return &replayModel{path: path, cassette: cassette}
+}
+
+func (m *replayModel) Name() string {
+	return m.cassette.Model
+}
+
+func (m *replayModel) GenerateContent(_ context.Context, _ *adkmodel.LLMRequest, _ bool) iter.Seq2[*adkmodel.LLMResponse, error] {
+	return func(yield func(*adkmodel.LLMResponse, error) bool) {
+		m.mu.Lock()
+		index := m.next
+		m.next++
+		m.mu.Unlock()
+
+		if index >= len(m.cassette.Interactions) {
+			yield(nil, fmt.Errorf("cassette %s has %d recorded model calls, but call %d was made", m.path, len(m.cassette.Interactions), index+1))
+
+			return
+		}
+
+		interaction := m.cassette.Interactions[index]
+
+		for _, data := range interaction.Responses {
+			var resp adkmodel.LLMResponse
+
+			err := json.Unmarshal(data, &resp)
+			if err != nil {
+				yield(nil, fmt.Errorf("could not parse response of call %d in cassette %s: %w", index+1, m.path, err))
+
+				return
+			}
+
+			if !yield(&resp, nil) {
+				return
+			}
+		}
+
+		if interaction.Error != "" {
+			yield(nil, errors.New(interaction.Error))
+		}
+	}
+}
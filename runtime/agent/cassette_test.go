@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRunAgent_Cassette(t *testing.T) {
+	auditTypes := func(result *AgentResult) []string {
+		types := []string{}
+		for _, event := range result.AuditLog {
+			types = append(types, event.Type)
+		}
+
+		return types
+	}
+
+	t.Run("records an exchange and replays it without the provider", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_echo", "run_command", `{"command":"echo","args":["hello"]}`),
+			chatCompletion("The command printed hello."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		dir := t.TempDir()
+		t.Setenv(cassetteRecordEnv, dir)
+
+		config := AgentConfig{
+			Name:   "echo agent",
+			Prompt: "Run echo.",
+			Model:  "openai/fake-model",
+		}
+
+		recorded, err := RunAgent(context.Background(), newNativeRunner(t, "agent-record"), nil, "", config)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(recorded.Text).To(Equal("The command printed hello."))
+
+		cassette, err := LoadCassette(filepath.Join(dir, "echo_agent.json"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(cassette.Model).To(Equal("openai/fake-model"))
+		assert.Expect(cassette.API).To(Equal(ProviderAPIOpenAI))
+		assert.Expect(cassette.Interactions).To(HaveLen(2))
+		assert.Expect(string(cassette.Interactions[1].Request)).To(ContainSubstring("hello"))
+
+		llm.Close()
+		t.Setenv(cassetteReplayEnv, dir)
+
+		replayed, err := RunAgent(context.Background(), newNativeRunner(t, "agent-replay"), nil, "", config)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(replayed.Text).To(Equal(recorded.Text))
+		assert.Expect(replayed.Usage).To(Equal(recorded.Usage))
+		assert.Expect(auditTypes(replayed)).To(Equal(auditTypes(recorded)))
+	})
+
+	t.Run("replays a cassette named by the model string", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		t.Setenv(cassetteReplayEnv, "testdata")
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-replay-model"), nil, "", AgentConfig{
+			Name:   "reviewer",
+			Prompt: "Review the change.",
+			Model:  "replay/cassettes/reviewer.json",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+		assert.Expect(result.Text).To(Equal("The command printed hello."))
+		assert.Expect(auditTypes(result)).To(Equal([]string{"user_message", "tool_call", "tool_response", "model_final"}))
+		assert.Expect(result.AuditLog[2].ToolResult).To(HaveKeyWithValue("stdout", "hello\n"))
+	})
+
+	t.Run("fails when the run makes more model calls than were recorded", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		cassette, err := LoadCassette("testdata/cassettes/reviewer.json")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		cassette.Interactions = cassette.Interactions[:1]
+		dir := t.TempDir()
+		assert.Expect(cassette.Save(filepath.Join(dir, "short.json"))).To(Succeed())
+		t.Setenv(cassetteReplayEnv, dir)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-replay-short"), nil, "", AgentConfig{
+			Name:   "reviewer",
+			Prompt: "Review the change.",
+			Model:  "replay/short.json",
+		})
+		assert.Expect(err).To(MatchError(ContainSubstring("has 1 recorded model calls, but call 2 was made")))
+		assert.Expect(result).To(BeNil())
+	})
+
+	t.Run("only replays model string cassettes within the replay directory", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		run := func(model string) error {
+			_, err := RunAgent(context.Background(), newNativeRunner(t, "agent-replay-outside"), nil, "", AgentConfig{
+				Name:   "reviewer",
+				Prompt: "Review the change.",
+				Model:  model,
+			})
+
+			return err
+		}
+
+		assert.Expect(run("replay/testdata/cassettes/reviewer.json")).To(MatchError(ContainSubstring("requires CI_AGENT_REPLAY to be set")))
+
+		t.Setenv(cassetteReplayEnv, "testdata/cassettes")
+
+		for _, model := range []string{"replay/../cassettes/reviewer.json", "replay/" + filepath.Join(t.TempDir(), "reviewer.json")} {
+			assert.Expect(run(model)).To(MatchError(ContainSubstring("must name a cassette within the CI_AGENT_REPLAY directory")), model)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	adkmodel "google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// agentCheckpoint is an agent's conversation as of its last completed turn.
+type agentCheckpoint struct {
+	SandboxID string            `json:"sandbox_id,omitempty"`
+	Turns     int               `json:"turns"`
+	Usage     AgentUsage        `json:"usage"`
+	AuditLog  []AuditEvent      `json:"audit_log"`
+	Events    []checkpointEvent `json:"events"`
+}
+
+// checkpointEvent is the part of a session event needed to replay it.
+type checkpointEvent struct {
+	InvocationID string         `json:"invocation_id"`
+	Author       string         `json:"author"`
+	Timestamp    time.Time      `json:"timestamp"`
+	Content      *genai.Content `json:"content"`
+}
+
+// loadCheckpoint returns the checkpoint saved under key, or nil when there is
+// none.
+func loadCheckpoint(ctx context.Context, store storage.Driver, key string) (*agentCheckpoint, error) {
+	payload, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("could not load checkpoint: %w", err)
+	}
+
+	contents, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode checkpoint: %w", err)
+	}
+
+	var checkpoint agentCheckpoint
+
+	err = json.Unmarshal(contents, &checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode checkpoint: %w", err)
+	}
+
+	if len(checkpoint.Events) == 0 {
+		return nil, nil
+	}
+
+	return &checkpoint, nil
+}
+
+// saveCheckpoint records the session's events along with the rest of
+// checkpoint under key.
+func saveCheckpoint(
+	ctx context.Context,
+	store storage.Driver,
+	key string,
+	svc session.Service,
+	sess session.Session,
+	checkpoint agentCheckpoint,
+) error {
+	resp, err := svc.Get(ctx, &session.GetRequest{
+		AppName:   sess.AppName(),
+		UserID:    sess.UserID(),
+		SessionID: sess.ID(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not get session: %w", err)
+	}
+
+	for event := range resp.Session.Events().All() {
+		if event.Content == nil {
+			continue
+		}
+
+		checkpoint.Events = append(checkpoint.Events, checkpointEvent{
+			InvocationID: event.InvocationID,
+			Author:       event.Author,
+			Timestamp:    event.Timestamp,
+			Content:      event.Content,
+		})
+	}
+
+	err = store.Set(ctx, key, checkpoint)
+	if err != nil {
+		return fmt.Errorf("could not save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// restoreCheckpoint appends the checkpoint's events to an empty session.
+func restoreCheckpoint(ctx context.Context, svc session.Service, sess session.Session, checkpoint *agentCheckpoint) error {
+	for _, saved := range checkpoint.Events {
+		event := session.NewEvent(saved.InvocationID)
+		event.Author = saved.Author
+		event.Timestamp = saved.Timestamp
+		event.LLMResponse = adkmodel.LLMResponse{Content: saved.Content}
+
+		err := svc.AppendEvent(ctx, sess, event)
+		if err != nil {
+			return fmt.Errorf("could not restore session event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// isCompletedTurn reports whether event returns tool results to the model,
+// after which the conversation can be continued safely.
+func isCompletedTurn(event *session.Event) bool {
+	if event.Partial || event.Content == nil {
+		return false
+	}
+
+	for _, part := range event.Content.Parts {
+		if part.FunctionResponse != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
+	"github.com/jtarchie/pocketci/storage"
+	. "github.com/onsi/gomega"
+)
+
+func TestRunAgent_Checkpoint(t *testing.T) {
+	const key = "_resume/agent/run-1/0-reviewer"
+
+	newConfig := func(store storage.Driver, resume bool) AgentConfig {
+		return AgentConfig{
+			Name:       "reviewer",
+			Prompt:     "Review the change.",
+			Model:      "openai/fake-model",
+			Storage:    store,
+			Checkpoint: &pipelinerunner.AgentCheckpoint{Key: key, Resume: resume},
+		}
+	}
+
+	auditTypes := func(result *AgentResult) []string {
+		types := []string{}
+		for _, event := range result.AuditLog {
+			types = append(types, event.Type)
+		}
+
+		return types
+	}
+
+	// interrupt runs the agent until it asks the model for a second turn,
+	// then cancels it as a shutdown would.
+	interrupt := func(t *testing.T, store storage.Driver, sandboxRunner pipelinerunner.Runner) {
+		t.Helper()
+		assert := NewGomegaWithT(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var requests int
+
+		llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests++
+			if requests > 1 {
+				cancel()
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(toolCallCompletion("call_write", "run_script", `{"script":"echo wrote the note"}`)))
+		}))
+		t.Cleanup(llm.Close)
+		configureFakeOpenAI(t, llm.URL)
+
+		_, err := RunAgent(ctx, sandboxRunner, nil, "pipeline-1", newConfig(store, false))
+		assert.Expect(err).To(MatchError(ContainSubstring("agent: run interrupted")))
+
+		checkpoint, err := loadCheckpoint(context.Background(), store, key)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(checkpoint).NotTo(BeNil())
+		assert.Expect(checkpoint.Turns).To(Equal(1))
+		assert.Expect(checkpoint.Events).To(HaveLen(3))
+		assert.Expect(checkpoint.AuditLog).To(HaveLen(3))
+	}
+
+	// newRecordingLLMServer serves responses in order and keeps the request
+	// bodies.
+	newRecordingLLMServer := func(t *testing.T, responses ...string) func() []string {
+		t.Helper()
+
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+
+		llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			response := responses[min(len(bodies), len(responses))-1]
+			mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(response))
+		}))
+		t.Cleanup(llm.Close)
+		configureFakeOpenAI(t, llm.URL)
+
+		return func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return append([]string{}, bodies...)
+		}
+	}
+
+	t.Run("continues an interrupted conversation", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		interrupt(t, store, newNativeRunner(t, "agent-checkpoint-1"))
+
+		bodies := newRecordingLLMServer(t, chatCompletion("Looks good."))
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-checkpoint-2"), nil, "pipeline-1", newConfig(store, true))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+		assert.Expect(result.Text).To(Equal("Looks good."))
+		assert.Expect(auditTypes(result)).To(Equal([]string{"user_message", "tool_call", "tool_response", "resumed", "model_final"}))
+		assert.Expect(result.AuditLog[3].Text).To(Equal("Resumed after an interruption at turn 1, in a new sandbox."))
+		assert.Expect(result.Usage.LLMRequests).To(Equal(2))
+		assert.Expect(result.Usage.TotalTokens).To(Equal(int32(30)))
+
+		assert.Expect(bodies()).To(HaveLen(1))
+		assert.Expect(bodies()[0]).To(ContainSubstring("Review the change."))
+		assert.Expect(bodies()[0]).To(ContainSubstring("wrote the note"))
+	})
+
+	t.Run("reuses the sandbox it was running in", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		sandboxRunner := newNativeRunner(t, "agent-checkpoint-3")
+		interrupt(t, store, sandboxRunner)
+
+		// A crash leaves the sandbox running, unlike the cancelled run above.
+		sandbox, err := sandboxRunner.StartSandbox(pipelinerunner.SandboxInput{Name: "reviewer"})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		var execInput pipelinerunner.ExecInput
+		execInput.Command.Path = "/bin/sh"
+		execInput.Command.Args = []string{"-c", "echo kept > marker.txt"}
+		_, err = sandbox.Exec(execInput)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		err = store.Set(context.Background(), key, map[string]any{"sandbox_id": sandbox.ID()})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		newRecordingLLMServer(t,
+			toolCallCompletion("call_read", "run_command", `{"command":"cat","args":["marker.txt"]}`),
+			chatCompletion("Looks good."),
+		)
+
+		result, err := RunAgent(context.Background(), sandboxRunner, nil, "pipeline-1", newConfig(store, true))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.AuditLog[3].Text).To(Equal("Resumed after an interruption at turn 1, reusing its sandbox."))
+		assert.Expect(result.AuditLog[5].Type).To(Equal("tool_response"))
+		assert.Expect(result.AuditLog[5].ToolResult["stdout"]).To(Equal("kept\n"))
+	})
+
+	t.Run("starts over without a checkpoint", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		newRecordingLLMServer(t, chatCompletion("Looks good."))
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-checkpoint-4"), nil, "pipeline-1", newConfig(store, true))
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(auditTypes(result)).To(Equal([]string{"user_message", "model_final"}))
+	})
+}
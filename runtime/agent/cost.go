@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ErrBudgetExceeded is returned when an agent is started after its
+// pipeline's budget has been spent. A running agent that spends it stops
+// with a limit_exceeded status instead.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ModelPrice is what a model costs, in USD per million tokens.
+type ModelPrice struct {
+	// Model is the "provider/model-name" string the price applies to.
+	Model            string  `json:"model" yaml:"model"`
+	InputPerMillion  float64 `json:"input_per_million" yaml:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million" yaml:"output_per_million"`
+}
+
+// Cost returns the USD cost of the given token counts.
+func (p ModelPrice) Cost(promptTokens, completionTokens int32) float64 {
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1_000_000
+}
+
+var (
+	prices   = map[string]ModelPrice{}
+	pricesMu sync.RWMutex
+)
+
+// RegisterPrice adds a model's price, or replaces its existing one.
+func RegisterPrice(price ModelPrice) error {
+	if price.Model == "" {
+		return fmt.Errorf("price model is required")
+	}
+
+	if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+		return fmt.Errorf("price of %q must not be negative", price.Model)
+	}
+
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+
+	prices[price.Model] = price
+
+	return nil
+}
+
+// GetPrice returns the price registered for a "provider/model-name" string.
+func GetPrice(model string) (ModelPrice, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	price, ok := prices[model]
+
+	return price, ok
+}
+
+// LoadPrices registers the model prices declared in a YAML file:
+//
+//	prices:
+//	  - model: anthropic/claude-sonnet-4-5
+//	    input_per_million: 3
+//	    output_per_million: 15
+func LoadPrices(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read agent prices: %w", err)
+	}
+
+	var file struct {
+		Prices []ModelPrice `yaml:"prices"`
+	}
+
+	err = yaml.Unmarshal(contents, &file)
+	if err != nil {
+		return fmt.Errorf("could not parse agent prices %q: %w", path, err)
+	}
+
+	for _, price := range file.Prices {
+		err = RegisterPrice(price)
+		if err != nil {
+			return fmt.Errorf("could not register agent price: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CostTracker records what agents spend and stops them once a budget has
+// been used up.
+type CostTracker interface {
+	// Check returns an error wrapping ErrBudgetExceeded when no budget is
+	// left to start an agent or for its next turn.
+	Check(ctx context.Context) error
+	// Record adds USD an agent run has spent since it last recorded.
+	Record(ctx context.Context, cost float64)
+}
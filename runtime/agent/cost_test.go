@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeCosts passes its first passes checks and returns err after that.
+type fakeCosts struct {
+	mu       sync.Mutex
+	err      error
+	passes   int
+	checks   int
+	recorded []float64
+}
+
+func (f *fakeCosts) Check(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.checks++
+	if f.checks <= f.passes {
+		return nil
+	}
+
+	return f.err
+}
+
+func (f *fakeCosts) Record(_ context.Context, cost float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.recorded = append(f.recorded, cost)
+}
+
+func (f *fakeCosts) total() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var total float64
+	for _, cost := range f.recorded {
+		total += cost
+	}
+
+	return total
+}
+
+func registerTestPrice(t *testing.T, price ModelPrice) {
+	t.Helper()
+
+	err := RegisterPrice(price)
+	if err != nil {
+		t.Fatalf("register price: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pricesMu.Lock()
+		defer pricesMu.Unlock()
+
+		delete(prices, price.Model)
+	})
+}
+
+func TestPrices(t *testing.T) {
+	t.Parallel()
+
+	t.Run("costs tokens per million", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		price := ModelPrice{Model: "openai/gpt-test", InputPerMillion: 3, OutputPerMillion: 15}
+		assert.Expect(price.Cost(1_000_000, 0)).To(BeNumerically("~", 3))
+		assert.Expect(price.Cost(2000, 1000)).To(BeNumerically("~", 0.021))
+	})
+
+	t.Run("loads prices from a YAML file", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		path := filepath.Join(t.TempDir(), "prices.yml")
+		err := os.WriteFile(path, []byte(`
+prices:
+  - model: test-load/model-a
+    input_per_million: 1.25
+    output_per_million: 10
+`), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(LoadPrices(path)).To(Succeed())
+		t.Cleanup(func() {
+			pricesMu.Lock()
+			defer pricesMu.Unlock()
+
+			delete(prices, "test-load/model-a")
+		})
+
+		price, ok := GetPrice("test-load/model-a")
+		assert.Expect(ok).To(BeTrue())
+		assert.Expect(price.InputPerMillion).To(Equal(1.25))
+		assert.Expect(price.OutputPerMillion).To(Equal(10.0))
+	})
+
+	t.Run("rejects invalid prices", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(RegisterPrice(ModelPrice{InputPerMillion: 1})).To(MatchError(ContainSubstring("model is required")))
+		assert.Expect(RegisterPrice(ModelPrice{Model: "a/b", OutputPerMillion: -1})).To(MatchError(ContainSubstring("must not be negative")))
+	})
+}
+
+func TestRunAgent_Cost(t *testing.T) {
+	// Each fake response uses 10 prompt and 5 completion tokens, which
+	// costs $0.04 at these prices.
+	price := ModelPrice{Model: "openai/priced-model", InputPerMillion: 2000, OutputPerMillion: 4000}
+
+	t.Run("prices model calls and records the run's cost", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+		registerTestPrice(t, price)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_echo", "run_command", `{"command":"echo","args":["hello"]}`),
+			chatCompletion("Done."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		costs := &fakeCosts{}
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-cost"), nil, "", AgentConfig{
+			Name:   "coster",
+			Prompt: "Run echo.",
+			Model:  price.Model,
+			Costs:  costs,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Usage.Cost).To(BeNumerically("~", 0.08))
+		assert.Expect(costs.total()).To(BeNumerically("~", 0.08))
+	})
+
+	t.Run("rolls sub-agent costs into the coordinator's once", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+		registerTestPrice(t, price)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_research", "researcher", `{"task":"What is X?"}`),
+			chatCompletion("X is 42."),
+			chatCompletion("X is 42."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		costs := &fakeCosts{}
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-cost-sub"), nil, "", AgentConfig{
+			Name:      "coordinator",
+			Prompt:    "Find out X.",
+			Model:     price.Model,
+			SubAgents: []AgentSubAgent{{Name: "researcher", Prompt: "You research questions."}},
+			Costs:     costs,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Usage.Cost).To(BeNumerically("~", 0.12))
+		assert.Expect(costs.total()).To(BeNumerically("~", 0.12))
+	})
+
+	t.Run("leaves unpriced models at zero cost", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{chatCompletion("Done.")})
+		configureFakeOpenAI(t, llm.URL)
+
+		costs := &fakeCosts{}
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-cost-unpriced"), nil, "", AgentConfig{
+			Name:   "coster",
+			Prompt: "Say done.",
+			Model:  "openai/unpriced-model",
+			Costs:  costs,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Usage.Cost).To(BeZero())
+		assert.Expect(costs.recorded).To(BeEmpty())
+	})
+
+	t.Run("fails before calling the model once the budget is spent", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{chatCompletion("Done.")})
+		configureFakeOpenAI(t, llm.URL)
+
+		costs := &fakeCosts{err: fmt.Errorf("%w: pipeline %q has spent $5.00 of its $5.00 monthly budget", ErrBudgetExceeded, "deploy")}
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-cost-budget"), nil, "", AgentConfig{
+			Name:   "coster",
+			Prompt: "Say done.",
+			Model:  price.Model,
+			Costs:  costs,
+		})
+		assert.Expect(err).To(MatchError(ErrBudgetExceeded))
+		assert.Expect(err).To(MatchError(ContainSubstring(`pipeline "deploy" has spent $5.00 of its $5.00 monthly budget`)))
+		assert.Expect(result).To(BeNil())
+		assert.Expect(atomic.LoadInt32(requests)).To(BeZero())
+	})
+
+	t.Run("stops between turns once the budget is spent", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+		registerTestPrice(t, price)
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_echo_1", "run_command", `{"command":"echo","args":["one"]}`),
+			toolCallCompletion("call_echo_2", "run_command", `{"command":"echo","args":["two"]}`),
+			chatCompletion("Done."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		costs := &fakeCosts{
+			passes: 1,
+			err:    fmt.Errorf("%w: pipeline %q has spent $5.02 of its $5.00 monthly budget", ErrBudgetExceeded, "deploy"),
+		}
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-cost-turns"), nil, "", AgentConfig{
+			Name:   "coster",
+			Prompt: "Run echo twice.",
+			Model:  price.Model,
+			Costs:  costs,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("limit_exceeded"))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeNumerically("==", 1))
+		assert.Expect(costs.total()).To(BeNumerically("~", 0.04))
+
+		last := result.AuditLog[len(result.AuditLog)-1]
+		assert.Expect(last.Type).To(Equal("limit_warning"))
+		assert.Expect(last.Text).To(ContainSubstring(`pipeline "deploy" has spent $5.02 of its $5.00 monthly budget`))
+	})
+}
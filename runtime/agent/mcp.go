@@ -0,0 +1,360 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/adk/agent"
+	adkmodel "google.golang.org/adk/model"
+	adktool "google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/mcptoolset"
+	"google.golang.org/genai"
+
+	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
+)
+
+// AgentMCPServer connects the agent to an MCP server whose tools are offered
+// to the model alongside the built-in ones, named "<server>_<tool>". Exactly
+// one of Command or URL is required.
+type AgentMCPServer struct {
+	Name string `json:"name"`
+	// Command starts a stdio server inside the agent's sandbox.
+	Command *AgentMCPCommand `json:"command,omitempty"`
+	// URL of a streamable HTTP server. Header values of the form
+	// "secret:<key>" are resolved from the pipeline's secrets.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Allow and Deny filter the server's tools by name, with path.Match
+	// patterns such as "get_*". Deny wins over Allow; an empty Allow allows
+	// every tool.
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// AgentMCPCommand is the executable of a stdio MCP server. Env values of the
+// form "secret:<key>" are resolved like those of any sandbox command.
+type AgentMCPCommand struct {
+	Path    string            `json:"path"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	WorkDir string            `json:"work_dir,omitempty"`
+}
+
+// validateMCPServers checks MCP server definitions before the sandbox is
+// started.
+func validateMCPServers(servers []AgentMCPServer) error {
+	seen := map[string]bool{}
+
+	for _, server := range servers {
+		if server.Name == "" {
+			return fmt.Errorf("mcp server requires a name")
+		}
+
+		if !toolNamePattern.MatchString(server.Name) {
+			return fmt.Errorf("mcp server name %q must start with a letter or underscore and contain only letters, digits, '_' and '-'", server.Name)
+		}
+
+		if seen[server.Name] {
+			return fmt.Errorf("mcp server %q is defined more than once", server.Name)
+		}
+
+		seen[server.Name] = true
+
+		if (server.Command == nil) == (server.URL == "") {
+			return fmt.Errorf("mcp server %q requires exactly one of command or url", server.Name)
+		}
+
+		if server.Command != nil && server.Command.Path == "" {
+			return fmt.Errorf("mcp server %q command requires a path", server.Name)
+		}
+
+		if server.URL != "" {
+			endpoint, err := url.Parse(server.URL)
+			if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+				return fmt.Errorf("mcp server %q url %q must be an http or https URL", server.Name, server.URL)
+			}
+		}
+
+		for _, pattern := range slices.Concat(server.Allow, server.Deny) {
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("mcp server %q has invalid tool pattern %q: %w", server.Name, pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// mcpToolName is the name a server's tool is offered to the model as, so that
+// tools of the same name on different servers do not clash.
+func mcpToolName(server AgentMCPServer, name string) string {
+	return server.Name + "_" + name
+}
+
+// allowMCPTool reports whether a server's tool is offered to the model. Allow
+// and Deny match the server's own name for the tool. Tools whose namespaced
+// name is taken by a reserved (built-in or pipeline) tool are dropped.
+func allowMCPTool(server AgentMCPServer, reserved []string, name string) bool {
+	if slices.Contains(reserved, mcpToolName(server, name)) {
+		return false
+	}
+
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			matched, _ := path.Match(pattern, name)
+			if matched {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if matches(server.Deny) {
+		return false
+	}
+
+	return len(server.Allow) == 0 || matches(server.Allow)
+}
+
+// mcpConnections opens the connections of the agent's MCP servers and closes
+// them when the agent finishes.
+type mcpConnections struct {
+	mu    sync.Mutex
+	conns []mcp.Connection
+}
+
+// Close closes every open connection, ending stdio servers and HTTP sessions.
+func (c *mcpConnections) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, conn := range c.conns {
+		_ = conn.Close()
+	}
+
+	c.conns = nil
+}
+
+type trackedTransport struct {
+	transport   mcp.Transport
+	connections *mcpConnections
+}
+
+func (t *trackedTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t.connections.mu.Lock()
+	t.connections.conns = append(t.connections.conns, conn)
+	t.connections.mu.Unlock()
+
+	return conn, nil
+}
+
+// sandboxTransport runs a stdio MCP server inside the agent's sandbox,
+// speaking newline-delimited JSON-RPC over its stdin and stdout.
+type sandboxTransport struct {
+	ctx     context.Context //nolint: containedctx
+	sandbox *pipelinerunner.SandboxHandle
+	input   pipelinerunner.ExecInput
+}
+
+func (t *sandboxTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	go func() {
+		code, err := t.sandbox.Attach(t.ctx, t.input, stdinReader, stdoutWriter)
+		if err == nil {
+			err = fmt.Errorf("mcp server %q exited with code %d", t.input.Command.Path, code)
+		}
+
+		_ = stdinReader.CloseWithError(err)
+		_ = stdoutWriter.CloseWithError(err)
+	}()
+
+	transport := &mcp.IOTransport{Reader: stdoutReader, Writer: stdinWriter}
+
+	return transport.Connect(ctx)
+}
+
+// headerRoundTripper adds fixed headers, such as auth tokens, to every
+// request sent to an HTTP MCP server.
+type headerRoundTripper struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+func (t headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// buildMCPToolsets creates a toolset per MCP server. Sessions are opened
+// lazily, when the model first asks for tools, and are closed by the returned
+// mcpConnections.
+func buildMCPToolsets(
+	ctx context.Context,
+	servers []AgentMCPServer,
+	sandbox *pipelinerunner.SandboxHandle,
+	resolveSecret func(key string) string,
+	reserved []string,
+	onOutput pipelinerunner.OutputCallback,
+) ([]adktool.Toolset, *mcpConnections, error) {
+	connections := &mcpConnections{}
+	toolsets := make([]adktool.Toolset, 0, len(servers))
+
+	for _, server := range servers {
+		var transport mcp.Transport
+
+		if server.Command != nil {
+			var execInput pipelinerunner.ExecInput
+
+			execInput.Command.Path = server.Command.Path
+			execInput.Command.Args = server.Command.Args
+			execInput.Env = server.Command.Env
+			execInput.WorkDir = server.Command.WorkDir
+			execInput.OnOutput = onOutput
+
+			transport = &sandboxTransport{ctx: ctx, sandbox: sandbox, input: execInput}
+		} else {
+			headers := http.Header{}
+
+			for name, value := range server.Headers {
+				if key, ok := strings.CutPrefix(value, "secret:"); ok {
+					value = resolveSecret(key)
+				}
+
+				headers.Set(name, value)
+			}
+
+			transport = &mcp.StreamableClientTransport{
+				Endpoint: server.URL,
+				HTTPClient: &http.Client{
+					Transport: headerRoundTripper{headers: headers, base: http.DefaultTransport},
+				},
+			}
+		}
+
+		toolset, err := mcptoolset.New(mcptoolset.Config{
+			Client:    mcp.NewClient(&mcp.Implementation{Name: "pocketci-agent", Version: "1.0.0"}, nil),
+			Transport: &trackedTransport{transport: transport, connections: connections},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create mcp server %q: %w", server.Name, err)
+		}
+
+		toolsets = append(toolsets, &mcpServerToolset{Toolset: toolset, server: server, reserved: reserved})
+	}
+
+	return toolsets, connections, nil
+}
+
+// mcpServerToolset offers the allowed tools of an MCP server under their
+// namespaced names.
+type mcpServerToolset struct {
+	adktool.Toolset
+	server   AgentMCPServer
+	reserved []string
+}
+
+func (s *mcpServerToolset) Tools(ctx agent.ReadonlyContext) ([]adktool.Tool, error) {
+	tools, err := s.Toolset.Tools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offered := make([]adktool.Tool, 0, len(tools))
+
+	for _, tool := range tools {
+		if !allowMCPTool(s.server, s.reserved, tool.Name()) {
+			continue
+		}
+
+		function, ok := tool.(mcpFunctionTool)
+		if !ok {
+			return nil, fmt.Errorf("mcp server %q tool %q cannot be called", s.server.Name, tool.Name())
+		}
+
+		offered = append(offered, &mcpServerTool{
+			mcpFunctionTool: function,
+			name:            mcpToolName(s.server, tool.Name()),
+		})
+	}
+
+	return offered, nil
+}
+
+// mcpFunctionTool is the callable side of the tools mcptoolset creates.
+type mcpFunctionTool interface {
+	adktool.Tool
+	Declaration() *genai.FunctionDeclaration
+	Run(ctx adktool.Context, args any) (map[string]any, error)
+}
+
+// mcpServerTool renames an MCP tool for the model. Calls still reach the
+// server under the tool's own name.
+type mcpServerTool struct {
+	mcpFunctionTool
+	name string
+}
+
+func (t *mcpServerTool) Name() string {
+	return t.name
+}
+
+func (t *mcpServerTool) Declaration() *genai.FunctionDeclaration {
+	declaration := *t.mcpFunctionTool.Declaration()
+	declaration.Name = t.name
+
+	return &declaration
+}
+
+// ProcessRequest adds the tool to the model request, as the ADK's own
+// function tools do.
+func (t *mcpServerTool) ProcessRequest(_ adktool.Context, req *adkmodel.LLMRequest) error {
+	if req.Tools == nil {
+		req.Tools = map[string]any{}
+	}
+
+	if _, ok := req.Tools[t.name]; ok {
+		return fmt.Errorf("duplicate tool: %q", t.name)
+	}
+
+	req.Tools[t.name] = t
+
+	if req.Config == nil {
+		req.Config = &genai.GenerateContentConfig{}
+	}
+
+	for _, tool := range req.Config.Tools {
+		if tool != nil && tool.FunctionDeclarations != nil {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, t.Declaration())
+
+			return nil
+		}
+	}
+
+	req.Config.Tools = append(req.Config.Tools, &genai.Tool{
+		FunctionDeclarations: []*genai.FunctionDeclaration{t.Declaration()},
+	})
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	. "github.com/onsi/gomega"
+)
+
+func TestMCPServers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates server definitions", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(validateMCPServers([]AgentMCPServer{
+			{Name: "github", URL: "https://mcp.example.com/mcp", Allow: []string{"get_*"}},
+			{Name: "filesystem", Command: &AgentMCPCommand{Path: "npx"}, Deny: []string{"write_file"}},
+		})).To(Succeed())
+
+		assert.Expect(validateMCPServers([]AgentMCPServer{{URL: "https://mcp.example.com"}})).
+			To(MatchError(ContainSubstring("mcp server requires a name")))
+		assert.Expect(validateMCPServers([]AgentMCPServer{
+			{Name: "a", URL: "https://mcp.example.com"},
+			{Name: "a", URL: "https://mcp.example.com"},
+		})).To(MatchError(ContainSubstring(`mcp server "a" is defined more than once`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "git hub", URL: "https://mcp.example.com"}})).
+			To(MatchError(ContainSubstring(`mcp server name "git hub" must start with`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "a"}})).
+			To(MatchError(ContainSubstring(`mcp server "a" requires exactly one of command or url`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "a", URL: "https://mcp.example.com", Command: &AgentMCPCommand{Path: "npx"}}})).
+			To(MatchError(ContainSubstring(`mcp server "a" requires exactly one of command or url`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "a", Command: &AgentMCPCommand{}}})).
+			To(MatchError(ContainSubstring(`mcp server "a" command requires a path`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "a", URL: "ftp://mcp.example.com"}})).
+			To(MatchError(ContainSubstring(`must be an http or https URL`)))
+		assert.Expect(validateMCPServers([]AgentMCPServer{{Name: "a", URL: "https://mcp.example.com", Deny: []string{"[get"}}})).
+			To(MatchError(ContainSubstring(`mcp server "a" has invalid tool pattern "[get"`)))
+	})
+
+	t.Run("filters tools with allow and deny lists", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		reserved := []string{"run_command", "all_read_file"}
+
+		all := AgentMCPServer{Name: "all"}
+		assert.Expect(allowMCPTool(all, reserved, "get_issue")).To(BeTrue())
+		assert.Expect(allowMCPTool(all, reserved, "run_command")).To(BeTrue())
+		assert.Expect(allowMCPTool(all, reserved, "read_file")).To(BeFalse())
+
+		filtered := AgentMCPServer{Name: "filtered", Allow: []string{"get_*", "search_code"}, Deny: []string{"get_secret"}}
+		assert.Expect(allowMCPTool(filtered, reserved, "get_issue")).To(BeTrue())
+		assert.Expect(allowMCPTool(filtered, reserved, "search_code")).To(BeTrue())
+		assert.Expect(allowMCPTool(filtered, reserved, "get_secret")).To(BeFalse())
+		assert.Expect(allowMCPTool(filtered, reserved, "create_issue")).To(BeFalse())
+	})
+
+	t.Run("sends configured headers to HTTP servers", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		var received http.Header
+
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: headerRoundTripper{
+			headers: http.Header{"Authorization": []string{"Bearer token"}},
+			base:    http.DefaultTransport,
+		}}
+
+		resp, err := client.Get(server.URL)
+		assert.Expect(err).NotTo(HaveOccurred())
+		_ = resp.Body.Close()
+
+		assert.Expect(received.Get("Authorization")).To(Equal("Bearer token"))
+	})
+}
+
+// newMCPTestServer serves an in-process MCP server with a lookup tool over
+// streamable HTTP.
+func newMCPTestServer(t *testing.T, name string) string {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "1.0.0"}, nil)
+
+	type lookupInput struct {
+		Key string `json:"key"`
+	}
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "lookup",
+		Description: "Looks up a key",
+	}, func(_ context.Context, _ *mcp.CallToolRequest, input lookupInput) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: name + " says " + input.Key + " is us-east-1"}},
+		}, nil, nil
+	})
+
+	httpServer := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(_ *http.Request) *mcp.Server {
+		return server
+	}, nil))
+	t.Cleanup(httpServer.Close)
+
+	return httpServer.URL
+}
+
+func TestRunAgent_MCPServers(t *testing.T) {
+	t.Run("lists and calls tools of each server under its name", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		responses := []string{
+			toolCallCompletion("call_lookup", "beta_lookup", `{"key":"region"}`),
+			chatCompletion("done"),
+		}
+
+		var (
+			mu       sync.Mutex
+			requests []map[string]any
+			count    int32
+		)
+
+		llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			var request map[string]any
+			_ = json.Unmarshal(body, &request)
+
+			mu.Lock()
+			requests = append(requests, request)
+			mu.Unlock()
+
+			index := min(int(atomic.AddInt32(&count, 1))-1, len(responses)-1)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(responses[index]))
+		}))
+		t.Cleanup(llm.Close)
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-mcp"), nil, "", AgentConfig{
+			Name:   "triage",
+			Prompt: "Find the region.",
+			Model:  "openai/fake-model",
+			MCPServers: []AgentMCPServer{
+				{Name: "alpha", URL: newMCPTestServer(t, "alpha")},
+				{Name: "beta", URL: newMCPTestServer(t, "beta")},
+			},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Expect(requests).To(HaveLen(2))
+
+		names := []string{}
+		for _, tool := range requests[0]["tools"].([]any) {
+			function := tool.(map[string]any)["function"].(map[string]any)
+			names = append(names, function["name"].(string))
+		}
+
+		assert.Expect(names).To(ContainElements("alpha_lookup", "beta_lookup"))
+		assert.Expect(names).NotTo(ContainElement("lookup"))
+
+		var response *AuditEvent
+		for i, event := range result.AuditLog {
+			if event.Type == "tool_response" {
+				response = &result.AuditLog[i]
+			}
+		}
+
+		assert.Expect(response).NotTo(BeNil())
+		assert.Expect(response.ToolName).To(Equal("beta_lookup"))
+		assert.Expect(response.ToolResult).To(HaveKeyWithValue("output", "beta says region is us-east-1"))
+	})
+}
@@ -0,0 +1,382 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	adktool "google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+
+	"github.com/jtarchie/pocketci/storage"
+)
+
+const (
+	defaultMemoryTTL        = 30 * 24 * time.Hour
+	defaultMemoryMaxEntries = 100
+	defaultMemoryMaxBytes   = 2048
+)
+
+// memoryToolNames are the tools an agent gets when memory is enabled.
+var memoryToolNames = []string{"remember", "recall"}
+
+var memoryKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// AgentMemoryConfig enables the remember and recall tools, which keep notes
+// for a pipeline across its runs. Memories are stored under
+// /memory/<pipeline-id>/ in the server's storage.
+type AgentMemoryConfig struct {
+	// TTL is how long a memory is kept after it was last written, as a Go
+	// duration. Defaults to 720h.
+	TTL string `json:"ttl,omitempty"`
+	// MaxEntries caps the pipeline's memories; the oldest are forgotten
+	// first. Defaults to 100.
+	MaxEntries int `json:"max_entries,omitempty"`
+	// MaxBytes caps the size of a single memory's content. Defaults to 2048.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// Memory is a note an agent kept for its pipeline.
+type Memory struct {
+	Key       string    `json:"key"`
+	Content   string    `json:"content"`
+	Agent     string    `json:"agent,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// rememberInput is the remember tool input schema.
+type rememberInput struct {
+	Key     string `json:"key"`
+	Content string `json:"content"`
+}
+
+// rememberOutput is the remember tool result schema.
+type rememberOutput struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// recallInput is the recall tool input schema.
+type recallInput struct {
+	Query string `json:"query,omitempty"`
+}
+
+// recallOutput is the recall tool result schema.
+type recallOutput struct {
+	Memories []Memory `json:"memories"`
+}
+
+type memorySettings struct {
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+}
+
+// memoryLimits applies the defaults to config.
+func memoryLimits(config *AgentMemoryConfig) (memorySettings, error) {
+	settings := memorySettings{
+		ttl:        defaultMemoryTTL,
+		maxEntries: cmp.Or(config.MaxEntries, defaultMemoryMaxEntries),
+		maxBytes:   cmp.Or(config.MaxBytes, defaultMemoryMaxBytes),
+	}
+
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil {
+			return memorySettings{}, fmt.Errorf("memory ttl %q is not a valid duration: %w", config.TTL, err)
+		}
+
+		if ttl <= 0 {
+			return memorySettings{}, fmt.Errorf("memory ttl %q must be positive", config.TTL)
+		}
+
+		settings.ttl = ttl
+	}
+
+	if config.MaxEntries < 0 {
+		return memorySettings{}, fmt.Errorf("memory max_entries must not be negative")
+	}
+
+	if config.MaxBytes < 0 {
+		return memorySettings{}, fmt.Errorf("memory max_bytes must not be negative")
+	}
+
+	return settings, nil
+}
+
+// validateMemory checks the memory config before the sandbox is started.
+func validateMemory(config AgentConfig) error {
+	if config.Memory == nil {
+		return nil
+	}
+
+	_, err := memoryLimits(config.Memory)
+	if err != nil {
+		return err
+	}
+
+	for _, tool := range config.Tools {
+		if slices.Contains(memoryToolNames, tool.Name) {
+			return fmt.Errorf("tool %q conflicts with a memory tool", tool.Name)
+		}
+	}
+
+	for _, subAgent := range config.SubAgents {
+		if slices.Contains(memoryToolNames, subAgent.Name) {
+			return fmt.Errorf("sub-agent %q conflicts with a memory tool", subAgent.Name)
+		}
+	}
+
+	return nil
+}
+
+func memoryPrefix(pipelineID string) string {
+	return "/memory/" + pipelineID
+}
+
+func validateMemoryKey(key string) error {
+	if !memoryKeyPattern.MatchString(key) {
+		return fmt.Errorf("memory key %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", key)
+	}
+
+	return nil
+}
+
+// ListMemories returns a pipeline's unexpired memories, newest first.
+func ListMemories(ctx context.Context, store storage.Driver, pipelineID string) ([]Memory, error) {
+	memories, err := loadMemories(ctx, store, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	return slices.DeleteFunc(memories, func(memory Memory) bool {
+		return !memory.ExpiresAt.After(now)
+	}), nil
+}
+
+// ForgetMemory removes one of a pipeline's memories.
+func ForgetMemory(ctx context.Context, store storage.Driver, pipelineID, key string) error {
+	err := validateMemoryKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = store.Delete(ctx, memoryPrefix(pipelineID)+"/"+key)
+	if err != nil {
+		return fmt.Errorf("could not forget memory %q: %w", key, err)
+	}
+
+	return nil
+}
+
+// ClearMemories removes all of a pipeline's memories.
+func ClearMemories(ctx context.Context, store storage.Driver, pipelineID string) error {
+	err := store.Delete(ctx, memoryPrefix(pipelineID))
+	if err != nil {
+		return fmt.Errorf("could not clear memories: %w", err)
+	}
+
+	return nil
+}
+
+// loadMemories returns all of a pipeline's memories, including expired ones,
+// newest first.
+func loadMemories(ctx context.Context, store storage.Driver, pipelineID string) ([]Memory, error) {
+	prefix := memoryPrefix(pipelineID)
+
+	results, err := store.GetAll(ctx, prefix, []string{"*"})
+	if err != nil {
+		return nil, fmt.Errorf("could not load memories: %w", err)
+	}
+
+	memories := make([]Memory, 0, len(results))
+
+	for _, result := range results {
+		// The prefix also matches pipeline IDs that start with this one.
+		if !strings.HasSuffix(path.Dir(result.Path), prefix) {
+			continue
+		}
+
+		memory, err := decodeMemory(result.Payload)
+		if err != nil {
+			continue
+		}
+
+		memories = append(memories, memory)
+	}
+
+	sortMemories(memories)
+
+	return memories, nil
+}
+
+func decodeMemory(payload storage.Payload) (Memory, error) {
+	contents, err := json.Marshal(payload)
+	if err != nil {
+		return Memory{}, fmt.Errorf("could not encode memory: %w", err)
+	}
+
+	var memory Memory
+
+	err = json.Unmarshal(contents, &memory)
+	if err != nil {
+		return Memory{}, fmt.Errorf("could not decode memory: %w", err)
+	}
+
+	return memory, nil
+}
+
+func sortMemories(memories []Memory) {
+	slices.SortFunc(memories, func(a, b Memory) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+// rememberMemory saves memory, then forgets expired memories and the oldest
+// ones over the entry limit.
+func rememberMemory(ctx context.Context, store storage.Driver, pipelineID string, settings memorySettings, memory Memory) error {
+	err := validateMemoryKey(memory.Key)
+	if err != nil {
+		return err
+	}
+
+	if memory.Content == "" {
+		return fmt.Errorf("memory content is required")
+	}
+
+	if len(memory.Content) > settings.maxBytes {
+		return fmt.Errorf("memory content is %d bytes, over the limit of %d bytes", len(memory.Content), settings.maxBytes)
+	}
+
+	err = store.Set(ctx, memoryPrefix(pipelineID)+"/"+memory.Key, memory)
+	if err != nil {
+		return fmt.Errorf("could not save memory %q: %w", memory.Key, err)
+	}
+
+	memories, err := loadMemories(ctx, store, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+
+	for _, stored := range memories {
+		if stored.ExpiresAt.After(memory.CreatedAt) && kept < settings.maxEntries {
+			kept++
+
+			continue
+		}
+
+		err = ForgetMemory(ctx, store, pipelineID, stored.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recallMemories returns the unexpired memories matching query, newest
+// first. An empty query returns all of them.
+func recallMemories(ctx context.Context, store storage.Driver, pipelineID, query string) ([]Memory, error) {
+	if strings.TrimSpace(query) == "" {
+		return ListMemories(ctx, store, pipelineID)
+	}
+
+	prefix := memoryPrefix(pipelineID)
+
+	results, err := store.Search(ctx, prefix, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not search memories: %w", err)
+	}
+
+	now := time.Now().UTC()
+	memories := []Memory{}
+
+	for _, result := range results {
+		payload, err := store.Get(ctx, prefix+"/"+path.Base(result.Path))
+		if err != nil {
+			continue
+		}
+
+		memory, err := decodeMemory(payload)
+		if err != nil || !memory.ExpiresAt.After(now) {
+			continue
+		}
+
+		memories = append(memories, memory)
+	}
+
+	sortMemories(memories)
+
+	return memories, nil
+}
+
+// buildMemoryTools creates the remember and recall tools of an agent.
+func buildMemoryTools(ctx context.Context, config AgentConfig, pipelineID string) ([]adktool.Tool, error) {
+	settings, err := memoryLimits(config.Memory)
+	if err != nil {
+		return nil, err
+	}
+
+	remember, err := functiontool.New[rememberInput, rememberOutput](
+		functiontool.Config{
+			Name: "remember",
+			Description: fmt.Sprintf(
+				"Save a note for future runs of this pipeline, such as a project convention or a recurring issue. Notes are kept for %s and are at most %d bytes. Saving under an existing key replaces that note.",
+				settings.ttl, settings.maxBytes,
+			),
+		},
+		func(_ adktool.Context, input rememberInput) (rememberOutput, error) {
+			now := time.Now().UTC()
+			memory := Memory{
+				Key:       input.Key,
+				Content:   input.Content,
+				Agent:     config.Name,
+				RunID:     config.RunID,
+				CreatedAt: now,
+				ExpiresAt: now.Add(settings.ttl),
+			}
+
+			err := rememberMemory(ctx, config.Storage, pipelineID, settings, memory)
+			if err != nil {
+				return rememberOutput{}, err
+			}
+
+			return rememberOutput{Key: memory.Key, ExpiresAt: memory.ExpiresAt}, nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remember tool: %w", err)
+	}
+
+	recall, err := functiontool.New[recallInput, recallOutput](
+		functiontool.Config{
+			Name:        "recall",
+			Description: "Search the notes saved by earlier runs of this pipeline. An empty query returns every note, newest first.",
+		},
+		func(_ adktool.Context, input recallInput) (recallOutput, error) {
+			memories, err := recallMemories(ctx, config.Storage, pipelineID, input.Query)
+			if err != nil {
+				return recallOutput{}, err
+			}
+
+			return recallOutput{Memories: memories}, nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recall tool: %w", err)
+	}
+
+	return []adktool.Tool{remember, recall}, nil
+}
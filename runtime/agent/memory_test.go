@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/storage"
+	storagesqlite "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func newMemoryStorage(t *testing.T) storage.Driver {
+	t.Helper()
+
+	client, err := storagesqlite.NewSqlite(filepath.Join(t.TempDir(), "memory.db"), "namespace", slog.Default())
+	if err != nil {
+		t.Fatalf("new sqlite: %v", err)
+	}
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	settings := memorySettings{ttl: time.Hour, maxEntries: 3, maxBytes: 64}
+
+	remember := func(t *testing.T, store storage.Driver, pipelineID, key, content string, createdAt time.Time) error {
+		t.Helper()
+
+		return rememberMemory(context.Background(), store, pipelineID, settings, Memory{
+			Key:       key,
+			Content:   content,
+			Agent:     "reviewer",
+			RunID:     "run-1",
+			CreatedAt: createdAt,
+			ExpiresAt: createdAt.Add(settings.ttl),
+		})
+	}
+
+	t.Run("validates memory config", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		limits, err := memoryLimits(&AgentMemoryConfig{})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(limits).To(Equal(memorySettings{ttl: 720 * time.Hour, maxEntries: 100, maxBytes: 2048}))
+
+		assert.Expect(validateMemory(AgentConfig{Memory: &AgentMemoryConfig{TTL: "soon"}})).
+			To(MatchError(ContainSubstring(`memory ttl "soon" is not a valid duration`)))
+		assert.Expect(validateMemory(AgentConfig{Memory: &AgentMemoryConfig{TTL: "-1h"}})).
+			To(MatchError(ContainSubstring(`memory ttl "-1h" must be positive`)))
+		assert.Expect(validateMemory(AgentConfig{Memory: &AgentMemoryConfig{MaxEntries: -1}})).
+			To(MatchError(ContainSubstring("max_entries must not be negative")))
+		assert.Expect(validateMemory(AgentConfig{Memory: &AgentMemoryConfig{}, SubAgents: []AgentSubAgent{{Name: "recall", Prompt: "x"}}})).
+			To(MatchError(ContainSubstring(`sub-agent "recall" conflicts with a memory tool`)))
+		assert.Expect(validateMemory(AgentConfig{SubAgents: []AgentSubAgent{{Name: "recall", Prompt: "x"}}})).To(Succeed())
+	})
+
+	t.Run("rejects invalid keys and oversized content", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		now := time.Now().UTC()
+
+		assert.Expect(remember(t, store, "p1", "../escape", "x", now)).
+			To(MatchError(ContainSubstring(`memory key "../escape" must start with a letter or digit`)))
+		assert.Expect(remember(t, store, "p1", "big", strings.Repeat("x", 65), now)).
+			To(MatchError(ContainSubstring("memory content is 65 bytes, over the limit of 64 bytes")))
+		assert.Expect(remember(t, store, "p1", "empty", "", now)).
+			To(MatchError(ContainSubstring("memory content is required")))
+	})
+
+	t.Run("forgets expired memories and the oldest over the limit", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		now := time.Now().UTC()
+
+		assert.Expect(remember(t, store, "p1", "expired", "old news", now.Add(-2*time.Hour))).To(Succeed())
+
+		memories, err := ListMemories(context.Background(), store, "p1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(BeEmpty())
+
+		for i := range 4 {
+			key := fmt.Sprintf("note-%d", i)
+			assert.Expect(remember(t, store, "p1", key, "content "+key, now.Add(time.Duration(i)*time.Minute))).To(Succeed())
+		}
+
+		assert.Expect(remember(t, store, "p10", "other", "another pipeline", now)).To(Succeed())
+
+		memories, err = ListMemories(context.Background(), store, "p1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(HaveLen(3))
+		assert.Expect(memories[0].Key).To(Equal("note-3"))
+		assert.Expect(memories[2].Key).To(Equal("note-1"))
+		assert.Expect(memories[0].Agent).To(Equal("reviewer"))
+
+		all, err := loadMemories(context.Background(), store, "p1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(all).To(HaveLen(3))
+	})
+
+	t.Run("recalls memories matching a query", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+		now := time.Now().UTC()
+
+		assert.Expect(remember(t, store, "p1", "style", "The project uses tabs for indentation.", now)).To(Succeed())
+		assert.Expect(remember(t, store, "p1", "flaky", "TestUpload is flaky on CI.", now.Add(time.Minute))).To(Succeed())
+
+		memories, err := recallMemories(context.Background(), store, "p1", "indentation")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(HaveLen(1))
+		assert.Expect(memories[0].Key).To(Equal("style"))
+
+		memories, err = recallMemories(context.Background(), store, "p1", "")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(HaveLen(2))
+		assert.Expect(memories[0].Key).To(Equal("flaky"))
+
+		assert.Expect(ForgetMemory(context.Background(), store, "p1", "flaky")).To(Succeed())
+
+		memories, err = recallMemories(context.Background(), store, "p1", "flaky")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(BeEmpty())
+
+		assert.Expect(ClearMemories(context.Background(), store, "p1")).To(Succeed())
+
+		memories, err = ListMemories(context.Background(), store, "p1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(BeEmpty())
+	})
+}
+
+func TestRunAgent_Memory(t *testing.T) {
+	t.Run("remembers in one run and recalls in the next", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		store := newMemoryStorage(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_remember", "remember", `{"key":"style","content":"The project uses tabs."}`),
+			chatCompletion("Noted."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-memory-1"), nil, "pipeline-1", AgentConfig{
+			Name:    "reviewer",
+			Prompt:  "Review the change.",
+			Model:   "openai/fake-model",
+			Memory:  &AgentMemoryConfig{},
+			Storage: store,
+			RunID:   "run-1",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+
+		memories, err := ListMemories(context.Background(), store, "pipeline-1")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(memories).To(HaveLen(1))
+		assert.Expect(memories[0].Content).To(Equal("The project uses tabs."))
+		assert.Expect(memories[0].RunID).To(Equal("run-1"))
+		assert.Expect(memories[0].ExpiresAt).To(BeTemporally("~", time.Now().Add(720*time.Hour), time.Minute))
+
+		llm, _ = newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_recall", "recall", `{"query":"tabs"}`),
+			chatCompletion("The project uses tabs."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err = RunAgent(context.Background(), newNativeRunner(t, "agent-memory-2"), nil, "pipeline-1", AgentConfig{
+			Name:    "reviewer",
+			Prompt:  "Review the change.",
+			Model:   "openai/fake-model",
+			Memory:  &AgentMemoryConfig{},
+			Storage: store,
+			RunID:   "run-2",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		var recalled AuditEvent
+		for _, event := range result.AuditLog {
+			if event.Type == "tool_response" && event.ToolName == "recall" {
+				recalled = event
+			}
+		}
+
+		assert.Expect(recalled.ToolResult).To(HaveKey("memories"))
+		assert.Expect(fmt.Sprint(recalled.ToolResult["memories"])).To(ContainSubstring("The project uses tabs."))
+	})
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// outputSchemaRetries is how many times the model is asked to correct a final
+// answer that does not match the output schema.
+const outputSchemaRetries = 2
+
+// compileOutputSchema resolves the agent's output schema so answers can be
+// validated against it. A nil schema disables structured output.
+func compileOutputSchema(outputSchema map[string]any) (*jsonschema.Resolved, error) {
+	if outputSchema == nil {
+		return nil, nil //nolint: nilnil
+	}
+
+	data, err := json.Marshal(outputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %w", err)
+	}
+
+	var schema jsonschema.Schema
+
+	err = json.Unmarshal(data, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %w", err)
+	}
+
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %w", err)
+	}
+
+	return resolved, nil
+}
+
+// parseOutput decodes the model's final answer as JSON and validates it
+// against schema. A surrounding markdown code fence is ignored, since models
+// often add one despite being told not to.
+func parseOutput(schema *jsonschema.Resolved, text string) (any, error) {
+	text = strings.TrimSpace(text)
+
+	if fenced, ok := strings.CutPrefix(text, "```"); ok {
+		// drop the fence's language tag, e.g. ```json
+		_, fenced, _ = strings.Cut(fenced, "\n")
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(fenced), "```"))
+	}
+
+	var output any
+
+	err := json.Unmarshal([]byte(text), &output)
+	if err != nil {
+		return nil, fmt.Errorf("answer is not valid JSON: %w", err)
+	}
+
+	err = schema.Validate(output)
+	if err != nil {
+		return nil, fmt.Errorf("answer does not match the output schema: %w", err)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/orchestra/native"
+	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
+	. "github.com/onsi/gomega"
+)
+
+var verdictSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"verdict": map[string]any{"type": "string", "enum": []any{"approve", "request_changes"}},
+	},
+	"required": []any{"verdict"},
+}
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+
+	t.Run("accepts answers matching the schema", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		schema, err := compileOutputSchema(verdictSchema)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		output, err := parseOutput(schema, `{"verdict": "approve"}`)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(output).To(Equal(map[string]any{"verdict": "approve"}))
+
+		output, err = parseOutput(schema, "```json\n{\"verdict\": \"request_changes\"}\n```")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(output).To(Equal(map[string]any{"verdict": "request_changes"}))
+	})
+
+	t.Run("rejects answers that are not JSON or do not match", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		schema, err := compileOutputSchema(verdictSchema)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = parseOutput(schema, "Looks good to me!")
+		assert.Expect(err).To(MatchError(ContainSubstring("answer is not valid JSON")))
+
+		_, err = parseOutput(schema, `{"verdict": "maybe"}`)
+		assert.Expect(err).To(MatchError(ContainSubstring("answer does not match the output schema")))
+	})
+
+	t.Run("rejects invalid schemas", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		schema, err := compileOutputSchema(nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(schema).To(BeNil())
+
+		_, err = compileOutputSchema(map[string]any{"type": 42})
+		assert.Expect(err).To(MatchError(ContainSubstring("invalid output_schema")))
+	})
+}
+
+func chatCompletion(content string) string {
+	message, _ := json.Marshal(content)
+
+	return fmt.Sprintf(`{
+		"id":"chatcmpl-output",
+		"object":"chat.completion",
+		"created":1730000000,
+		"model":"fake-model",
+		"choices":[{"index":0,"message":{"role":"assistant","content":%s},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}
+	}`, message)
+}
+
+func newNativeRunner(t *testing.T, prefix string) *pipelinerunner.PipelineRunner {
+	t.Helper()
+
+	namespace := fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+
+	driver, err := native.NewNative(namespace, slog.Default(), nil)
+	if err != nil {
+		t.Fatalf("new native driver: %v", err)
+	}
+
+	t.Cleanup(func() { _ = driver.Close() })
+
+	return pipelinerunner.NewPipelineRunner(context.Background(), driver, nil, slog.Default(), namespace, prefix+"-run")
+}
+
+func TestRunAgent_OutputSchema(t *testing.T) {
+	t.Run("re-asks until the answer matches the schema", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			chatCompletion("I approve this change."),
+			chatCompletion(`{"verdict": "approve"}`),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-output"), nil, "", AgentConfig{
+			Name:         "reviewer",
+			Prompt:       "Review the change.",
+			Model:        "openai/fake-model",
+			OutputSchema: verdictSchema,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+		assert.Expect(result.Output).To(Equal(map[string]any{"verdict": "approve"}))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeEquivalentTo(2))
+
+		types := make([]string, 0, len(result.AuditLog))
+		for _, event := range result.AuditLog {
+			types = append(types, event.Type)
+		}
+
+		assert.Expect(types).To(Equal([]string{"user_message", "model_final", "output_invalid", "user_message", "model_final"}))
+	})
+
+	t.Run("validates only the final answer, not narration before tool calls", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		narratedToolCall := `{
+			"id":"chatcmpl-narrated",
+			"object":"chat.completion",
+			"created":1730000000,
+			"model":"fake-model",
+			"choices":[{
+				"index":0,
+				"message":{"role":"assistant","content":"Let me check the change first.","tool_calls":[
+					{"id":"call_echo","type":"function","function":{"name":"run_command","arguments":"{\"command\":\"echo\",\"args\":[\"diff\"]}"}}
+				]},
+				"finish_reason":"tool_calls"
+			}],
+			"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}
+		}`
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			narratedToolCall,
+			chatCompletion(`{"verdict": "approve"}`),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-output-tools"), nil, "", AgentConfig{
+			Name:         "reviewer",
+			Prompt:       "Review the change.",
+			Model:        "openai/fake-model",
+			OutputSchema: verdictSchema,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+		assert.Expect(result.Output).To(Equal(map[string]any{"verdict": "approve"}))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeEquivalentTo(2))
+
+		for _, event := range result.AuditLog {
+			assert.Expect(event.Type).NotTo(Equal("output_invalid"))
+		}
+	})
+
+	t.Run("gives up after the retries are used", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{chatCompletion(`{"verdict": "maybe"}`)})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-output-invalid"), nil, "", AgentConfig{
+			Name:         "reviewer",
+			Prompt:       "Review the change.",
+			Model:        "openai/fake-model",
+			OutputSchema: verdictSchema,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("invalid_output"))
+		assert.Expect(result.Output).To(BeNil())
+		assert.Expect(result.Text).To(Equal(`{"verdict": "maybe"}`))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeEquivalentTo(1 + outputSchemaRetries))
+	})
+}
@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Provider APIs that models are reached through.
+const (
+	ProviderAPIOpenAI    = "openai"
+	ProviderAPIAnthropic = "anthropic"
+)
+
+// Auth header styles that a provider's API key is sent with. Any other value
+// is the name of a header that receives the raw key.
+const (
+	AuthHeaderBearer = "bearer"    // Authorization: Bearer <key>
+	AuthHeaderAPIKey = "x-api-key" // x-api-key: <key>
+	AuthHeaderNone   = "none"      // no key is sent
+)
+
+const (
+	defaultContextWindow = 128000
+	defaultMaxTokens     = 4096
+)
+
+// Provider describes an LLM endpoint that agent steps can choose with a
+// "provider/model" string.
+type Provider struct {
+	Name string `json:"name" yaml:"name"`
+	// API is the protocol the endpoint speaks: openai (the default) or
+	// anthropic. Self-hosted servers such as vLLM and llama.cpp speak openai.
+	API string `json:"api,omitempty" yaml:"api,omitempty"`
+	// BaseURL of the endpoint. Empty uses the API's public endpoint.
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// AuthHeader is how the API key is sent, defaulting to the API's own
+	// style: bearer for openai and x-api-key for anthropic.
+	AuthHeader string `json:"auth_header,omitempty" yaml:"auth_header,omitempty"`
+	// DefaultModel is used when a step names only the provider.
+	DefaultModel string `json:"default_model,omitempty" yaml:"default_model,omitempty"`
+	// ContextWindow and MaxTokens size the context guard and the output
+	// limit, defaulting to 128000 and 4096 tokens.
+	ContextWindow int `json:"context_window,omitempty" yaml:"context_window,omitempty"`
+	MaxTokens     int `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+}
+
+var (
+	providers = map[string]Provider{
+		"anthropic": {
+			Name:          "anthropic",
+			API:           ProviderAPIAnthropic,
+			ContextWindow: 200000,
+		},
+		"openai": {
+			Name:    "openai",
+			API:     ProviderAPIOpenAI,
+			BaseURL: "https://api.openai.com/v1",
+		},
+		"openrouter": {
+			Name:    "openrouter",
+			API:     ProviderAPIOpenAI,
+			BaseURL: "https://openrouter.ai/api/v1",
+		},
+		"ollama": {
+			Name:       "ollama",
+			API:        ProviderAPIOpenAI,
+			BaseURL:    "http://localhost:11434/v1",
+			AuthHeader: AuthHeaderNone,
+		},
+	}
+	providersMu sync.RWMutex
+)
+
+// RegisterProvider adds a provider, or replaces one with the same name, such
+// as to point openai at an internal gateway.
+func RegisterProvider(provider Provider) error {
+	if provider.Name == "" {
+		return fmt.Errorf("provider name is required")
+	}
+
+	if provider.API == "" {
+		provider.API = ProviderAPIOpenAI
+	}
+
+	switch provider.API {
+	case ProviderAPIOpenAI:
+		if provider.BaseURL == "" {
+			return fmt.Errorf("provider %q requires a base_url", provider.Name)
+		}
+	case ProviderAPIAnthropic:
+	default:
+		return fmt.Errorf("provider %q has unsupported api %q: expected openai or anthropic", provider.Name, provider.API)
+	}
+
+	if provider.ContextWindow < 0 || provider.MaxTokens < 0 {
+		return fmt.Errorf("provider %q context_window and max_tokens must not be negative", provider.Name)
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[provider.Name] = provider
+
+	return nil
+}
+
+// GetProvider returns the provider registered under name.
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	provider, ok := providers[name]
+
+	return provider, ok
+}
+
+// ListProviders returns a sorted list of the registered provider names.
+func ListProviders() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadProviders registers the providers declared in a YAML file:
+//
+//	providers:
+//	  - name: vllm
+//	    base_url: http://vllm.internal:8000/v1
+//	    default_model: meta-llama/Llama-3.3-70B-Instruct
+//	    context_window: 131072
+func LoadProviders(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read agent providers: %w", err)
+	}
+
+	var file struct {
+		Providers []Provider `yaml:"providers"`
+	}
+
+	err = yaml.Unmarshal(contents, &file)
+	if err != nil {
+		return fmt.Errorf("could not parse agent providers %q: %w", path, err)
+	}
+
+	for _, provider := range file.Providers {
+		err = RegisterProvider(provider)
+		if err != nil {
+			return fmt.Errorf("could not register agent provider: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// authHeaders returns the headers that send apiKey in the provider's style,
+// and whether the key should instead be given to the client as its own. When
+// it is not, the returned headers replace the auth headers of the client,
+// which falls back to OPENAI_API_KEY or ANTHROPIC_API_KEY when not given a
+// key and must not send those to a provider that authenticates differently.
+func (p Provider) authHeaders(apiKey string) (http.Header, bool) {
+	headers := http.Header{}
+
+	style := p.AuthHeader
+	if style == "" {
+		return headers, true
+	}
+
+	value := apiKey
+
+	switch style {
+	case AuthHeaderBearer:
+		if p.API == ProviderAPIOpenAI {
+			return headers, true
+		}
+
+		style, value = "Authorization", "Bearer "+apiKey
+	case AuthHeaderAPIKey:
+		if p.API == ProviderAPIAnthropic {
+			return headers, true
+		}
+
+		style = "X-Api-Key"
+	}
+
+	if style != AuthHeaderNone && apiKey != "" {
+		headers.Set(style, value)
+	}
+
+	return headers, false
+}
+
+// authOptions returns the client options that replace the auth headers of a
+// provider client with headers.
+func authOptions[T any](headers http.Header, set func(key, value string) T, del func(key string) T) []T {
+	options := []T{del("Authorization"), del("X-Api-Key")}
+
+	for name := range headers {
+		options = append(options, set(name, headers.Get(name)))
+	}
+
+	return options
+}
+
+// providerRegistry sizes contextguard from the provider's declared context
+// window and output limit.
+type providerRegistry struct {
+	provider Provider
+}
+
+func (r providerRegistry) ContextWindow(_ string) int {
+	if r.provider.ContextWindow > 0 {
+		return r.provider.ContextWindow
+	}
+
+	return defaultContextWindow
+}
+
+func (r providerRegistry) DefaultMaxTokens(_ string) int {
+	if r.provider.MaxTokens > 0 {
+		return r.provider.MaxTokens
+	}
+
+	return defaultMaxTokens
+}
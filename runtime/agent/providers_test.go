@@ -0,0 +1,252 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	adkmodel "google.golang.org/adk/model"
+	"google.golang.org/genai"
+
+	. "github.com/onsi/gomega"
+)
+
+type capturedRequest struct {
+	Path    string
+	Headers http.Header
+	Body    map[string]any
+}
+
+// newProviderServer stands in for a provider's API, answering in the OpenAI
+// or Anthropic format and recording each request.
+func newProviderServer(t *testing.T, api string) (*httptest.Server, func() capturedRequest) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		captured capturedRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		captured = capturedRequest{Path: r.URL.Path, Headers: r.Header.Clone()}
+		_ = json.Unmarshal(body, &captured.Body)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if api == ProviderAPIAnthropic {
+			_, _ = w.Write([]byte(`{
+				"id":"msg_1",
+				"type":"message",
+				"role":"assistant",
+				"model":"stand-in",
+				"content":[{"type":"text","text":"hello from anthropic"}],
+				"stop_reason":"end_turn",
+				"usage":{"input_tokens":3,"output_tokens":4}
+			}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{
+			"id":"chatcmpl-1",
+			"object":"chat.completion",
+			"created":1730000000,
+			"model":"stand-in",
+			"choices":[{"index":0,"message":{"role":"assistant","content":"hello from openai"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}
+		}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() capturedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return captured
+	}
+}
+
+func generateText(t *testing.T, model string, apiKey string) string {
+	t.Helper()
+	assert := NewGomegaWithT(t)
+
+	provider, modelName, err := lookupModel(model)
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	llm, err := resolveModel(provider, modelName, apiKey, nil, nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	var text string
+
+	for response, err := range llm.GenerateContent(context.Background(), &adkmodel.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("hello", genai.RoleUser)},
+	}, false) {
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		for _, part := range response.Content.Parts {
+			text += part.Text
+		}
+	}
+
+	return text
+}
+
+func TestProviders(t *testing.T) {
+	// The clients fall back to these when not given a key, which must not be
+	// sent to providers that authenticate differently.
+	t.Setenv("OPENAI_API_KEY", "env-openai-key")
+	t.Setenv("ANTHROPIC_API_KEY", "env-anthropic-key")
+
+	t.Run("sends requests to a custom base URL with its default model", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		server, captured := newProviderServer(t, ProviderAPIOpenAI)
+
+		err := RegisterProvider(Provider{
+			Name:         "test-vllm",
+			BaseURL:      server.URL + "/v1",
+			DefaultModel: "meta-llama/Llama-3.3-70B-Instruct",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(generateText(t, "test-vllm", "vllm-key")).To(Equal("hello from openai"))
+
+		request := captured()
+		assert.Expect(request.Path).To(Equal("/v1/chat/completions"))
+		assert.Expect(request.Headers.Get("Authorization")).To(Equal("Bearer vllm-key"))
+		assert.Expect(request.Body).To(HaveKeyWithValue("model", "meta-llama/Llama-3.3-70B-Instruct"))
+
+		generateText(t, "test-vllm/other-model", "vllm-key")
+		assert.Expect(captured().Body).To(HaveKeyWithValue("model", "other-model"))
+	})
+
+	t.Run("sends the API key in the provider's auth header", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		server, captured := newProviderServer(t, ProviderAPIOpenAI)
+
+		err := RegisterProvider(Provider{
+			Name:       "test-gateway",
+			BaseURL:    server.URL,
+			AuthHeader: "X-Gateway-Token",
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		generateText(t, "test-gateway/model", "gateway-key")
+
+		request := captured()
+		assert.Expect(request.Headers.Get("X-Gateway-Token")).To(Equal("gateway-key"))
+		assert.Expect(request.Headers.Get("Authorization")).To(BeEmpty())
+		assert.Expect(request.Headers.Get("X-Api-Key")).To(BeEmpty())
+		assert.Expect(request.Headers.Get("X-Pocketci-Provider-Auth")).To(BeEmpty())
+
+		generateText(t, "test-gateway/model", "")
+		assert.Expect(captured().Headers.Get("Authorization")).To(BeEmpty())
+
+		err = RegisterProvider(Provider{
+			Name:       "test-llamacpp",
+			BaseURL:    server.URL,
+			AuthHeader: AuthHeaderNone,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		generateText(t, "test-llamacpp/model", "unused-key")
+
+		request = captured()
+		assert.Expect(request.Headers.Get("Authorization")).To(BeEmpty())
+		assert.Expect(request.Headers.Get("X-Api-Key")).To(BeEmpty())
+
+		// the headers are set per client, not on the process's default one
+		assert.Expect(http.DefaultClient.Transport).To(BeNil())
+	})
+
+	t.Run("speaks the anthropic API", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		server, captured := newProviderServer(t, ProviderAPIAnthropic)
+
+		err := RegisterProvider(Provider{
+			Name:       "test-anthropic-proxy",
+			API:        ProviderAPIAnthropic,
+			BaseURL:    server.URL,
+			AuthHeader: AuthHeaderBearer,
+			MaxTokens:  2048,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(generateText(t, "test-anthropic-proxy/claude", "proxy-key")).To(Equal("hello from anthropic"))
+
+		request := captured()
+		assert.Expect(request.Path).To(Equal("/v1/messages"))
+		assert.Expect(request.Headers.Get("Authorization")).To(Equal("Bearer proxy-key"))
+		assert.Expect(request.Headers.Get("X-Api-Key")).To(BeEmpty())
+		assert.Expect(request.Body).To(HaveKeyWithValue("max_tokens", BeNumerically("==", 2048)))
+	})
+
+	t.Run("sizes the context guard from the provider", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		registry := providerRegistry{provider: Provider{ContextWindow: 32768, MaxTokens: 2048}}
+		assert.Expect(registry.ContextWindow("any")).To(Equal(32768))
+		assert.Expect(registry.DefaultMaxTokens("any")).To(Equal(2048))
+
+		registry = providerRegistry{}
+		assert.Expect(registry.ContextWindow("any")).To(Equal(128000))
+		assert.Expect(registry.DefaultMaxTokens("any")).To(Equal(4096))
+
+		anthropic, ok := GetProvider("anthropic")
+		assert.Expect(ok).To(BeTrue())
+		assert.Expect(providerRegistry{provider: anthropic}.ContextWindow("claude")).To(Equal(200000))
+	})
+
+	t.Run("loads providers from a file", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		path := filepath.Join(t.TempDir(), "providers.yml")
+		err := os.WriteFile(path, []byte(`
+providers:
+  - name: test-loaded
+    base_url: http://llm.internal/v1
+    default_model: qwen3
+    context_window: 65536
+`), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		assert.Expect(LoadProviders(path)).To(Succeed())
+
+		provider, modelName, err := lookupModel("test-loaded")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(provider.API).To(Equal(ProviderAPIOpenAI))
+		assert.Expect(provider.ContextWindow).To(Equal(65536))
+		assert.Expect(modelName).To(Equal("qwen3"))
+
+		err = os.WriteFile(path, []byte("providers:\n  - name: test-invalid\n    api: gemini\n"), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(LoadProviders(path)).To(MatchError(ContainSubstring(`unsupported api "gemini"`)))
+
+		err = os.WriteFile(path, []byte("providers:\n  - name: test-invalid\n"), 0o600)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(LoadProviders(path)).To(MatchError(ContainSubstring(`provider "test-invalid" requires a base_url`)))
+	})
+
+	t.Run("rejects unknown providers and missing models", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		_, _, err := lookupModel("nope/model")
+		assert.Expect(err).To(MatchError(ContainSubstring(`unknown provider "nope"`)))
+		assert.Expect(err).To(MatchError(ContainSubstring("anthropic, ")))
+
+		_, _, err = lookupModel("openai")
+		assert.Expect(err).To(MatchError(ContainSubstring(`provider "openai" has no default_model`)))
+	})
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	adktool "google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+
+	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
+	"github.com/jtarchie/pocketci/secrets"
+)
+
+// AgentSubAgent is a specialised agent that a coordinator delegates to by
+// calling it as a tool named Name. It runs through RunAgent in its own
+// sandbox; Model, Image and Mounts default to the coordinator's. Policy,
+// safety settings and approvals are inherited from the coordinator.
+type AgentSubAgent struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Prompt describes the sub-agent's role. The coordinator's task is
+	// appended to it.
+	Prompt       string                                 `json:"prompt"`
+	Model        string                                 `json:"model,omitempty"`
+	Image        string                                 `json:"image,omitempty"`
+	Mounts       map[string]pipelinerunner.VolumeResult `json:"mounts,omitempty"`
+	LLM          *AgentLLMConfig                        `json:"llm,omitempty"`
+	Thinking     *AgentThinkingConfig                   `json:"thinking,omitempty"`
+	Limits       *AgentLimitsConfig                     `json:"limits,omitempty"`
+	Context      *AgentContext                          `json:"context,omitempty"`
+	MCPServers   []AgentMCPServer                       `json:"mcp_servers,omitempty"`
+	OutputSchema map[string]any                         `json:"output_schema,omitempty"`
+	SubAgents    []AgentSubAgent                        `json:"sub_agents,omitempty"`
+}
+
+// subAgentInput is the tool schema of a sub-agent.
+type subAgentInput struct {
+	Task string `json:"task"`
+}
+
+// subAgentOutput is the tool result schema of a sub-agent.
+type subAgentOutput struct {
+	Status string `json:"status"`
+	Text   string `json:"text"`
+	Output any    `json:"output,omitempty"`
+}
+
+// agentBudget counts turns and tokens across a coordinator and its
+// sub-agents, so the coordinator's limits apply to the whole tree.
+type agentBudget struct {
+	mu             sync.Mutex
+	maxTurns       int
+	maxTotalTokens int32
+	turns          int
+	totalTokens    int32
+}
+
+func newAgentBudget(limits *AgentLimitsConfig) *agentBudget {
+	maxTurns, maxTotalTokens := effectiveLimits(limits)
+
+	return &agentBudget{maxTurns: maxTurns, maxTotalTokens: maxTotalTokens}
+}
+
+// spend records one model response and returns the tree's totals.
+func (b *agentBudget) spend(tokens int32) (int, int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.turns++
+	b.totalTokens += tokens
+
+	return b.turns, b.totalTokens
+}
+
+// restore records what an interrupted run of the agent already spent.
+func (b *agentBudget) restore(turns int, tokens int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.turns += turns
+	b.totalTokens += tokens
+}
+
+// validateSubAgents checks sub-agent definitions before the sandbox is
+// started. reserved holds the names of the coordinator's pipeline tools.
+func validateSubAgents(subAgents []AgentSubAgent, reserved []string) error {
+	seen := map[string]bool{}
+
+	for _, subAgent := range subAgents {
+		if !toolNamePattern.MatchString(subAgent.Name) {
+			return fmt.Errorf("sub-agent name %q must start with a letter or underscore and contain only letters, digits, '_' and '-'", subAgent.Name)
+		}
+
+		if slices.Contains(builtinToolNames, subAgent.Name) || slices.Contains(reserved, subAgent.Name) {
+			return fmt.Errorf("sub-agent %q conflicts with a tool of the same name", subAgent.Name)
+		}
+
+		if seen[subAgent.Name] {
+			return fmt.Errorf("sub-agent %q is defined more than once", subAgent.Name)
+		}
+
+		seen[subAgent.Name] = true
+
+		if subAgent.Prompt == "" {
+			return fmt.Errorf("sub-agent %q requires a prompt", subAgent.Name)
+		}
+
+		err := validateSubAgents(subAgent.SubAgents, nil)
+		if err != nil {
+			return fmt.Errorf("sub-agent %q: %w", subAgent.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// subAgentConfig builds the config a sub-agent runs with for task.
+func subAgentConfig(parent AgentConfig, subAgent AgentSubAgent, task string, budget *agentBudget) AgentConfig {
+	mounts := parent.Mounts
+	if subAgent.Mounts != nil {
+		mounts = maps.Clone(subAgent.Mounts)
+	}
+
+	return AgentConfig{
+		Name:         parent.Name + "-" + subAgent.Name,
+		Prompt:       fmt.Sprintf("%s\n\nTask from %s:\n%s", subAgent.Prompt, parent.Name, task),
+		Model:        cmp.Or(subAgent.Model, parent.Model),
+		Image:        cmp.Or(subAgent.Image, parent.Image),
+		Mounts:       mounts,
+		LLM:          subAgent.LLM,
+		Thinking:     subAgent.Thinking,
+		Safety:       parent.Safety,
+		Limits:       subAgent.Limits,
+		Context:      subAgent.Context,
+		MCPServers:   subAgent.MCPServers,
+		OutputSchema: subAgent.OutputSchema,
+		Policy:       parent.Policy,
+		SubAgents:    subAgent.SubAgents,
+		OnOutput:     parent.OnOutput,
+		Storage:      parent.Storage,
+		Namespace:    parent.Namespace,
+		RunID:        parent.RunID,
+		PipelineID:   parent.PipelineID,
+		TriggeredBy:  parent.TriggeredBy,
+		Approvals:    parent.Approvals,
+		budget:       budget,
+	}
+}
+
+// buildSubAgentTools creates a tool per sub-agent that runs it to completion
+// and returns its answer. onResult is called with each finished run.
+func buildSubAgentTools(
+	sandboxRunner pipelinerunner.Runner,
+	sm secrets.Manager,
+	pipelineID string,
+	parent AgentConfig,
+	budget *agentBudget,
+	onResult func(subAgent AgentSubAgent, callID string, result *AgentResult),
+) ([]adktool.Tool, error) {
+	built := make([]adktool.Tool, 0, len(parent.SubAgents))
+
+	for _, subAgent := range parent.SubAgents {
+		description := subAgent.Description
+		if description == "" {
+			description = fmt.Sprintf("Delegate a task to the %s sub-agent and return its answer.", subAgent.Name)
+		}
+
+		tool, err := functiontool.New[subAgentInput, subAgentOutput](
+			functiontool.Config{
+				Name:        subAgent.Name,
+				Description: description,
+			},
+			func(toolCtx adktool.Context, input subAgentInput) (subAgentOutput, error) {
+				config := subAgentConfig(parent, subAgent, input.Task, budget)
+
+				result, err := RunAgent(toolCtx, sandboxRunner, sm, pipelineID, config)
+				if err != nil {
+					return subAgentOutput{}, fmt.Errorf("sub-agent %q failed: %w", subAgent.Name, err)
+				}
+
+				onResult(subAgent, toolCtx.FunctionCallID(), result)
+
+				return subAgentOutput{Status: result.Status, Text: result.Text, Output: result.Output}, nil
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sub-agent tool %q: %w", subAgent.Name, err)
+		}
+
+		built = append(built, tool)
+	}
+
+	return built, nil
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSubAgents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates sub-agent definitions", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(validateSubAgents([]AgentSubAgent{
+			{Name: "researcher", Prompt: "Find facts.", SubAgents: []AgentSubAgent{{Name: "searcher", Prompt: "Search."}}},
+			{Name: "writer", Prompt: "Write."},
+		}, []string{"run_tests"})).To(Succeed())
+
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "bad name", Prompt: "x"}}, nil)).
+			To(MatchError(ContainSubstring(`sub-agent name "bad name" must start with a letter`)))
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "run_command", Prompt: "x"}}, nil)).
+			To(MatchError(ContainSubstring(`sub-agent "run_command" conflicts with a tool`)))
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "run_tests", Prompt: "x"}}, []string{"run_tests"})).
+			To(MatchError(ContainSubstring(`sub-agent "run_tests" conflicts with a tool`)))
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "a", Prompt: "x"}, {Name: "a", Prompt: "x"}}, nil)).
+			To(MatchError(ContainSubstring(`sub-agent "a" is defined more than once`)))
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "a"}}, nil)).
+			To(MatchError(ContainSubstring(`sub-agent "a" requires a prompt`)))
+		assert.Expect(validateSubAgents([]AgentSubAgent{{Name: "a", Prompt: "x", SubAgents: []AgentSubAgent{{Name: "b"}}}}, nil)).
+			To(MatchError(ContainSubstring(`sub-agent "a": sub-agent "b" requires a prompt`)))
+	})
+
+	t.Run("falls back to the coordinator's settings", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		parent := AgentConfig{
+			Name:   "coordinator",
+			Model:  "openai/big-model",
+			Image:  "alpine",
+			RunID:  "run-1",
+			Policy: []AgentPolicyRule{{Tool: "run_script", Action: "deny"}},
+		}
+
+		config := subAgentConfig(parent, AgentSubAgent{Name: "researcher", Prompt: "Find facts.", Model: "openai/small-model"}, "Look up X.", nil)
+		assert.Expect(config.Name).To(Equal("coordinator-researcher"))
+		assert.Expect(config.Prompt).To(Equal("Find facts.\n\nTask from coordinator:\nLook up X."))
+		assert.Expect(config.Model).To(Equal("openai/small-model"))
+		assert.Expect(config.Image).To(Equal("alpine"))
+		assert.Expect(config.RunID).To(Equal("run-1"))
+		assert.Expect(config.Policy).To(Equal(parent.Policy))
+	})
+}
+
+func TestRunAgent_SubAgents(t *testing.T) {
+	auditTypes := func(events []AuditEvent) []string {
+		types := []string{}
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+
+		return types
+	}
+
+	subAgents := []AgentSubAgent{{
+		Name:        "researcher",
+		Description: "Researches a question.",
+		Prompt:      "You research questions.",
+	}}
+
+	t.Run("delegates to a sub-agent and rolls up its usage and audit log", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_research", "researcher", `{"task":"What is X?"}`),
+			chatCompletion("X is 42."),
+			chatCompletion("The researcher found that X is 42."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-sub"), nil, "", AgentConfig{
+			Name:      "coordinator",
+			Prompt:    "Find out X.",
+			Model:     "openai/fake-model",
+			SubAgents: subAgents,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("success"))
+		assert.Expect(result.Text).To(Equal("The researcher found that X is 42."))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeEquivalentTo(3))
+
+		assert.Expect(result.Usage.LLMRequests).To(Equal(3))
+		assert.Expect(result.Usage.TotalTokens).To(BeEquivalentTo(45))
+		assert.Expect(result.Usage.ToolCallCount).To(Equal(1))
+
+		assert.Expect(auditTypes(result.AuditLog)).To(Equal([]string{"user_message", "tool_call", "sub_agent", "tool_response", "model_final"}))
+
+		subAgentEvent := result.AuditLog[2]
+		assert.Expect(subAgentEvent.ToolName).To(Equal("researcher"))
+		assert.Expect(subAgentEvent.ToolCallID).To(Equal("call_research"))
+		assert.Expect(subAgentEvent.Text).To(Equal("X is 42."))
+		assert.Expect(auditTypes(subAgentEvent.Events)).To(Equal([]string{"user_message", "model_final"}))
+		assert.Expect(subAgentEvent.Events[0].Text).To(ContainSubstring("What is X?"))
+
+		assert.Expect(result.AuditLog[3].ToolResult).To(HaveKeyWithValue("text", "X is 42."))
+	})
+
+	t.Run("applies the coordinator's limits across the tree", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_research", "researcher", `{"task":"What is X?"}`),
+			chatCompletion("X is 42."),
+			chatCompletion("The researcher found that X is 42."),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-sub-limits"), nil, "", AgentConfig{
+			Name:      "coordinator",
+			Prompt:    "Find out X.",
+			Model:     "openai/fake-model",
+			SubAgents: subAgents,
+			Limits:    &AgentLimitsConfig{MaxTurns: 2},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.Status).To(Equal("limit_exceeded"))
+		assert.Expect(atomic.LoadInt32(requests)).To(BeEquivalentTo(3))
+
+		subAgentEvent := result.AuditLog[2]
+		assert.Expect(subAgentEvent.Type).To(Equal("sub_agent"))
+		assert.Expect(auditTypes(subAgentEvent.Events)).To(ContainElement("limit_warning"))
+		assert.Expect(result.AuditLog[3].ToolResult).To(HaveKeyWithValue("status", "limit_exceeded"))
+	})
+}
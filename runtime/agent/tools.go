@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"text/template"
+
+	sprig "github.com/go-task/slim-sprig/v3"
+	"github.com/google/jsonschema-go/jsonschema"
+	adktool "google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+
+	pipelinerunner "github.com/jtarchie/pocketci/runtime/runner"
+)
+
+// builtinToolNames are the tools every agent has, which pipeline tools cannot
+// replace.
+var builtinToolNames = []string{"run_command", "run_script", "read_file", "list_tasks", "get_task_result"}
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]{0,63}$`)
+
+// AgentTool is a pipeline-defined tool offered to the model alongside the
+// built-in ones, so a pipeline can hand out narrowly-scoped actions such as
+// "run_tests" instead of a raw shell. A tool runs either Command in the
+// agent's sandbox or Handler.
+type AgentTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the tool's arguments. Defaults to an
+	// object without properties.
+	Parameters map[string]any    `json:"parameters,omitempty"`
+	Command    *AgentToolCommand `json:"command,omitempty"`
+	// Handler is called with the model's arguments. Set from a JS callback,
+	// not serialised.
+	Handler func(args map[string]any) (map[string]any, error) `json:"-"`
+}
+
+// AgentToolCommand runs an executable in the agent's sandbox. Path and each
+// of Args are Go templates (with Sprig functions) rendered with the tool's
+// arguments, e.g. "{{ .package }}". Args are passed as-is, never through a
+// shell.
+type AgentToolCommand struct {
+	Path    string            `json:"path"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	WorkDir string            `json:"work_dir,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+}
+
+// validateTools checks pipeline tools before the sandbox is started.
+func validateTools(tools []AgentTool) error {
+	seen := map[string]bool{}
+
+	for _, tool := range tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			return fmt.Errorf("tool name %q must start with a letter or underscore and contain only letters, digits, '_' and '-'", tool.Name)
+		}
+
+		if slices.Contains(builtinToolNames, tool.Name) {
+			return fmt.Errorf("tool %q conflicts with a built-in tool", tool.Name)
+		}
+
+		if seen[tool.Name] {
+			return fmt.Errorf("tool %q is defined more than once", tool.Name)
+		}
+
+		seen[tool.Name] = true
+
+		if (tool.Command == nil) == (tool.Handler == nil) {
+			return fmt.Errorf("tool %q requires exactly one of command or handler", tool.Name)
+		}
+
+		if tool.Command != nil && tool.Command.Path == "" {
+			return fmt.Errorf("tool %q command requires a path", tool.Name)
+		}
+	}
+
+	return nil
+}
+
+// buildTools creates the ADK tools for pipeline tools. Command tools run in
+// sandbox, streaming their output to onOutput.
+func buildTools(tools []AgentTool, sandbox *pipelinerunner.SandboxHandle, onOutput pipelinerunner.OutputCallback) ([]adktool.Tool, error) {
+	built := make([]adktool.Tool, 0, len(tools))
+
+	for _, tool := range tools {
+		schema, err := toolSchema(tool.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("tool %q has invalid parameters: %w", tool.Name, err)
+		}
+
+		handler := tool.Handler
+		if tool.Command != nil {
+			handler = commandHandler(tool.Name, *tool.Command, sandbox, onOutput)
+		}
+
+		adkTool, err := functiontool.New[map[string]any, map[string]any](
+			functiontool.Config{
+				Name:        tool.Name,
+				Description: tool.Description,
+				InputSchema: schema,
+			},
+			func(_ adktool.Context, args map[string]any) (map[string]any, error) {
+				if args == nil {
+					args = map[string]any{}
+				}
+
+				return handler(args)
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tool %q: %w", tool.Name, err)
+		}
+
+		built = append(built, adkTool)
+	}
+
+	return built, nil
+}
+
+func toolSchema(parameters map[string]any) (*jsonschema.Schema, error) {
+	if len(parameters) == 0 {
+		return &jsonschema.Schema{Type: "object"}, nil
+	}
+
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema jsonschema.Schema
+
+	err = json.Unmarshal(data, &schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Type != "object" {
+		return nil, fmt.Errorf("schema type must be \"object\", got %q", schema.Type)
+	}
+
+	return &schema, nil
+}
+
+func commandHandler(
+	name string,
+	command AgentToolCommand,
+	sandbox *pipelinerunner.SandboxHandle,
+	onOutput pipelinerunner.OutputCallback,
+) func(map[string]any) (map[string]any, error) {
+	return func(args map[string]any) (map[string]any, error) {
+		var execInput pipelinerunner.ExecInput
+
+		path, err := renderToolTemplate(name, command.Path, args)
+		if err != nil {
+			return nil, err
+		}
+
+		execInput.Command.Path = path
+
+		for _, arg := range command.Args {
+			rendered, err := renderToolTemplate(name, arg, args)
+			if err != nil {
+				return nil, err
+			}
+
+			execInput.Command.Args = append(execInput.Command.Args, rendered)
+		}
+
+		execInput.Env = command.Env
+		execInput.WorkDir = command.WorkDir
+		execInput.Timeout = command.Timeout
+		execInput.OnOutput = onOutput
+
+		result, err := sandbox.Exec(execInput)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{
+			"stdout":    result.Stdout,
+			"stderr":    result.Stderr,
+			"exit_code": result.Code,
+		}, nil
+	}
+}
+
+func renderToolTemplate(name, text string, args map[string]any) (string, error) {
+	tmpl, err := template.New(name).Funcs(sprig.FuncMap()).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("tool %q: could not parse command template %q: %w", name, text, err)
+	}
+
+	var rendered bytes.Buffer
+
+	err = tmpl.Execute(&rendered, args)
+	if err != nil {
+		return "", fmt.Errorf("tool %q: could not render command template %q: %w", name, text, err)
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTools(t *testing.T) {
+	t.Parallel()
+
+	handler := func(args map[string]any) (map[string]any, error) { return args, nil }
+
+	t.Run("validates tool definitions", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		assert.Expect(validateTools([]AgentTool{
+			{Name: "run_tests", Command: &AgentToolCommand{Path: "go"}},
+			{Name: "notify-team", Handler: handler},
+		})).To(Succeed())
+
+		assert.Expect(validateTools([]AgentTool{{Name: "run tests", Handler: handler}})).
+			To(MatchError(ContainSubstring(`tool name "run tests" must start with`)))
+		assert.Expect(validateTools([]AgentTool{{Name: "read_file", Handler: handler}})).
+			To(MatchError(ContainSubstring(`tool "read_file" conflicts with a built-in tool`)))
+		assert.Expect(validateTools([]AgentTool{{Name: "a", Handler: handler}, {Name: "a", Handler: handler}})).
+			To(MatchError(ContainSubstring(`tool "a" is defined more than once`)))
+		assert.Expect(validateTools([]AgentTool{{Name: "a"}})).
+			To(MatchError(ContainSubstring(`tool "a" requires exactly one of command or handler`)))
+		assert.Expect(validateTools([]AgentTool{{Name: "a", Handler: handler, Command: &AgentToolCommand{Path: "go"}}})).
+			To(MatchError(ContainSubstring(`tool "a" requires exactly one of command or handler`)))
+		assert.Expect(validateTools([]AgentTool{{Name: "a", Command: &AgentToolCommand{}}})).
+			To(MatchError(ContainSubstring(`tool "a" command requires a path`)))
+	})
+
+	t.Run("converts parameters to a schema", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		schema, err := toolSchema(nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(schema.Type).To(Equal("object"))
+
+		schema, err = toolSchema(map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"package": map[string]any{"type": "string"}},
+			"required":   []any{"package"},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(schema.Properties).To(HaveKey("package"))
+		assert.Expect(schema.Required).To(Equal([]string{"package"}))
+
+		_, err = toolSchema(map[string]any{"type": "string"})
+		assert.Expect(err).To(MatchError(ContainSubstring(`schema type must be "object"`)))
+	})
+
+	t.Run("renders command templates with the arguments", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		rendered, err := renderToolTemplate("run_tests", "{{ .package | default \"./...\" }}", map[string]any{"package": "./server"})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(rendered).To(Equal("./server"))
+
+		rendered, err = renderToolTemplate("run_tests", "{{ .package | default \"./...\" }}", map[string]any{})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(rendered).To(Equal("./..."))
+
+		_, err = renderToolTemplate("run_tests", "{{ .package", map[string]any{})
+		assert.Expect(err).To(MatchError(ContainSubstring(`tool "run_tests": could not parse command template`)))
+	})
+
+	t.Run("builds tools for the model", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		tools, err := buildTools([]AgentTool{
+			{Name: "run_tests", Description: "runs tests", Command: &AgentToolCommand{Path: "go"}},
+			{Name: "notify_team", Description: "notifies", Handler: handler},
+		}, nil, nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(tools).To(HaveLen(2))
+		assert.Expect(tools[0].Name()).To(Equal("run_tests"))
+		assert.Expect(tools[1].Description()).To(Equal("notifies"))
+	})
+}
+
+func TestRunAgent_Tools(t *testing.T) {
+	toolEvents := func(result *AgentResult, name string) []AuditEvent {
+		events := []AuditEvent{}
+		for _, event := range result.AuditLog {
+			if event.ToolName == name {
+				events = append(events, event)
+			}
+		}
+
+		return events
+	}
+
+	t.Run("runs command tools in the sandbox", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, requests := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_greet", "greet", `{"name":"world"}`),
+			chatCompletion("done"),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-command-tool"), nil, "", AgentConfig{
+			Name:   "greeter",
+			Prompt: "Greet the world.",
+			Model:  "openai/fake-model",
+			Tools: []AgentTool{{
+				Name:        "greet",
+				Description: "Greets someone",
+				Command:     &AgentToolCommand{Path: "echo", Args: []string{"hello {{ .name }}"}},
+			}},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(*requests).To(BeNumerically("==", 2))
+
+		events := toolEvents(result, "greet")
+		assert.Expect(events).To(HaveLen(2))
+		assert.Expect(events[0].Type).To(Equal("tool_call"))
+		assert.Expect(events[0].ToolArgs).To(HaveKeyWithValue("name", "world"))
+		assert.Expect(events[1].Type).To(Equal("tool_response"))
+		assert.Expect(events[1].ToolResult).To(HaveKeyWithValue("stdout", "hello world\n"))
+		assert.Expect(events[1].ToolResult).To(HaveKeyWithValue("exit_code", BeNumerically("==", 0)))
+	})
+
+	t.Run("calls handler tools and reports their errors to the model", func(t *testing.T) {
+		assert := NewGomegaWithT(t)
+
+		llm, _ := newSequencedLLMServer(t, []string{
+			toolCallCompletion("call_lookup", "lookup", `{"key":"region"}`),
+			toolCallCompletion("call_broken", "broken", `{}`),
+			chatCompletion("done"),
+		})
+		configureFakeOpenAI(t, llm.URL)
+
+		var received map[string]any
+
+		result, err := RunAgent(context.Background(), newNativeRunner(t, "agent-handler-tool"), nil, "", AgentConfig{
+			Name:   "helper",
+			Prompt: "Look up the region.",
+			Model:  "openai/fake-model",
+			Tools: []AgentTool{
+				{
+					Name: "lookup",
+					Handler: func(args map[string]any) (map[string]any, error) {
+						received = args
+
+						return map[string]any{"value": "us-east-1"}, nil
+					},
+				},
+				{
+					Name: "broken",
+					Handler: func(map[string]any) (map[string]any, error) {
+						return nil, errors.New("lookup service unavailable")
+					},
+				},
+			},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(received).To(Equal(map[string]any{"key": "region"}))
+
+		events := toolEvents(result, "lookup")
+		assert.Expect(events).To(HaveLen(2))
+		assert.Expect(events[1].ToolResult).To(Equal(map[string]any{"value": "us-east-1"}))
+
+		events = toolEvents(result, "broken")
+		assert.Expect(events).To(HaveLen(2))
+		assert.Expect(events[1].ToolResult).To(HaveKeyWithValue("error", ContainSubstring("lookup service unavailable")))
+	})
+}
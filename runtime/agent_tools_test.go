@@ -0,0 +1,120 @@
+package runtime_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jtarchie/pocketci/orchestra/native"
+	"github.com/jtarchie/pocketci/runtime"
+	"github.com/jtarchie/pocketci/runtime/agent"
+	storage "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestAgentTools(t *testing.T) {
+	t.Parallel()
+
+	t.Run("calls JS handlers on the event loop", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		responses := []string{
+			`{
+				"id":"chatcmpl-1",
+				"object":"chat.completion",
+				"created":1730000000,
+				"model":"fake-model",
+				"choices":[{
+					"index":0,
+					"message":{"role":"assistant","content":"","tool_calls":[
+						{"id":"call_lookup","type":"function","function":{"name":"lookup","arguments":"{\"key\":\"region\"}"}},
+						{"id":"call_fail","type":"function","function":{"name":"fail","arguments":"{}"}}
+					]},
+					"finish_reason":"tool_calls"
+				}],
+				"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}
+			}`,
+			`{
+				"id":"chatcmpl-2",
+				"object":"chat.completion",
+				"created":1730000000,
+				"model":"fake-model",
+				"choices":[{"index":0,"message":{"role":"assistant","content":"done"},"finish_reason":"stop"}],
+				"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}
+			}`,
+		}
+
+		var requests int32
+
+		llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			index := min(int(atomic.AddInt32(&requests, 1))-1, len(responses)-1)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(responses[index]))
+		}))
+		t.Cleanup(llm.Close)
+
+		err := agent.RegisterProvider(agent.Provider{
+			Name:       "test-js-tools",
+			BaseURL:    llm.URL + "/v1",
+			AuthHeader: agent.AuthHeaderNone,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		store, err := storage.NewSqlite("sqlite://:memory:", "test-ns", nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = store.Close() })
+
+		driver, err := native.NewNative("test-ns", slog.Default(), nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = driver.Close() })
+
+		js := runtime.NewJS(slog.Default())
+		err = js.ExecuteWithOptions(context.Background(), `
+			const pipeline = async () => {
+				const calls = [];
+
+				const result = await runtime.agent({
+					name: "helper",
+					prompt: "Look up the region.",
+					model: "test-js-tools/fake-model",
+					tools: [
+						{
+							name: "lookup",
+							description: "Looks up a value",
+							handler: async ({ key }) => {
+								calls.push(key);
+								return "value of " + key;
+							},
+						},
+						{
+							name: "fail",
+							description: "Always fails",
+							handler: () => {
+								throw new Error("not available");
+							},
+						},
+					],
+				});
+
+				assert.equal(calls.length, 1);
+				assert.equal(calls[0], "region");
+
+				const responses = result.auditLog.filter((event) => event.type === "tool_response");
+				const lookup = responses.find((event) => event.toolName === "lookup");
+				assert.equal(lookup.toolResult.result, "value of region");
+
+				const fail = responses.find((event) => event.toolName === "fail");
+				assert.containsString(fail.toolResult.error, "not available");
+			};
+
+			export { pipeline };
+		`, driver, store, runtime.ExecuteOptions{RunID: "agent-tools-run", Namespace: "test-ns"})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(atomic.LoadInt32(&requests)).To(BeNumerically("==", 2))
+	})
+}
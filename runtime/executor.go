@@ -8,6 +8,7 @@ import (
 
 	"github.com/jtarchie/pocketci/orchestra"
 	"github.com/jtarchie/pocketci/orchestra/cache"
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/runtime/jsapi"
 	"github.com/jtarchie/pocketci/runtime/support"
 	"github.com/jtarchie/pocketci/secrets"
@@ -35,6 +36,9 @@ type ExecutorOptions struct {
 	// SecretsManager provides access to encrypted secrets.
 	// If nil, secret resolution is disabled.
 	SecretsManager secrets.Manager
+	// SecretValues are redacted from task output, such as secrets that were
+	// interpolated into a YAML pipeline's ((vars)).
+	SecretValues []string
 	// DisableNotifications prevents the notify system from sending messages.
 	DisableNotifications bool
 	// DisableFetch prevents the fetch() function from making outbound HTTP requests.
@@ -45,6 +49,12 @@ type ExecutorOptions struct {
 	FetchMaxResponseBytes int64
 	// Args contains CLI arguments passed to the pipeline via pipelineContext.args.
 	Args []string
+	// TriggeredBy overrides pipelineContext.triggeredBy (e.g. "resource").
+	// Defaults to "webhook" when WebhookData is set, otherwise "manual".
+	TriggeredBy string
+	// Jobs restricts a YAML pipeline run to the named jobs and their dependents.
+	// It is passed to the pipeline via pipelineContext.jobs.
+	Jobs []string
 	// PreseededVolumes maps volume names to pre-created, already-seeded volumes.
 	// When the pipeline calls runtime.createVolume("name"), a matching
 	// pre-created volume is reused instead of creating a new one.
@@ -55,6 +65,12 @@ type ExecutorOptions struct {
 	// Driver, if set, is used for pipeline execution instead of creating
 	// one from the driver DSN. The caller owns the driver lifecycle.
 	Driver orchestra.Driver
+	// Approvals receives agent tool calls that require human approval.
+	// If nil, such tool calls are denied.
+	Approvals *agent.Approvals
+	// AgentCosts checks the pipeline's budget before each agent starts and
+	// records what agents spend.
+	AgentCosts agent.CostTracker
 }
 
 // ExecutePipeline executes a pipeline with the given content and driver DSN.
@@ -125,11 +141,16 @@ func ExecutePipeline(
 		WebhookData:           opts.WebhookData,
 		ResponseChan:          opts.ResponseChan,
 		SecretsManager:        opts.SecretsManager,
+		SecretValues:          opts.SecretValues,
 		DisableNotifications:  opts.DisableNotifications,
 		DisableFetch:          opts.DisableFetch,
 		FetchTimeout:          opts.FetchTimeout,
 		FetchMaxResponseBytes: opts.FetchMaxResponseBytes,
 		Args:                  opts.Args,
+		TriggeredBy:           opts.TriggeredBy,
+		Jobs:                  opts.Jobs,
+		Approvals:             opts.Approvals,
+		AgentCosts:            opts.AgentCosts,
 	}
 
 	// If pre-seeded volumes were provided, pass them through.
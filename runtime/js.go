@@ -14,6 +14,7 @@ import (
 	"github.com/dop251/goja_nodejs/require"
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/runtime/jsapi"
 	"github.com/jtarchie/pocketci/runtime/runner"
 	"github.com/jtarchie/pocketci/secrets"
@@ -40,6 +41,9 @@ type ExecuteOptions struct {
 	// SecretsManager provides access to encrypted secrets for this pipeline.
 	// If nil, secret resolution is disabled.
 	SecretsManager secrets.Manager
+	// SecretValues are redacted from task output, such as secrets that were
+	// interpolated into a YAML pipeline's ((vars)).
+	SecretValues []string
 	// DisableNotifications prevents the notify system from sending messages.
 	DisableNotifications bool
 	// DisableFetch prevents the fetch() function from making outbound HTTP requests.
@@ -50,6 +54,11 @@ type ExecuteOptions struct {
 	FetchMaxResponseBytes int64
 	// Args contains CLI arguments passed to the pipeline via pipelineContext.args.
 	Args []string
+	// TriggeredBy overrides pipelineContext.triggeredBy.
+	// Defaults to "webhook" when WebhookData is set, otherwise "manual".
+	TriggeredBy string
+	// Jobs restricts a YAML pipeline run to the named jobs and their dependents.
+	Jobs []string
 	// PreseededVolumes maps volume names to pre-created, already-seeded volumes.
 	// When the pipeline calls runtime.createVolume(name), a matching pre-created
 	// volume is reused instead of allocating a new one.
@@ -57,6 +66,12 @@ type ExecuteOptions struct {
 	// OutputCallback, if set, is applied to every container task so that
 	// stdout/stderr chunks are forwarded to the caller in real time.
 	OutputCallback runner.OutputCallback
+	// Approvals receives agent tool calls that require human approval.
+	// If nil, such tool calls are denied.
+	Approvals *agent.Approvals
+	// AgentCosts checks the pipeline's budget before each agent starts and
+	// records what agents spend.
+	AgentCosts agent.CostTracker
 }
 
 type JS struct {
@@ -69,6 +84,18 @@ func NewJS(logger *slog.Logger) *JS {
 	}
 }
 
+// SyntaxError is a transpilation error with its 1-based position in the
+// pipeline source. Line and Column are zero when esbuild reports no location.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return "syntax error: " + e.Message
+}
+
 // TranspileAndValidate transpiles TypeScript/JavaScript source code to executable JavaScript.
 // It performs esbuild transpilation, wraps the code for module exports, and validates
 // the result can be compiled by goja. Returns the ready-to-execute code or an error.
@@ -83,7 +110,13 @@ func TranspileAndValidate(source string) (string, error) {
 	})
 
 	if len(result.Errors) > 0 {
-		return "", fmt.Errorf("syntax error: %s", result.Errors[0].Text)
+		syntaxErr := &SyntaxError{Message: result.Errors[0].Text}
+		if location := result.Errors[0].Location; location != nil {
+			syntaxErr.Line = location.Line
+			syntaxErr.Column = location.Column + 1
+		}
+
+		return "", syntaxErr
 	}
 
 	lines := strings.Split(strings.TrimSpace(string(result.Code)), "\n")
@@ -127,6 +160,8 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 			resumableRunner.SetSecretsManager(opts.SecretsManager, opts.PipelineID)
 		}
 
+		resumableRunner.AddSecretValues(opts.SecretValues...)
+
 		if opts.PreseededVolumes != nil {
 			resumableRunner.SetPreseededVolumes(opts.PreseededVolumes)
 		}
@@ -142,6 +177,8 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 			pipelineRunner.SetSecretsManager(opts.SecretsManager, opts.PipelineID)
 		}
 
+		pipelineRunner.AddSecretValues(opts.SecretValues...)
+
 		if opts.PreseededVolumes != nil {
 			pipelineRunner.SetPreseededVolumes(opts.PreseededVolumes)
 		}
@@ -199,6 +236,8 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 	runtime := NewRuntime(jsVM, r, opts.Namespace, opts.RunID)
 	runtime.secretsManager = opts.SecretsManager
 	runtime.pipelineID = opts.PipelineID
+	runtime.approvals = opts.Approvals
+	runtime.agentCosts = opts.AgentCosts
 	runtime.ctx = ctx
 	runtime.storage = storage
 
@@ -226,6 +265,8 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 		resourceRunner.SetSecretsManager(opts.SecretsManager, opts.PipelineID)
 	}
 
+	resourceRunner.SetStorage(storage, opts.PipelineID)
+
 	err = jsVM.Set("nativeResources", resourceRunner)
 	if err != nil {
 		return fmt.Errorf("could not set nativeResources: %w", err)
@@ -338,6 +379,9 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 	if opts.WebhookData != nil {
 		triggeredBy = "webhook"
 	}
+	if opts.TriggeredBy != "" {
+		triggeredBy = opts.TriggeredBy
+	}
 	runtime.triggeredBy = triggeredBy
 
 	args := opts.Args
@@ -345,11 +389,17 @@ func (j *JS) ExecuteWithOptions(ctx context.Context, source string, driver orche
 		args = []string{}
 	}
 
+	jobs := opts.Jobs
+	if jobs == nil {
+		jobs = []string{}
+	}
+
 	pipelineContext := map[string]any{
 		"runID":       opts.RunID,
 		"pipelineID":  opts.PipelineID,
 		"triggeredBy": triggeredBy,
 		"args":        args,
+		"jobs":        jobs,
 	}
 	if driver != nil {
 		pipelineContext["driverName"] = driver.Name()
@@ -2,12 +2,16 @@ package runtime_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"testing"
 	"time"
 
 	_ "github.com/jtarchie/pocketci/orchestra/docker"
-	_ "github.com/jtarchie/pocketci/orchestra/native"
+	"github.com/jtarchie/pocketci/orchestra/native"
 	"github.com/jtarchie/pocketci/runtime/runner"
+	storagelib "github.com/jtarchie/pocketci/storage"
 	storage "github.com/jtarchie/pocketci/storage/sqlite"
 	. "github.com/onsi/gomega"
 )
@@ -224,3 +228,65 @@ func TestResumableRunnerCreation(t *testing.T) {
 	_ = store
 	_ = assert
 }
+
+func TestResumableRunnerAgentCheckpoint(t *testing.T) {
+	t.Parallel()
+	assert := NewGomegaWithT(t)
+
+	store, err := storage.NewSqlite("sqlite://:memory:", "test-ns", nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = store.Close() }()
+
+	driver, err := native.NewNative("test-ns", slog.Default(), nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = driver.Close() }()
+
+	const key = "_resume/agent/agent-run/0-reviewer"
+
+	runAgent := func(resume bool, result json.RawMessage, agentErr error) (*runner.AgentCheckpoint, error) {
+		r, err := runner.NewResumableRunner(context.Background(), driver, store, slog.Default(), "test-ns", runner.ResumeOptions{
+			RunID:  "agent-run",
+			Resume: resume,
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		var checkpoint *runner.AgentCheckpoint
+
+		r.SetAgentFunc(func(configJSON json.RawMessage) (json.RawMessage, error) {
+			var config struct {
+				Checkpoint *runner.AgentCheckpoint `json:"checkpoint"`
+			}
+			assert.Expect(json.Unmarshal(configJSON, &config)).To(Succeed())
+
+			checkpoint = config.Checkpoint
+
+			return result, agentErr
+		})
+
+		_, err = r.RunAgent(json.RawMessage(`{"name":"reviewer"}`))
+
+		return checkpoint, err
+	}
+
+	// The first run is interrupted mid-conversation.
+	checkpoint, err := runAgent(false, nil, fmt.Errorf("agent: run interrupted: %w", context.Canceled))
+	assert.Expect(err).To(HaveOccurred())
+	assert.Expect(checkpoint).To(Equal(&runner.AgentCheckpoint{Key: key}))
+
+	err = store.Set(context.Background(), key, map[string]any{"turns": 3})
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	// Resuming continues from the checkpoint, which is removed once the
+	// agent completes.
+	checkpoint, err = runAgent(true, json.RawMessage(`{"status":"success"}`), nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	assert.Expect(checkpoint).To(Equal(&runner.AgentCheckpoint{Key: key, Resume: true}))
+
+	_, err = store.Get(context.Background(), key)
+	assert.Expect(err).To(MatchError(storagelib.ErrNotFound))
+
+	// The completed agent is not run again.
+	checkpoint, err = runAgent(true, nil, nil)
+	assert.Expect(err).NotTo(HaveOccurred())
+	assert.Expect(checkpoint).To(BeNil())
+}
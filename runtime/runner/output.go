@@ -0,0 +1,57 @@
+package runner
+
+import "unicode/utf8"
+
+// maxOutputSize bounds how much of each of a task's stdout and stderr is kept
+// in memory for its RunResult. The complete output is in the task's log.
+const maxOutputSize = 1024 * 1024
+
+// outputTail keeps the last max bytes written to it, so that a task's output
+// takes the same memory however much of it there is.
+type outputTail struct {
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func newOutputTail() *outputTail {
+	return &outputTail{max: maxOutputSize}
+}
+
+func (o *outputTail) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if len(p) >= o.max {
+		o.buf = append(o.buf[:0], p[len(p)-o.max:]...)
+		o.truncated = true
+
+		return n, nil
+	}
+
+	if over := len(o.buf) + len(p) - o.max; over > 0 {
+		o.buf = append(o.buf[:0], o.buf[over:]...)
+		o.truncated = true
+	}
+
+	o.buf = append(o.buf, p...)
+
+	return n, nil
+}
+
+func (o *outputTail) WriteString(s string) (int, error) {
+	return o.Write([]byte(s))
+}
+
+// String returns the kept output. When earlier output was dropped, it starts
+// at the first whole character.
+func (o *outputTail) String() string {
+	buf := o.buf
+
+	if o.truncated {
+		for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.RuneStart(buf[0]); i++ {
+			buf = buf[1:]
+		}
+	}
+
+	return string(buf)
+}
@@ -93,6 +93,12 @@ func (c *PipelineRunner) SetSecretsManager(mgr secrets.Manager, pipelineID strin
 	c.pipelineID = pipelineID
 }
 
+// AddSecretValues tracks values to redact from task output, such as secrets
+// that were interpolated into a YAML pipeline's ((vars)).
+func (c *PipelineRunner) AddSecretValues(values ...string) {
+	c.secretValues = append(c.secretValues, values...)
+}
+
 // loadSecrets loads all secrets for this pipeline from the secrets manager
 // and returns them as a map of key->value. It checks pipeline scope first,
 // then falls back to global scope.
@@ -183,6 +189,8 @@ func (c *PipelineRunner) CreateVolume(input VolumeInput) (*VolumeResult, error)
 	}, nil
 }
 
+// RunResult is the outcome of a task. Stdout and Stderr hold at most the last
+// MiB of each stream; the task's log holds all of it.
 type RunResult struct {
 	Code   int    `json:"code"`
 	Stderr string `json:"stderr"`
@@ -191,11 +199,6 @@ type RunResult struct {
 	Status RunStatus `json:"status"`
 }
 
-type TaskLogEntry struct {
-	Type    string `json:"type"`
-	Content string `json:"content"`
-}
-
 // OutputCallback is called with streaming output chunks.
 // stream is either "stdout" or "stderr", data is the output chunk.
 type OutputCallback func(stream string, data string)
@@ -290,13 +293,11 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 		if len(secretKeys) > 0 {
 			secretMap, err := c.loadSecrets(ctx, secretKeys)
 			if err != nil {
-				c.setTaskStatus(effectiveStorageKey, map[string]any{
-					"status": "error",
-					"logs": []TaskLogEntry{{
-						Type:    "stderr",
-						Content: err.Error(),
-					}},
-				})
+				taskLog := c.newTaskLog(effectiveStorageKey)
+				taskLog.Write("stderr", err.Error())
+				taskLog.Close()
+
+				c.setTaskStatus(effectiveStorageKey, map[string]any{"status": "error"})
 
 				return nil, fmt.Errorf("failed to load secrets for task %q: %w", input.Name, err)
 			}
@@ -316,9 +317,16 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 		}
 	}
 
-	// Apply global output callback if no per-task callback is set.
-	if input.OnOutput == nil && c.outputCallback != nil {
-		input.OnOutput = c.outputCallback
+	// Apply the global output callback alongside any per-task callback.
+	if c.outputCallback != nil {
+		if taskCallback := input.OnOutput; taskCallback != nil {
+			input.OnOutput = func(stream, data string) {
+				taskCallback(stream, data)
+				c.outputCallback(stream, data)
+			}
+		} else {
+			input.OnOutput = c.outputCallback
+		}
 	}
 
 	logger.Info("container.run.start", "image", input.Image, "command", append([]string{input.Command.Path}, input.Command.Args...))
@@ -386,21 +394,14 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 	})
 
 	var containerStatus orchestra.ContainerStatus
-	logs := make([]TaskLogEntry, 0, 32)
-	var logsMu sync.Mutex
-
-	appendLog := func(stream, data string) {
-		if data == "" {
-			return
-		}
 
-		logsMu.Lock()
-		logs = append(logs, TaskLogEntry{Type: stream, Content: data})
-		logsMu.Unlock()
-	}
+	// Output is appended to storage as it streams rather than kept in the
+	// task's payload, so that large logs are not rewritten on every update.
+	taskLog := c.newTaskLog(storageKey)
+	defer taskLog.Close()
 
 	streamCallback := OutputCallback(func(stream, data string) {
-		appendLog(stream, data)
+		taskLog.Write(stream, data)
 
 		if input.OnOutput != nil {
 			input.OnOutput(stream, data)
@@ -411,12 +412,14 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 	streamCtx, cancelStream := context.WithCancel(ctx)
 	defer cancelStream()
 
-	stdout, stderr := &strings.Builder{}, &strings.Builder{}
+	// Only the tail of the output is kept in memory; the log has all of it.
+	stdout, stderr := newOutputTail(), newOutputTail()
 	var streamWg sync.WaitGroup
 
-	// Start streaming logs if callback is provided
-	if input.OnOutput != nil {
-
+	// Stream the output when it is logged or a callback is provided, rather
+	// than reading it once the container exits.
+	streaming := input.OnOutput != nil || taskLog.Enabled()
+	if streaming {
 		streamWg.Go(func() {
 			c.streamLogsWithCallback(streamCtx, container, streamCallback, stdout, stderr)
 		})
@@ -429,6 +432,7 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 		containerStatus, err = container.Status(ctx)
 		if err != nil {
 			cancelStream()
+			streamWg.Wait()
 
 			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 				c.setTaskStatus(storageKey, map[string]any{
@@ -475,7 +479,7 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 	}()
 
 	// Get final logs (if we weren't streaming, or to ensure we have complete output)
-	if input.OnOutput == nil {
+	if !streaming {
 		err = container.Logs(ctx, stdout, stderr, false)
 		if err != nil {
 			logger.Error("container.logs.error", "err", err)
@@ -500,27 +504,21 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 		}
 	}
 
-	// Redact secret values from output before storing or returning
 	stdoutStr := stdout.String()
 	stderrStr := stderr.String()
 
-	if len(c.secretValues) > 0 {
-		stdoutStr = support.RedactSecrets(stdoutStr, c.secretValues)
-		stderrStr = support.RedactSecrets(stderrStr, c.secretValues)
-
-		for idx := range logs {
-			logs[idx].Content = support.RedactSecrets(logs[idx].Content, c.secretValues)
-		}
+	if !streaming {
+		taskLog.Write("stdout", stdoutStr)
+		taskLog.Write("stderr", stderrStr)
 	}
 
-	if len(logs) == 0 {
-		if stdoutStr != "" {
-			logs = append(logs, TaskLogEntry{Type: "stdout", Content: stdoutStr})
-		}
+	// The log is complete before the final status is stored.
+	taskLog.Close()
 
-		if stderrStr != "" {
-			logs = append(logs, TaskLogEntry{Type: "stderr", Content: stderrStr})
-		}
+	// Redact secret values from output before returning
+	if len(c.secretValues) > 0 {
+		stdoutStr = support.RedactSecrets(stdoutStr, c.secretValues)
+		stderrStr = support.RedactSecrets(stderrStr, c.secretValues)
 	}
 
 	status := "success"
@@ -534,7 +532,6 @@ func (c *PipelineRunner) Run(input RunInput) (*RunResult, error) {
 	c.setTaskStatus(storageKey, map[string]any{
 		"status":     status,
 		"code":       containerStatus.ExitCode(),
-		"logs":       logs,
 		"started_at": taskStartedAt.UTC().Format(time.RFC3339),
 		"elapsed":    formatElapsed(time.Since(taskStartedAt)),
 	})
@@ -552,7 +549,7 @@ func (c *PipelineRunner) streamLogsWithCallback(
 	ctx context.Context,
 	container orchestra.Container,
 	callback OutputCallback,
-	stdout, stderr *strings.Builder,
+	stdout, stderr *outputTail,
 ) {
 	logger := c.logger
 
@@ -585,13 +582,13 @@ func (c *PipelineRunner) streamLogsWithCallback(
 	wg.Wait()
 }
 
-// readStreamChunks reads from r in 4 KiB chunks, appends to builder,
+// readStreamChunks reads from r in 4 KiB chunks, appends to tail,
 // and invokes callback for the given stream name.
 func (c *PipelineRunner) readStreamChunks(
 	ctx context.Context,
 	r io.Reader,
 	stream string,
-	builder *strings.Builder,
+	tail *outputTail,
 	callback OutputCallback,
 	logger *slog.Logger,
 ) {
@@ -601,11 +598,11 @@ func (c *PipelineRunner) readStreamChunks(
 		n, err := r.Read(buf)
 		if n > 0 {
 			chunk := string(buf[:n])
-			builder.WriteString(chunk)
+			_, _ = tail.WriteString(chunk)
 
-			if ctx.Err() == nil {
-				callback(stream, chunk)
-			}
+			// The stream is only cancelled once the container is done, so
+			// output that drivers flush on cancellation is still delivered.
+			callback(stream, chunk)
 		}
 
 		if err != nil {
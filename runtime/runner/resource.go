@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 
 	"github.com/jtarchie/pocketci/resources"
 	"github.com/jtarchie/pocketci/runtime/support"
 	"github.com/jtarchie/pocketci/secrets"
+	"github.com/jtarchie/pocketci/storage"
 )
 
 // ResourceRunner provides methods for executing native resources.
@@ -34,6 +37,12 @@ func (r *ResourceRunner) SetSecretsManager(mgr secrets.Manager, pipelineID strin
 	r.pipelineID = pipelineID
 }
 
+// SetStorage makes the pipeline's storage driver available to native
+// resources that keep state in it, such as semver.
+func (r *ResourceRunner) SetStorage(driver storage.Driver, pipelineID string) {
+	r.ctx = resources.WithStorage(r.ctx, driver, pipelineID)
+}
+
 // ResourceCheckInput is the input for a Check operation from JS.
 type ResourceCheckInput struct {
 	Type    string            `json:"type"`
@@ -144,11 +153,14 @@ func (r *ResourceRunner) Fetch(input ResourceFetchInput) (*ResourceFetchResult,
 }
 
 // ResourcePushInput is the input for a Push operation from JS.
+// When SrcDir is empty, Mounts maps names to volume paths that are linked
+// into a temporary directory used as SrcDir.
 type ResourcePushInput struct {
-	Type   string         `json:"type"`
-	Source map[string]any `json:"source"`
-	Params map[string]any `json:"params,omitempty"`
-	SrcDir string         `json:"srcDir"`
+	Type   string            `json:"type"`
+	Source map[string]any    `json:"source"`
+	Params map[string]any    `json:"params,omitempty"`
+	SrcDir string            `json:"srcDir"`
+	Mounts map[string]string `json:"mounts,omitempty"`
 }
 
 // ResourcePushResult is the result of a Push operation.
@@ -178,12 +190,21 @@ func (r *ResourceRunner) Push(input ResourcePushInput) (*ResourcePushResult, err
 		return nil, fmt.Errorf("resource type not found: %w", err)
 	}
 
+	srcDir := input.SrcDir
+	if srcDir == "" {
+		srcDir, err = linkMounts(input.Mounts)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.RemoveAll(srcDir) }()
+	}
+
 	req := resources.OutRequest{
 		Source: input.Source,
 		Params: input.Params,
 	}
 
-	resp, err := res.Out(r.ctx, input.SrcDir, req)
+	resp, err := res.Out(r.ctx, srcDir, req)
 	if err != nil {
 		logger.Error("resource.push.failed", "err", err)
 
@@ -204,6 +225,30 @@ func (r *ResourceRunner) Push(input ResourcePushInput) (*ResourcePushResult, err
 	return result, nil
 }
 
+// linkMounts creates a temporary directory with a symlink to each mount path,
+// named after the mount, so a resource sees them like a put step's inputs.
+func linkMounts(mounts map[string]string) (string, error) {
+	dir, err := os.MkdirTemp("", "resource-push")
+	if err != nil {
+		return "", fmt.Errorf("could not create push directory: %w", err)
+	}
+
+	for name, path := range mounts {
+		if name == "" || name != filepath.Base(name) {
+			continue
+		}
+
+		err = os.Symlink(path, filepath.Join(dir, name))
+		if err != nil {
+			_ = os.RemoveAll(dir)
+
+			return "", fmt.Errorf("could not link mount %q: %w", name, err)
+		}
+	}
+
+	return dir, nil
+}
+
 // IsNative returns true if the given resource type is a native resource.
 func (r *ResourceRunner) IsNative(resourceType string) bool {
 	return resources.IsNative(resourceType)
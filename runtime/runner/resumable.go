@@ -87,11 +87,30 @@ func NewResumableRunner(
 
 const stateStoragePrefix = "_resume/state"
 
+// agentCheckpointPrefix is where agents save their conversation while they
+// run, keyed by run and step ID.
+const agentCheckpointPrefix = "_resume/agent"
+
+// AgentCheckpoint tells an agent where to save its conversation after each
+// completed turn, so that an interrupted run can continue from there rather
+// than starting over.
+type AgentCheckpoint struct {
+	// Key is the storage path of the checkpoint.
+	Key string `json:"key"`
+	// Resume restores the conversation saved under Key, when there is one.
+	Resume bool `json:"resume,omitempty"`
+}
+
 // SetSecretsManager configures the underlying pipeline runner to load secrets.
 func (r *ResumableRunner) SetSecretsManager(mgr secrets.Manager, pipelineID string) {
 	r.runner.SetSecretsManager(mgr, pipelineID)
 }
 
+// AddSecretValues tracks values to redact from the underlying runner's task output.
+func (r *ResumableRunner) AddSecretValues(values ...string) {
+	r.runner.AddSecretValues(values...)
+}
+
 // loadState loads pipeline state from storage.
 func (r *ResumableRunner) loadState(runID string) (*PipelineState, error) {
 	payload, err := r.storage.Get(r.ctx, stateStoragePrefix+"/"+runID)
@@ -460,7 +479,9 @@ func (r *ResumableRunner) SetAgentFunc(fn AgentFunc) {
 }
 
 // RunAgent executes an LLM agent step with resume support.
-// Completed agents are skipped; failed/aborted agents are retried from scratch.
+// Completed agents are skipped and failed agents are retried from scratch.
+// Agents that were interrupted while running continue their conversation
+// from the last checkpoint they saved.
 func (r *ResumableRunner) RunAgent(configJSON json.RawMessage) (json.RawMessage, error) {
 	// Extract name from config for step ID generation
 	var meta struct {
@@ -477,6 +498,7 @@ func (r *ResumableRunner) RunAgent(configJSON json.RawMessage) (json.RawMessage,
 
 	stepID := r.findOrGenerateStepID(name)
 	existingStep := r.state.GetStep(stepID)
+	checkpoint := AgentCheckpoint{Key: agentCheckpointPrefix + "/" + r.state.RunID + "/" + stepID}
 
 	if existingStep != nil {
 		// Completed agent — return cached result
@@ -485,26 +507,31 @@ func (r *ResumableRunner) RunAgent(configJSON json.RawMessage) (json.RawMessage,
 			return existingStep.AgentResultJSON, nil
 		}
 
-		// Failed/aborted agent — retry from scratch
-		if existingStep.ShouldRetry() {
+		switch existingStep.Status {
+		case StepStatusRunning, StepStatusAborted:
+			// Interrupted agent (crash or shutdown mid-conversation) —
+			// continue from its checkpoint
+			r.logger.Info("resume.agent_interrupted", "stepID", stepID, "name", name, "previousStatus", existingStep.Status)
+			checkpoint.Resume = true
+		case StepStatusFailed:
+			// Failed agent — retry from scratch
 			r.logger.Info("resume.retry_agent", "stepID", stepID, "name", name, "previousStatus", existingStep.Status)
 			existingStep.MarkForRetry()
 			if err := r.saveState(); err != nil {
 				r.logger.Error("resume.save_state_failed.agent_retry", "stepID", stepID, "err", err)
 			}
-		}
 
-		// Running agent (interrupted mid-conversation) — treat as needing re-run
-		if existingStep.Status == StepStatusRunning {
-			r.logger.Info("resume.agent_was_running", "stepID", stepID, "name", name)
-			existingStep.MarkForRetry()
-			if err := r.saveState(); err != nil {
-				r.logger.Error("resume.save_state_failed.agent_running", "stepID", stepID, "err", err)
+			if err := r.storage.Delete(r.ctx, checkpoint.Key); err != nil {
+				r.logger.Error("resume.delete_checkpoint_failed", "stepID", stepID, "err", err)
 			}
 		}
 	}
 
-	// Execute agent fresh
+	configJSON, err := withAgentCheckpoint(configJSON, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	step := &StepState{
 		StepID:    stepID,
@@ -522,6 +549,10 @@ func (r *ResumableRunner) RunAgent(configJSON json.RawMessage) (json.RawMessage,
 	resultJSON, err := r.runner.RunAgent(configJSON)
 	if err != nil {
 		step.Status = StepStatusFailed
+		if errors.Is(err, context.Canceled) || r.ctx.Err() != nil {
+			step.Status = StepStatusAborted
+		}
+
 		step.Error = err.Error()
 		_ = r.saveState()
 
@@ -538,9 +569,37 @@ func (r *ResumableRunner) RunAgent(configJSON json.RawMessage) (json.RawMessage,
 		r.logger.Error("resume.save_state_failed.agent_completed", "stepID", stepID, "err", err)
 	}
 
+	// The result is cached in the step state, so the conversation is no
+	// longer needed.
+	if err := r.storage.Delete(r.ctx, checkpoint.Key); err != nil {
+		r.logger.Error("resume.delete_checkpoint_failed", "stepID", stepID, "err", err)
+	}
+
 	return resultJSON, nil
 }
 
+// withAgentCheckpoint adds checkpoint to an agent's config.
+func withAgentCheckpoint(configJSON json.RawMessage, checkpoint AgentCheckpoint) (json.RawMessage, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("could not parse agent config: %w", err)
+	}
+
+	encoded, err := json.Marshal(checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal agent checkpoint: %w", err)
+	}
+
+	config["checkpoint"] = encoded
+
+	configJSON, err = json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal agent config: %w", err)
+	}
+
+	return configJSON, nil
+}
+
 // MarkInProgressAsAborted marks all currently-running steps as aborted.
 // Called during context cancellation cleanup to ensure state is consistent.
 func (r *ResumableRunner) MarkInProgressAsAborted() {
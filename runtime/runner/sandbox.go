@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"strings"
 	"time"
 
@@ -21,6 +22,9 @@ type SandboxInput struct {
 	Mounts     map[string]VolumeResult `json:"mounts"`
 	WorkDir    string                  `json:"work_dir"`
 	Privileged bool                    `json:"privileged"`
+	// ReattachID is the ID of a sandbox from an interrupted run to reuse, if
+	// the driver can still reach it. A new sandbox is started otherwise.
+	ReattachID string `json:"reattach_id,omitempty"`
 }
 
 // ExecInput describes a single command to run inside a sandbox.
@@ -38,8 +42,8 @@ type ExecInput struct {
 	OnOutput OutputCallback `json:"-"`
 }
 
-// sandboxStreamWriter writes to a strings.Builder and optionally invokes
-// an OutputCallback for each chunk. Satisfies io.Writer.
+// sandboxStreamWriter writes to a strings.Builder, when set, and optionally
+// invokes an OutputCallback for each chunk. Satisfies io.Writer.
 type sandboxStreamWriter struct {
 	stream   string
 	buf      *strings.Builder
@@ -47,7 +51,11 @@ type sandboxStreamWriter struct {
 }
 
 func (w *sandboxStreamWriter) Write(p []byte) (n int, err error) {
-	n, err = w.buf.Write(p)
+	n = len(p)
+	if w.buf != nil {
+		n, err = w.buf.Write(p)
+	}
+
 	if err != nil || w.callback == nil || n == 0 {
 		return
 	}
@@ -60,9 +68,13 @@ func (w *sandboxStreamWriter) Write(p []byte) (n int, err error) {
 // SandboxHandle manages a long-lived sandbox container that accepts sequential
 // exec calls. Obtain one via Runner.StartSandbox.
 type SandboxHandle struct {
-	sandbox orchestra.Sandbox
-	runner  *PipelineRunner
-	logger  *slog.Logger
+	sandbox    orchestra.Sandbox
+	runner     *PipelineRunner
+	logger     *slog.Logger
+	reattached bool
+	// env is the sandbox's environment, applied to each exec call of a
+	// reattached sandbox, since drivers need not keep it.
+	env map[string]string
 }
 
 // ID returns the driver-specific sandbox container identifier.
@@ -70,6 +82,12 @@ func (h *SandboxHandle) ID() string {
 	return h.sandbox.ID()
 }
 
+// Reattached reports whether the handle reuses the sandbox named by
+// SandboxInput.ReattachID rather than a newly started one.
+func (h *SandboxHandle) Reattached() bool {
+	return h.reattached
+}
+
 // Exec runs a single command inside the sandbox.
 // env and workDir apply only to this invocation; they do not persist.
 func (h *SandboxHandle) Exec(input ExecInput) (*RunResult, error) {
@@ -89,41 +107,9 @@ func (h *SandboxHandle) Exec(input ExecInput) (*RunResult, error) {
 		}
 	}
 
-	// Resolve secret references in env (matching PipelineRunner.Run behaviour).
-	env := input.Env
-	if h.runner.secretsManager != nil && len(env) > 0 {
-		var secretKeys []string
-
-		for _, val := range env {
-			if strings.HasPrefix(val, "secret:") {
-				secretKeys = append(secretKeys, strings.TrimPrefix(val, "secret:"))
-			}
-		}
-
-		if len(secretKeys) > 0 {
-			secretMap, err := h.runner.loadSecrets(ctx, secretKeys)
-			if err != nil {
-				return nil, fmt.Errorf("sandbox exec: failed to load secrets: %w", err)
-			}
-
-			resolved := make(map[string]string, len(env))
-
-			for k, v := range env {
-				if strings.HasPrefix(v, "secret:") {
-					secretKey := strings.TrimPrefix(v, "secret:")
-					if secretVal, ok := secretMap[secretKey]; ok {
-						resolved[k] = secretVal
-						h.runner.secretValues = append(h.runner.secretValues, secretVal)
-
-						continue
-					}
-				}
-
-				resolved[k] = v
-			}
-
-			env = resolved
-		}
+	env, err := h.resolveEnv(ctx, input.Env)
+	if err != nil {
+		return nil, err
 	}
 
 	cmd := []string{input.Command.Path}
@@ -165,6 +151,78 @@ func (h *SandboxHandle) Exec(input ExecInput) (*RunResult, error) {
 	}, nil
 }
 
+// Attach runs a long-lived command inside the sandbox with its stdin and
+// stdout connected to the given streams, such as a stdio MCP server. Stderr
+// is streamed to input.OnOutput. It blocks until the command exits or ctx is
+// cancelled.
+func (h *SandboxHandle) Attach(ctx context.Context, input ExecInput, stdin io.Reader, stdout io.Writer) (int, error) {
+	env, err := h.resolveEnv(ctx, input.Env)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := []string{input.Command.Path}
+	cmd = append(cmd, input.Command.Args...)
+
+	stderrWriter := &sandboxStreamWriter{stream: "stderr", callback: input.OnOutput}
+
+	status, err := h.sandbox.Exec(ctx, cmd, env, input.WorkDir, stdin, stdout, stderrWriter)
+	if err != nil {
+		return 0, fmt.Errorf("sandbox attach: %w", err)
+	}
+
+	return status.ExitCode(), nil
+}
+
+// resolveEnv resolves secret references in env (matching PipelineRunner.Run
+// behaviour), after applying env over the sandbox's environment.
+func (h *SandboxHandle) resolveEnv(ctx context.Context, env map[string]string) (map[string]string, error) {
+	if len(h.env) > 0 {
+		merged := maps.Clone(h.env)
+		maps.Copy(merged, env)
+		env = merged
+	}
+
+	if h.runner.secretsManager == nil || len(env) == 0 {
+		return env, nil
+	}
+
+	var secretKeys []string
+
+	for _, val := range env {
+		if strings.HasPrefix(val, "secret:") {
+			secretKeys = append(secretKeys, strings.TrimPrefix(val, "secret:"))
+		}
+	}
+
+	if len(secretKeys) == 0 {
+		return env, nil
+	}
+
+	secretMap, err := h.runner.loadSecrets(ctx, secretKeys)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox exec: failed to load secrets: %w", err)
+	}
+
+	resolved := make(map[string]string, len(env))
+
+	for k, v := range env {
+		if strings.HasPrefix(v, "secret:") {
+			secretKey := strings.TrimPrefix(v, "secret:")
+			if secretVal, ok := secretMap[secretKey]; ok {
+				resolved[k] = secretVal
+				h.runner.secretValues = append(h.runner.secretValues, secretVal)
+
+				continue
+			}
+		}
+
+		resolved[k] = v
+	}
+
+	return resolved, nil
+}
+
 // Close shuts down the sandbox container.
 func (h *SandboxHandle) Close() error {
 	return h.sandbox.Cleanup(h.runner.ctx)
@@ -202,6 +260,23 @@ func (c *PipelineRunner) StartSandbox(input SandboxInput) (*SandboxHandle, error
 		WorkDir:    input.WorkDir,
 	}
 
+	if input.ReattachID != "" {
+		container, err := c.client.GetContainer(c.ctx, input.ReattachID)
+		if sandbox, ok := container.(orchestra.Sandbox); ok && err == nil {
+			c.logger.Info("sandbox.reattached", "sandboxID", input.ReattachID)
+
+			return &SandboxHandle{
+				sandbox:    sandbox,
+				runner:     c,
+				logger:     c.logger,
+				reattached: true,
+				env:        input.Env,
+			}, nil
+		}
+
+		c.logger.Warn("sandbox.reattach_failed", "sandboxID", input.ReattachID, "err", err)
+	}
+
 	sandbox, err := sandboxDriver.StartSandbox(c.ctx, task)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start sandbox: %w", err)
@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jtarchie/pocketci/runtime/support"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// taskLogFlushSize is how much output a task buffers before appending it to
+// storage. It also bounds the size of a single log chunk.
+const taskLogFlushSize = 64 * 1024
+
+// taskLogFlushInterval bounds how long output waits in the buffer, so that
+// slow output still reaches the terminal view promptly.
+const taskLogFlushInterval = time.Second
+
+// taskLog appends a task's output to storage as log chunks while it streams.
+// Consecutive output of the same stream is coalesced into one chunk, and only
+// output not yet flushed is held in memory, however long the log grows.
+type taskLog struct {
+	ctx          context.Context //nolint: containedctx
+	storage      storage.Driver
+	key          string
+	secretValues []string
+	logger       *slog.Logger
+
+	mu      sync.Mutex
+	pending []storage.LogChunk
+	size    int
+	timer   *time.Timer
+	closed  bool
+}
+
+// newTaskLog returns the log of the task stored at key. It discards output
+// when there is no key or storage, as when running without a run ID.
+func (c *PipelineRunner) newTaskLog(key string) *taskLog {
+	return &taskLog{
+		ctx:          c.ctx,
+		storage:      c.storage,
+		key:          key,
+		secretValues: append([]string(nil), c.secretValues...),
+		logger:       c.logger,
+	}
+}
+
+// Enabled reports whether output written to the log is stored.
+func (l *taskLog) Enabled() bool {
+	return l.key != "" && l.storage != nil
+}
+
+// Write buffers data as output of stream, flushing once the buffer is full.
+func (l *taskLog) Write(stream, data string) {
+	if data == "" || !l.Enabled() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	for data != "" {
+		n := min(len(data), taskLogFlushSize-l.size)
+
+		last := len(l.pending) - 1
+		if last >= 0 && l.pending[last].Stream == stream {
+			l.pending[last].Content += data[:n]
+		} else {
+			l.pending = append(l.pending, storage.LogChunk{
+				Stream:    stream,
+				Content:   data[:n],
+				CreatedAt: time.Now().UTC(),
+			})
+		}
+
+		l.size += n
+		data = data[n:]
+
+		if l.size >= taskLogFlushSize {
+			l.flushLocked(false)
+		}
+	}
+
+	if l.size > 0 && l.timer == nil {
+		l.timer = time.AfterFunc(taskLogFlushInterval, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.flushLocked(false)
+		})
+	}
+}
+
+// Close flushes the remaining output and tells storage the log is complete.
+// Output written after Close is dropped.
+func (l *taskLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	l.flushLocked(true)
+	l.closed = true
+
+	if !l.Enabled() {
+		return
+	}
+
+	err := l.storage.CloseLogs(l.ctx, l.key)
+	if err != nil {
+		l.logger.Error("task.logs.close.error", "key", l.key, "err", err)
+	}
+}
+
+// flushLocked appends the buffered output to storage. Unless final, a
+// multi-byte character split at the end of the buffer is held back until the
+// rest of it is written. Errors are logged but not propagated, like
+// setTaskStatus.
+func (l *taskLog) flushLocked(final bool) {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	if len(l.pending) == 0 {
+		return
+	}
+
+	chunks := l.pending
+	l.pending = nil
+	l.size = 0
+
+	if !final {
+		last := &chunks[len(chunks)-1]
+
+		var rest string
+
+		last.Content, rest = splitIncompleteRune(last.Content)
+		if rest != "" {
+			l.pending = []storage.LogChunk{{Stream: last.Stream, Content: rest, CreatedAt: last.CreatedAt}}
+			l.size = len(rest)
+		}
+	}
+
+	stored := chunks[:0]
+
+	for _, chunk := range chunks {
+		if chunk.Content == "" {
+			continue
+		}
+
+		chunk.Content = strings.ToValidUTF8(chunk.Content, "\uFFFD")
+		if len(l.secretValues) > 0 {
+			chunk.Content = support.RedactSecrets(chunk.Content, l.secretValues)
+		}
+
+		stored = append(stored, chunk)
+	}
+
+	if len(stored) == 0 {
+		return
+	}
+
+	err := l.storage.AppendLogs(l.ctx, l.key, stored)
+	if err != nil {
+		l.logger.Error("task.logs.persist.error", "key", l.key, "err", err)
+	}
+}
+
+// splitIncompleteRune splits s before a multi-byte character that it ends
+// partway through.
+func splitIncompleteRune(s string) (string, string) {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(s[i]) {
+			continue
+		}
+
+		if utf8.FullRuneInString(s[i:]) {
+			return s, ""
+		}
+
+		return s[:i], s[i:]
+	}
+
+	return s, ""
+}
@@ -31,6 +31,8 @@ type Runtime struct {
 	ctx            context.Context //nolint: containedctx
 	storage        storage.Driver
 	triggeredBy    string
+	approvals      *agent.Approvals
+	agentCosts     agent.CostTracker
 }
 
 func NewRuntime(
@@ -420,6 +422,24 @@ func (r *Runtime) Agent(call goja.FunctionCall) goja.Value {
 		}
 	}
 
+	// Extract handler callbacks of pipeline-defined tools.
+	toolsVal := inputObj.Get("tools")
+	if toolsVal != nil && !goja.IsUndefined(toolsVal) && !goja.IsNull(toolsVal) {
+		toolsObj := toolsVal.ToObject(r.jsVM)
+
+		for i := range config.Tools {
+			toolObj := toolsObj.Get(fmt.Sprint(i))
+			if toolObj == nil || goja.IsUndefined(toolObj) || goja.IsNull(toolObj) {
+				continue
+			}
+
+			handlerVal := toolObj.ToObject(r.jsVM).Get("handler")
+			if handlerFunc, ok := goja.AssertFunction(handlerVal); ok {
+				config.Tools[i].Handler = r.agentToolHandler(handlerFunc)
+			}
+		}
+	}
+
 	r.promises.Add(1)
 
 	go func() {
@@ -443,6 +463,8 @@ func (r *Runtime) Agent(call goja.FunctionCall) goja.Value {
 		config.Namespace = r.namespace
 		config.RunID = r.runID
 		config.TriggeredBy = r.triggeredBy
+		config.Approvals = r.approvals
+		config.Costs = r.agentCosts
 
 		// Set the AgentFunc on the runner so that ResumableRunner can track
 		// and cache agent results. The func captures per-call context
@@ -465,6 +487,14 @@ func (r *Runtime) Agent(call goja.FunctionCall) goja.Value {
 			serializableConfig.RunID = config.RunID
 			serializableConfig.PipelineID = config.PipelineID
 			serializableConfig.TriggeredBy = config.TriggeredBy
+			serializableConfig.Approvals = config.Approvals
+			serializableConfig.Costs = config.Costs
+
+			for i := range serializableConfig.Tools {
+				if i < len(config.Tools) {
+					serializableConfig.Tools[i].Handler = config.Tools[i].Handler
+				}
+			}
 
 			result, err := agent.RunAgent(ctx, r.runner, r.secretsManager, r.pipelineID, serializableConfig)
 			if err != nil {
@@ -522,6 +552,83 @@ func (r *Runtime) Agent(call goja.FunctionCall) goja.Value {
 	return r.jsVM.ToValue(promise)
 }
 
+// agentToolHandler calls a JS tool handler on the event loop and waits for
+// its result, awaiting it when the handler returns a promise. Results that
+// are not objects are returned to the model as {"result": value}.
+func (r *Runtime) agentToolHandler(handler goja.Callable) func(map[string]any) (map[string]any, error) {
+	return func(args map[string]any) (map[string]any, error) {
+		type outcome struct {
+			value any
+			err   error
+		}
+
+		done := make(chan outcome, 1)
+		settle := func(value any, err error) {
+			select {
+			case done <- outcome{value: value, err: err}:
+			default:
+			}
+		}
+
+		ctx := r.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		r.tasks <- func() error {
+			value, err := handler(goja.Undefined(), r.jsVM.ToValue(args))
+			if err != nil {
+				settle(nil, err)
+
+				return nil
+			}
+
+			promise, ok := value.Export().(*goja.Promise)
+			if !ok {
+				settle(value.Export(), nil)
+
+				return nil
+			}
+
+			switch promise.State() {
+			case goja.PromiseStateFulfilled:
+				settle(promise.Result().Export(), nil)
+			case goja.PromiseStateRejected:
+				settle(nil, fmt.Errorf("%s", promise.Result().String()))
+			case goja.PromiseStatePending:
+				promiseObj := value.ToObject(r.jsVM)
+
+				then, _ := goja.AssertFunction(promiseObj.Get("then"))
+				_, err = then(
+					promiseObj,
+					r.jsVM.ToValue(func(result goja.Value) { settle(result.Export(), nil) }),
+					r.jsVM.ToValue(func(reason goja.Value) { settle(nil, fmt.Errorf("%s", reason.String())) }),
+				)
+				if err != nil {
+					settle(nil, err)
+				}
+			}
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result := <-done:
+			if result.err != nil {
+				return nil, result.err
+			}
+
+			if object, ok := result.value.(map[string]any); ok {
+				return object, nil
+			}
+
+			return map[string]any{"result": result.value}, nil
+		}
+	}
+}
+
 func (r *Runtime) Wait() error {
 	go func() {
 		r.promises.Wait()
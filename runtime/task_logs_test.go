@@ -0,0 +1,119 @@
+package runtime_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jtarchie/pocketci/orchestra/native"
+	"github.com/jtarchie/pocketci/runtime/runner"
+	storagelib "github.com/jtarchie/pocketci/storage"
+	storage "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestTaskLogs(t *testing.T) {
+	t.Parallel()
+
+	run := func(t *testing.T, runID string, script string) (*runner.RunResult, storagelib.Driver) {
+		t.Helper()
+		assert := NewGomegaWithT(t)
+
+		store, err := storage.NewSqlite("sqlite://:memory:", "test-ns", nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = store.Close() })
+
+		driver, err := native.NewNative("test-ns", slog.Default(), nil)
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = driver.Close() })
+
+		r := runner.NewPipelineRunner(context.Background(), driver, store, slog.Default(), "test-ns", runID)
+		t.Cleanup(func() { _ = r.CleanupVolumes() })
+
+		result, err := r.Run(runner.RunInput{
+			Name: "logs",
+			Command: struct {
+				Path string   `json:"path"`
+				Args []string `json:"args"`
+				User string   `json:"user"`
+			}{
+				Path: "sh",
+				Args: []string{"-c", script},
+			},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		return result, store
+	}
+
+	t.Run("stores output as log chunks rather than in the payload", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		result, store := run(t, "logs-run", "echo hello; echo oops >&2")
+		assert.Expect(result.Stdout).To(ContainSubstring("hello"))
+
+		taskPath := "/pipeline/logs-run/tasks/0-logs"
+
+		payload, err := store.Get(context.Background(), taskPath)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(payload["status"]).To(Equal("success"))
+		assert.Expect(payload).NotTo(HaveKey("logs"))
+
+		chunks, err := store.GetLogs(context.Background(), taskPath, 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		// The native driver combines stderr into stdout.
+		var output strings.Builder
+		for i, chunk := range chunks {
+			assert.Expect(chunk.Sequence).To(Equal(i))
+			assert.Expect(chunk.Stream).To(Equal("stdout"))
+			output.WriteString(chunk.Content)
+		}
+
+		assert.Expect(output.String()).To(ContainSubstring("hello\n"))
+		assert.Expect(output.String()).To(ContainSubstring("oops\n"))
+	})
+
+	t.Run("splits large output into bounded chunks", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		result, store := run(t, "large-logs-run", "yes 0123456789 | head -n 20000")
+		assert.Expect(result.Stdout).To(HaveLen(20000 * 11))
+
+		chunks, err := store.GetLogs(context.Background(), "/pipeline/large-logs-run/tasks/0-logs", 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(len(chunks)).To(BeNumerically(">", 1))
+
+		var output strings.Builder
+		for _, chunk := range chunks {
+			assert.Expect(len(chunk.Content)).To(BeNumerically("<=", 64*1024))
+			output.WriteString(chunk.Content)
+		}
+
+		assert.Expect(output.String()).To(Equal(result.Stdout))
+	})
+
+	t.Run("keeps only the tail of output beyond a MiB in memory", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		// 3 MiB of output, then a final line.
+		result, store := run(t, "tail-logs-run", "head -c 3145728 /dev/zero | tr '\\0' a; echo; echo last line")
+		assert.Expect(result.Stdout).To(HaveLen(1024 * 1024))
+		assert.Expect(result.Stdout).To(HaveSuffix("a\nlast line\n"))
+
+		chunks, err := store.GetLogs(context.Background(), "/pipeline/tail-logs-run/tasks/0-logs", 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		var output strings.Builder
+		for _, chunk := range chunks {
+			output.WriteString(chunk.Content)
+		}
+
+		assert.Expect(output.Len()).To(Equal(3*1024*1024 + len("\nlast line\n")))
+		assert.Expect(output.String()).To(HaveSuffix(result.Stdout))
+	})
+}
@@ -75,13 +75,12 @@ func TestTaskStatusPersistence(t *testing.T) {
 		payload, err := store.Get(ctx, taskPath)
 		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(payload["status"]).To(Equal("success"))
-		logs, ok := payload["logs"].([]any)
-		assert.Expect(ok).To(BeTrue())
+		assert.Expect(payload).NotTo(HaveKey("logs"))
+		logs, err := store.GetLogs(ctx, taskPath, 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(logs).NotTo(BeEmpty())
-		firstLog, ok := logs[0].(map[string]any)
-		assert.Expect(ok).To(BeTrue())
-		assert.Expect(firstLog["type"]).To(Equal("stdout"))
-		assert.Expect(firstLog["content"]).To(ContainSubstring("hello world"))
+		assert.Expect(logs[0].Stream).To(Equal("stdout"))
+		assert.Expect(logs[0].Content).To(ContainSubstring("hello world"))
 		assert.Expect(payload["code"]).To(BeEquivalentTo(0))
 		startedAt, ok := payload["started_at"].(string)
 		assert.Expect(ok).To(BeTrue())
@@ -135,13 +134,11 @@ func TestTaskStatusPersistence(t *testing.T) {
 		payload, err := store.Get(ctx, taskPath)
 		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(payload["status"]).To(Equal("failure"))
-		logs, ok := payload["logs"].([]any)
-		assert.Expect(ok).To(BeTrue())
+		logs, err := store.GetLogs(ctx, taskPath, 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(logs).NotTo(BeEmpty())
-		firstLog, ok := logs[0].(map[string]any)
-		assert.Expect(ok).To(BeTrue())
-		assert.Expect(firstLog["type"]).To(Equal("stdout"))
-		assert.Expect(firstLog["content"]).To(ContainSubstring("some output"))
+		assert.Expect(logs[0].Stream).To(Equal("stdout"))
+		assert.Expect(logs[0].Content).To(ContainSubstring("some output"))
 		assert.Expect(payload["code"]).To(BeEquivalentTo(1))
 		startedAt, ok := payload["started_at"].(string)
 		assert.Expect(ok).To(BeTrue())
@@ -215,22 +212,18 @@ func TestTaskStatusPersistence(t *testing.T) {
 		payload1, err := store.Get(ctx, "/pipeline/"+runID+"/tasks/0-task-a")
 		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(payload1["status"]).To(Equal("success"))
-		logs1, ok := payload1["logs"].([]any)
-		assert.Expect(ok).To(BeTrue())
+		logs1, err := store.GetLogs(ctx, "/pipeline/"+runID+"/tasks/0-task-a", 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(logs1).NotTo(BeEmpty())
-		firstLog1, ok := logs1[0].(map[string]any)
-		assert.Expect(ok).To(BeTrue())
-		assert.Expect(firstLog1["content"]).To(ContainSubstring("first"))
+		assert.Expect(logs1[0].Content).To(ContainSubstring("first"))
 
 		payload2, err := store.Get(ctx, "/pipeline/"+runID+"/tasks/1-task-b")
 		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(payload2["status"]).To(Equal("success"))
-		logs2, ok := payload2["logs"].([]any)
-		assert.Expect(ok).To(BeTrue())
+		logs2, err := store.GetLogs(ctx, "/pipeline/"+runID+"/tasks/1-task-b", 0, 0)
+		assert.Expect(err).NotTo(HaveOccurred())
 		assert.Expect(logs2).NotTo(BeEmpty())
-		firstLog2, ok := logs2[0].(map[string]any)
-		assert.Expect(ok).To(BeTrue())
-		assert.Expect(firstLog2["content"]).To(ContainSubstring("second"))
+		assert.Expect(logs2[0].Content).To(ContainSubstring("second"))
 	})
 
 	t.Run("tasks visible via GetAll for UI rendering", func(t *testing.T) {
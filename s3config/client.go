@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
@@ -241,3 +243,101 @@ func (c *Client) DeleteKey(ctx context.Context, key string) error {
 
 	return nil
 }
+
+// ObjectVersion describes one version of an object in a versioned bucket.
+type ObjectVersion struct {
+	VersionID    string
+	LastModified time.Time
+	IsLatest     bool
+}
+
+// ListVersions returns the versions of the object at key, oldest first.
+// Delete markers and objects that merely share the key as a prefix are skipped.
+func (c *Client) ListVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(key),
+	}
+
+	for {
+		page, err := c.s3Client.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions of %q: %w", key, err)
+		}
+
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) != key {
+				continue
+			}
+
+			versions = append(versions, ObjectVersion{
+				VersionID:    aws.ToString(version.VersionId),
+				LastModified: aws.ToTime(version.LastModified),
+				IsLatest:     aws.ToBool(version.IsLatest),
+			})
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+
+	// S3 lists the versions of a key newest first. LastModified has only
+	// second resolution, so versions written within a second can only be
+	// ordered by their position in the listing.
+	slices.Reverse(versions)
+
+	return versions, nil
+}
+
+// GetVersionStream is GetStream for a specific object version. An empty
+// versionID fetches the latest version.
+func (c *Client) GetVersionStream(ctx context.Context, key, versionID string) (*s3.GetObjectOutput, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	c.cfg.ApplySSEToGet(input)
+
+	result, err := c.s3Client.GetObject(ctx, input)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("get %q (version %q): %w", key, versionID, errKeyNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to get object %q (version %q): %w", key, versionID, err)
+	}
+
+	return result, nil
+}
+
+// PutVersioned uploads reader to key and returns the version ID assigned by
+// the bucket. The version ID is empty when the bucket is not versioned.
+func (c *Client) PutVersioned(ctx context.Context, key string, reader io.Reader, contentType string) (string, error) {
+	uploader := transfermanager.New(c.s3Client)
+
+	input := &transfermanager.UploadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		Body:   reader,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	c.cfg.ApplySSEToUpload(input)
+
+	output, err := uploader.UploadObject(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to put object %q: %w", key, err)
+	}
+
+	return aws.ToString(output.VersionID), nil
+}
@@ -0,0 +1,87 @@
+// Package schedule parses cron schedules that trigger pipelines and jobs.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Catch-up policies decide what happens to fire times that were missed while
+// the server was not running.
+const (
+	// CatchUpSkip drops missed fire times and waits for the next one.
+	CatchUpSkip = "skip"
+	// CatchUpRunOnce triggers a single run for any number of missed fire times.
+	CatchUpRunOnce = "run-once"
+)
+
+// Schedule is a parsed cron expression evaluated in a timezone.
+type Schedule struct {
+	spec     cron.Schedule
+	location *time.Location
+	catchUp  string
+}
+
+// Parse parses a standard 5-field cron expression (or a descriptor such as
+// "@daily"). An empty timezone means UTC and an empty catch-up policy means
+// the caller's default.
+func Parse(expression, timezone, catchUp string) (*Schedule, error) {
+	if strings.HasPrefix(expression, "TZ=") || strings.HasPrefix(expression, "CRON_TZ=") {
+		return nil, fmt.Errorf("could not parse cron %q: use timezone instead of a TZ prefix", expression)
+	}
+
+	spec, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse cron %q: %w", expression, err)
+	}
+
+	location := time.UTC
+
+	if timezone != "" {
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("could not load timezone %q: %w", timezone, err)
+		}
+	}
+
+	switch catchUp {
+	case "", CatchUpSkip, CatchUpRunOnce:
+	default:
+		return nil, fmt.Errorf("unknown catch_up policy %q: expected %q or %q", catchUp, CatchUpSkip, CatchUpRunOnce)
+	}
+
+	return &Schedule{
+		spec:     spec,
+		location: location,
+		catchUp:  catchUp,
+	}, nil
+}
+
+// CatchUp returns the schedule's catch-up policy, or defaultPolicy when unset.
+func (s *Schedule) CatchUp(defaultPolicy string) string {
+	if s.catchUp == "" {
+		return defaultPolicy
+	}
+
+	return s.catchUp
+}
+
+// Next returns the first fire time after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.spec.Next(t.In(s.location)).UTC()
+}
+
+// Due returns the latest fire time after last and at or before now.
+// It returns false when no fire time has passed since last.
+func (s *Schedule) Due(last, now time.Time) (time.Time, bool) {
+	var due time.Time
+
+	for next := s.Next(last); !next.IsZero() && !next.After(now); next = s.Next(next) {
+		due = next
+	}
+
+	return due, !due.IsZero()
+}
@@ -0,0 +1,79 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/schedule"
+	. "github.com/onsi/gomega"
+)
+
+func mustTime(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	t.Run("computes the next fire time in a timezone", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		sched, err := schedule.Parse("0 9 * * 1-5", "America/New_York", "")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		// Friday 2024-01-05 at 10:00 in New York, next is Monday at 09:00
+		next := sched.Next(mustTime("2024-01-05T15:00:00Z"))
+		assert.Expect(next).To(Equal(mustTime("2024-01-08T14:00:00Z")))
+	})
+
+	t.Run("returns the latest missed fire time", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		sched, err := schedule.Parse("*/15 * * * *", "", "")
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		due, ok := sched.Due(mustTime("2024-01-01T00:00:00Z"), mustTime("2024-01-01T01:05:00Z"))
+		assert.Expect(ok).To(BeTrue())
+		assert.Expect(due).To(Equal(mustTime("2024-01-01T01:00:00Z")))
+
+		_, ok = sched.Due(mustTime("2024-01-01T01:00:00Z"), mustTime("2024-01-01T01:05:00Z"))
+		assert.Expect(ok).To(BeFalse())
+	})
+
+	t.Run("defaults the catch-up policy", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		sched, err := schedule.Parse("@daily", "", "")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(sched.CatchUp(schedule.CatchUpSkip)).To(Equal(schedule.CatchUpSkip))
+
+		sched, err = schedule.Parse("@daily", "", schedule.CatchUpRunOnce)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(sched.CatchUp(schedule.CatchUpSkip)).To(Equal(schedule.CatchUpRunOnce))
+	})
+
+	t.Run("rejects invalid schedules", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		_, err := schedule.Parse("* * *", "", "")
+		assert.Expect(err).To(MatchError(ContainSubstring("could not parse cron")))
+
+		_, err = schedule.Parse("CRON_TZ=UTC * * * * *", "", "")
+		assert.Expect(err).To(MatchError(ContainSubstring("use timezone")))
+
+		_, err = schedule.Parse("* * * * *", "Mars/Olympus", "")
+		assert.Expect(err).To(MatchError(ContainSubstring("could not load timezone")))
+
+		_, err = schedule.Parse("* * * * *", "", "always")
+		assert.Expect(err).To(MatchError(ContainSubstring("unknown catch_up policy")))
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// runCosts charges what a run's agents spend to the run and stops its agents
+// once the pipeline's monthly budget has been spent.
+type runCosts struct {
+	store      storage.Driver
+	logger     *slog.Logger
+	pipelineID string
+	runID      string
+}
+
+var _ agent.CostTracker = runCosts{}
+
+// Check reads the budget on every call so that changes apply to runs that
+// are already in flight.
+func (c runCosts) Check(ctx context.Context) error {
+	pipeline, err := c.store.GetPipeline(ctx, c.pipelineID)
+	if err != nil {
+		return fmt.Errorf("could not get pipeline budget: %w", err)
+	}
+
+	if pipeline.MonthlyBudget <= 0 {
+		return nil
+	}
+
+	spent, err := c.store.GetPipelineCost(ctx, pipeline.ID, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not get pipeline cost: %w", err)
+	}
+
+	if spent >= pipeline.MonthlyBudget {
+		return fmt.Errorf(
+			"%w: pipeline %q has spent $%.2f of its $%.2f monthly budget",
+			agent.ErrBudgetExceeded, pipeline.Name, spent, pipeline.MonthlyBudget,
+		)
+	}
+
+	return nil
+}
+
+func (c runCosts) Record(ctx context.Context, cost float64) {
+	err := c.store.AddRunCost(ctx, c.runID, cost)
+	if err != nil {
+		c.logger.Error("run.cost.record.failed", "run_id", c.runID, "cost", cost, "error", err)
+	}
+}
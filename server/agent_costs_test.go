@@ -0,0 +1,177 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	_ "github.com/jtarchie/pocketci/orchestra/native"
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestPipelineMonthlyBudget(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			newClient := func(t *testing.T) storage.Driver {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = client.Close() })
+
+				return client
+			}
+
+			put := func(router *server.Router, body map[string]any) *httptest.ResponseRecorder {
+				jsonBody, _ := json.Marshal(body)
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/budgeted", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				return rec
+			}
+
+			t.Run("PUT /api/pipelines/:name sets and clears monthly_budget", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client := newClient(t)
+				router := newRouterWithSecrets(t, client, server.RouterOptions{})
+
+				rec := put(router, map[string]any{"content": "export { pipeline };", "driver_dsn": "native://", "monthly_budget": 25})
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var resp map[string]any
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(resp["monthly_budget"]).To(BeEquivalentTo(25))
+
+				pipeline, err := client.GetPipelineByName(context.Background(), "budgeted")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(pipeline.MonthlyBudget).To(Equal(25.0))
+
+				rec = put(router, map[string]any{"content": "export { pipeline };", "driver_dsn": "native://", "monthly_budget": 0})
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				pipeline, err = client.GetPipelineByName(context.Background(), "budgeted")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(pipeline.MonthlyBudget).To(BeZero())
+
+				rec = put(router, map[string]any{"content": "export { pipeline };", "driver_dsn": "native://", "monthly_budget": -1})
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("monthly_budget must not be negative"))
+			})
+
+			t.Run("fails agent steps once the month's budget is spent", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				ctx := context.Background()
+				client := newClient(t)
+
+				pipelineContent := `
+export const pipeline = async () => {
+	await runtime.agent({
+		name: "reviewer",
+		prompt: "Review the change.",
+		model: "openai/gpt-4o",
+		image: "busybox",
+	});
+};`
+
+				pipeline, err := client.SavePipeline(ctx, "budgeted", pipelineContent, "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.UpdatePipelineMonthlyBudget(ctx, pipeline.ID, 5)).To(Succeed())
+
+				previous, err := client.SaveRun(ctx, pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.AddRunCost(ctx, previous.ID, 5.5)).To(Succeed())
+
+				router := newStrictSecretRouter(t, client, server.RouterOptions{MaxInFlight: 5})
+
+				execService := router.ExecutionService()
+				run, err := execService.TriggerPipeline(ctx, pipeline)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				execService.Wait()
+
+				finalRun, err := client.GetRun(ctx, run.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(finalRun.Status).To(Equal(storage.RunStatusFailed))
+				assert.Expect(finalRun.ErrorMessage).To(ContainSubstring(`pipeline "budgeted" has spent $5.50 of its $5.00 monthly budget`))
+				assert.Expect(finalRun.Cost).To(BeZero())
+			})
+
+			t.Run("counts the spend of runs reaped by retention", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				ctx := context.Background()
+				client := newClient(t)
+
+				pipelineContent := `
+export const pipeline = async () => {
+	await runtime.agent({
+		name: "reviewer",
+		prompt: "Review the change.",
+		model: "openai/gpt-4o",
+		image: "busybox",
+	});
+};`
+
+				pipeline, err := client.SavePipeline(ctx, "budgeted", pipelineContent, "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.UpdatePipelineMonthlyBudget(ctx, pipeline.ID, 5)).To(Succeed())
+				assert.Expect(client.UpdatePipelineRetention(ctx, pipeline.ID, &storage.PipelineRetention{Runs: 1})).To(Succeed())
+
+				var runIDs []string
+
+				for _, cost := range []float64{2, 2, 1.5} {
+					run, err := client.SaveRun(ctx, pipeline.ID)
+					assert.Expect(err).NotTo(HaveOccurred())
+					assert.Expect(client.UpdateRunStatus(ctx, run.ID, storage.RunStatusSuccess, "")).To(Succeed())
+					assert.Expect(client.AddRunCost(ctx, run.ID, cost)).To(Succeed())
+
+					runIDs = append(runIDs, run.ID)
+				}
+
+				router := newStrictSecretRouter(t, client, server.RouterOptions{MaxInFlight: 5})
+
+				execService := router.ExecutionService()
+				server.NewReaper(client, execService, slog.Default()).Reap(ctx)
+
+				_, err = client.GetRun(ctx, runIDs[0])
+				assert.Expect(err).To(MatchError(storage.ErrNotFound))
+
+				run, err := execService.TriggerPipeline(ctx, pipeline)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				execService.Wait()
+
+				finalRun, err := client.GetRun(ctx, run.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(finalRun.Status).To(Equal(storage.RunStatusFailed))
+				assert.Expect(finalRun.ErrorMessage).To(ContainSubstring(`pipeline "budgeted" has spent $5.50 of its $5.00 monthly budget`))
+			})
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestPipelineMemory(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setup := func(t *testing.T) (storage.Driver, *storage.Pipeline, *server.Router) {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = client.Close() })
+
+				pipeline, err := client.SavePipeline(context.Background(), "remembering", "export { pipeline };", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				now := time.Now().UTC()
+				memories := map[string]time.Time{
+					"style":   now.Add(time.Hour),
+					"flaky":   now.Add(time.Hour),
+					"expired": now.Add(-time.Hour),
+				}
+
+				for key, expiresAt := range memories {
+					err = client.Set(context.Background(), "/memory/"+pipeline.ID+"/"+key, map[string]any{
+						"key":        key,
+						"content":    "note about " + key,
+						"created_at": now,
+						"expires_at": expiresAt,
+					})
+					assert.Expect(err).NotTo(HaveOccurred())
+				}
+
+				router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				return client, pipeline, router
+			}
+
+			request := func(router *server.Router, method, target string, headers ...string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, target, nil)
+				for i := 0; i+1 < len(headers); i += 2 {
+					req.Header.Set(headers[i], headers[i+1])
+				}
+
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				return rec
+			}
+
+			listKeys := func(t *testing.T, router *server.Router, pipelineID string) []string {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				rec := request(router, http.MethodGet, "/api/pipelines/"+pipelineID+"/memory")
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var resp struct {
+					Memories []struct {
+						Key     string `json:"key"`
+						Content string `json:"content"`
+					} `json:"memories"`
+				}
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				keys := []string{}
+				for _, memory := range resp.Memories {
+					keys = append(keys, memory.Key)
+				}
+
+				return keys
+			}
+
+			t.Run("GET /api/pipelines/:id/memory lists unexpired memories", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				_, pipeline, router := setup(t)
+
+				assert.Expect(listKeys(t, router, pipeline.ID)).To(ConsistOf("style", "flaky"))
+
+				rec := request(router, http.MethodGet, "/api/pipelines/missing/memory")
+				assert.Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+
+			t.Run("DELETE forgets one memory or all of them", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				_, pipeline, router := setup(t)
+
+				rec := request(router, http.MethodDelete, "/api/pipelines/"+pipeline.ID+"/memory/style", "HX-Request", "true")
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				assert.Expect(rec.Header().Get("HX-Trigger")).To(ContainSubstring("Forgot style"))
+				assert.Expect(listKeys(t, router, pipeline.ID)).To(ConsistOf("flaky"))
+
+				rec = request(router, http.MethodDelete, "/api/pipelines/"+pipeline.ID+"/memory/..bad")
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+
+				rec = request(router, http.MethodDelete, "/api/pipelines/"+pipeline.ID+"/memory")
+				assert.Expect(rec.Code).To(Equal(http.StatusNoContent))
+				assert.Expect(listKeys(t, router, pipeline.ID)).To(BeEmpty())
+			})
+
+			t.Run("deleting the pipeline clears its memory", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, router := setup(t)
+
+				rec := request(router, http.MethodDelete, "/api/pipelines/"+pipeline.ID)
+				assert.Expect(rec.Code).To(Equal(http.StatusNoContent))
+
+				results, err := client.GetAll(context.Background(), "/memory/"+pipeline.ID, []string{"key"})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(BeEmpty())
+			})
+
+			t.Run("GET /pipelines/:id/memory/ shows memories", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				_, pipeline, router := setup(t)
+
+				rec := request(router, http.MethodGet, "/pipelines/"+pipeline.ID+"/memory/")
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("note about style"))
+				assert.Expect(rec.Body.String()).NotTo(ContainSubstring("note about expired"))
+				assert.Expect(rec.Body.String()).To(ContainSubstring(`hx-delete="/api/pipelines/` + pipeline.ID + `/memory/style"`))
+			})
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/labstack/echo/v5"
+)
+
+// APIMemoryController handles the API endpoints for the notes agents keep
+// for a pipeline across runs.
+type APIMemoryController struct {
+	BaseController
+}
+
+// Index handles GET /api/pipelines/:id/memory - List a pipeline's memories.
+func (c *APIMemoryController) Index(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	memories, err := agent.ListMemories(ctx.Request().Context(), c.store, pipeline.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to list memories: %v", err),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"memories": memories,
+	})
+}
+
+// Clear handles DELETE /api/pipelines/:id/memory - Forget all of a
+// pipeline's memories.
+func (c *APIMemoryController) Clear(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	err = agent.ClearMemories(ctx.Request().Context(), c.store, pipeline.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to clear memories: %v", err),
+		})
+	}
+
+	if isHtmxRequest(ctx) {
+		ctx.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Memory cleared","type":"success"}}`)
+
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Forget handles DELETE /api/pipelines/:id/memory/:key - Forget one memory.
+func (c *APIMemoryController) Forget(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	key := ctx.Param("key")
+
+	err = agent.ForgetMemory(ctx.Request().Context(), c.store, pipeline.ID, key)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("failed to forget memory: %v", err),
+		})
+	}
+
+	if isHtmxRequest(ctx) {
+		ctx.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":%q,"type":"success"}}`, "Forgot "+key))
+
+		// An empty 200 lets htmx swap out the memory's row.
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// RegisterRoutes registers all memory API routes on the given group.
+func (c *APIMemoryController) RegisterRoutes(api *echo.Group) {
+	api.GET("/pipelines/:id/memory", c.Index)
+	api.DELETE("/pipelines/:id/memory", c.Clear)
+	api.DELETE("/pipelines/:id/memory/:key", c.Forget)
+}
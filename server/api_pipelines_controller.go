@@ -9,7 +9,10 @@ import (
 	"sort"
 	"time"
 
+	"github.com/jtarchie/pocketci/backwards"
 	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/jtarchie/pocketci/schedule"
 	"github.com/jtarchie/pocketci/secrets"
 	"github.com/jtarchie/pocketci/server/auth"
 	"github.com/jtarchie/pocketci/storage"
@@ -19,25 +22,33 @@ import (
 
 // PipelineRequest represents the JSON body for creating or updating a pipeline.
 type PipelineRequest struct {
-	Content        string            `json:"content"`
-	ContentType    string            `json:"content_type"`
-	DriverDSN      string            `json:"driver_dsn"`
-	WebhookSecret  *string           `json:"webhook_secret,omitempty"`
-	Secrets        map[string]string `json:"secrets,omitempty"`
-	ResumeEnabled  *bool             `json:"resume_enabled,omitempty"`
-	RBACExpression *string           `json:"rbac_expression,omitempty"`
+	Content        string                     `json:"content"`
+	ContentType    string                     `json:"content_type"`
+	DriverDSN      string                     `json:"driver_dsn"`
+	WebhookSecret  *string                    `json:"webhook_secret,omitempty"`
+	Secrets        map[string]string          `json:"secrets,omitempty"`
+	Vars           map[string]any             `json:"vars,omitempty"` // values for ((var)) references in YAML pipelines
+	ResumeEnabled  *bool                      `json:"resume_enabled,omitempty"`
+	RBACExpression *string                    `json:"rbac_expression,omitempty"`
+	Schedule       *storage.PipelineSchedule  `json:"schedule,omitempty"`       // an empty cron removes the schedule
+	MonthlyBudget  *float64                   `json:"monthly_budget,omitempty"` // agent budget in USD; zero removes it
+	Retention      *storage.PipelineRetention `json:"retention,omitempty"`      // zero runs and days remove it
 }
 
 // PipelineAPIResponse is a sanitized pipeline representation for the public API.
 type PipelineAPIResponse struct {
-	ID             string    `json:"id"`
-	Name           string    `json:"name"`
-	Content        string    `json:"content"`
-	ContentType    string    `json:"content_type"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	ResumeEnabled  bool      `json:"resume_enabled"`
-	RBACExpression string    `json:"rbac_expression,omitempty"`
+	ID             string                     `json:"id"`
+	Name           string                     `json:"name"`
+	Content        string                     `json:"content"`
+	ContentType    string                     `json:"content_type"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	UpdatedAt      time.Time                  `json:"updated_at"`
+	ResumeEnabled  bool                       `json:"resume_enabled"`
+	RBACExpression string                     `json:"rbac_expression,omitempty"`
+	Schedule       *storage.PipelineSchedule  `json:"schedule,omitempty"`
+	MonthlyBudget  float64                    `json:"monthly_budget,omitempty"`
+	Retention      *storage.PipelineRetention `json:"retention,omitempty"`
+	Version        int                        `json:"version,omitempty"`
 }
 
 func toPipelineAPIResponse(pipeline *storage.Pipeline) PipelineAPIResponse {
@@ -54,6 +65,10 @@ func toPipelineAPIResponse(pipeline *storage.Pipeline) PipelineAPIResponse {
 		UpdatedAt:      pipeline.UpdatedAt,
 		ResumeEnabled:  pipeline.ResumeEnabled,
 		RBACExpression: pipeline.RBACExpression,
+		Schedule:       pipeline.Schedule,
+		MonthlyBudget:  pipeline.MonthlyBudget,
+		Retention:      pipeline.Retention,
+		Version:        pipeline.Version,
 	}
 }
 
@@ -65,7 +80,10 @@ type APIPipelinesController struct {
 	secretsMgr      secrets.Manager
 }
 
-const pipelineDriverDSNSecretKey = "driver_dsn"
+const (
+	pipelineDriverDSNSecretKey = "driver_dsn"
+	pipelineVarsSecretKey      = "pipeline_vars"
+)
 
 // checkPipelineRBAC evaluates a pipeline's RBAC expression against the current user.
 // Returns nil if access is allowed, or an error response if denied.
@@ -217,6 +235,22 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 		})
 	}
 
+	// Vars are stored and read back as a pipeline secret, so they need the
+	// same feature gate and secrets manager as req.Secrets.
+	if len(req.Vars) > 0 {
+		if !IsFeatureEnabled(FeatureSecrets, c.allowedFeatures) {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{
+				"error": "secrets feature is not enabled",
+			})
+		}
+
+		if c.secretsMgr == nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{
+				"error": "secrets backend is not configured on the server",
+			})
+		}
+	}
+
 	// Validate secrets: require feature gate and secrets manager
 	if len(req.Secrets) > 0 {
 		if !IsFeatureEnabled(FeatureSecrets, c.allowedFeatures) {
@@ -232,6 +266,26 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 		}
 	}
 
+	if req.Schedule != nil && req.Schedule.Cron != "" {
+		if _, err := schedule.Parse(req.Schedule.Cron, req.Schedule.Timezone, req.Schedule.CatchUp); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("invalid schedule: %v", err),
+			})
+		}
+	}
+
+	if req.MonthlyBudget != nil && *req.MonthlyBudget < 0 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "monthly_budget must not be negative",
+		})
+	}
+
+	if req.Retention != nil && (req.Retention.Runs < 0 || req.Retention.Days < 0) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "retention runs and days must not be negative",
+		})
+	}
+
 	if len(req.Secrets) > 0 && c.secretsMgr != nil {
 		existingPipeline, getErr := c.store.GetPipelineByName(ctx.Request().Context(), name)
 		if getErr != nil && !errors.Is(getErr, storage.ErrNotFound) {
@@ -251,9 +305,9 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 			}
 
 			for _, existingKey := range existingKeys {
-				// webhook_secret is managed by req.WebhookSecret and should not be coupled
-				// to generic pipeline secrets in req.Secrets.
-				if existingKey == "webhook_secret" {
+				// webhook_secret and pipeline_vars are managed by req.WebhookSecret and
+				// req.Vars and should not be coupled to generic pipeline secrets in req.Secrets.
+				if existingKey == "webhook_secret" || existingKey == pipelineVarsSecretKey {
 					continue
 				}
 
@@ -285,6 +339,14 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 		})
 	}
 
+	if req.ContentType == storage.ContentTypeYAML {
+		if err := c.validatePipelineVars(ctx, name, req); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("invalid pipeline: %v", err),
+			})
+		}
+	}
+
 	pipeline, err := c.store.SavePipeline(ctx.Request().Context(), name, req.Content, driverConfig.Name, req.ContentType)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{
@@ -320,6 +382,12 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 		}
 	}
 
+	if err := c.storePipelineVars(ctx, pipeline.ID, req.Vars); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to store pipeline vars: %v", err),
+		})
+	}
+
 	// Store per-pipeline secrets if provided
 	if len(req.Secrets) > 0 && c.secretsMgr != nil {
 		scope := secrets.PipelineScope(pipeline.ID)
@@ -378,9 +446,115 @@ func (c *APIPipelinesController) Upsert(ctx *echo.Context) error {
 		pipeline.RBACExpression = *req.RBACExpression
 	}
 
+	if req.Schedule != nil {
+		pipelineSchedule := req.Schedule
+		if pipelineSchedule.Cron == "" {
+			pipelineSchedule = nil
+		}
+
+		if err := c.store.UpdatePipelineSchedule(ctx.Request().Context(), pipeline.ID, pipelineSchedule); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("failed to update schedule: %v", err),
+			})
+		}
+
+		pipeline.Schedule = pipelineSchedule
+	}
+
+	if req.MonthlyBudget != nil {
+		if err := c.store.UpdatePipelineMonthlyBudget(ctx.Request().Context(), pipeline.ID, *req.MonthlyBudget); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("failed to update monthly_budget: %v", err),
+			})
+		}
+
+		pipeline.MonthlyBudget = *req.MonthlyBudget
+	}
+
+	if req.Retention != nil {
+		retention := req.Retention
+		if retention.Runs == 0 && retention.Days == 0 {
+			retention = nil
+		}
+
+		if err := c.store.UpdatePipelineRetention(ctx.Request().Context(), pipeline.ID, retention); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("failed to update retention: %v", err),
+			})
+		}
+
+		pipeline.Retention = retention
+	}
+
+	version, err := recordPipelineVersion(ctx, c.store, pipeline.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to save pipeline version: %v", err),
+		})
+	}
+
+	pipeline.Version = version.Version
+
 	return ctx.JSON(http.StatusOK, toPipelineAPIResponse(pipeline))
 }
 
+// validatePipelineVars checks that every ((var)) in a YAML pipeline is
+// defined by the request's vars and secrets, or by a stored secret.
+func (c *APIPipelinesController) validatePipelineVars(ctx *echo.Context, name string, req PipelineRequest) error {
+	pipelineID := ""
+
+	existingPipeline, err := c.store.GetPipelineByName(ctx.Request().Context(), name)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to get existing pipeline by name: %w", err)
+	}
+
+	if err == nil {
+		pipelineID = existingPipeline.ID
+	}
+
+	requestSecrets := backwards.StaticVariables{}
+	for key, value := range req.Secrets {
+		requestSecrets[key] = value
+	}
+
+	return backwards.ValidatePipelineWithVars([]byte(req.Content), backwards.MultiVariables{
+		backwards.StaticVariables(req.Vars),
+		requestSecrets,
+		backwards.NewSecretVariables(ctx.Request().Context(), c.secretsMgr, pipelineID),
+	})
+}
+
+// storePipelineVars keeps the vars given when a pipeline is set alongside
+// its secrets, so they are interpolated each time it runs. Setting a
+// pipeline without vars removes them.
+func (c *APIPipelinesController) storePipelineVars(ctx *echo.Context, pipelineID string, vars map[string]any) error {
+	if c.secretsMgr == nil {
+		if len(vars) > 0 {
+			return errors.New("secrets backend is not configured")
+		}
+
+		return nil
+	}
+
+	scope := secrets.PipelineScope(pipelineID)
+
+	if len(vars) == 0 {
+		err := c.secretsMgr.Delete(ctx.Request().Context(), scope, pipelineVarsSecretKey)
+		if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+			return err
+		}
+
+		return nil
+	}
+
+	encoded, err := json.Marshal(vars)
+	if err != nil {
+		return fmt.Errorf("could not encode vars: %w", err)
+	}
+
+	return c.secretsMgr.Set(ctx.Request().Context(), scope, pipelineVarsSecretKey, string(encoded))
+}
+
 // Destroy handles DELETE /api/pipelines/:id - Delete a pipeline.
 func (c *APIPipelinesController) Destroy(ctx *echo.Context) error {
 	id := ctx.Param("id")
@@ -414,6 +588,9 @@ func (c *APIPipelinesController) Destroy(ctx *echo.Context) error {
 		_ = c.secretsMgr.DeleteByScope(ctx.Request().Context(), secrets.PipelineScope(id))
 	}
 
+	// Cascade delete the notes its agents kept.
+	_ = agent.ClearMemories(ctx.Request().Context(), c.store, id)
+
 	return ctx.NoContent(http.StatusNoContent)
 }
 
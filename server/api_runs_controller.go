@@ -1,11 +1,14 @@
 package server
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/jtarchie/pocketci/server/auth"
 	"github.com/jtarchie/pocketci/storage"
 	"github.com/labstack/echo/v5"
 )
@@ -21,6 +24,12 @@ type APIRunTask struct {
 	Payload storage.Payload `json:"payload"`
 }
 
+// ApprovalDecisionRequest is the body of POST /api/runs/:run_id/approvals/:approval_id.
+type ApprovalDecisionRequest struct {
+	Decision string `json:"decision" form:"decision"` // "approve" or "reject"
+	Reason   string `json:"reason"   form:"reason"`
+}
+
 func normalizeRunTaskPath(path, runPrefix string) string {
 	start := strings.Index(path, runPrefix)
 	if start >= 0 {
@@ -232,10 +241,162 @@ func (c *APIRunsController) Resume(ctx *echo.Context) error {
 	})
 }
 
+// Approvals handles GET /api/runs/:run_id/approvals - List agent tool calls
+// waiting for approval.
+func (c *APIRunsController) Approvals(ctx *echo.Context) error {
+	runID := ctx.Param("run_id")
+
+	_, err := c.store.GetRun(ctx.Request().Context(), runID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{
+				"error": "run not found",
+			})
+		}
+
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get run: %v", err),
+		})
+	}
+
+	pending, err := c.execService.Approvals.Pending(ctx.Request().Context(), runID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get approvals: %v", err),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, pending)
+}
+
+// Decide handles POST /api/runs/:run_id/approvals/:approval_id - Approve or
+// reject an agent tool call.
+func (c *APIRunsController) Decide(ctx *echo.Context) error {
+	runID := ctx.Param("run_id")
+	approvalID := ctx.Param("approval_id")
+
+	reqCtx := ctx.Request().Context()
+
+	run, err := c.store.GetRun(reqCtx, runID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{
+				"error": "run not found",
+			})
+		}
+
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get run: %v", err),
+		})
+	}
+
+	var req ApprovalDecisionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": `decision must be "approve" or "reject"`,
+		})
+	}
+
+	request, err := c.execService.Approvals.Get(reqCtx, runID, approvalID)
+	if errors.Is(err, agent.ErrApprovalNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{
+			"error": "approval not found",
+		})
+	}
+
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get approval: %v", err),
+		})
+	}
+
+	pipeline, err := c.store.GetPipeline(reqCtx, run.PipelineID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get pipeline: %v", err),
+		})
+	}
+
+	user := auth.GetUser(ctx)
+	if !canDecideApproval(user, pipeline, request) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{
+			"error": "not allowed to decide this approval",
+		})
+	}
+
+	decision := agent.ApprovalDecision{
+		Approved:  req.Decision == "approve",
+		DecidedBy: "anonymous",
+		Reason:    req.Reason,
+	}
+
+	if user != nil {
+		decision.DecidedBy = cmp.Or(user.Email, user.Name, user.NickName, decision.DecidedBy)
+	}
+
+	err = c.execService.Approvals.Decide(reqCtx, runID, approvalID, decision)
+	if errors.Is(err, agent.ErrApprovalNotFound) {
+		// Answered concurrently, or the run stopped in the meantime.
+		return ctx.JSON(http.StatusNotFound, map[string]string{
+			"error": "approval not found",
+		})
+	}
+
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to decide approval: %v", err),
+		})
+	}
+
+	if isHtmxRequest(ctx) {
+		message := "Tool call approved"
+		if !decision.Approved {
+			message = "Tool call rejected"
+		}
+
+		ctx.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":%q,"type":"success"}}`, message))
+
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"run_id":      runID,
+		"approval_id": approvalID,
+		"approved":    decision.Approved,
+		"decided_by":  decision.DecidedBy,
+	})
+}
+
+// canDecideApproval reports whether user may answer an approval request. The
+// user needs access to the run's pipeline and must match the policy rule's
+// approvers expression, which always requires a signed-in user.
+func canDecideApproval(user *auth.User, pipeline *storage.Pipeline, request agent.ApprovalRequest) bool {
+	if user == nil {
+		return request.Approvers == ""
+	}
+
+	for _, expression := range []string{pipeline.RBACExpression, request.Approvers} {
+		allowed, err := auth.EvaluateAccess(expression, *user)
+		if err != nil || !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
 // RegisterRoutes registers all run API routes on the given group.
 func (c *APIRunsController) RegisterRoutes(api *echo.Group) {
 	api.GET("/runs/:run_id/status", c.Status)
 	api.GET("/runs/:run_id/tasks", c.Tasks)
 	api.POST("/runs/:run_id/stop", c.Stop)
 	api.POST("/runs/:run_id/resume", c.Resume)
+	api.GET("/runs/:run_id/approvals", c.Approvals)
+	api.POST("/runs/:run_id/approvals/:approval_id", c.Decide)
 }
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/secrets"
+	"github.com/jtarchie/pocketci/server/auth"
+	"github.com/jtarchie/pocketci/storage"
+	"github.com/labstack/echo/v5"
+)
+
+// APIVersionsController handles the API endpoints for the versions saved
+// each time a pipeline is set.
+type APIVersionsController struct {
+	BaseController
+	allowedDrivers  []string
+	allowedFeatures []Feature
+	secretsMgr      secrets.Manager
+}
+
+// recordPipelineVersion saves the pipeline as it is now stored as its next
+// version, authored by the current user.
+func recordPipelineVersion(ctx *echo.Context, store storage.Driver, pipelineID string) (*storage.PipelineVersion, error) {
+	pipeline, err := store.GetPipeline(ctx.Request().Context(), pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.SavePipelineVersion(ctx.Request().Context(), storage.PipelineVersion{
+		PipelineID:     pipeline.ID,
+		Content:        pipeline.Content,
+		ContentType:    pipeline.ContentType,
+		DriverDSN:      pipeline.DriverDSN,
+		ResumeEnabled:  pipeline.ResumeEnabled,
+		RBACExpression: pipeline.RBACExpression,
+		Author:         versionAuthor(auth.GetUser(ctx)),
+	})
+}
+
+// versionAuthor names the user who saved a version. It is empty when no
+// authentication is configured.
+func versionAuthor(user *auth.User) string {
+	if user == nil {
+		return ""
+	}
+
+	for _, name := range []string{user.Email, user.NickName, user.Name, user.UserID} {
+		if name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// version returns the version of the pipeline named by the :version param,
+// writing an error response when it cannot be found.
+func (c *APIVersionsController) version(ctx *echo.Context, pipeline *storage.Pipeline) (*storage.PipelineVersion, bool, error) {
+	number, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || number < 1 {
+		return nil, false, ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "version must be a positive integer",
+		})
+	}
+
+	version, err := c.store.GetPipelineVersion(ctx.Request().Context(), pipeline.ID, number)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, false, ctx.JSON(http.StatusNotFound, map[string]string{
+				"error": "pipeline version not found",
+			})
+		}
+
+		return nil, false, ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get pipeline version: %v", err),
+		})
+	}
+
+	return version, true, nil
+}
+
+// Index handles GET /api/pipelines/:id/versions - List a pipeline's versions,
+// newest first.
+func (c *APIVersionsController) Index(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	page := 1
+	perPage := 20
+
+	if p := ctx.QueryParam("page"); p != "" {
+		_, _ = fmt.Sscanf(p, "%d", &page)
+	}
+	if pp := ctx.QueryParam("per_page"); pp != "" {
+		_, _ = fmt.Sscanf(pp, "%d", &perPage)
+	}
+
+	result, err := c.store.ListPipelineVersions(ctx.Request().Context(), pipeline.ID, page, perPage)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to list pipeline versions: %v", err),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// Show handles GET /api/pipelines/:id/versions/:version - Get one version.
+func (c *APIVersionsController) Show(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	version, ok, err := c.version(ctx, pipeline)
+	if !ok {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, version)
+}
+
+// Rollback handles POST /api/pipelines/:id/versions/:version/rollback -
+// Set the pipeline back to an earlier version. The rollback is saved as a
+// new version, so history is never rewritten.
+func (c *APIVersionsController) Rollback(ctx *echo.Context) error {
+	pipeline, ok, err := c.pipeline(ctx)
+	if !ok {
+		return err
+	}
+
+	version, ok, err := c.version(ctx, pipeline)
+	if !ok {
+		return err
+	}
+
+	if err := orchestra.IsDriverAllowed(version.DriverDSN, c.allowedDrivers); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("driver not allowed: %v", err),
+		})
+	}
+
+	if version.ResumeEnabled && !IsFeatureEnabled(FeatureResume, c.allowedFeatures) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "resume feature is not enabled",
+		})
+	}
+
+	if c.secretsMgr == nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "secrets backend is not configured on the server",
+		})
+	}
+
+	_, err = c.store.SavePipeline(ctx.Request().Context(), pipeline.Name, version.Content, version.DriverDSN, version.ContentType)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to save pipeline: %v", err),
+		})
+	}
+
+	// Versions keep only the driver's name, as the full DSN may hold
+	// credentials. Keep the stored DSN unless it is for another driver.
+	scope := secrets.PipelineScope(pipeline.ID)
+
+	driverDSN, err := c.secretsMgr.Get(ctx.Request().Context(), scope, pipelineDriverDSNSecretKey)
+	if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get driver DSN: %v", err),
+		})
+	}
+
+	currentDriver := ""
+	if driverConfig, parseErr := orchestra.ParseDriverDSN(driverDSN); err == nil && parseErr == nil {
+		currentDriver = driverConfig.Name
+	}
+
+	if currentDriver != version.DriverDSN {
+		if err := c.secretsMgr.Set(ctx.Request().Context(), scope, pipelineDriverDSNSecretKey, version.DriverDSN); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("failed to store driver DSN: %v", err),
+			})
+		}
+	}
+
+	if err := c.store.UpdatePipelineResumeEnabled(ctx.Request().Context(), pipeline.ID, version.ResumeEnabled); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to update resume_enabled: %v", err),
+		})
+	}
+
+	if err := c.store.UpdatePipelineRBACExpression(ctx.Request().Context(), pipeline.ID, version.RBACExpression); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to update rbac_expression: %v", err),
+		})
+	}
+
+	if _, err := recordPipelineVersion(ctx, c.store, pipeline.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to save pipeline version: %v", err),
+		})
+	}
+
+	if isHtmxRequest(ctx) {
+		ctx.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":"Rolled back to version %d","type":"success"}}`, version.Version))
+
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	pipeline, err = c.store.GetPipeline(ctx.Request().Context(), pipeline.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get pipeline: %v", err),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, toPipelineAPIResponse(pipeline))
+}
+
+// RegisterRoutes registers all pipeline version API routes on the given group.
+func (c *APIVersionsController) RegisterRoutes(api *echo.Group) {
+	api.GET("/pipelines/:id/versions", c.Index)
+	api.GET("/pipelines/:id/versions/:version", c.Show)
+	api.POST("/pipelines/:id/versions/:version/rollback", c.Rollback)
+}
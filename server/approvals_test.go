@@ -0,0 +1,184 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/runtime/agent"
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	. "github.com/onsi/gomega"
+)
+
+func TestRunApprovals(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setupWithStorage := func(t *testing.T) (*server.Router, storage.Driver, *storage.PipelineRun) {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = client.Close() })
+
+				pipeline, err := client.SavePipeline(context.Background(), "approval-pipeline", "export const pipeline = async () => {};", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				router := newStrictSecretRouter(t, client, server.RouterOptions{})
+
+				run, err := client.SaveRun(context.Background(), pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				return router, client, run
+			}
+
+			setup := func(t *testing.T) (*server.Router, *storage.PipelineRun) {
+				t.Helper()
+
+				router, _, run := setupWithStorage(t)
+
+				return router, run
+			}
+
+			request := func(router *server.Router, method, path, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				return rec
+			}
+
+			t.Run("lists and decides pending approvals", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				router, run := setup(t)
+				approvals := router.ExecutionService().Approvals
+
+				decided := make(chan agent.ApprovalDecision, 1)
+
+				go func() {
+					decision, _ := approvals.Request(context.Background(), agent.ApprovalRequest{
+						ID:       "approval-1",
+						RunID:    run.ID,
+						Agent:    "deployer",
+						ToolName: "run_command",
+						ToolArgs: map[string]any{"command": "kubectl"},
+					})
+					decided <- decision
+				}()
+
+				assert.Eventually(func() ([]agent.ApprovalRequest, error) { return approvals.Pending(context.Background(), run.ID) }).Should(HaveLen(1))
+
+				rec := request(router, http.MethodGet, "/api/runs/"+run.ID+"/approvals", "")
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var pending []agent.ApprovalRequest
+				err := json.Unmarshal(rec.Body.Bytes(), &pending)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(pending).To(HaveLen(1))
+				assert.Expect(pending[0].ID).To(Equal("approval-1"))
+				assert.Expect(pending[0].ToolName).To(Equal("run_command"))
+
+				rec = request(router, http.MethodPost, "/api/runs/"+run.ID+"/approvals/approval-1", `{"decision":"maybe"}`)
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+
+				rec = request(router, http.MethodPost, "/api/runs/"+run.ID+"/approvals/approval-1", `{"decision":"reject","reason":"not today"}`)
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var decision agent.ApprovalDecision
+				assert.Eventually(decided).Should(Receive(&decision))
+				assert.Expect(decision.Approved).To(BeFalse())
+				assert.Expect(decision.Reason).To(Equal("not today"))
+
+				rec = request(router, http.MethodPost, "/api/runs/"+run.ID+"/approvals/approval-1", `{"decision":"approve"}`)
+				assert.Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+
+			t.Run("decides approvals requested on another server sharing the storage", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				running, client, run := setupWithStorage(t)
+				deciding := newStrictSecretRouter(t, client, server.RouterOptions{})
+
+				approvals := running.ExecutionService().Approvals
+				approvals.PollInterval = 10 * time.Millisecond
+
+				decided := make(chan agent.ApprovalDecision, 1)
+
+				go func() {
+					decision, _ := approvals.Request(context.Background(), agent.ApprovalRequest{
+						ID:       "approval-3",
+						RunID:    run.ID,
+						ToolName: "run_command",
+					})
+					decided <- decision
+				}()
+
+				assert.Eventually(func() string {
+					return request(deciding, http.MethodGet, "/api/runs/"+run.ID+"/approvals", "").Body.String()
+				}).Should(ContainSubstring(`"id":"approval-3"`))
+
+				rec := request(deciding, http.MethodPost, "/api/runs/"+run.ID+"/approvals/approval-3", `{"decision":"approve"}`)
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var decision agent.ApprovalDecision
+				assert.Eventually(decided).Should(Receive(&decision))
+				assert.Expect(decision.Approved).To(BeTrue())
+			})
+
+			t.Run("requires a signed-in user when approvers are restricted", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				router, run := setup(t)
+				approvals := router.ExecutionService().Approvals
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				go func() {
+					_, _ = approvals.Request(ctx, agent.ApprovalRequest{
+						ID:        "approval-2",
+						RunID:     run.ID,
+						ToolName:  "run_command",
+						Approvers: `"ops" in Groups`,
+					})
+				}()
+
+				assert.Eventually(func() ([]agent.ApprovalRequest, error) { return approvals.Pending(context.Background(), run.ID) }).Should(HaveLen(1))
+
+				rec := request(router, http.MethodPost, "/api/runs/"+run.ID+"/approvals/approval-2", `{"decision":"approve"}`)
+				assert.Expect(rec.Code).To(Equal(http.StatusForbidden))
+				assert.Expect(approvals.Pending(context.Background(), run.ID)).To(HaveLen(1))
+			})
+
+			t.Run("returns 404 for a non-existent run", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				router, _ := setup(t)
+
+				rec := request(router, http.MethodGet, "/api/runs/does-not-exist/approvals", "")
+				assert.Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+}
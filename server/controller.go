@@ -1,9 +1,13 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/jtarchie/pocketci/storage"
+	"github.com/labstack/echo/v5"
 )
 
 // BaseController holds dependencies shared by all controllers.
@@ -12,6 +16,32 @@ type BaseController struct {
 	execService *ExecutionService
 }
 
+// pipeline returns the pipeline named by the :id param, writing an error
+// response when it cannot be used.
+func (c *BaseController) pipeline(ctx *echo.Context) (*storage.Pipeline, bool, error) {
+	pipeline, err := c.store.GetPipeline(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, false, ctx.JSON(http.StatusNotFound, map[string]string{
+				"error": "pipeline not found",
+			})
+		}
+
+		return nil, false, ctx.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("failed to get pipeline: %v", err),
+		})
+	}
+
+	// checkPipelineRBAC writes a 403 rather than returning an error, so stop
+	// once a response has been sent.
+	err = checkPipelineRBAC(ctx, pipeline)
+	if resp, _ := echo.UnwrapResponse(ctx.Response()); err != nil || (resp != nil && resp.Committed) {
+		return nil, false, err
+	}
+
+	return pipeline, true, nil
+}
+
 // parseAllowedDrivers parses a comma-separated list of driver names.
 // Returns ["*"] if input is empty or "*".
 // Trims whitespace from each driver name.
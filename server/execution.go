@@ -16,6 +16,7 @@ import (
 	"github.com/jtarchie/pocketci/orchestra"
 	"github.com/jtarchie/pocketci/orchestra/cache"
 	"github.com/jtarchie/pocketci/runtime"
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/runtime/jsapi"
 	"github.com/jtarchie/pocketci/secrets"
 	"github.com/jtarchie/pocketci/storage"
@@ -37,8 +38,11 @@ type ExecutionService struct {
 	AllowedFeatures       []Feature
 	FetchTimeout          time.Duration
 	FetchMaxResponseBytes int64
-	stopRegistry          map[string]context.CancelFunc
-	stopMu                sync.Mutex
+	// Approvals holds agent tool calls of in-flight runs waiting for a
+	// human decision.
+	Approvals    *agent.Approvals
+	stopRegistry map[string]context.CancelFunc
+	stopMu       sync.Mutex
 }
 
 // NewExecutionService creates a new execution service.
@@ -61,9 +65,15 @@ func NewExecutionService(store storage.Driver, logger *slog.Logger, maxInFlight
 		maxInFlight:   maxInFlight,
 		DefaultDriver: defaultDriver,
 		stopRegistry:  make(map[string]context.CancelFunc),
+		Approvals:     agent.NewApprovals(store),
 	}
 }
 
+// agentCosts returns the tracker that charges a run's agent spend to it.
+func (s *ExecutionService) agentCosts(pipelineID, runID string) agent.CostTracker {
+	return runCosts{store: s.store, logger: s.logger, pipelineID: pipelineID, runID: runID}
+}
+
 // Wait blocks until all in-flight pipeline executions have completed.
 // This is useful for graceful shutdown or testing.
 func (s *ExecutionService) Wait() {
@@ -105,11 +115,26 @@ func (s *ExecutionService) MaxInFlight() int {
 // It creates a run record, starts a goroutine to execute the pipeline,
 // and returns the run ID immediately.
 func (s *ExecutionService) TriggerPipeline(ctx context.Context, pipeline *storage.Pipeline) (*storage.PipelineRun, error) {
+	return s.TriggerPipelineWithOptions(ctx, pipeline, TriggerOptions{})
+}
+
+// TriggerOptions customizes a triggered pipeline execution.
+type TriggerOptions struct {
+	// TriggeredBy is exposed to the pipeline as pipelineContext.triggeredBy.
+	// Defaults to "manual".
+	TriggeredBy string
+	// Jobs restricts a YAML pipeline run to the named jobs and their dependents.
+	Jobs []string
+}
+
+// TriggerPipelineWithOptions behaves like TriggerPipeline, passing the
+// trigger source and job selection through to the pipeline runtime.
+func (s *ExecutionService) TriggerPipelineWithOptions(ctx context.Context, pipeline *storage.Pipeline, opts TriggerOptions) (*storage.PipelineRun, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Create run record with queued status
-	run, err := s.store.SaveRun(ctx, pipeline.ID)
+	run, err := s.saveRun(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +144,10 @@ func (s *ExecutionService) TriggerPipeline(ctx context.Context, pipeline *storag
 	s.wg.Add(1)
 
 	// Launch execution goroutine
-	go s.executePipeline(pipeline, run, execOptions{})
+	go s.executePipeline(pipeline, run, execOptions{
+		triggeredBy: opts.TriggeredBy,
+		jobs:        opts.Jobs,
+	})
 
 	return run, nil
 }
@@ -136,7 +164,7 @@ func (s *ExecutionService) TriggerWebhookPipeline(
 	defer s.mu.Unlock()
 
 	// Create run record with queued status
-	run, err := s.store.SaveRun(ctx, pipeline.ID)
+	run, err := s.saveRun(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
@@ -211,6 +239,7 @@ func (s *ExecutionService) RecoverOrphanedRuns(ctx context.Context) {
 		}
 
 		logger.Info("orphan.recovery.marking_failed")
+		_ = s.Approvals.Clear(ctx, run.ID)
 		_ = s.store.UpdateRunStatus(ctx, run.ID, storage.RunStatusFailed, "Server restarted during execution")
 		_ = s.store.UpdateStatusForPrefix(ctx, "/pipeline/"+run.ID+"/", []string{"pending", "running"}, "aborted")
 	}
@@ -224,8 +253,30 @@ type webhookExecData struct {
 
 // execOptions holds options for executePipeline.
 type execOptions struct {
-	webhook *webhookExecData
-	resume  bool
+	webhook     *webhookExecData
+	resume      bool
+	triggeredBy string
+	jobs        []string
+}
+
+// saveRun creates a queued run of the pipeline and records which version of
+// it the run executes.
+func (s *ExecutionService) saveRun(ctx context.Context, pipeline *storage.Pipeline) (*storage.PipelineRun, error) {
+	run, err := s.store.SaveRun(ctx, pipeline.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pipeline.Version > 0 {
+		err = s.store.UpdateRunPipelineVersion(ctx, run.ID, pipeline.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record pipeline version: %w", err)
+		}
+
+		run.PipelineVersion = pipeline.Version
+	}
+
+	return run, nil
 }
 
 func (s *ExecutionService) resolveDriverDSN(ctx context.Context, pipeline *storage.Pipeline) (string, error) {
@@ -299,9 +350,13 @@ func (s *ExecutionService) executePipeline(pipeline *storage.Pipeline, run *stor
 
 	// Execute the pipeline
 	execOpts := runtime.ExecutorOptions{
-		RunID:      run.ID,
-		PipelineID: pipeline.ID,
-		Resume:     IsFeatureEnabled(FeatureResume, s.AllowedFeatures) && (opts.resume || pipeline.ResumeEnabled),
+		RunID:       run.ID,
+		PipelineID:  pipeline.ID,
+		Resume:      IsFeatureEnabled(FeatureResume, s.AllowedFeatures) && (opts.resume || pipeline.ResumeEnabled),
+		TriggeredBy: opts.triggeredBy,
+		Jobs:        opts.jobs,
+		Approvals:   s.Approvals,
+		AgentCosts:  s.agentCosts(pipeline.ID, run.ID),
 	}
 
 	// Only pass secrets manager if the secrets feature is enabled
@@ -323,7 +378,7 @@ func (s *ExecutionService) executePipeline(pipeline *storage.Pipeline, run *stor
 	execOpts.FetchTimeout = s.FetchTimeout
 	execOpts.FetchMaxResponseBytes = s.FetchMaxResponseBytes
 
-	executableContent, err := resolveExecutableContent(pipeline)
+	executableContent, secretValues, err := s.resolveExecutableContent(dbCtx, pipeline)
 	if err != nil {
 		logger.Error("pipeline.transpile.failed", "error", err)
 
@@ -335,7 +390,15 @@ func (s *ExecutionService) executePipeline(pipeline *storage.Pipeline, run *stor
 		return
 	}
 
+	execOpts.SecretValues = secretValues
+
 	err = runtime.ExecutePipeline(ctx, executableContent, driverDSN, s.store, logger, execOpts)
+
+	// Approval requests end with the run that made them.
+	if clearErr := s.Approvals.Clear(dbCtx, run.ID); clearErr != nil {
+		logger.Error("run.approvals.clear.failed", "error", clearErr)
+	}
+
 	if err != nil {
 		logger.Error("pipeline.execute.failed", "error", err)
 
@@ -412,7 +475,7 @@ func (s *ExecutionService) RunByNameSync(
 		return err
 	}
 
-	run, err := s.store.SaveRun(ctx, pipeline.ID)
+	run, err := s.saveRun(ctx, pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to save run: %w", err)
 	}
@@ -502,6 +565,8 @@ func (s *ExecutionService) RunByNameSync(
 		DisableFetch:          !IsFeatureEnabled(FeatureFetch, s.AllowedFeatures),
 		FetchTimeout:          s.FetchTimeout,
 		FetchMaxResponseBytes: s.FetchMaxResponseBytes,
+		Approvals:             s.Approvals,
+		AgentCosts:            s.agentCosts(pipeline.ID, run.ID),
 	}
 	if IsFeatureEnabled(FeatureSecrets, s.AllowedFeatures) {
 		opts.SecretsManager = s.SecretsManager
@@ -521,13 +586,20 @@ func (s *ExecutionService) RunByNameSync(
 		}
 	}
 
-	executableContent, execContentErr := resolveExecutableContent(pipeline)
+	executableContent, secretValues, execContentErr := s.resolveExecutableContent(ctx, pipeline)
 	if execContentErr != nil {
 		return fmt.Errorf("could not resolve pipeline content: %w", execContentErr)
 	}
 
+	opts.SecretValues = secretValues
+
 	execErr := runtime.ExecutePipeline(ctx, executableContent, driverDSN, s.store, s.logger, opts)
 
+	// Approval requests end with the run that made them.
+	if clearErr := s.Approvals.Clear(context.WithoutCancel(ctx), run.ID); clearErr != nil {
+		s.logger.Error("run.approvals.clear.failed", "error", clearErr)
+	}
+
 	exitCode := 0
 	var finalStatus storage.RunStatus
 	errMsg := ""
@@ -605,17 +677,68 @@ func (s *ExecutionService) determineRunStatus(ctx context.Context, runID string,
 
 // resolveExecutableContent returns JS/TS content ready for the runtime.
 // When the pipeline was stored as YAML it is transpiled on the fly so that
-// the latest pipeline_runner.ts bundle is always used. JS and TS content is
+// the latest pipeline_runner.ts bundle is always used, and its ((var))
+// references are interpolated. The secrets read for those references are
+// returned so they can be redacted from task output. JS and TS content is
 // returned as-is.
-func resolveExecutableContent(pipeline *storage.Pipeline) (string, error) {
-	if pipeline.ContentType == storage.ContentTypeYAML {
-		ts, err := backwards.NewPipelineFromContent(pipeline.Content)
-		if err != nil {
-			return "", fmt.Errorf("could not transpile YAML pipeline %q: %w", pipeline.Name, err)
-		}
+func (s *ExecutionService) resolveExecutableContent(ctx context.Context, pipeline *storage.Pipeline) (string, []string, error) {
+	if pipeline.ContentType != storage.ContentTypeYAML {
+		return pipeline.Content, nil, nil
+	}
+
+	vars, secretVars, err := s.pipelineVariables(ctx, pipeline.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ts, err := backwards.NewPipelineFromContentWithVars(pipeline.Content, vars)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not transpile YAML pipeline %q: %w", pipeline.Name, err)
+	}
+
+	return ts, secretVars.Resolved(), nil
+}
+
+// pipelineConfig parses a YAML pipeline with its ((var)) references
+// interpolated, as its runs see it.
+func (s *ExecutionService) pipelineConfig(ctx context.Context, pipeline *storage.Pipeline) (*backwards.Config, error) {
+	vars, _, err := s.pipelineVariables(ctx, pipeline.ID)
+	if err != nil {
+		return nil, err
+	}
 
-		return ts, nil
+	return backwards.ParseConfigWithVars([]byte(pipeline.Content), vars)
+}
+
+// pipelineVariables returns the sources for a pipeline's ((var)) references:
+// the vars given when it was set, then its secrets.
+func (s *ExecutionService) pipelineVariables(ctx context.Context, pipelineID string) (backwards.Variables, *backwards.SecretVariables, error) {
+	var manager secrets.Manager
+	if IsFeatureEnabled(FeatureSecrets, s.AllowedFeatures) {
+		manager = s.SecretsManager
+	}
+
+	secretVars := backwards.NewSecretVariables(ctx, manager, pipelineID)
+
+	if manager == nil {
+		return secretVars, secretVars, nil
+	}
+
+	stored, err := manager.Get(ctx, secrets.PipelineScope(pipelineID), pipelineVarsSecretKey)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return secretVars, secretVars, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not resolve pipeline vars: %w", err)
+	}
+
+	var vars backwards.StaticVariables
+
+	err = json.Unmarshal([]byte(stored), &vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode pipeline vars: %w", err)
 	}
 
-	return pipeline.Content, nil
+	return backwards.MultiVariables{vars, secretVars}, secretVars, nil
 }
@@ -240,6 +240,51 @@ func TestFeatureRestriction(t *testing.T) {
 				assert.Expect(rec.Code).To(Equal(http.StatusOK))
 			})
 
+			t.Run("rejects vars when secrets feature is disabled", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = buildFile.Close() }()
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = client.Close() }()
+
+				secretsMgr, err := secrets.GetFromDSN("sqlite://:memory:?key=test-key", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = secretsMgr.Close() }()
+
+				// Create router with only webhooks (no secrets)
+				router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{
+					AllowedDrivers:  "native",
+					AllowedFeatures: "webhooks",
+					SecretsManager:  secretsMgr,
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				body := map[string]any{
+					"content":      "jobs: []",
+					"content_type": "yaml",
+					"driver_dsn":   "native",
+					"vars":         map[string]any{"region": "us-east-1"},
+				}
+				jsonBody, _ := json.Marshal(body)
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/test-pipeline", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				message := mustJSONErrorText(t, rec)
+				assert.Expect(message).To(ContainSubstring("secrets feature is not enabled"))
+
+				_, err = client.GetPipelineByName(t.Context(), "test-pipeline")
+				assert.Expect(err).To(MatchError(storage.ErrNotFound))
+			})
+
 			t.Run("pipeline without webhook_secret works even when webhooks disabled", func(t *testing.T) {
 				t.Parallel()
 				assert := NewGomegaWithT(t)
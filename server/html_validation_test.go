@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jtarchie/pocketci/server"
 	"github.com/jtarchie/pocketci/storage"
@@ -33,15 +34,35 @@ func TestHTMLEndpointsAreStrictlyValid(t *testing.T) {
 			pipeline, err := client.SavePipeline(context.Background(), "html-validation-pipeline", "export const pipeline = async () => {};", "docker://", "")
 			assert.Expect(err).NotTo(HaveOccurred())
 
+			_, err = client.SavePipelineVersion(context.Background(), storage.PipelineVersion{
+				PipelineID: pipeline.ID,
+				Content:    pipeline.Content,
+				DriverDSN:  "docker",
+			})
+			assert.Expect(err).NotTo(HaveOccurred())
+
 			run, err := client.SaveRun(context.Background(), pipeline.ID)
 			assert.Expect(err).NotTo(HaveOccurred())
 
+			err = client.UpdateRunPipelineVersion(context.Background(), run.ID, 1)
+			assert.Expect(err).NotTo(HaveOccurred())
+
 			err = client.Set(context.Background(), "/pipeline/"+run.ID+"/tasks/0-build", map[string]any{
 				"status": "success",
 				"logs":   []map[string]any{{"type": "stdout", "content": "ok"}},
 			})
 			assert.Expect(err).NotTo(HaveOccurred())
 
+			err = client.Set(context.Background(), "/memory/"+pipeline.ID+"/style", map[string]any{
+				"key":        "style",
+				"content":    "The project uses tabs.",
+				"agent":      "reviewer",
+				"run_id":     run.ID,
+				"created_at": time.Now().UTC(),
+				"expires_at": time.Now().UTC().Add(time.Hour),
+			})
+			assert.Expect(err).NotTo(HaveOccurred())
+
 			router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{})
 			assert.Expect(err).NotTo(HaveOccurred())
 
@@ -49,6 +70,9 @@ func TestHTMLEndpointsAreStrictlyValid(t *testing.T) {
 				"/pipelines/",
 				"/pipelines/" + pipeline.ID + "/",
 				"/pipelines/" + pipeline.ID + "/source/",
+				"/pipelines/" + pipeline.ID + "/memory/",
+				"/pipelines/" + pipeline.ID + "/versions/",
+				"/pipelines/" + pipeline.ID + "/versions/1/diff/",
 				"/runs/" + run.ID + "/tasks",
 				"/runs/" + run.ID + "/graph",
 			}
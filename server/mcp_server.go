@@ -52,7 +52,7 @@ func buildMCPServer(store storage.Driver) *mcp.Server {
 	}
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "list_run_tasks",
-		Description: "List all tasks for a pipeline run. Returns each task's path, status, type (task/agent/pipeline), typed log output, elapsed time, and other details. Use this to identify which step failed and why.",
+		Description: "List all tasks for a pipeline run. Returns each task's path, status, type (task/agent/pipeline), elapsed time, and other details. Use this to identify which step failed, then get_run_task for its log output.",
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input ListRunTasksInput) (*mcp.CallToolResult, any, error) {
 		fields := []string{"status", "elapsed", "started_at", "type", "text", "tokensUsed", "duration", "logs", "dependsOn"}
 		prefix := fmt.Sprintf("/pipeline/%s/", input.RunID)
@@ -74,12 +74,14 @@ func buildMCPServer(store storage.Driver) *mcp.Server {
 
 	// Tool: get_run_task
 	type GetRunTaskInput struct {
-		RunID string `json:"run_id" jsonschema:"The run ID containing the task"`
-		Path  string `json:"path"   jsonschema:"Task path, either absolute (/pipeline/<run>/...) or relative to the run prefix"`
+		RunID     string `json:"run_id"               jsonschema:"The run ID containing the task"`
+		Path      string `json:"path"                 jsonschema:"Task path, either absolute (/pipeline/<run>/...) or relative to the run prefix"`
+		LogOffset *int   `json:"log_offset,omitempty" jsonschema:"Sequence number of the first log chunk to return (default 0)"`
+		LogLimit  *int   `json:"log_limit,omitempty"  jsonschema:"Maximum number of log chunks to return (default 100)"`
 	}
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "get_run_task",
-		Description: "Get a single task payload for a run. Returns full stored payload fields (for example: logs, usage, audit_log).",
+		Description: "Get a single task payload for a run. Returns full stored payload fields (for example: usage, audit_log) and a page of its log chunks under logs. Page through long logs with log_offset and log_limit.",
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input GetRunTaskInput) (*mcp.CallToolResult, any, error) {
 		if input.Path == "" {
 			return nil, nil, fmt.Errorf("path is required")
@@ -100,6 +102,26 @@ func buildMCPServer(store storage.Driver) *mcp.Server {
 			return nil, nil, fmt.Errorf("could not get task: %w", err)
 		}
 
+		logOffset := 0
+		if input.LogOffset != nil && *input.LogOffset > 0 {
+			logOffset = *input.LogOffset
+		}
+
+		logLimit := 100
+		if input.LogLimit != nil && *input.LogLimit > 0 {
+			logLimit = *input.LogLimit
+		}
+
+		chunks, err := store.GetLogs(ctx, lookupPath, logOffset, logLimit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not get task logs: %w", err)
+		}
+
+		// Tasks stored before logs were kept in chunks have them in the payload.
+		if len(chunks) > 0 || logOffset > 0 {
+			payload["logs"] = chunks
+		}
+
 		result := []storage.Result{{
 			Path:    lookupPath,
 			Payload: payload,
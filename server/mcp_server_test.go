@@ -185,6 +185,17 @@ func TestMCPGetRunTask(t *testing.T) {
 	})
 	assert.Expect(err).NotTo(HaveOccurred())
 
+	logsPath := "/pipeline/" + run.ID + "/tasks/0-build"
+	err = store.Set(ctx, logsPath, map[string]any{"status": "success"})
+	assert.Expect(err).NotTo(HaveOccurred())
+
+	err = store.AppendLogs(ctx, logsPath, []storage.LogChunk{
+		{Stream: "stdout", Content: "compiling\n"},
+		{Stream: "stderr", Content: "warning: unused variable\n"},
+		{Stream: "stdout", Content: "done\n"},
+	})
+	assert.Expect(err).NotTo(HaveOccurred())
+
 	session := setupMCPSession(t, store)
 
 	t.Run("returns full payload for absolute path", func(t *testing.T) {
@@ -224,6 +235,36 @@ func TestMCPGetRunTask(t *testing.T) {
 		assert.Expect(result.IsError).To(BeFalse())
 	})
 
+	t.Run("returns a page of log chunks", func(t *testing.T) {
+		t.Parallel()
+		assert := NewWithT(t)
+
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{
+			Name: "get_run_task",
+			Arguments: map[string]any{
+				"run_id":     run.ID,
+				"path":       "tasks/0-build",
+				"log_offset": 1,
+				"log_limit":  1,
+			},
+		})
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(result.IsError).To(BeFalse())
+
+		text := result.Content[0].(*mcp.TextContent).Text
+		var got []struct {
+			Payload struct {
+				Logs []storage.LogChunk `json:"logs"`
+			} `json:"payload"`
+		}
+		assert.Expect(json.Unmarshal([]byte(text), &got)).NotTo(HaveOccurred())
+		assert.Expect(got).To(HaveLen(1))
+		assert.Expect(got[0].Payload.Logs).To(HaveLen(1))
+		assert.Expect(got[0].Payload.Logs[0].Sequence).To(Equal(1))
+		assert.Expect(got[0].Payload.Logs[0].Stream).To(Equal("stderr"))
+		assert.Expect(got[0].Payload.Logs[0].Content).To(Equal("warning: unused variable\n"))
+	})
+
 	t.Run("rejects task path outside run scope", func(t *testing.T) {
 		t.Parallel()
 		assert := NewWithT(t)
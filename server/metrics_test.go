@@ -102,6 +102,40 @@ func TestMetricsDashboard(t *testing.T) {
 				assert.Expect(body).To(ContainSubstring("something went wrong"))
 			})
 
+			t.Run("GET /metrics/ shows each pipeline's agent cost this month", func(t *testing.T) {
+				t.Parallel()
+				assert := NewWithT(t)
+
+				ctx := context.Background()
+				client := newMetricsTestClient(t, init)
+
+				budgeted, err := client.SavePipeline(ctx, "pipeline-budgeted", "export {};", "native://", "js")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.UpdatePipelineMonthlyBudget(ctx, budgeted.ID, 20)).To(Succeed())
+
+				unbudgeted, err := client.SavePipeline(ctx, "pipeline-unbudgeted", "export {};", "native://", "js")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				run, err := client.SaveRun(ctx, budgeted.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.AddRunCost(ctx, run.ID, 1.5)).To(Succeed())
+
+				run, err = client.SaveRun(ctx, unbudgeted.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(client.AddRunCost(ctx, run.ID, 0.25)).To(Succeed())
+
+				router := newRouterWithSecrets(t, client, server.RouterOptions{})
+				req := httptest.NewRequest(http.MethodGet, "/metrics/", http.NoBody)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				body := rec.Body.String()
+				assert.Expect(body).To(ContainSubstring("Cost (Month)"))
+				assert.Expect(body).To(ContainSubstring("$1.50 / $20.00"))
+				assert.Expect(body).To(ContainSubstring("$0.25"))
+			})
+
 			t.Run("GET /metrics/content returns partial for HTMX", func(t *testing.T) {
 				t.Parallel()
 				assert := NewWithT(t)
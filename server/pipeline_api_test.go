@@ -295,6 +295,40 @@ func TestPipelineAPI(t *testing.T) {
 				assert.Expect(updated.Content).To(Equal("content-v2"))
 			})
 
+			t.Run("PUT /api/pipelines/:name rejects vars without a secrets backend", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = buildFile.Close() }()
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = client.Close() }()
+
+				router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				body := map[string]any{
+					"content":    "export { pipeline };",
+					"driver_dsn": "docker://",
+					"vars":       map[string]any{"region": "us-east-1"},
+				}
+				jsonBody, _ := json.Marshal(body)
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/test-pipeline", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				assert.Expect(mustJSONErrorText(t, rec)).To(ContainSubstring("secrets backend is not configured"))
+
+				_, err = client.GetPipelineByName(context.Background(), "test-pipeline")
+				assert.Expect(err).To(MatchError(storage.ErrNotFound))
+			})
+
 			t.Run("PUT /api/pipelines/:name missing existing secret key does not persist content update", func(t *testing.T) {
 				t.Parallel()
 				assert := NewGomegaWithT(t)
@@ -0,0 +1,206 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jtarchie/pocketci/secrets"
+	_ "github.com/jtarchie/pocketci/secrets/sqlite"
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestPipelineVersions(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setup := func(t *testing.T) (storage.Driver, secrets.Manager, *server.Router) {
+				t.Helper()
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				if err != nil {
+					t.Fatalf("could not create build file: %v", err)
+				}
+
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				if err != nil {
+					t.Fatalf("could not create storage: %v", err)
+				}
+
+				secretsMgr, err := secrets.GetFromDSN("sqlite://:memory:?key=test-key", slog.Default())
+				if err != nil {
+					t.Fatalf("could not create secrets manager: %v", err)
+				}
+
+				t.Cleanup(func() { _ = secretsMgr.Close() })
+
+				router := newRouterWithSecrets(t, client, server.RouterOptions{SecretsManager: secretsMgr})
+
+				t.Cleanup(func() {
+					router.WaitForExecutions()
+					_ = client.Close()
+				})
+
+				return client, secretsMgr, router
+			}
+
+			setPipeline := func(t *testing.T, router *server.Router, content, driverDSN string) map[string]any {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				jsonBody, _ := json.Marshal(map[string]string{
+					"content":    content,
+					"driver_dsn": driverDSN,
+				})
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/versioned", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+
+				var resp map[string]any
+				assert.Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+
+				return resp
+			}
+
+			t.Run("setting a pipeline saves a new version each time", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				_, _, router := setup(t)
+
+				first := setPipeline(t, router, "export const pipeline = async () => { 1 };", "docker://")
+				assert.Expect(first["version"]).To(BeEquivalentTo(1))
+
+				second := setPipeline(t, router, "export const pipeline = async () => { 2 };", "native://")
+				assert.Expect(second["version"]).To(BeEquivalentTo(2))
+
+				pipelineID, _ := second["id"].(string)
+
+				req := httptest.NewRequest(http.MethodGet, "/api/pipelines/"+pipelineID+"/versions", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				var versions storage.PaginationResult[storage.PipelineVersion]
+				assert.Expect(json.Unmarshal(rec.Body.Bytes(), &versions)).To(Succeed())
+				assert.Expect(versions.Items).To(HaveLen(2))
+				assert.Expect(versions.Items[0].Version).To(Equal(2))
+				assert.Expect(versions.Items[0].DriverDSN).To(Equal("native"))
+				assert.Expect(versions.Items[1].Content).To(Equal("export const pipeline = async () => { 1 };"))
+
+				req = httptest.NewRequest(http.MethodGet, "/api/pipelines/"+pipelineID+"/versions/3", nil)
+				rec = httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+
+			t.Run("the diff page shows what changed between versions", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				_, _, router := setup(t)
+
+				setPipeline(t, router, "const a = 1;\nconst b = 2;\n", "docker://")
+				resp := setPipeline(t, router, "const a = 1;\nconst b = 3;\n", "docker://")
+
+				pipelineID, _ := resp["id"].(string)
+
+				req := httptest.NewRequest(http.MethodGet, "/pipelines/"+pipelineID+"/versions/2/diff/", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("-const b = 2;"))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("+const b = 3;"))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("Changes since version 1"))
+			})
+
+			t.Run("rolling back saves the old version as the newest", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, secretsMgr, router := setup(t)
+
+				setPipeline(t, router, "export const pipeline = async () => { 1 };", "docker://")
+				resp := setPipeline(t, router, "export const pipeline = async () => { 2 };", "native://")
+
+				pipelineID, _ := resp["id"].(string)
+
+				req := httptest.NewRequest(http.MethodPost, "/api/pipelines/"+pipelineID+"/versions/1/rollback", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+
+				var pipeline map[string]any
+				assert.Expect(json.Unmarshal(rec.Body.Bytes(), &pipeline)).To(Succeed())
+				assert.Expect(pipeline["version"]).To(BeEquivalentTo(3))
+				assert.Expect(pipeline["content"]).To(Equal("export const pipeline = async () => { 1 };"))
+
+				stored, err := client.GetPipeline(context.Background(), pipelineID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(stored.DriverDSN).To(Equal("docker"))
+
+				driverDSN, err := secretsMgr.Get(context.Background(), secrets.PipelineScope(pipelineID), "driver_dsn")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(driverDSN).To(Equal("docker"))
+
+				version, err := client.GetPipelineVersion(context.Background(), pipelineID, 3)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(version.Content).To(Equal("export const pipeline = async () => { 1 };"))
+
+				req = httptest.NewRequest(http.MethodPost, "/api/pipelines/"+pipelineID+"/versions/9/rollback", nil)
+				rec = httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+
+			t.Run("a run records the version it executed", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, _, router := setup(t)
+
+				setPipeline(t, router, "export const pipeline = async () => {};", "native://")
+				resp := setPipeline(t, router, "export const pipeline = async () => { };", "native://")
+
+				pipelineID, _ := resp["id"].(string)
+
+				req := httptest.NewRequest(http.MethodPost, "/api/pipelines/"+pipelineID+"/trigger", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusAccepted))
+
+				var triggered map[string]any
+				assert.Expect(json.Unmarshal(rec.Body.Bytes(), &triggered)).To(Succeed())
+
+				runID, _ := triggered["run_id"].(string)
+
+				run, err := client.GetRun(context.Background(), runID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(run.PipelineVersion).To(Equal(2))
+			})
+		})
+	})
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jtarchie/pocketci/backwards"
+	"github.com/jtarchie/pocketci/resources"
+	"github.com/jtarchie/pocketci/runtime/runner"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// ResourceChecker periodically runs Check for the native resources of stored
+// YAML pipelines. New versions are recorded in the resource version store and
+// trigger the jobs that get the resource with `trigger: true`.
+//
+// A resource is checked when it sets check_every, or when a job triggers on it.
+// Resources whose type is not a native resource are skipped.
+type ResourceChecker struct {
+	store       storage.Driver
+	execService *ExecutionService
+	logger      *slog.Logger
+
+	// DefaultInterval is used for resources without check_every.
+	DefaultInterval time.Duration
+	// PollInterval is how often the checker looks for resources that are due.
+	PollInterval time.Duration
+	// Jitter is the maximum random delay added to each check, as a fraction of its interval.
+	Jitter float64
+	// MaxBackoff caps the delay between checks of a resource that keeps failing.
+	MaxBackoff time.Duration
+
+	mu      sync.Mutex
+	states  map[string]*resourceCheckState
+	pending map[string]map[string]bool
+}
+
+type resourceCheckState struct {
+	nextCheck time.Time
+	failures  int
+}
+
+// NewResourceChecker creates a resource checker with default intervals.
+func NewResourceChecker(store storage.Driver, execService *ExecutionService, logger *slog.Logger) *ResourceChecker {
+	return &ResourceChecker{
+		store:           store,
+		execService:     execService,
+		logger:          logger.WithGroup("resource.checker"),
+		DefaultInterval: time.Minute,
+		PollInterval:    10 * time.Second,
+		Jitter:          0.1,
+		MaxBackoff:      time.Hour,
+		states:          map[string]*resourceCheckState{},
+		pending:         map[string]map[string]bool{},
+	}
+}
+
+// Start runs the checker in the background until ctx is cancelled.
+func (c *ResourceChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			c.CheckOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckOnce checks every resource that is due and triggers pipelines with new versions.
+func (c *ResourceChecker) CheckOnce(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[string]bool{}
+
+	for page := 1; ; page++ {
+		result, err := c.store.SearchPipelines(ctx, "", page, 100)
+		if err != nil {
+			c.logger.Error("pipelines.list.failed", "error", err)
+
+			return
+		}
+
+		for i := range result.Items {
+			pipeline := &result.Items[i]
+			if pipeline.ContentType != storage.ContentTypeYAML {
+				continue
+			}
+
+			c.checkPipeline(ctx, pipeline, seen)
+		}
+
+		if !result.HasNext {
+			break
+		}
+	}
+
+	// forget resources that were removed from their pipeline
+	for key := range c.states {
+		if !seen[key] {
+			delete(c.states, key)
+		}
+	}
+
+	c.dispatch(ctx)
+}
+
+func (c *ResourceChecker) checkPipeline(ctx context.Context, pipeline *storage.Pipeline, seen map[string]bool) {
+	logger := c.logger.With("pipeline_id", pipeline.ID, "pipeline_name", pipeline.Name)
+
+	config, err := c.execService.pipelineConfig(ctx, pipeline)
+	if err != nil {
+		logger.Debug("pipeline.parse.failed", "error", err)
+
+		return
+	}
+
+	now := time.Now()
+
+	for _, resource := range config.Resources {
+		jobs := config.TriggeringJobs(resource.Name)
+		if resource.CheckEvery == "" && len(jobs) == 0 {
+			continue
+		}
+
+		if !resources.IsNative(resource.Type) {
+			continue
+		}
+
+		interval, err := resource.CheckInterval(c.DefaultInterval)
+		if err != nil || interval == 0 {
+			continue
+		}
+
+		key := pipeline.ID + "/" + resource.Name
+		seen[key] = true
+
+		state, ok := c.states[key]
+		if !ok {
+			state = &resourceCheckState{nextCheck: now.Add(c.jitter(interval))}
+			c.states[key] = state
+		}
+
+		if now.Before(state.nextCheck) {
+			continue
+		}
+
+		resourceLogger := logger.With("resource", resource.Name, "type", resource.Type)
+
+		found, err := c.checkResource(ctx, pipeline, resource, key)
+		if err != nil {
+			state.failures++
+			backoff := c.backoff(interval, state.failures)
+			state.nextCheck = now.Add(backoff)
+
+			resourceLogger.Warn("resource.check.failed", "error", err, "failures", state.failures, "retry_in", backoff)
+
+			continue
+		}
+
+		state.failures = 0
+		state.nextCheck = now.Add(interval + c.jitter(interval))
+
+		if found && len(jobs) > 0 {
+			resourceLogger.Info("resource.check.new_versions", "jobs", jobs)
+
+			if c.pending[pipeline.ID] == nil {
+				c.pending[pipeline.ID] = map[string]bool{}
+			}
+
+			for _, job := range jobs {
+				c.pending[pipeline.ID][job] = true
+			}
+		}
+	}
+}
+
+// checkResource runs Check from the latest recorded version and records the result.
+// It returns true when at least one previously unknown version was recorded.
+func (c *ResourceChecker) checkResource(ctx context.Context, pipeline *storage.Pipeline, resource backwards.Resource, scopedName string) (bool, error) {
+	latest, err := latestResourceVersion(ctx, c.store, scopedName)
+	if err != nil {
+		return false, err
+	}
+
+	resourceRunner := runner.NewResourceRunner(ctx, c.logger)
+	if IsFeatureEnabled(FeatureSecrets, c.execService.AllowedFeatures) && c.execService.SecretsManager != nil {
+		resourceRunner.SetSecretsManager(c.execService.SecretsManager, pipeline.ID)
+	}
+
+	resourceRunner.SetStorage(c.store, pipeline.ID)
+
+	result, err := resourceRunner.Check(runner.ResourceCheckInput{
+		Type:    resource.Type,
+		Source:  maps.Clone(resource.Source),
+		Version: latest,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+
+	for _, version := range result.Versions {
+		saved, err := saveResourceVersion(ctx, c.store, scopedName, version, "")
+		if err != nil {
+			return found, err
+		}
+
+		found = found || saved
+	}
+
+	return found, nil
+}
+
+// dispatch triggers pipelines with pending jobs while capacity allows.
+// Pipelines that cannot start yet stay pending for the next pass.
+func (c *ResourceChecker) dispatch(ctx context.Context) {
+	for pipelineID, pendingJobs := range c.pending {
+		if !c.execService.CanExecute() {
+			c.logger.Debug("resource.trigger.deferred", "reason", "max_in_flight", "pending", len(c.pending))
+
+			return
+		}
+
+		pipeline, err := c.store.GetPipeline(ctx, pipelineID)
+		if err != nil {
+			c.logger.Error("resource.trigger.get_pipeline_failed", "pipeline_id", pipelineID, "error", err)
+			delete(c.pending, pipelineID)
+
+			continue
+		}
+
+		jobs := slices.Sorted(maps.Keys(pendingJobs))
+
+		run, err := c.execService.TriggerPipelineWithOptions(ctx, pipeline, TriggerOptions{
+			TriggeredBy: "resource",
+			Jobs:        jobs,
+		})
+		if err != nil {
+			c.logger.Error("resource.trigger.failed", "pipeline_id", pipelineID, "error", err)
+
+			continue
+		}
+
+		c.logger.Info("resource.trigger", "pipeline_id", pipelineID, "run_id", run.ID, "jobs", jobs)
+		delete(c.pending, pipelineID)
+	}
+}
+
+func (c *ResourceChecker) jitter(interval time.Duration) time.Duration {
+	maxJitter := int64(float64(interval) * c.Jitter)
+	if maxJitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int64N(maxJitter)) //nolint: gosec
+}
+
+func (c *ResourceChecker) backoff(interval time.Duration, failures int) time.Duration {
+	backoff := interval
+	for range failures {
+		backoff *= 2
+		if backoff >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+
+	return backoff
+}
@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jtarchie/pocketci/orchestra/native"
+	_ "github.com/jtarchie/pocketci/resources/mock"
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+const resourceTriggeredPipeline = `
+resource_types:
+  - name: mock
+    type: registry-image
+    source:
+      repository: concourse/mock-resource
+
+resources:
+  - name: counter
+    type: mock
+    check_every: 1ms
+    source:
+      force_version: "1"
+
+jobs:
+  - name: triggered
+    plan:
+      - get: counter
+        trigger: true
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["triggered"]
+  - name: untriggered
+    plan:
+      - get: counter
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["untriggered"]
+`
+
+func TestResourceChecker(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("records new versions and triggers jobs with trigger: true", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = buildFile.Close() }()
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = client.Close() }()
+
+				ctx := context.Background()
+
+				pipeline, err := client.SavePipeline(ctx, "resource-checker", resourceTriggeredPipeline, "native://", storage.ContentTypeYAML)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				router := newStrictSecretRouter(t, client, server.RouterOptions{MaxInFlight: 5})
+				execService := router.ExecutionService()
+
+				checker := server.NewResourceChecker(client, execService, slog.Default())
+				checker.Jitter = 0
+
+				checker.CheckOnce(ctx)
+				execService.Wait()
+
+				meta, err := client.Get(ctx, "/rv/"+pipeline.ID+"/counter/meta")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(meta["count"]).To(BeEquivalentTo(1))
+
+				runs, err := client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+				assert.Expect(runs.Items[0].Status).To(Equal(storage.RunStatusSuccess))
+
+				jobs, err := client.GetAll(ctx, "/pipeline/"+runs.Items[0].ID+"/jobs", []string{"status"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				statuses := map[string]any{}
+				for _, job := range jobs {
+					statuses[job.Path] = job.Payload["status"]
+				}
+
+				assert.Expect(statuses).To(HaveKeyWithValue(HaveSuffix("/jobs/triggered"), "success"))
+				assert.Expect(statuses).To(HaveKeyWithValue(HaveSuffix("/jobs/untriggered"), "skipped"))
+
+				// the same version does not trigger another run
+				time.Sleep(5 * time.Millisecond)
+				checker.CheckOnce(ctx)
+				execService.Wait()
+
+				runs, err = client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+			})
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	"github.com/jtarchie/pocketci/resources"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// The functions below mirror backwards/src/resource_store.ts so that versions
+// recorded by the server are visible to get steps in pipeline runs, and
+// versions fetched by pipeline runs are visible to the server.
+
+func resourceVersionMetaKey(name string) string {
+	return "/rv/" + name + "/meta"
+}
+
+func resourceVersionKey(name string, index int) string {
+	return fmt.Sprintf("/rv/%s/versions/%010d", name, index)
+}
+
+func resourceVersionDedupKey(name, versionJSON string) string {
+	return "/rv/" + name + "/v/" + resourceVersionHash(versionJSON)
+}
+
+// resourceVersionHash matches hashString in resource_store.ts, which operates
+// on UTF-16 code units with 32-bit integer overflow.
+func resourceVersionHash(value string) string {
+	var hash int32 = 5381
+
+	for _, unit := range utf16.Encode([]rune(value)) {
+		hash = hash*31 ^ int32(unit)
+	}
+
+	return fmt.Sprintf("%x", uint32(hash))
+}
+
+func resourceVersionCount(ctx context.Context, store storage.Driver, name string) (int, error) {
+	meta, err := store.Get(ctx, resourceVersionMetaKey(name))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("could not get resource version meta: %w", err)
+	}
+
+	count, _ := meta["count"].(float64)
+
+	return int(count), nil
+}
+
+// latestResourceVersion returns the most recently recorded version, or nil when none exist.
+func latestResourceVersion(ctx context.Context, store storage.Driver, name string) (resources.Version, error) {
+	count, err := resourceVersionCount(ctx, store, name)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	payload, err := store.Get(ctx, resourceVersionKey(name, count-1))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("could not get resource version: %w", err)
+	}
+
+	raw, _ := payload["version"].(map[string]any)
+	version := resources.Version{}
+
+	for key, value := range raw {
+		if str, ok := value.(string); ok {
+			version[key] = str
+		}
+	}
+
+	return version, nil
+}
+
+// saveResourceVersion records a version unless it is already known.
+// It returns true when the version was not previously recorded.
+func saveResourceVersion(ctx context.Context, store storage.Driver, name string, version resources.Version, jobName string) (bool, error) {
+	contents, err := json.Marshal(version)
+	if err != nil {
+		return false, fmt.Errorf("could not marshal version: %w", err)
+	}
+
+	versionJSON := string(contents)
+	dedupKey := resourceVersionDedupKey(name, versionJSON)
+
+	entry, err := store.Get(ctx, dedupKey)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, fmt.Errorf("could not get resource version: %w", err)
+	}
+
+	if err == nil && entry["version_json"] == versionJSON {
+		return false, nil
+	}
+
+	count, err := resourceVersionCount(ctx, store, name)
+	if err != nil {
+		return false, err
+	}
+
+	err = store.Set(ctx, resourceVersionKey(name, count), map[string]any{
+		"version":    version,
+		"job_name":   jobName,
+		"fetched_at": time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not save resource version: %w", err)
+	}
+
+	err = store.Set(ctx, dedupKey, map[string]any{"index": count, "version_json": versionJSON})
+	if err != nil {
+		return false, fmt.Errorf("could not save resource version index: %w", err)
+	}
+
+	err = store.Set(ctx, resourceVersionMetaKey(name), map[string]any{"count": count + 1})
+	if err != nil {
+		return false, fmt.Errorf("could not save resource version meta: %w", err)
+	}
+
+	return true, nil
+}
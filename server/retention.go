@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// Reaper deletes old runs and their logs. A pipeline's retention
+// (storage.Pipeline.Retention) decides how many of its runs are kept, falling
+// back to the server's Default. Jobs of YAML pipelines can declare
+// `build_log_retention` to keep fewer of their own logs than the pipeline keeps
+// runs.
+//
+// Queued and running runs are never reaped, nor are failed runs that can
+// still be resumed.
+type Reaper struct {
+	store       storage.Driver
+	execService *ExecutionService
+	logger      *slog.Logger
+
+	// Interval is how often old runs are reaped.
+	Interval time.Duration
+	// Default is the retention of pipelines that do not set their own.
+	Default storage.PipelineRetention
+	// VacuumInterval is how often storage that supports it is vacuumed after
+	// reaping. Zero disables vacuuming.
+	VacuumInterval time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	lastVacuum time.Time
+}
+
+// NewReaper creates a reaper that runs hourly, keeps every run by default,
+// and vacuums daily.
+func NewReaper(store storage.Driver, execService *ExecutionService, logger *slog.Logger) *Reaper {
+	return &Reaper{
+		store:          store,
+		execService:    execService,
+		logger:         logger.WithGroup("retention"),
+		Interval:       time.Hour,
+		VacuumInterval: 24 * time.Hour,
+		Now:            time.Now,
+	}
+}
+
+// Start runs the reaper in the background until ctx is cancelled.
+func (r *Reaper) Start(ctx context.Context) {
+	r.lastVacuum = r.Now()
+
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			r.Reap(ctx)
+			r.Compact(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Reap deletes the runs and job logs of every pipeline that are past their retention.
+func (r *Reaper) Reap(ctx context.Context) {
+	now := r.Now().UTC()
+
+	for page := 1; ; page++ {
+		result, err := r.store.SearchPipelines(ctx, "", page, 100)
+		if err != nil {
+			r.logger.Error("pipelines.list.failed", "error", err)
+
+			return
+		}
+
+		for i := range result.Items {
+			r.reapPipeline(ctx, &result.Items[i], now)
+		}
+
+		if !result.HasNext {
+			break
+		}
+	}
+}
+
+// Compact moves the write-ahead log into the database and, once every
+// VacuumInterval, vacuums it. Storage that does not need compacting is skipped.
+func (r *Reaper) Compact(ctx context.Context) {
+	compactor, ok := r.store.(storage.Compactor)
+	if !ok {
+		return
+	}
+
+	err := compactor.Checkpoint(ctx)
+	if err != nil {
+		r.logger.Error("storage.checkpoint.failed", "error", err)
+	}
+
+	now := r.Now()
+	if r.VacuumInterval <= 0 || now.Sub(r.lastVacuum) < r.VacuumInterval {
+		return
+	}
+
+	r.lastVacuum = now
+
+	started := time.Now()
+
+	err = compactor.Vacuum(ctx)
+	if err != nil {
+		r.logger.Error("storage.vacuum.failed", "error", err)
+
+		return
+	}
+
+	r.logger.Info("storage.vacuum", "duration", time.Since(started))
+}
+
+func (r *Reaper) reapPipeline(ctx context.Context, pipeline *storage.Pipeline, now time.Time) {
+	logger := r.logger.With("pipeline_id", pipeline.ID, "pipeline_name", pipeline.Name)
+
+	retention := r.Default
+	if pipeline.Retention != nil {
+		retention = *pipeline.Retention
+	}
+
+	jobs := r.jobRetentions(ctx, logger, pipeline)
+
+	if isUnlimited(retention) && len(jobs) == 0 {
+		return
+	}
+
+	runs, err := r.listRuns(ctx, pipeline.ID)
+	if err != nil {
+		logger.Error("runs.list.failed", "error", err)
+
+		return
+	}
+
+	builds := map[string]int{}
+	reaped := 0
+
+	// Runs are listed newest first, so a run's index is the number of newer runs.
+	for index, run := range runs {
+		if r.isActive(ctx, logger, &run) {
+			continue
+		}
+
+		if isExpired(retention, index, run.CreatedAt, now) {
+			err := r.deleteRun(ctx, run.ID)
+			if err != nil {
+				logger.Error("run.reap.failed", "run_id", run.ID, "error", err)
+
+				continue
+			}
+
+			reaped++
+
+			continue
+		}
+
+		for job, jobRetention := range jobs {
+			key := "/pipeline/" + run.ID + "/jobs/" + job
+
+			_, err := r.store.Get(ctx, key)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+
+			if err != nil {
+				logger.Error("job.logs.get_failed", "run_id", run.ID, "job", job, "error", err)
+
+				continue
+			}
+
+			if isExpired(jobRetention, builds[job], run.CreatedAt, now) {
+				err := r.store.Delete(ctx, key)
+				if err != nil {
+					logger.Error("job.logs.reap_failed", "run_id", run.ID, "job", job, "error", err)
+				}
+
+				continue
+			}
+
+			builds[job]++
+		}
+	}
+
+	if reaped > 0 {
+		logger.Info("runs.reaped", "count", reaped, "runs", retention.Runs, "days", retention.Days)
+	}
+}
+
+// listRuns returns all of a pipeline's runs, newest first. They are listed
+// before any are deleted so that deleting does not shift the pages.
+func (r *Reaper) listRuns(ctx context.Context, pipelineID string) ([]storage.PipelineRun, error) {
+	var runs []storage.PipelineRun
+
+	for page := 1; ; page++ {
+		result, err := r.store.SearchRunsByPipeline(ctx, pipelineID, "", page, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, result.Items...)
+
+		if !result.HasNext {
+			return runs, nil
+		}
+	}
+}
+
+// isActive reports whether a run is queued, running, or failed with resume
+// state saved, any of which means it may still write to its logs. A failed
+// run can be resumed whether or not its pipeline enables resume, so only its
+// state decides.
+func (r *Reaper) isActive(ctx context.Context, logger *slog.Logger, run *storage.PipelineRun) bool {
+	switch run.Status {
+	case storage.RunStatusQueued, storage.RunStatusRunning:
+		return true
+	case storage.RunStatusFailed:
+		_, err := r.store.Get(ctx, "_resume/state/"+run.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return false
+		}
+
+		if err != nil {
+			logger.Error("run.resume_state.get_failed", "run_id", run.ID, "error", err)
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+// deleteRun removes a run, its logs, and any resume state it left behind.
+func (r *Reaper) deleteRun(ctx context.Context, runID string) error {
+	err := r.store.DeleteRun(ctx, runID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	for _, prefix := range []string{"_resume/state/", "_resume/agent/"} {
+		err := r.store.Delete(ctx, prefix+runID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jobRetentions returns the build_log_retention of each job of a YAML
+// pipeline, with its ((vars)) interpolated.
+func (r *Reaper) jobRetentions(ctx context.Context, logger *slog.Logger, pipeline *storage.Pipeline) map[string]storage.PipelineRetention {
+	if pipeline.ContentType != storage.ContentTypeYAML {
+		return nil
+	}
+
+	config, err := r.execService.pipelineConfig(ctx, pipeline)
+	if err != nil {
+		logger.Debug("pipeline.parse.failed", "error", err)
+
+		return nil
+	}
+
+	jobs := map[string]storage.PipelineRetention{}
+
+	for _, job := range config.Jobs {
+		if job.BuildLogRetention == nil {
+			continue
+		}
+
+		retention := storage.PipelineRetention{
+			Runs: job.BuildLogRetention.Builds,
+			Days: job.BuildLogRetention.Days,
+		}
+
+		if !isUnlimited(retention) {
+			jobs[job.Name] = retention
+		}
+	}
+
+	return jobs
+}
+
+func isUnlimited(retention storage.PipelineRetention) bool {
+	return retention.Runs <= 0 && retention.Days <= 0
+}
+
+// isExpired reports whether a run with newer runs before it, created at
+// createdAt, is past retention.
+func isExpired(retention storage.PipelineRetention, newer int, createdAt, now time.Time) bool {
+	if retention.Runs > 0 && newer >= retention.Runs {
+		return true
+	}
+
+	return retention.Days > 0 && createdAt.Before(now.AddDate(0, 0, -retention.Days))
+}
@@ -0,0 +1,270 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+const retainedJobPipeline = `
+jobs:
+  - name: build
+    build_log_retention:
+      builds: 1
+    plan:
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["build"]
+`
+
+func TestReaper(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setup := func(t *testing.T, content, contentType string) (storage.Driver, *storage.Pipeline, *server.Reaper) {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = client.Close() })
+
+				pipeline, err := client.SavePipeline(context.Background(), "retained", content, "native://", contentType)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				execService := server.NewExecutionService(client, slog.Default(), 1, nil)
+
+				return client, pipeline, server.NewReaper(client, execService, slog.Default())
+			}
+
+			// saveRuns creates runs oldest first, each with a job record.
+			saveRuns := func(t *testing.T, client storage.Driver, pipelineID string, statuses ...storage.RunStatus) []string {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				ids := []string{}
+
+				for _, status := range statuses {
+					run, err := client.SaveRun(context.Background(), pipelineID)
+					assert.Expect(err).NotTo(HaveOccurred())
+
+					if status != storage.RunStatusQueued {
+						err = client.UpdateRunStatus(context.Background(), run.ID, status, "")
+						assert.Expect(err).NotTo(HaveOccurred())
+					}
+
+					err = client.Set(context.Background(), "/pipeline/"+run.ID+"/jobs/build", map[string]any{"status": string(status)})
+					assert.Expect(err).NotTo(HaveOccurred())
+
+					ids = append(ids, run.ID)
+				}
+
+				return ids
+			}
+
+			exists := func(client storage.Driver, runID string) bool {
+				_, err := client.GetRun(context.Background(), runID)
+
+				return err == nil
+			}
+
+			hasJob := func(client storage.Driver, runID string) bool {
+				_, err := client.Get(context.Background(), "/pipeline/"+runID+"/jobs/build")
+
+				return err == nil
+			}
+
+			t.Run("keeps the newest runs of the pipeline's retention", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, reaper := setup(t, "export { pipeline };", storage.ContentTypeTypeScript)
+				reaper.Default = storage.PipelineRetention{Runs: 10}
+
+				err := client.UpdatePipelineRetention(context.Background(), pipeline.ID, &storage.PipelineRetention{Runs: 2})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				ids := saveRuns(t, client, pipeline.ID,
+					storage.RunStatusSuccess,
+					storage.RunStatusRunning,
+					storage.RunStatusFailed,
+					storage.RunStatusQueued,
+					storage.RunStatusSuccess,
+				)
+
+				err = client.Set(context.Background(), "_resume/state/"+ids[0], map[string]any{"run_id": ids[0]})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				reaper.Reap(context.Background())
+
+				assert.Expect(exists(client, ids[0])).To(BeFalse())
+				assert.Expect(hasJob(client, ids[0])).To(BeFalse())
+				assert.Expect(exists(client, ids[1])).To(BeTrue())
+				assert.Expect(exists(client, ids[2])).To(BeFalse())
+				assert.Expect(exists(client, ids[3])).To(BeTrue())
+				assert.Expect(exists(client, ids[4])).To(BeTrue())
+
+				_, err = client.Get(context.Background(), "_resume/state/"+ids[0])
+				assert.Expect(err).To(MatchError(storage.ErrNotFound))
+			})
+
+			t.Run("falls back to the server default", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, reaper := setup(t, "export { pipeline };", storage.ContentTypeTypeScript)
+
+				ids := saveRuns(t, client, pipeline.ID, storage.RunStatusSuccess, storage.RunStatusSuccess)
+
+				reaper.Reap(context.Background())
+				assert.Expect(exists(client, ids[0])).To(BeTrue())
+
+				reaper.Default = storage.PipelineRetention{Days: 7}
+				reaper.Now = func() time.Time { return time.Now().AddDate(0, 0, 8) }
+
+				reaper.Reap(context.Background())
+				assert.Expect(exists(client, ids[0])).To(BeFalse())
+				assert.Expect(exists(client, ids[1])).To(BeFalse())
+			})
+
+			t.Run("keeps failed runs that can be resumed", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				// Any failed run with saved state can be resumed, whether or not
+				// its pipeline enables resume.
+				client, pipeline, reaper := setup(t, "export { pipeline };", storage.ContentTypeTypeScript)
+				reaper.Default = storage.PipelineRetention{Runs: 1}
+
+				ids := saveRuns(t, client, pipeline.ID, storage.RunStatusFailed, storage.RunStatusFailed, storage.RunStatusSuccess)
+
+				err := client.Set(context.Background(), "_resume/state/"+ids[1], map[string]any{"run_id": ids[1]})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				reaper.Reap(context.Background())
+
+				assert.Expect(exists(client, ids[0])).To(BeFalse())
+				assert.Expect(exists(client, ids[1])).To(BeTrue())
+				assert.Expect(exists(client, ids[2])).To(BeTrue())
+			})
+
+			t.Run("PUT /api/pipelines/:name sets and clears retention", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, _, _ := setup(t, "export { pipeline };", storage.ContentTypeTypeScript)
+				router := newRouterWithSecrets(t, client, server.RouterOptions{})
+
+				put := func(retention map[string]any) *httptest.ResponseRecorder {
+					jsonBody, _ := json.Marshal(map[string]any{"content": "export { pipeline };", "driver_dsn": "native://", "retention": retention})
+
+					req := httptest.NewRequest(http.MethodPut, "/api/pipelines/retained", bytes.NewReader(jsonBody))
+					req.Header.Set("Content-Type", "application/json")
+					rec := httptest.NewRecorder()
+					router.ServeHTTP(rec, req)
+
+					return rec
+				}
+
+				rec := put(map[string]any{"runs": 20, "days": 30})
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				assert.Expect(rec.Body.String()).To(ContainSubstring(`"retention":{"runs":20,"days":30}`))
+
+				pipeline, err := client.GetPipelineByName(context.Background(), "retained")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(pipeline.Retention).To(Equal(&storage.PipelineRetention{Runs: 20, Days: 30}))
+
+				rec = put(map[string]any{})
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				pipeline, err = client.GetPipelineByName(context.Background(), "retained")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(pipeline.Retention).To(BeNil())
+
+				rec = put(map[string]any{"runs": -1})
+				assert.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				assert.Expect(rec.Body.String()).To(ContainSubstring("retention runs and days must not be negative"))
+			})
+
+			t.Run("deletes job logs past build_log_retention", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, reaper := setup(t, retainedJobPipeline, storage.ContentTypeYAML)
+
+				ids := saveRuns(t, client, pipeline.ID, storage.RunStatusSuccess, storage.RunStatusSuccess, storage.RunStatusRunning)
+
+				reaper.Reap(context.Background())
+
+				for _, id := range ids {
+					assert.Expect(exists(client, id)).To(BeTrue())
+				}
+
+				assert.Expect(hasJob(client, ids[0])).To(BeFalse())
+				assert.Expect(hasJob(client, ids[1])).To(BeTrue())
+				assert.Expect(hasJob(client, ids[2])).To(BeTrue())
+
+				reaper.Reap(context.Background())
+				assert.Expect(hasJob(client, ids[1])).To(BeTrue())
+			})
+
+			t.Run("reads build_log_retention set from the pipeline's vars", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, _, _ := setup(t, "export { pipeline };", storage.ContentTypeTypeScript)
+				router := newRouterWithSecrets(t, client, server.RouterOptions{})
+
+				content := strings.Replace(retainedJobPipeline, "builds: 1", "builds: ((keep_builds))", 1)
+				jsonBody, _ := json.Marshal(map[string]any{
+					"content":      content,
+					"content_type": storage.ContentTypeYAML,
+					"driver_dsn":   "native://",
+					"vars":         map[string]any{"keep_builds": 1},
+				})
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/retained", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				pipeline, err := client.GetPipelineByName(context.Background(), "retained")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				ids := saveRuns(t, client, pipeline.ID, storage.RunStatusSuccess, storage.RunStatusSuccess)
+
+				server.NewReaper(client, router.ExecutionService(), slog.Default()).Reap(context.Background())
+
+				assert.Expect(hasJob(client, ids[0])).To(BeFalse())
+				assert.Expect(hasJob(client, ids[1])).To(BeTrue())
+			})
+		})
+	})
+}
@@ -28,6 +28,26 @@ type RouterOptions struct {
 	FetchTimeout          time.Duration
 	FetchMaxResponseBytes int64
 	AuthConfig            *auth.Config
+	// ResourceCheckInterval is the default check_every for pipeline resources.
+	// Zero disables periodic resource checking.
+	ResourceCheckInterval time.Duration
+	// SchedulePollInterval is how often cron schedules are evaluated.
+	// Zero disables the scheduler.
+	SchedulePollInterval time.Duration
+	// ScheduleCatchUp is the default catch-up policy for missed schedules.
+	ScheduleCatchUp string
+	// RetainRuns is how many runs of each pipeline are kept by default.
+	// Zero keeps every run.
+	RetainRuns int
+	// RetainDays is how many days runs are kept by default. Zero keeps runs
+	// regardless of age.
+	RetainDays int
+	// RetentionInterval is how often runs past their retention are deleted.
+	// Zero disables retention.
+	RetentionInterval time.Duration
+	// VacuumInterval is how often storage is vacuumed after deleting runs.
+	// Zero disables vacuuming.
+	VacuumInterval time.Duration
 }
 
 // Router wraps echo.Echo and provides access to the execution service.
@@ -141,6 +161,31 @@ func NewRouter(logger *slog.Logger, store storage.Driver, opts RouterOptions) (*
 	// Recover orphaned runs from previous server instance
 	execService.RecoverOrphanedRuns(context.Background())
 
+	if opts.ResourceCheckInterval > 0 {
+		checker := NewResourceChecker(store, execService, logger)
+		checker.DefaultInterval = opts.ResourceCheckInterval
+		checker.Start(context.Background())
+	}
+
+	if opts.SchedulePollInterval > 0 {
+		scheduler := NewScheduler(store, execService, logger)
+		scheduler.PollInterval = opts.SchedulePollInterval
+
+		if opts.ScheduleCatchUp != "" {
+			scheduler.CatchUp = opts.ScheduleCatchUp
+		}
+
+		scheduler.Start(context.Background())
+	}
+
+	if opts.RetentionInterval > 0 {
+		reaper := NewReaper(store, execService, logger)
+		reaper.Interval = opts.RetentionInterval
+		reaper.VacuumInterval = opts.VacuumInterval
+		reaper.Default = storage.PipelineRetention{Runs: opts.RetainRuns, Days: opts.RetainDays}
+		reaper.Start(context.Background())
+	}
+
 	router.Use(middleware.RequestID())
 	router.Use(newSlogMiddleware(logger))
 	router.Use(middleware.Recover())
@@ -276,6 +321,8 @@ func registerRoutes(
 	(&APIRunsController{BaseController: base, allowedFeatures: allowedFeatures}).RegisterRoutes(api)
 	(&APIDriversController{allowedDrivers: allowedDrivers}).RegisterRoutes(api)
 	(&APIFeaturesController{allowedFeatures: allowedFeatures}).RegisterRoutes(api)
+	(&APIMemoryController{BaseController: base}).RegisterRoutes(api)
+	(&APIVersionsController{BaseController: base, allowedDrivers: allowedDrivers, allowedFeatures: allowedFeatures, secretsMgr: secretsMgr}).RegisterRoutes(api)
 
 	// Webhooks registered on the main router (no auth group, before API group)
 	(&APIWebhooksController{BaseController: base, allowedFeatures: allowedFeatures, webhookTimeout: webhookTimeout, logger: logger.WithGroup("webhook"), secretsMgr: secretsMgr}).RegisterRoutes(router)
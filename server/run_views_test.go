@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -675,6 +676,96 @@ func TestRunViews(t *testing.T) {
 				assert.Expect(hasSelectorWithText(doc, "div[id^='terminal-']", "lint failed: missing semicolon")).To(BeTrue())
 			})
 
+			t.Run("GET /runs/:id/tasks renders task log chunks", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = buildFile.Close() }()
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = client.Close() }()
+
+				pipeline, err := client.SavePipeline(context.Background(), "log-chunks-pipeline", "export const pipeline = async () => {};", "docker://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				run, err := client.SaveRun(context.Background(), pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				taskPath := "/pipeline/" + run.ID + "/tasks/0-test"
+				err = client.Set(context.Background(), taskPath, map[string]any{"status": "failure"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(context.Background(), taskPath, []storage.LogChunk{
+					{Stream: "stdout", Content: "running tests\n"},
+					{Stream: "stderr", Content: "test failed: expected 2\n"},
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				req := httptest.NewRequest(http.MethodGet, "/runs/"+run.ID+"/tasks", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				doc := mustHTMLDocument(t, rec)
+				assert.Expect(hasSelectorWithText(doc, "div[id^='terminal-']", "running tests")).To(BeTrue())
+				assert.Expect(hasSelectorWithText(doc, "div[id^='terminal-']", "test failed: expected 2")).To(BeTrue())
+			})
+
+			t.Run("GET /terminal/* pages through task log chunks", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = buildFile.Close() }()
+
+				client, err := init(buildFile.Name(), "", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = client.Close() }()
+
+				taskPath := "/pipeline/paged-run/tasks/0-build"
+				err = client.Set(context.Background(), taskPath, map[string]any{"status": "success"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks := make([]storage.LogChunk, 205)
+				for i := range chunks {
+					chunks[i] = storage.LogChunk{Stream: "stdout", Content: fmt.Sprintf("output line %d\n", i)}
+				}
+
+				err = client.AppendLogs(context.Background(), taskPath, chunks)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				router, err := server.NewRouter(slog.Default(), client, server.RouterOptions{})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				req := httptest.NewRequest(http.MethodGet, "/terminal"+taskPath, nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				body := rec.Body.String()
+				assert.Expect(body).To(HavePrefix(`<div class="term-container">`))
+				assert.Expect(body).To(ContainSubstring("output line 199"))
+				assert.Expect(body).NotTo(ContainSubstring("output line 200"))
+				assert.Expect(body).To(ContainSubstring(`hx-get="/terminal` + taskPath + `?offset=200"`))
+
+				req = httptest.NewRequest(http.MethodGet, "/terminal"+taskPath+"?offset=200", nil)
+				rec = httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+				body = rec.Body.String()
+				assert.Expect(body).NotTo(ContainSubstring("term-container"))
+				assert.Expect(body).NotTo(ContainSubstring("output line 199"))
+				assert.Expect(body).To(ContainSubstring("output line 204"))
+				assert.Expect(body).NotTo(ContainSubstring("hx-get"))
+			})
 		})
 	})
 }
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jtarchie/pocketci/schedule"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+// Scheduler triggers pipelines on cron schedules. A pipeline-level schedule
+// (storage.Pipeline.Schedule) runs every job of a pipeline, and jobs of YAML
+// pipelines can declare `triggers.schedule` to run on their own.
+//
+// The last fire time of each schedule is persisted, so fire times missed while
+// the server was down are detected on startup and handled by the catch-up
+// policy: "skip" waits for the next fire time, "run-once" triggers one run.
+type Scheduler struct {
+	store       storage.Driver
+	execService *ExecutionService
+	logger      *slog.Logger
+
+	// PollInterval is how often the scheduler looks for schedules that are due.
+	PollInterval time.Duration
+	// Grace is how late a fire time may be handled before it counts as missed.
+	Grace time.Duration
+	// CatchUp is the policy for schedules that do not set catch_up.
+	CatchUp string
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*scheduledTrigger
+}
+
+type scheduledTrigger struct {
+	all  bool
+	jobs map[string]bool
+}
+
+type scheduleEntry struct {
+	key      string
+	job      string
+	cron     string
+	timezone string
+	schedule *schedule.Schedule
+}
+
+// NewScheduler creates a scheduler with default intervals and the skip catch-up policy.
+func NewScheduler(store storage.Driver, execService *ExecutionService, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		store:        store,
+		execService:  execService,
+		logger:       logger.WithGroup("scheduler"),
+		PollInterval: 10 * time.Second,
+		Grace:        time.Minute,
+		CatchUp:      schedule.CatchUpSkip,
+		Now:          time.Now,
+		pending:      map[string]*scheduledTrigger{},
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.TriggerDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// TriggerDue triggers every pipeline and job whose schedule is due.
+func (s *Scheduler) TriggerDue(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now().UTC()
+
+	for page := 1; ; page++ {
+		result, err := s.store.SearchPipelines(ctx, "", page, 100)
+		if err != nil {
+			s.logger.Error("pipelines.list.failed", "error", err)
+
+			return
+		}
+
+		for i := range result.Items {
+			pipeline := &result.Items[i]
+
+			for _, entry := range s.entries(ctx, pipeline) {
+				if s.isDue(ctx, pipeline, entry, now) {
+					s.enqueue(pipeline.ID, entry.job)
+				}
+			}
+		}
+
+		if !result.HasNext {
+			break
+		}
+	}
+
+	s.dispatch(ctx)
+}
+
+// entries returns the pipeline-level schedule and the schedules of YAML jobs,
+// read with the pipeline's vars.
+func (s *Scheduler) entries(ctx context.Context, pipeline *storage.Pipeline) []scheduleEntry {
+	logger := s.logger.With("pipeline_id", pipeline.ID, "pipeline_name", pipeline.Name)
+
+	var entries []scheduleEntry
+
+	if pipeline.Schedule != nil {
+		sched, err := schedule.Parse(pipeline.Schedule.Cron, pipeline.Schedule.Timezone, pipeline.Schedule.CatchUp)
+		if err != nil {
+			logger.Warn("schedule.parse.failed", "error", err)
+		} else {
+			entries = append(entries, scheduleEntry{
+				key:      "/schedules/" + pipeline.ID + "/pipeline",
+				cron:     pipeline.Schedule.Cron,
+				timezone: pipeline.Schedule.Timezone,
+				schedule: sched,
+			})
+		}
+	}
+
+	if pipeline.ContentType != storage.ContentTypeYAML {
+		return entries
+	}
+
+	config, err := s.execService.pipelineConfig(ctx, pipeline)
+	if err != nil {
+		logger.Debug("pipeline.parse.failed", "error", err)
+
+		return entries
+	}
+
+	for _, job := range config.Jobs {
+		if job.Triggers == nil || job.Triggers.Schedule == nil {
+			continue
+		}
+
+		trigger := job.Triggers.Schedule
+
+		sched, err := schedule.Parse(trigger.Cron, trigger.Timezone, trigger.CatchUp)
+		if err != nil {
+			logger.Warn("schedule.parse.failed", "job", job.Name, "error", err)
+
+			continue
+		}
+
+		entries = append(entries, scheduleEntry{
+			key:      "/schedules/" + pipeline.ID + "/jobs/" + job.Name,
+			job:      job.Name,
+			cron:     trigger.Cron,
+			timezone: trigger.Timezone,
+			schedule: sched,
+		})
+	}
+
+	return entries
+}
+
+// isDue records the latest fire time of a schedule and reports whether it should trigger.
+// New or changed schedules start counting from now rather than firing immediately.
+func (s *Scheduler) isDue(ctx context.Context, pipeline *storage.Pipeline, entry scheduleEntry, now time.Time) bool {
+	logger := s.logger.With("pipeline_id", pipeline.ID, "job", entry.job, "cron", entry.cron)
+
+	state, err := s.store.Get(ctx, entry.key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logger.Error("schedule.state.get_failed", "error", err)
+
+		return false
+	}
+
+	last, parseErr := time.Parse(time.RFC3339, stringValue(state["last_scheduled"]))
+	if err != nil || parseErr != nil || state["cron"] != entry.cron || state["timezone"] != entry.timezone {
+		s.saveLastScheduled(ctx, logger, entry, now)
+
+		return false
+	}
+
+	due, ok := entry.schedule.Due(last, now)
+	if !ok {
+		return false
+	}
+
+	s.saveLastScheduled(ctx, logger, entry, due)
+
+	if now.Sub(due) > s.Grace && entry.schedule.CatchUp(s.CatchUp) == schedule.CatchUpSkip {
+		logger.Info("schedule.missed", "due", due, "catch_up", schedule.CatchUpSkip)
+
+		return false
+	}
+
+	return true
+}
+
+func (s *Scheduler) saveLastScheduled(ctx context.Context, logger *slog.Logger, entry scheduleEntry, at time.Time) {
+	err := s.store.Set(ctx, entry.key, map[string]any{
+		"cron":           entry.cron,
+		"timezone":       entry.timezone,
+		"last_scheduled": at.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		logger.Error("schedule.state.save_failed", "error", err)
+	}
+}
+
+// enqueue records a pending trigger. An empty job name triggers every job.
+func (s *Scheduler) enqueue(pipelineID, job string) {
+	trigger, ok := s.pending[pipelineID]
+	if !ok {
+		trigger = &scheduledTrigger{jobs: map[string]bool{}}
+		s.pending[pipelineID] = trigger
+	}
+
+	if job == "" {
+		trigger.all = true
+
+		return
+	}
+
+	trigger.jobs[job] = true
+}
+
+// dispatch triggers pipelines with pending schedules while capacity allows.
+// Pipelines that cannot start yet stay pending for the next pass.
+func (s *Scheduler) dispatch(ctx context.Context) {
+	for pipelineID, trigger := range s.pending {
+		if !s.execService.CanExecute() {
+			s.logger.Debug("schedule.trigger.deferred", "reason", "max_in_flight", "pending", len(s.pending))
+
+			return
+		}
+
+		pipeline, err := s.store.GetPipeline(ctx, pipelineID)
+		if err != nil {
+			s.logger.Error("schedule.trigger.get_pipeline_failed", "pipeline_id", pipelineID, "error", err)
+			delete(s.pending, pipelineID)
+
+			continue
+		}
+
+		opts := TriggerOptions{TriggeredBy: "schedule"}
+		if !trigger.all {
+			opts.Jobs = slices.Sorted(maps.Keys(trigger.jobs))
+		}
+
+		run, err := s.execService.TriggerPipelineWithOptions(ctx, pipeline, opts)
+		if err != nil {
+			s.logger.Error("schedule.trigger.failed", "pipeline_id", pipelineID, "error", err)
+
+			continue
+		}
+
+		s.logger.Info("schedule.trigger", "pipeline_id", pipelineID, "run_id", run.ID, "jobs", opts.Jobs)
+		delete(s.pending, pipelineID)
+	}
+}
+
+func stringValue(value any) string {
+	str, _ := value.(string)
+
+	return str
+}
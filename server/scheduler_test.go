@@ -0,0 +1,205 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jtarchie/pocketci/orchestra/native"
+	"github.com/jtarchie/pocketci/schedule"
+	"github.com/jtarchie/pocketci/server"
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+const scheduledJobPipeline = `
+jobs:
+  - name: nightly
+    triggers:
+      schedule:
+        cron: "0 2 * * *"
+    plan:
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["nightly"]
+  - name: manual
+    plan:
+      - task: echo
+        config:
+          platform: linux
+          image_resource:
+            type: registry-image
+            source:
+              repository: busybox
+          run:
+            path: echo
+            args: ["manual"]
+`
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setup := func(t *testing.T, name, content, contentType string) (storage.Driver, *storage.Pipeline, *server.ExecutionService, *server.Scheduler, *time.Time) {
+				t.Helper()
+				assert := NewGomegaWithT(t)
+
+				buildFile, err := os.CreateTemp(t.TempDir(), "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = buildFile.Close() })
+
+				client, err := init(buildFile.Name(), "namespace", slog.Default())
+				assert.Expect(err).NotTo(HaveOccurred())
+				t.Cleanup(func() { _ = client.Close() })
+
+				pipeline, err := client.SavePipeline(context.Background(), name, content, "native://", contentType)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				router := newStrictSecretRouter(t, client, server.RouterOptions{MaxInFlight: 5})
+				execService := router.ExecutionService()
+
+				now := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+				scheduler := server.NewScheduler(client, execService, slog.Default())
+				scheduler.Now = func() time.Time { return now }
+
+				return client, pipeline, execService, scheduler, &now
+			}
+
+			t.Run("triggers jobs whose schedule is due", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, execService, scheduler, now := setup(t, "scheduled-jobs", scheduledJobPipeline, storage.ContentTypeYAML)
+				ctx := context.Background()
+
+				// the first pass only records when the schedule started
+				scheduler.TriggerDue(ctx)
+				execService.Wait()
+
+				runs, err := client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(BeEmpty())
+
+				*now = time.Date(2024, 1, 1, 2, 0, 5, 0, time.UTC)
+				scheduler.TriggerDue(ctx)
+				execService.Wait()
+
+				runs, err = client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+				assert.Expect(runs.Items[0].Status).To(Equal(storage.RunStatusSuccess))
+
+				jobs, err := client.GetAll(ctx, "/pipeline/"+runs.Items[0].ID+"/jobs", []string{"status"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				statuses := map[string]any{}
+				for _, job := range jobs {
+					statuses[job.Path] = job.Payload["status"]
+				}
+
+				assert.Expect(statuses).To(HaveKeyWithValue(HaveSuffix("/jobs/nightly"), "success"))
+				assert.Expect(statuses).To(HaveKeyWithValue(HaveSuffix("/jobs/manual"), "skipped"))
+
+				// the same fire time does not trigger twice
+				*now = time.Date(2024, 1, 1, 2, 0, 15, 0, time.UTC)
+				scheduler.TriggerDue(ctx)
+				execService.Wait()
+
+				runs, err = client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+			})
+
+			t.Run("reads job schedules set from the pipeline's vars", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, _, _, _, _ := setup(t, "placeholder", "export { pipeline };", storage.ContentTypeTypeScript)
+				router := newRouterWithSecrets(t, client, server.RouterOptions{})
+				ctx := context.Background()
+
+				content := strings.Replace(scheduledJobPipeline, `cron: "0 2 * * *"`, "cron: ((nightly_cron))", 1)
+				jsonBody, _ := json.Marshal(map[string]any{
+					"content":      content,
+					"content_type": storage.ContentTypeYAML,
+					"driver_dsn":   "native://",
+					"vars":         map[string]any{"nightly_cron": "0 2 * * *"},
+				})
+
+				req := httptest.NewRequest(http.MethodPut, "/api/pipelines/scheduled-vars", bytes.NewReader(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				assert.Expect(rec.Code).To(Equal(http.StatusOK))
+
+				pipeline, err := client.GetPipelineByName(ctx, "scheduled-vars")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				now := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+				scheduler := server.NewScheduler(client, router.ExecutionService(), slog.Default())
+				scheduler.Now = func() time.Time { return now }
+
+				scheduler.TriggerDue(ctx)
+
+				now = time.Date(2024, 1, 1, 2, 0, 5, 0, time.UTC)
+				scheduler.TriggerDue(ctx)
+				router.ExecutionService().Wait()
+
+				runs, err := client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+				assert.Expect(runs.Items[0].Status).To(Equal(storage.RunStatusSuccess))
+			})
+
+			t.Run("applies the catch-up policy to missed pipeline schedules", func(t *testing.T) {
+				t.Parallel()
+				assert := NewGomegaWithT(t)
+
+				client, pipeline, execService, scheduler, now := setup(t, "scheduled-pipeline", "export const pipeline = async () => {};", storage.ContentTypeJavaScript)
+				ctx := context.Background()
+
+				err := client.UpdatePipelineSchedule(ctx, pipeline.ID, &storage.PipelineSchedule{Cron: "*/5 * * * *"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				scheduler.TriggerDue(ctx)
+
+				// the server was down for an hour, so the missed runs are skipped
+				*now = now.Add(time.Hour + 2*time.Minute)
+				scheduler.TriggerDue(ctx)
+				execService.Wait()
+
+				runs, err := client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(BeEmpty())
+
+				// with run-once, another hour of missed runs triggers a single run
+				scheduler.CatchUp = schedule.CatchUpRunOnce
+				*now = now.Add(time.Hour)
+				scheduler.TriggerDue(ctx)
+				execService.Wait()
+
+				runs, err = client.SearchRunsByPipeline(ctx, pipeline.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items).To(HaveLen(1))
+			})
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	terminal "github.com/buildkite/terminal-to-html/v3"
+	"github.com/jtarchie/pocketci/storage"
 )
 
 type TerminalLogEntry struct {
@@ -59,3 +60,17 @@ func ToTerminalHTMLFromLogs(logs []TerminalLogEntry) string {
 
 	return terminal.Render(combined)
 }
+
+// ToTerminalHTMLFromChunks renders log chunks as one stream of terminal output.
+func ToTerminalHTMLFromChunks(chunks []storage.LogChunk) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+
+	var combined []byte
+	for _, chunk := range chunks {
+		combined = append(combined, chunk.Content...)
+	}
+
+	return terminal.Render(combined)
+}
@@ -17,6 +17,7 @@ type PipelineMetrics struct {
 	FailedRuns  int
 	SkippedRuns int
 	AvgDuration time.Duration // average of completed runs with both timestamps; 0 if none
+	MonthlyCost float64       // agent cost in USD of runs this calendar month (UTC)
 	LastRun     *storage.PipelineRun
 }
 
@@ -47,6 +48,24 @@ func (p PipelineMetrics) AvgDurationStr() string {
 	return fmt.Sprintf("%ds", s)
 }
 
+// CostStr returns the month's agent cost, against the monthly budget when one is set.
+func (p PipelineMetrics) CostStr() string {
+	if p.Pipeline.MonthlyBudget > 0 {
+		return fmt.Sprintf("$%.2f / $%.2f", p.MonthlyCost, p.Pipeline.MonthlyBudget)
+	}
+
+	if p.MonthlyCost == 0 {
+		return "—"
+	}
+
+	return fmt.Sprintf("$%.2f", p.MonthlyCost)
+}
+
+// OverBudget reports whether the month's agent cost has reached the monthly budget.
+func (p PipelineMetrics) OverBudget() bool {
+	return p.Pipeline.MonthlyBudget > 0 && p.MonthlyCost >= p.Pipeline.MonthlyBudget
+}
+
 // MetricsDashboardData is the view model passed to the metrics templates.
 type MetricsDashboardData struct {
 	// System overview
@@ -158,9 +177,14 @@ func (c *WebMetricsController) buildData(ctx *echo.Context) (MetricsDashboardDat
 		return data, err
 	}
 	data.PipelineMetrics = make([]PipelineMetrics, 0, len(allPipelines.Items))
+	now := time.Now()
 	for _, pipeline := range allPipelines.Items {
 		pm := PipelineMetrics{Pipeline: pipeline}
 
+		if cost, costErr := c.store.GetPipelineCost(reqCtx, pipeline.ID, now); costErr == nil {
+			pm.MonthlyCost = cost
+		}
+
 		// Fetch up to 100 most-recent runs to compute stats
 		runs, runsErr := c.store.SearchRunsByPipeline(reqCtx, pipeline.ID, "", 1, 100)
 		if runsErr != nil || runs == nil {
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/jtarchie/pocketci/orchestra"
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/server/auth"
 	"github.com/jtarchie/pocketci/storage"
 	"github.com/labstack/echo/v5"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 // PipelineRow is a view model for the pipeline listing page that pairs a
@@ -291,6 +294,166 @@ func (c *WebPipelinesController) Source(ctx *echo.Context) error {
 	})
 }
 
+// Memory handles GET /pipelines/:id/memory[/] - Notes the pipeline's agents kept.
+func (c *WebPipelinesController) Memory(ctx *echo.Context) error {
+	id := ctx.Param("id")
+	pipeline, err := c.store.GetPipeline(ctx.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.String(http.StatusNotFound, "Pipeline not found")
+		}
+		return fmt.Errorf("could not get pipeline: %w", err)
+	}
+
+	if err := checkPipelineRBAC(ctx, pipeline); err != nil {
+		return err
+	}
+
+	memories, err := agent.ListMemories(ctx.Request().Context(), c.store, pipeline.ID)
+	if err != nil {
+		return fmt.Errorf("could not list memories: %w", err)
+	}
+
+	return ctx.Render(http.StatusOK, "pipeline_memory.html", map[string]any{
+		"Pipeline": pipeline,
+		"Memories": memories,
+	})
+}
+
+// Versions handles GET /pipelines/:id/versions[/] - The versions saved each
+// time the pipeline was set.
+func (c *WebPipelinesController) Versions(ctx *echo.Context) error {
+	id := ctx.Param("id")
+	pipeline, err := c.store.GetPipeline(ctx.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.String(http.StatusNotFound, "Pipeline not found")
+		}
+		return fmt.Errorf("could not get pipeline: %w", err)
+	}
+
+	if err := checkPipelineRBAC(ctx, pipeline); err != nil {
+		return err
+	}
+
+	page := 1
+	if p := ctx.QueryParam("page"); p != "" {
+		_, _ = fmt.Sscanf(p, "%d", &page)
+	}
+
+	result, err := c.store.ListPipelineVersions(ctx.Request().Context(), pipeline.ID, page, 20)
+	if err != nil {
+		return fmt.Errorf("could not list pipeline versions: %w", err)
+	}
+
+	return ctx.Render(http.StatusOK, "pipeline_versions.html", map[string]any{
+		"Pipeline":   pipeline,
+		"Versions":   result.Items,
+		"Pagination": result,
+	})
+}
+
+// DiffLine is one line of a unified diff. Kind is "header", "hunk", "add",
+// "remove", or "context".
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// diffLines compares two pipeline sources as a unified diff.
+func diffLines(fromName, from, toName, to string) []DiffLine {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+
+	lines := []DiffLine{}
+
+	for _, text := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		if text == "" {
+			continue
+		}
+
+		kind := "context"
+
+		switch {
+		case strings.HasPrefix(text, "+++"), strings.HasPrefix(text, "---"):
+			kind = "header"
+		case strings.HasPrefix(text, "@@"):
+			kind = "hunk"
+		case strings.HasPrefix(text, "+"):
+			kind = "add"
+		case strings.HasPrefix(text, "-"):
+			kind = "remove"
+		}
+
+		lines = append(lines, DiffLine{Kind: kind, Text: text})
+	}
+
+	return lines
+}
+
+// VersionDiff handles GET /pipelines/:id/versions/:version/diff[/] - What
+// changed in a version. It compares against the previous version, or the
+// version given by the against query param.
+func (c *WebPipelinesController) VersionDiff(ctx *echo.Context) error {
+	id := ctx.Param("id")
+	pipeline, err := c.store.GetPipeline(ctx.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.String(http.StatusNotFound, "Pipeline not found")
+		}
+		return fmt.Errorf("could not get pipeline: %w", err)
+	}
+
+	if err := checkPipelineRBAC(ctx, pipeline); err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, "Version must be a number")
+	}
+
+	against := number - 1
+	if a := ctx.QueryParam("against"); a != "" {
+		against, err = strconv.Atoi(a)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "Against must be a number")
+		}
+	}
+
+	version, err := c.store.GetPipelineVersion(ctx.Request().Context(), pipeline.ID, number)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ctx.String(http.StatusNotFound, "Pipeline version not found")
+		}
+		return fmt.Errorf("could not get pipeline version: %w", err)
+	}
+
+	// Version 1 has nothing before it, so it is compared to an empty pipeline.
+	base := &storage.PipelineVersion{}
+	if against > 0 {
+		base, err = c.store.GetPipelineVersion(ctx.Request().Context(), pipeline.ID, against)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return ctx.String(http.StatusNotFound, "Pipeline version not found")
+			}
+			return fmt.Errorf("could not get pipeline version: %w", err)
+		}
+	}
+
+	return ctx.Render(http.StatusOK, "pipeline_version_diff.html", map[string]any{
+		"Pipeline": pipeline,
+		"Version":  version,
+		"Against":  base,
+		"Lines":    diffLines(fmt.Sprintf("v%d", base.Version), base.Content, fmt.Sprintf("v%d", version.Version), version.Content),
+	})
+}
+
 // buildPipelinesURL constructs a URL for the pipelines listing page.
 func buildPipelinesURL(q string, page, perPage int) string {
 	url := "/pipelines/"
@@ -311,4 +474,10 @@ func (c *WebPipelinesController) RegisterRoutes(web *echo.Group) {
 	web.GET("/pipelines/:id/runs-search/", c.RunsSearch)
 	web.GET("/pipelines/:id/source", c.Source)
 	web.GET("/pipelines/:id/source/", c.Source)
+	web.GET("/pipelines/:id/memory", c.Memory)
+	web.GET("/pipelines/:id/memory/", c.Memory)
+	web.GET("/pipelines/:id/versions", c.Versions)
+	web.GET("/pipelines/:id/versions/", c.Versions)
+	web.GET("/pipelines/:id/versions/:version/diff", c.VersionDiff)
+	web.GET("/pipelines/:id/versions/:version/diff/", c.VersionDiff)
 }
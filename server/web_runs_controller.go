@@ -1,12 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/jtarchie/pocketci/runtime/agent"
 	"github.com/jtarchie/pocketci/storage"
 	"github.com/labstack/echo/v5"
 )
@@ -53,6 +56,10 @@ func countTaskStatsRecursive(node *storage.Tree[storage.Payload], stats *TaskSta
 	}
 }
 
+// terminalLogPageSize is how many log chunks a terminal fragment renders.
+// Longer logs end with a button that loads the next page.
+const terminalLogPageSize = 200
+
 // preloadTerminalHTML fetches logs for all tasks and injects "terminalHTML"
 // into each leaf node's Payload. This lets templates render terminal output
 // inline without a separate lazy-loading request.
@@ -65,74 +72,112 @@ func (c *WebRunsController) preloadTerminalHTML(ctx *echo.Context, lookupPath st
 	// Build a map from path to terminal HTML.
 	htmlByPath := make(map[string]template.HTML, len(stdoutResults))
 	for _, r := range stdoutResults {
-		logs := ParseTerminalLogs(r.Payload["logs"])
-		stdout, _ := r.Payload["stdout"].(string)
-		stderr, _ := r.Payload["stderr"].(string)
-		status, _ := r.Payload["status"].(string)
-		errorMessage, _ := r.Payload["error_message"].(string)
-
-		html := ToTerminalHTMLFromLogs(logs)
-		if html == "" {
-			displayOutput := stdout + stderr
-			if displayOutput == "" && errorMessage != "" {
-				displayOutput = errorMessage
-			}
-
-			html = ToTerminalHTML(displayOutput)
+		// Paths include the store's namespace; log keys do not.
+		key := r.Path
+		if i := strings.Index(r.Path, strings.TrimSuffix(lookupPath, "/")); i > 0 {
+			key = r.Path[i:]
 		}
 
-		if status == "running" || status == "" {
-			htmlByPath[r.Path] = template.HTML(fmt.Sprintf(
-				`<div class="term-container" hx-get="/terminal%s" hx-trigger="load delay:2s" hx-swap="outerHTML">%s</div>`,
-				r.Path, html,
-			))
-		} else {
-			htmlByPath[r.Path] = template.HTML(fmt.Sprintf(
-				`<div class="term-container">%s</div>`, html,
-			))
-		}
+		htmlByPath[r.Path] = template.HTML(c.renderTerminal(ctx.Request().Context(), r.Path, key, r.Payload, 0))
 	}
 
 	injectTerminalHTML(tree, htmlByPath)
 }
 
 // Terminal handles GET /terminal/* and returns a rendered task terminal fragment.
+// With an offset query parameter it returns only the output from that log
+// chunk on, to append to an already rendered terminal.
 func (c *WebRunsController) Terminal(ctx *echo.Context) error {
 	lookupPath := "/" + strings.TrimPrefix(ctx.Param("*"), "/")
 	if lookupPath == "/" {
 		return ctx.HTML(http.StatusNotFound, `<div class="term-container"></div>`)
 	}
 
+	offset, _ := strconv.Atoi(ctx.QueryParam("offset"))
+	offset = max(offset, 0)
+
 	payload, err := c.store.Get(ctx.Request().Context(), lookupPath)
 	if err != nil {
 		return ctx.HTML(http.StatusNotFound, `<div class="term-container"></div>`)
 	}
 
-	logs := ParseTerminalLogs(payload["logs"])
-	html := ToTerminalHTMLFromLogs(logs)
-	if html == "" {
-		stdout, _ := payload["stdout"].(string)
-		stderr, _ := payload["stderr"].(string)
-		errorMessage, _ := payload["error_message"].(string)
+	return ctx.HTML(http.StatusOK, c.renderTerminal(ctx.Request().Context(), lookupPath, lookupPath, payload, offset))
+}
+
+// renderTerminal renders a page of the output of the task stored at key,
+// starting at log chunk offset. At offset 0 it is a whole term-container;
+// later pages are only the output to append to it. Each page ends with a
+// button or, while the task runs, a poll that fetches the next from
+// /terminal{terminalPath}. Tasks stored before logs were kept in chunks are
+// rendered from their payload.
+func (c *WebRunsController) renderTerminal(ctx context.Context, terminalPath, key string, payload storage.Payload, offset int) string {
+	status, _ := payload["status"].(string)
+	running := status == "running" || status == ""
 
-		displayOutput := stdout + stderr
-		if displayOutput == "" {
-			displayOutput = errorMessage
+	// Fetch one extra chunk to tell whether there is another page.
+	chunks, err := c.store.GetLogs(ctx, key, offset, terminalLogPageSize+1)
+	if err != nil {
+		chunks = nil
+	}
+
+	if offset == 0 && len(chunks) == 0 {
+		html := ToTerminalHTMLFromLogs(ParseTerminalLogs(payload["logs"]))
+		if html == "" {
+			stdout, _ := payload["stdout"].(string)
+			stderr, _ := payload["stderr"].(string)
+			errorMessage, _ := payload["error_message"].(string)
+
+			displayOutput := stdout + stderr
+			if displayOutput == "" {
+				displayOutput = errorMessage
+			}
+
+			html = ToTerminalHTML(displayOutput)
 		}
 
-		html = ToTerminalHTML(displayOutput)
+		if running {
+			return fmt.Sprintf(
+				`<div class="term-container" hx-get="/terminal%s" hx-trigger="load delay:2s" hx-swap="outerHTML">%s</div>`,
+				terminalPath,
+				html,
+			)
+		}
+
+		return fmt.Sprintf(`<div class="term-container">%s</div>`, html)
 	}
 
-	status, _ := payload["status"].(string)
-	if status == "running" || status == "" {
-		return ctx.HTML(http.StatusOK, fmt.Sprintf(
-			`<div class="term-container" hx-get="/terminal%s" hx-trigger="load delay:2s" hx-swap="outerHTML">%s</div>`,
-			lookupPath,
-			html,
-		))
+	hasMore := len(chunks) > terminalLogPageSize
+	if hasMore {
+		chunks = chunks[:terminalLogPageSize]
+	}
+
+	next := offset
+	if len(chunks) > 0 {
+		next = chunks[len(chunks)-1].Sequence + 1
+	}
+
+	html := ToTerminalHTMLFromChunks(chunks)
+
+	switch {
+	case hasMore:
+		html += fmt.Sprintf(
+			`<button type="button" class="term-more" hx-get="/terminal%s?offset=%d" hx-swap="outerHTML">Show more output</button>`,
+			terminalPath,
+			next,
+		)
+	case running:
+		html += fmt.Sprintf(
+			`<div hx-get="/terminal%s?offset=%d" hx-trigger="load delay:2s" hx-swap="outerHTML"></div>`,
+			terminalPath,
+			next,
+		)
+	}
+
+	if offset > 0 {
+		return html
 	}
 
-	return ctx.HTML(http.StatusOK, fmt.Sprintf(`<div class="term-container">%s</div>`, html))
+	return fmt.Sprintf(`<div class="term-container">%s</div>`, html)
 }
 
 func injectTerminalHTML(node *storage.Tree[storage.Payload], htmlByPath map[string]template.HTML) {
@@ -151,6 +196,20 @@ func injectTerminalHTML(node *storage.Tree[storage.Payload], htmlByPath map[stri
 	}
 }
 
+// pendingApprovals returns the agent tool calls of a run waiting for approval.
+func (c *WebRunsController) pendingApprovals(ctx context.Context, runID string) ([]agent.ApprovalRequest, error) {
+	if c.execService == nil || c.execService.Approvals == nil {
+		return nil, nil
+	}
+
+	pending, err := c.execService.Approvals.Pending(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get approvals: %w", err)
+	}
+
+	return pending, nil
+}
+
 // Show handles GET /runs/:id/tasks - Task tree view for a run.
 func (c *WebRunsController) Show(ctx *echo.Context) error {
 	runID := ctx.Param("id")
@@ -173,19 +232,25 @@ func (c *WebRunsController) Show(ctx *echo.Context) error {
 		}
 	}
 
+	approvals, err := c.pendingApprovals(ctx.Request().Context(), runID)
+	if err != nil {
+		return err
+	}
+
 	tree := results.AsTree()
 	stats := countTaskStats(tree)
 	c.preloadTerminalHTML(ctx, lookupPath, tree)
 
 	return ctx.Render(http.StatusOK, "results.html", map[string]any{
-		"Tree":     tree,
-		"Path":     lookupPath,
-		"RunID":    runID,
-		"IsActive": isActive,
-		"Run":      run,
-		"Pipeline": pipeline,
-		"Title":    title,
-		"Stats":    stats,
+		"Tree":      tree,
+		"Path":      lookupPath,
+		"RunID":     runID,
+		"IsActive":  isActive,
+		"Run":       run,
+		"Pipeline":  pipeline,
+		"Title":     title,
+		"Stats":     stats,
+		"Approvals": approvals,
 	})
 }
 
@@ -255,14 +320,20 @@ func (c *WebRunsController) TasksPartial(ctx *echo.Context) error {
 
 		ctx.Response().Header().Set("HX-Push-Url", fmt.Sprintf("/runs/%s/tasks?q=%s", runID, q))
 
+		approvals, err := c.pendingApprovals(ctx.Request().Context(), runID)
+		if err != nil {
+			return err
+		}
+
 		return ctx.Render(http.StatusOK, "tasks-partial", map[string]any{
-			"Tree":     tree,
-			"Path":     lookupPath,
-			"RunID":    runID,
-			"IsActive": isActive,
-			"Run":      run,
-			"Stats":    stats,
-			"OOB":      true,
+			"Tree":      tree,
+			"Path":      lookupPath,
+			"RunID":     runID,
+			"IsActive":  isActive,
+			"Run":       run,
+			"Stats":     stats,
+			"OOB":       true,
+			"Approvals": approvals,
 		})
 	}
 
@@ -284,14 +355,20 @@ func (c *WebRunsController) TasksPartial(ctx *echo.Context) error {
 		statusCode = 286
 	}
 
+	approvals, err := c.pendingApprovals(ctx.Request().Context(), runID)
+	if err != nil {
+		return err
+	}
+
 	return ctx.Render(statusCode, "tasks-partial", map[string]any{
-		"Tree":     tree,
-		"Path":     lookupPath,
-		"RunID":    runID,
-		"IsActive": isActive,
-		"Run":      run,
-		"Stats":    stats,
-		"OOB":      true,
+		"Tree":      tree,
+		"Path":      lookupPath,
+		"RunID":     runID,
+		"IsActive":  isActive,
+		"Run":       run,
+		"Stats":     stats,
+		"OOB":       true,
+		"Approvals": approvals,
 	})
 }
 
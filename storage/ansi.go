@@ -31,6 +31,18 @@ var ansiEscape = regexp.MustCompile(
 		`|[\x80-\x9f]`,
 )
 
+// SearchText returns the plain text of a JSON-marshaled payload for full-text
+// indexing: its string values, without ANSI escape sequences.
+func SearchText(data []byte) string {
+	return stripANSI(extractTextFromJSON(data))
+}
+
+// LogSearchText returns the plain text of a log chunk for full-text indexing:
+// its content without ANSI escape sequences.
+func LogSearchText(content string) string {
+	return stripANSI(content)
+}
+
 // stripANSI removes all ANSI escape sequences from s, returning plain text
 // suitable for full-text indexing.
 func stripANSI(s string) string {
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/postgres"
 	_ "github.com/jtarchie/pocketci/storage/s3"
 	_ "github.com/jtarchie/pocketci/storage/sqlite"
 	"github.com/jtarchie/pocketci/testhelpers"
@@ -28,6 +29,16 @@ func newStorageClient(t *testing.T, name string, init storage.InitFunc, namespac
 		server := testhelpers.StartMinIO(t)
 		t.Cleanup(server.Stop)
 		dsn = server.CacheURL()
+	case "postgres":
+		for _, binary := range []string{"initdb", "postgres"} {
+			if _, err := testhelpers.PostgresBinary(binary); err != nil {
+				t.Skip("postgres not installed, skipping PostgreSQL storage test")
+			}
+		}
+
+		server := testhelpers.StartPostgres(t)
+		t.Cleanup(server.Stop)
+		dsn = server.DSN()
 	default:
 		f, err := os.CreateTemp(t.TempDir(), "")
 		if err != nil {
@@ -162,6 +173,40 @@ func TestDrivers(t *testing.T) {
 				assert.Expect(err).NotTo(HaveOccurred())
 				assert.Expect(p2["status"]).To(Equal("pending"))
 			})
+
+			t.Run("Delete removes a prefix and its children", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				for _, prefix := range []string{"/memory/p1", "/memory/p1/a", "/memory/p1/b", "/memory/p10/a"} {
+					err := client.Set(ctx, prefix, map[string]string{"content": "remember " + prefix})
+					assert.Expect(err).NotTo(HaveOccurred())
+				}
+
+				err := client.Delete(ctx, "/memory/p1")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				_, err = client.Get(ctx, "/memory/p1/a")
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+
+				_, err = client.Get(ctx, "/memory/p1")
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+
+				results, err := client.GetAll(ctx, "/memory", []string{"content"})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(HaveLen(1))
+				assert.Expect(results[0].Path).To(Equal("/namespace/memory/p10/a"))
+
+				results, err = client.Search(ctx, "/memory", "remember")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(HaveLen(1))
+
+				err = client.Delete(ctx, "/memory/missing")
+				assert.Expect(err).NotTo(HaveOccurred())
+			})
 		})
 	})
 }
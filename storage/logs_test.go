@@ -0,0 +1,196 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtarchie/pocketci/storage"
+	_ "github.com/jtarchie/pocketci/storage/s3"
+	_ "github.com/jtarchie/pocketci/storage/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestTaskLogs(t *testing.T) {
+	storage.Each(func(name string, init storage.InitFunc) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("appends chunks in sequence", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+				key := "/pipeline/run-1/tasks/0-build"
+
+				err := client.AppendLogs(ctx, key, []storage.LogChunk{
+					{Stream: "stdout", Content: "line 1\n"},
+					{Stream: "stderr", Content: "warning\n"},
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(ctx, key, []storage.LogChunk{
+					{Sequence: 99, Stream: "stdout", Content: "line 2\n"},
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err := client.GetLogs(ctx, key, 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(HaveLen(3))
+
+				for i, chunk := range chunks {
+					assert.Expect(chunk.Sequence).To(Equal(i))
+				}
+
+				assert.Expect(chunks[0].Stream).To(Equal("stdout"))
+				assert.Expect(chunks[0].Content).To(Equal("line 1\n"))
+				assert.Expect(chunks[1].Stream).To(Equal("stderr"))
+				assert.Expect(chunks[2].Content).To(Equal("line 2\n"))
+			})
+
+			t.Run("appends after CloseLogs continue the sequence", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+				key := "/pipeline/run-1/tasks/0-build"
+
+				err := client.AppendLogs(ctx, key, []storage.LogChunk{{Stream: "stdout", Content: "first"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.CloseLogs(ctx, key)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(ctx, key, []storage.LogChunk{{Stream: "stdout", Content: "second"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err := client.GetLogs(ctx, key, 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(HaveLen(2))
+				assert.Expect(chunks[1].Sequence).To(Equal(1))
+				assert.Expect(chunks[1].Content).To(Equal("second"))
+			})
+
+			t.Run("reads a page with offset and limit", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+				key := "/pipeline/run-1/tasks/0-build"
+
+				chunks := make([]storage.LogChunk, 5)
+				for i := range chunks {
+					chunks[i] = storage.LogChunk{Stream: "stdout", Content: string(rune('a' + i))}
+				}
+
+				err := client.AppendLogs(ctx, key, chunks)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				page, err := client.GetLogs(ctx, key, 1, 2)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(page).To(HaveLen(2))
+				assert.Expect(page[0].Sequence).To(Equal(1))
+				assert.Expect(page[0].Content).To(Equal("b"))
+				assert.Expect(page[1].Content).To(Equal("c"))
+
+				page, err = client.GetLogs(ctx, key, 4, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(page).To(HaveLen(1))
+				assert.Expect(page[0].Content).To(Equal("e"))
+
+				page, err = client.GetLogs(ctx, key, 5, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(page).To(BeEmpty())
+			})
+
+			t.Run("keeps the logs of each task apart", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+
+				err := client.AppendLogs(ctx, "/pipeline/run-1/tasks/0-build", []storage.LogChunk{{Stream: "stdout", Content: "build"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(ctx, "/pipeline/run-1/tasks/0-build/nested", []storage.LogChunk{{Stream: "stdout", Content: "nested"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err := client.GetLogs(ctx, "/pipeline/run-1/tasks/0-build", 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(HaveLen(1))
+				assert.Expect(chunks[0].Content).To(Equal("build"))
+
+				chunks, err = client.GetLogs(ctx, "/pipeline/run-1/tasks/0-build/nested", 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(HaveLen(1))
+				assert.Expect(chunks[0].Sequence).To(Equal(0))
+			})
+
+			t.Run("Delete removes the logs under the prefix", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+				key := "/pipeline/run-1/tasks/0-build"
+
+				err := client.AppendLogs(ctx, key, []storage.LogChunk{{Stream: "stdout", Content: "old"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.Delete(ctx, "/pipeline/run-1")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err := client.GetLogs(ctx, key, 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(BeEmpty())
+
+				err = client.AppendLogs(ctx, key, []storage.LogChunk{{Stream: "stdout", Content: "new"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err = client.GetLogs(ctx, key, 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(HaveLen(1))
+				assert.Expect(chunks[0].Sequence).To(Equal(0))
+			})
+
+			t.Run("DeleteRun removes the run's logs", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+
+				pipeline, err := client.SavePipeline(ctx, "logs", "export const pipeline = async () => {};", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				run, err := client.SaveRun(ctx, pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				key := "/pipeline/" + run.ID + "/tasks/0-build"
+				err = client.AppendLogs(ctx, key, []storage.LogChunk{{Stream: "stdout", Content: "output"}})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.DeleteRun(ctx, run.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				chunks, err := client.GetLogs(ctx, key, 0, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(chunks).To(BeEmpty())
+			})
+
+			t.Run("Search finds tasks by their logs", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+				client := newStorageClient(t, name, init, "ns")
+				ctx := context.Background()
+
+				err := client.Set(ctx, "/pipeline/run-1/tasks/0-build", map[string]any{"status": "failure"})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(ctx, "/pipeline/run-1/tasks/0-build", []storage.LogChunk{
+					{Stream: "stderr", Content: "\x1b[31msegmentation\x1b[0m fault"},
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				err = client.AppendLogs(ctx, "/pipeline/run-2/tasks/0-build", []storage.LogChunk{
+					{Stream: "stderr", Content: "segmentation fault"},
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				results, err := client.Search(ctx, "pipeline/run-1", "segmentation")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(HaveLen(1))
+				assert.Expect(results[0].Path).To(HaveSuffix("/pipeline/run-1/tasks/0-build"))
+				assert.Expect(results[0].Payload["status"]).To(Equal("failure"))
+			})
+		})
+	})
+}
@@ -195,6 +195,210 @@ func TestPipelineStorage(t *testing.T) {
 				assert.Expect(result.Items[0].Name).To(Equal("my-pipeline"))
 				assert.Expect(result.Items[0].Content).To(Equal("content-v2"))
 			})
+
+			t.Run("UpdatePipelineSchedule sets and clears the schedule", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				saved, err := client.SavePipeline(ctx, "scheduled", "content", "docker://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(saved.Schedule).To(BeNil())
+
+				schedule := &storage.PipelineSchedule{Cron: "0 2 * * *", Timezone: "UTC", CatchUp: "run-once"}
+				err = client.UpdatePipelineSchedule(ctx, saved.ID, schedule)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err := client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.Schedule).To(Equal(schedule))
+
+				err = client.UpdatePipelineSchedule(ctx, saved.ID, nil)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err = client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.Schedule).To(BeNil())
+
+				err = client.UpdatePipelineSchedule(ctx, "non-existent-id", schedule)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
+
+			t.Run("UpdatePipelineMonthlyBudget sets and clears the budget", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				saved, err := client.SavePipeline(ctx, "budgeted", "content", "docker://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(saved.MonthlyBudget).To(BeZero())
+
+				err = client.UpdatePipelineMonthlyBudget(ctx, saved.ID, 25.5)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err := client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.MonthlyBudget).To(Equal(25.5))
+
+				result, err := client.SearchPipelines(ctx, "budgeted", 1, 20)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(result.Items).To(HaveLen(1))
+				assert.Expect(result.Items[0].MonthlyBudget).To(Equal(25.5))
+
+				err = client.UpdatePipelineMonthlyBudget(ctx, saved.ID, 0)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err = client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.MonthlyBudget).To(BeZero())
+
+				err = client.UpdatePipelineMonthlyBudget(ctx, "non-existent-id", 10)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
+
+			t.Run("UpdatePipelineRetention sets and clears the retention", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				saved, err := client.SavePipeline(ctx, "retained", "content", "docker://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(saved.Retention).To(BeNil())
+
+				retention := &storage.PipelineRetention{Runs: 10, Days: 30}
+
+				err = client.UpdatePipelineRetention(ctx, saved.ID, retention)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err := client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.Retention).To(Equal(retention))
+
+				result, err := client.SearchPipelines(ctx, "retained", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(result.Items).To(HaveLen(1))
+				assert.Expect(result.Items[0].Retention).To(Equal(retention))
+
+				err = client.UpdatePipelineRetention(ctx, saved.ID, nil)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err = client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.Retention).To(BeNil())
+
+				err = client.UpdatePipelineRetention(ctx, "non-existent-id", retention)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
+
+			t.Run("SavePipelineVersion numbers versions and makes the latest current", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				saved, err := client.SavePipeline(ctx, "versioned", "first", "docker", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(saved.Version).To(BeZero())
+
+				first, err := client.SavePipelineVersion(ctx, storage.PipelineVersion{
+					PipelineID: saved.ID,
+					Content:    "first",
+					DriverDSN:  "docker",
+					Author:     "alice@example.com",
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(first.Version).To(Equal(1))
+				assert.Expect(first.CreatedAt).NotTo(BeZero())
+
+				_, err = client.SavePipeline(ctx, "versioned", "second", "native", storage.ContentTypeYAML)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				second, err := client.SavePipelineVersion(ctx, storage.PipelineVersion{
+					PipelineID:     saved.ID,
+					Content:        "second",
+					ContentType:    storage.ContentTypeYAML,
+					DriverDSN:      "native",
+					ResumeEnabled:  true,
+					RBACExpression: `"admins" in Groups`,
+				})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(second.Version).To(Equal(2))
+
+				retrieved, err := client.GetPipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.Version).To(Equal(2))
+
+				byName, err := client.GetPipelineByName(ctx, "versioned")
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(byName.Version).To(Equal(2))
+
+				version, err := client.GetPipelineVersion(ctx, saved.ID, 1)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(version.Content).To(Equal("first"))
+				assert.Expect(version.Author).To(Equal("alice@example.com"))
+
+				version, err = client.GetPipelineVersion(ctx, saved.ID, 2)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(version.ContentType).To(Equal(storage.ContentTypeYAML))
+				assert.Expect(version.DriverDSN).To(Equal("native"))
+				assert.Expect(version.ResumeEnabled).To(BeTrue())
+				assert.Expect(version.RBACExpression).To(Equal(`"admins" in Groups`))
+
+				versions, err := client.ListPipelineVersions(ctx, saved.ID, 1, 1)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(versions.Items).To(HaveLen(1))
+				assert.Expect(versions.Items[0].Version).To(Equal(2))
+				assert.Expect(versions.TotalItems).To(Equal(2))
+				assert.Expect(versions.HasNext).To(BeTrue())
+
+				_, err = client.GetPipelineVersion(ctx, saved.ID, 3)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+
+				_, err = client.SavePipelineVersion(ctx, storage.PipelineVersion{PipelineID: "non-existent-id"})
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+
+				err = client.DeletePipeline(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				_, err = client.GetPipelineVersion(ctx, saved.ID, 1)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
+
+			t.Run("UpdateRunPipelineVersion records the version a run executed", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				saved, err := client.SavePipeline(ctx, "versioned-run", "content", "docker", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				run, err := client.SaveRun(ctx, saved.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(run.PipelineVersion).To(BeZero())
+
+				err = client.UpdateRunPipelineVersion(ctx, run.ID, 3)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				retrieved, err := client.GetRun(ctx, run.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(retrieved.PipelineVersion).To(Equal(3))
+
+				runs, err := client.SearchRunsByPipeline(ctx, saved.ID, "", 1, 10)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(runs.Items[0].PipelineVersion).To(Equal(3))
+
+				err = client.UpdateRunPipelineVersion(ctx, "non-existent-id", 1)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
 		})
 	})
 }
@@ -0,0 +1,999 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/jtarchie/pocketci/runtime/support"
+	"github.com/jtarchie/pocketci/storage"
+	"github.com/lib/pq"
+)
+
+//go:embed schema.sql
+var schemaSQL string
+
+// schemaLockID serializes schema changes between servers sharing a database.
+const schemaLockID = 0x706f636b6574
+
+// maxSearchText bounds the text indexed for a single task or log chunk,
+// keeping its tsvector under PostgreSQL's 1MB limit.
+const maxSearchText = 256 * 1024
+
+const pipelineColumns = `id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, schedule, monthly_budget, retention, version, created_at, updated_at`
+
+const runColumns = `id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at`
+
+const versionColumns = `pipeline_id, version, content, content_type, driver_dsn, resume_enabled, rbac_expression, author, created_at`
+
+// Postgres implements the storage.Driver interface on PostgreSQL, so that
+// several servers can share one store. Full-text search uses tsvector columns
+// in place of SQLite's FTS5 tables.
+//
+// DSN format: postgres://[user[:password]@]host[:port]/database[?sslmode=disable]
+type Postgres struct {
+	db        *sql.DB
+	namespace string
+}
+
+// pipelineScan is an intermediate struct for scanning pipeline rows.
+type pipelineScan struct {
+	ID             string    `db:"id"`
+	Name           string    `db:"name"`
+	Content        string    `db:"content"`
+	ContentType    string    `db:"content_type"`
+	DriverDSN      string    `db:"driver_dsn"`
+	ResumeEnabled  bool      `db:"resume_enabled"`
+	RBACExpression string    `db:"rbac_expression"`
+	Schedule       string    `db:"schedule"`
+	MonthlyBudget  float64   `db:"monthly_budget"`
+	Retention      string    `db:"retention"`
+	Version        int       `db:"version"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+func (p pipelineScan) toStorage() storage.Pipeline {
+	var schedule *storage.PipelineSchedule
+	if p.Schedule != "" {
+		schedule = &storage.PipelineSchedule{}
+		if err := json.Unmarshal([]byte(p.Schedule), schedule); err != nil {
+			schedule = nil
+		}
+	}
+
+	var retention *storage.PipelineRetention
+	if p.Retention != "" {
+		retention = &storage.PipelineRetention{}
+		if err := json.Unmarshal([]byte(p.Retention), retention); err != nil {
+			retention = nil
+		}
+	}
+
+	return storage.Pipeline{
+		ID:             p.ID,
+		Name:           p.Name,
+		Content:        p.Content,
+		ContentType:    p.ContentType,
+		DriverDSN:      p.DriverDSN,
+		ResumeEnabled:  p.ResumeEnabled,
+		RBACExpression: p.RBACExpression,
+		Schedule:       schedule,
+		MonthlyBudget:  p.MonthlyBudget,
+		Retention:      retention,
+		Version:        p.Version,
+		CreatedAt:      p.CreatedAt.UTC(),
+		UpdatedAt:      p.UpdatedAt.UTC(),
+	}
+}
+
+// pipelineRunScan is an intermediate struct for scanning pipeline run rows.
+type pipelineRunScan struct {
+	ID              string         `db:"id"`
+	PipelineID      string         `db:"pipeline_id"`
+	Status          string         `db:"status"`
+	StartedAt       sql.NullTime   `db:"started_at"`
+	CompletedAt     sql.NullTime   `db:"completed_at"`
+	ErrorMessage    sql.NullString `db:"error_message"`
+	Cost            float64        `db:"cost"`
+	PipelineVersion int            `db:"pipeline_version"`
+	CreatedAt       time.Time      `db:"created_at"`
+}
+
+func (p pipelineRunScan) toStorage() storage.PipelineRun {
+	run := storage.PipelineRun{
+		ID:              p.ID,
+		PipelineID:      p.PipelineID,
+		Status:          storage.RunStatus(p.Status),
+		ErrorMessage:    p.ErrorMessage.String,
+		Cost:            p.Cost,
+		PipelineVersion: p.PipelineVersion,
+		CreatedAt:       p.CreatedAt.UTC(),
+	}
+
+	if p.StartedAt.Valid {
+		t := p.StartedAt.Time.UTC()
+		run.StartedAt = &t
+	}
+
+	if p.CompletedAt.Valid {
+		t := p.CompletedAt.Time.UTC()
+		run.CompletedAt = &t
+	}
+
+	return run
+}
+
+// pipelineVersionScan is an intermediate struct for scanning pipeline version rows.
+type pipelineVersionScan struct {
+	PipelineID     string    `db:"pipeline_id"`
+	Version        int       `db:"version"`
+	Content        string    `db:"content"`
+	ContentType    string    `db:"content_type"`
+	DriverDSN      string    `db:"driver_dsn"`
+	ResumeEnabled  bool      `db:"resume_enabled"`
+	RBACExpression string    `db:"rbac_expression"`
+	Author         string    `db:"author"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+func (p pipelineVersionScan) toStorage() storage.PipelineVersion {
+	return storage.PipelineVersion{
+		PipelineID:     p.PipelineID,
+		Version:        p.Version,
+		Content:        p.Content,
+		ContentType:    p.ContentType,
+		DriverDSN:      p.DriverDSN,
+		ResumeEnabled:  p.ResumeEnabled,
+		RBACExpression: p.RBACExpression,
+		Author:         p.Author,
+		CreatedAt:      p.CreatedAt.UTC(),
+	}
+}
+
+func NewPostgres(dsn string, namespace string, _ *slog.Logger) (storage.Driver, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	err = applySchema(db)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, err
+	}
+
+	return &Postgres{
+		db:        db,
+		namespace: namespace,
+	}, nil
+}
+
+// applySchema creates the tables, holding an advisory lock so that servers
+// starting together do not race.
+func applySchema(db *sql.DB) error {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin schema transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, schemaLockID)
+	if err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, schemaSQL)
+	if err != nil {
+		return fmt.Errorf("failed to apply schema: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit schema: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) fullPath(prefix string) string {
+	return filepath.Clean("/" + p.namespace + "/" + prefix)
+}
+
+func (p *Postgres) Set(ctx context.Context, prefix string, payload any) error {
+	path := p.fullPath(prefix)
+
+	contents, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO tasks (path, payload, search)
+		VALUES ($1, $2, to_tsvector('simple', $3::TEXT))
+		ON CONFLICT (path) DO UPDATE SET
+			payload = pocketci_merge_patch(tasks.payload, excluded.payload),
+			search = excluded.search
+	`, path, string(contents), searchText(path, contents))
+	if err != nil {
+		return fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) Get(ctx context.Context, prefix string) (storage.Payload, error) {
+	var payload storage.Payload
+
+	err := sqlscan.Get(ctx, p.db, &payload, `SELECT payload FROM tasks WHERE path = $1`, p.fullPath(prefix))
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	return payload, nil
+}
+
+func (p *Postgres) GetAll(ctx context.Context, prefix string, fields []string) (storage.Results, error) {
+	if len(fields) == 0 {
+		fields = []string{"status"}
+	}
+
+	args := []any{likePrefix(p.fullPath(prefix))}
+
+	// Support wildcard "*" to return all fields
+	selected := "payload"
+	if len(fields) != 1 || fields[0] != "*" {
+		pairs := make([]string, 0, len(fields))
+
+		for _, field := range fields {
+			args = append(args, field, pq.Array(strings.Split(field, ".")))
+			pairs = append(pairs, fmt.Sprintf("$%d::TEXT, payload #> $%d::TEXT[]", len(args)-1, len(args)))
+		}
+
+		selected = "jsonb_build_object(" + strings.Join(pairs, ", ") + ")"
+	}
+
+	var results storage.Results
+
+	err := sqlscan.Select(ctx, p.db, &results, `
+		SELECT id, path, `+selected+` AS payload
+		FROM tasks
+		WHERE path LIKE $1
+		ORDER BY id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not select: %w", err)
+	}
+
+	return results, nil
+}
+
+func (p *Postgres) UpdateStatusForPrefix(ctx context.Context, prefix string, matchStatuses []string, newStatus string) error {
+	if len(matchStatuses) == 0 {
+		return nil
+	}
+
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE tasks SET payload = pocketci_merge_patch(payload, jsonb_build_object('status', $1::TEXT))
+		WHERE payload ->> 'status' = ANY($2) AND path LIKE $3
+	`, newStatus, pq.Array(matchStatuses), likePrefix(p.fullPath(prefix)))
+	if err != nil {
+		return fmt.Errorf("failed to update task statuses for prefix %q: %w", prefix, err)
+	}
+
+	return nil
+}
+
+// Delete removes the tasks and logs at and beneath prefix.
+func (p *Postgres) Delete(ctx context.Context, prefix string) error {
+	path := p.fullPath(prefix)
+
+	_, err := p.db.ExecContext(ctx, `
+		DELETE FROM tasks WHERE path = $1 OR path LIKE $2
+	`, path, likePrefix(path+"/"))
+	if err != nil {
+		return fmt.Errorf("failed to delete tasks for prefix %q: %w", prefix, err)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		DELETE FROM task_logs WHERE path = $1 OR path LIKE $2
+	`, path, likePrefix(path+"/"))
+	if err != nil {
+		return fmt.Errorf("failed to delete task logs for prefix %q: %w", prefix, err)
+	}
+
+	return nil
+}
+
+// CloseLogs does nothing, as appending logs keeps no state in memory.
+func (p *Postgres) CloseLogs(_ context.Context, _ string) error {
+	return nil
+}
+
+// AppendLogs stores chunks after the task's existing logs. An advisory lock
+// on the path keeps concurrent appends from taking the same sequence.
+func (p *Postgres) AppendLogs(ctx context.Context, prefix string, chunks []storage.LogChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	path := p.fullPath(prefix)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, path)
+	if err != nil {
+		return fmt.Errorf("failed to lock task logs: %w", err)
+	}
+
+	var sequence int
+
+	err = sqlscan.Get(ctx, tx, &sequence, `
+		SELECT COALESCE(MAX(sequence), -1) + 1 FROM task_logs WHERE path = $1
+	`, path)
+	if err != nil {
+		return fmt.Errorf("failed to get next log sequence: %w", err)
+	}
+
+	for _, chunk := range chunks {
+		createdAt := chunk.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO task_logs (path, sequence, stream, content, search, created_at)
+			VALUES ($1, $2, $3, $4, to_tsvector('simple', $5::TEXT), $6)
+		`, path, sequence, chunk.Stream, chunk.Content, clipSearchText(storage.LogSearchText(chunk.Content)), createdAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to insert log chunk: %w", err)
+		}
+
+		sequence++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit log chunks: %w", err)
+	}
+
+	return nil
+}
+
+// GetLogs returns the task's logs from sequence offset, in order.
+func (p *Postgres) GetLogs(ctx context.Context, prefix string, offset, limit int) ([]storage.LogChunk, error) {
+	args := []any{p.fullPath(prefix), offset}
+
+	query := `
+		SELECT sequence, stream, content, created_at FROM task_logs
+		WHERE path = $1 AND sequence >= $2
+		ORDER BY sequence ASC
+	`
+	if limit > 0 {
+		query += ` LIMIT $3`
+
+		args = append(args, limit)
+	}
+
+	chunks := []storage.LogChunk{}
+
+	err := sqlscan.Select(ctx, p.db, &chunks, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+
+	return chunks, nil
+}
+
+func (p *Postgres) Close() error {
+	err := p.db.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return nil
+}
+
+// SavePipeline creates or updates a pipeline in the database.
+// Pipeline names are unique; saving with an existing name updates the record
+// while preserving the original ID so existing pipeline_runs references remain valid.
+func (p *Postgres) SavePipeline(ctx context.Context, name, content, driverDSN, contentType string) (*storage.Pipeline, error) {
+	now := time.Now().UTC()
+
+	var storedID string
+
+	err := sqlscan.Get(ctx, p.db, &storedID, `
+		INSERT INTO pipelines (id, name, content, content_type, driver_dsn, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			content = excluded.content,
+			content_type = excluded.content_type,
+			driver_dsn = excluded.driver_dsn,
+			updated_at = excluded.updated_at
+		RETURNING id
+	`, support.PipelineID(name, content), name, content, contentType, driverDSN, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pipeline: %w", err)
+	}
+
+	return &storage.Pipeline{
+		ID:          storedID,
+		Name:        name,
+		Content:     content,
+		ContentType: contentType,
+		DriverDSN:   driverDSN,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// GetPipeline retrieves a pipeline by its ID.
+func (p *Postgres) GetPipeline(ctx context.Context, id string) (*storage.Pipeline, error) {
+	var row pipelineScan
+
+	err := sqlscan.Get(ctx, p.db, &row, `SELECT `+pipelineColumns+` FROM pipelines WHERE id = $1`, id)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pipeline: %w", err)
+	}
+
+	pipeline := row.toStorage()
+
+	return &pipeline, nil
+}
+
+// GetPipelineByName retrieves the pipeline with the given name.
+func (p *Postgres) GetPipelineByName(ctx context.Context, name string) (*storage.Pipeline, error) {
+	var row pipelineScan
+
+	err := sqlscan.Get(ctx, p.db, &row, `SELECT `+pipelineColumns+` FROM pipelines WHERE name = $1`, name)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pipeline by name: %w", err)
+	}
+
+	pipeline := row.toStorage()
+
+	return &pipeline, nil
+}
+
+// DeletePipeline removes a pipeline by its ID. Its runs are removed by the
+// foreign key cascade, and their task data by the pipeline_runs_tasks_delete trigger.
+func (p *Postgres) DeletePipeline(ctx context.Context, id string) error {
+	return p.execOne(ctx, "delete pipeline", `DELETE FROM pipelines WHERE id = $1`, id)
+}
+
+// SavePipelineVersion records the pipeline's next version and makes it
+// current. The pipeline row is locked so that concurrent saves are numbered
+// in turn.
+func (p *Postgres) SavePipelineVersion(ctx context.Context, version storage.PipelineVersion) (*storage.PipelineVersion, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	var current int
+
+	err = sqlscan.Get(ctx, tx, &current, `SELECT version FROM pipelines WHERE id = $1 FOR UPDATE`, version.PipelineID)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to lock pipeline: %w", err)
+	}
+
+	var row pipelineVersionScan
+
+	err = sqlscan.Get(ctx, tx, &row, `
+		INSERT INTO pipeline_versions (pipeline_id, version, content, content_type, driver_dsn, resume_enabled, rbac_expression, author, created_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7, $8
+		FROM pipeline_versions WHERE pipeline_id = $1
+		RETURNING `+versionColumns,
+		version.PipelineID, version.Content, version.ContentType, version.DriverDSN,
+		version.ResumeEnabled, version.RBACExpression, version.Author, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pipeline version: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE pipelines SET version = $1 WHERE id = $2`, row.Version, version.PipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pipeline version: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit pipeline version: %w", err)
+	}
+
+	saved := row.toStorage()
+
+	return &saved, nil
+}
+
+// GetPipelineVersion retrieves one version of a pipeline.
+func (p *Postgres) GetPipelineVersion(ctx context.Context, pipelineID string, version int) (*storage.PipelineVersion, error) {
+	var row pipelineVersionScan
+
+	err := sqlscan.Get(ctx, p.db, &row, `
+		SELECT `+versionColumns+` FROM pipeline_versions WHERE pipeline_id = $1 AND version = $2
+	`, pipelineID, version)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pipeline version: %w", err)
+	}
+
+	v := row.toStorage()
+
+	return &v, nil
+}
+
+// ListPipelineVersions returns a pipeline's versions, newest first.
+func (p *Postgres) ListPipelineVersions(ctx context.Context, pipelineID string, page, perPage int) (*storage.PaginationResult[storage.PipelineVersion], error) {
+	page, perPage = normalizePage(page, perPage)
+
+	var totalItems int
+
+	err := sqlscan.Get(ctx, p.db, &totalItems, `SELECT COUNT(*) FROM pipeline_versions WHERE pipeline_id = $1`, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pipeline versions: %w", err)
+	}
+
+	var rows []pipelineVersionScan
+
+	err = sqlscan.Select(ctx, p.db, &rows, `
+		SELECT `+versionColumns+` FROM pipeline_versions WHERE pipeline_id = $1
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3
+	`, pipelineID, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipeline versions: %w", err)
+	}
+
+	versions := make([]storage.PipelineVersion, 0, len(rows))
+	for _, row := range rows {
+		versions = append(versions, row.toStorage())
+	}
+
+	return paginate(versions, page, perPage, totalItems), nil
+}
+
+// UpdatePipelineResumeEnabled updates the resume_enabled flag for a pipeline.
+func (p *Postgres) UpdatePipelineResumeEnabled(ctx context.Context, pipelineID string, enabled bool) error {
+	return p.execOne(ctx, "update pipeline resume_enabled", `UPDATE pipelines SET resume_enabled = $1 WHERE id = $2`, enabled, pipelineID)
+}
+
+// UpdatePipelineRBACExpression updates the RBAC expression for a pipeline.
+func (p *Postgres) UpdatePipelineRBACExpression(ctx context.Context, pipelineID, expression string) error {
+	return p.execOne(ctx, "update pipeline rbac_expression", `UPDATE pipelines SET rbac_expression = $1 WHERE id = $2`, expression, pipelineID)
+}
+
+// UpdatePipelineSchedule updates the pipeline-level schedule. A nil schedule removes it.
+func (p *Postgres) UpdatePipelineSchedule(ctx context.Context, pipelineID string, schedule *storage.PipelineSchedule) error {
+	value, err := marshalOptional(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline schedule: %w", err)
+	}
+
+	return p.execOne(ctx, "update pipeline schedule", `UPDATE pipelines SET schedule = $1 WHERE id = $2`, value, pipelineID)
+}
+
+// UpdatePipelineMonthlyBudget sets the pipeline's monthly agent budget in USD. Zero removes it.
+func (p *Postgres) UpdatePipelineMonthlyBudget(ctx context.Context, pipelineID string, budget float64) error {
+	return p.execOne(ctx, "update pipeline monthly budget", `UPDATE pipelines SET monthly_budget = $1 WHERE id = $2`, budget, pipelineID)
+}
+
+// UpdatePipelineRetention sets the pipeline's run retention policy. A nil policy removes it.
+func (p *Postgres) UpdatePipelineRetention(ctx context.Context, pipelineID string, retention *storage.PipelineRetention) error {
+	value, err := marshalOptional(retention)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline retention: %w", err)
+	}
+
+	return p.execOne(ctx, "update pipeline retention", `UPDATE pipelines SET retention = $1 WHERE id = $2`, value, pipelineID)
+}
+
+// SaveRun creates a new pipeline run record.
+func (p *Postgres) SaveRun(ctx context.Context, pipelineID string) (*storage.PipelineRun, error) {
+	id := support.UniqueID()
+	now := time.Now().UTC()
+
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO pipeline_runs (id, pipeline_id, status, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, id, pipelineID, storage.RunStatusQueued, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save run: %w", err)
+	}
+
+	return &storage.PipelineRun{
+		ID:         id,
+		PipelineID: pipelineID,
+		Status:     storage.RunStatusQueued,
+		CreatedAt:  now,
+	}, nil
+}
+
+// GetRun retrieves a pipeline run by its ID.
+func (p *Postgres) GetRun(ctx context.Context, runID string) (*storage.PipelineRun, error) {
+	var row pipelineRunScan
+
+	err := sqlscan.Get(ctx, p.db, &row, `SELECT `+runColumns+` FROM pipeline_runs WHERE id = $1`, runID)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get run: %w", err)
+	}
+
+	run := row.toStorage()
+
+	return &run, nil
+}
+
+// GetRunsByStatus returns all pipeline runs with the given status.
+func (p *Postgres) GetRunsByStatus(ctx context.Context, status storage.RunStatus) ([]storage.PipelineRun, error) {
+	var rows []pipelineRunScan
+
+	err := sqlscan.Select(ctx, p.db, &rows, `
+		SELECT `+runColumns+` FROM pipeline_runs WHERE status = $1
+		ORDER BY created_at DESC
+	`, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get runs by status: %w", err)
+	}
+
+	return toRuns(rows), nil
+}
+
+// GetRunStats returns the count of pipeline runs grouped by status.
+func (p *Postgres) GetRunStats(ctx context.Context) (map[storage.RunStatus]int, error) {
+	type row struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
+	}
+
+	var rows []row
+
+	err := sqlscan.Select(ctx, p.db, &rows, `SELECT status, COUNT(*) AS count FROM pipeline_runs GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run stats: %w", err)
+	}
+
+	stats := make(map[storage.RunStatus]int, len(rows))
+	for _, r := range rows {
+		stats[storage.RunStatus(r.Status)] = r.Count
+	}
+
+	return stats, nil
+}
+
+// GetRecentRunsByStatus returns the most recent N pipeline runs with the given status.
+func (p *Postgres) GetRecentRunsByStatus(ctx context.Context, status storage.RunStatus, limit int) ([]storage.PipelineRun, error) {
+	var rows []pipelineRunScan
+
+	err := sqlscan.Select(ctx, p.db, &rows, `
+		SELECT `+runColumns+` FROM pipeline_runs WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, string(status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent runs by status: %w", err)
+	}
+
+	return toRuns(rows), nil
+}
+
+// SearchRunsByPipeline returns a paginated list of runs for a specific pipeline
+// filtered by query matching the run ID, status, or error message.
+// When query is empty it returns all runs ordered by creation date descending.
+func (p *Postgres) SearchRunsByPipeline(ctx context.Context, pipelineID, query string, page, perPage int) (*storage.PaginationResult[storage.PipelineRun], error) {
+	page, perPage = normalizePage(page, perPage)
+	tsQuery := toTSQuery(query)
+
+	var totalItems int
+
+	err := sqlscan.Get(ctx, p.db, &totalItems, `
+		SELECT COUNT(*) FROM pipeline_runs
+		WHERE pipeline_id = $1 AND ($2::TEXT = '' OR search @@ to_tsquery('simple', $2::TEXT))
+	`, pipelineID, tsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count runs: %w", err)
+	}
+
+	var rows []pipelineRunScan
+
+	err = sqlscan.Select(ctx, p.db, &rows, `
+		SELECT `+runColumns+` FROM pipeline_runs
+		WHERE pipeline_id = $1 AND ($2::TEXT = '' OR search @@ to_tsquery('simple', $2::TEXT))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, pipelineID, tsQuery, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search runs: %w", err)
+	}
+
+	return paginate(toRuns(rows), page, perPage, totalItems), nil
+}
+
+func (p *Postgres) UpdateRunStatus(ctx context.Context, runID string, status storage.RunStatus, errorMessage string) error {
+	now := time.Now().UTC()
+
+	switch status {
+	case storage.RunStatusRunning:
+		return p.execOne(ctx, "update run status",
+			`UPDATE pipeline_runs SET status = $1, started_at = $2 WHERE id = $3`,
+			status, now, runID)
+	case storage.RunStatusSuccess, storage.RunStatusFailed, storage.RunStatusSkipped:
+		return p.execOne(ctx, "update run status",
+			`UPDATE pipeline_runs SET status = $1, completed_at = $2, error_message = $3 WHERE id = $4`,
+			status, now, errorMessage, runID)
+	default:
+		return p.execOne(ctx, "update run status",
+			`UPDATE pipeline_runs SET status = $1 WHERE id = $2`,
+			status, runID)
+	}
+}
+
+// DeleteRun removes a run. The pipeline_runs_tasks_delete trigger removes the
+// run's task records and logs with it.
+func (p *Postgres) DeleteRun(ctx context.Context, runID string) error {
+	return p.execOne(ctx, "delete run", `DELETE FROM pipeline_runs WHERE id = $1`, runID)
+}
+
+// UpdateRunPipelineVersion records the pipeline version a run executes.
+func (p *Postgres) UpdateRunPipelineVersion(ctx context.Context, runID string, version int) error {
+	return p.execOne(ctx, "update run pipeline version", `UPDATE pipeline_runs SET pipeline_version = $1 WHERE id = $2`, version, runID)
+}
+
+// AddRunCost adds cost, in USD, to the run's agent cost and to the
+// pipeline_costs ledger, which outlives the run.
+func (p *Postgres) AddRunCost(ctx context.Context, runID string, cost float64) error {
+	return p.execOne(ctx, "add run cost", `
+		WITH run AS (
+			UPDATE pipeline_runs SET cost = cost + $1 WHERE id = $2 RETURNING pipeline_id
+		)
+		INSERT INTO pipeline_costs (pipeline_id, month, cost)
+		SELECT pipeline_id, $3, $1 FROM run
+		ON CONFLICT (pipeline_id, month) DO UPDATE SET cost = pipeline_costs.cost + EXCLUDED.cost
+	`, cost, runID, storage.CostMonth(time.Now()))
+}
+
+// GetPipelineCost returns the pipeline's cost in the ledger for month.
+func (p *Postgres) GetPipelineCost(ctx context.Context, pipelineID string, month time.Time) (float64, error) {
+	var cost float64
+
+	err := sqlscan.Get(ctx, p.db, &cost, `
+		SELECT COALESCE(SUM(cost), 0) FROM pipeline_costs
+		WHERE pipeline_id = $1 AND month = $2
+	`, pipelineID, storage.CostMonth(month))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pipeline cost: %w", err)
+	}
+
+	return cost, nil
+}
+
+// SearchPipelines returns pipelines whose name or content match query. When
+// query is empty it returns all pipelines ordered by creation date descending.
+func (p *Postgres) SearchPipelines(ctx context.Context, query string, page, perPage int) (*storage.PaginationResult[storage.Pipeline], error) {
+	page, perPage = normalizePage(page, perPage)
+	tsQuery := toTSQuery(query)
+
+	var totalItems int
+
+	err := sqlscan.Get(ctx, p.db, &totalItems, `
+		SELECT COUNT(*) FROM pipelines
+		WHERE $1::TEXT = '' OR search @@ to_tsquery('simple', $1::TEXT)
+	`, tsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pipelines: %w", err)
+	}
+
+	var rows []pipelineScan
+
+	err = sqlscan.Select(ctx, p.db, &rows, `
+		SELECT `+pipelineColumns+` FROM pipelines
+		WHERE $1::TEXT = '' OR search @@ to_tsquery('simple', $1::TEXT)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, tsQuery, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pipelines: %w", err)
+	}
+
+	pipelines := make([]storage.Pipeline, 0, len(rows))
+	for _, row := range rows {
+		pipelines = append(pipelines, row.toStorage())
+	}
+
+	return paginate(pipelines, page, perPage, totalItems), nil
+}
+
+// Search returns records whose indexed text or logs match query and whose path
+// begins with prefix. prefix follows the same convention as Set (no namespace
+// prefix).
+func (p *Postgres) Search(ctx context.Context, prefix, query string) (storage.Results, error) {
+	tsQuery := toTSQuery(query)
+	if tsQuery == "" {
+		return nil, nil
+	}
+
+	var results storage.Results
+
+	err := sqlscan.Select(ctx, p.db, &results, `
+		SELECT
+			COALESCE(t.id, 0) AS id,
+			m.path,
+			jsonb_build_object(
+				'status',     t.payload -> 'status',
+				'elapsed',    t.payload -> 'elapsed',
+				'started_at', t.payload -> 'started_at'
+			) AS payload
+		FROM (
+			SELECT path FROM tasks
+			WHERE search @@ to_tsquery('simple', $1::TEXT) AND path LIKE $2
+			UNION
+			SELECT path FROM task_logs
+			WHERE search @@ to_tsquery('simple', $1::TEXT) AND path LIKE $2
+		) m
+		LEFT JOIN tasks t ON t.path = m.path
+		ORDER BY t.id ASC NULLS LAST, m.path ASC
+	`, tsQuery, likePrefix(p.fullPath(prefix)+"/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	return results, nil
+}
+
+// execOne runs a statement that must affect a row, returning
+// storage.ErrNotFound when it affects none.
+func (p *Postgres) execOne(ctx context.Context, action, query string, args ...any) error {
+	result, err := p.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// marshalOptional encodes value as JSON, or as an empty string when it is nil.
+func marshalOptional[T any](value *T) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+// searchText is the text indexed for a task: the components of its path and
+// the strings of its payload.
+func searchText(path string, contents []byte) string {
+	return clipSearchText(strings.ReplaceAll(path, "/", " ") + " " + storage.SearchText(contents))
+}
+
+// clipSearchText truncates text to maxSearchText bytes on a rune boundary.
+func clipSearchText(text string) string {
+	if len(text) <= maxSearchText {
+		return text
+	}
+
+	text = text[:maxSearchText]
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+
+	return text
+}
+
+// likePrefix returns a LIKE pattern matching strings that begin with prefix.
+func likePrefix(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	return escaper.Replace(prefix) + "%"
+}
+
+// toTSQuery converts a freeform user query into a safe tsquery. Each
+// whitespace-separated token is quoted as a literal prefix match term,
+// preventing accidental use of tsquery operators (&, |, !, etc.).
+func toTSQuery(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return ""
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `'`, `''`)
+	terms := make([]string, 0, len(words))
+
+	for _, word := range words {
+		terms = append(terms, "'"+escaper.Replace(word)+"':*")
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+func normalizePage(page, perPage int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	return page, perPage
+}
+
+func paginate[T any](items []T, page, perPage, totalItems int) *storage.PaginationResult[T] {
+	totalPages := (totalItems + perPage - 1) / perPage
+
+	return &storage.PaginationResult[T]{
+		Items:      items,
+		Page:       page,
+		PerPage:    perPage,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+	}
+}
+
+func toRuns(rows []pipelineRunScan) []storage.PipelineRun {
+	runs := make([]storage.PipelineRun, 0, len(rows))
+	for _, row := range rows {
+		runs = append(runs, row.toStorage())
+	}
+
+	return runs
+}
+
+func init() {
+	storage.Add("postgres", NewPostgres)
+}
@@ -0,0 +1,141 @@
+CREATE TABLE IF NOT EXISTS tasks (
+  id BIGSERIAL PRIMARY KEY,
+  path TEXT NOT NULL UNIQUE,
+  payload JSONB,
+  search TSVECTOR,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Prefix lookups use LIKE 'prefix%', which needs the pattern operator class
+-- unless the database collation is C.
+CREATE INDEX IF NOT EXISTS tasks_path_pattern ON tasks (path text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS tasks_search ON tasks USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS pipelines (
+  id TEXT NOT NULL PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  content TEXT NOT NULL,
+  content_type TEXT NOT NULL DEFAULT '',
+  driver_dsn TEXT NOT NULL,
+  resume_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  rbac_expression TEXT NOT NULL DEFAULT '',
+  schedule TEXT NOT NULL DEFAULT '',
+  monthly_budget DOUBLE PRECISION NOT NULL DEFAULT 0,
+  retention TEXT NOT NULL DEFAULT '',
+  version INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  search TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple', name || ' ' || content)
+  ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS pipelines_search ON pipelines USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+  id TEXT NOT NULL PRIMARY KEY,
+  pipeline_id TEXT NOT NULL REFERENCES pipelines(id) ON DELETE CASCADE,
+  status TEXT NOT NULL,
+  started_at TIMESTAMPTZ,
+  completed_at TIMESTAMPTZ,
+  error_message TEXT,
+  cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+  pipeline_version INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  search TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple', id || ' ' || status || ' ' || COALESCE(error_message, ''))
+  ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS pipeline_runs_pipeline_created ON pipeline_runs (pipeline_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS pipeline_runs_status ON pipeline_runs (status);
+
+CREATE INDEX IF NOT EXISTS pipeline_runs_search ON pipeline_runs USING GIN (search);
+
+-- Columns added after the tables were first created.
+ALTER TABLE pipelines ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE pipeline_runs ADD COLUMN IF NOT EXISTS pipeline_version INTEGER NOT NULL DEFAULT 0;
+
+-- Immutable snapshots of a pipeline's definition, one per set-pipeline.
+CREATE TABLE IF NOT EXISTS pipeline_versions (
+  pipeline_id TEXT NOT NULL REFERENCES pipelines(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  content TEXT NOT NULL,
+  content_type TEXT NOT NULL DEFAULT '',
+  driver_dsn TEXT NOT NULL,
+  resume_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  rbac_expression TEXT NOT NULL DEFAULT '',
+  author TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (pipeline_id, version)
+);
+
+-- Each pipeline's agent cost by month, kept apart from its runs so that
+-- deleting old runs does not lower what counts against its monthly budget.
+CREATE TABLE IF NOT EXISTS pipeline_costs (
+  pipeline_id TEXT NOT NULL REFERENCES pipelines(id) ON DELETE CASCADE,
+  month TEXT NOT NULL,
+  cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+  PRIMARY KEY (pipeline_id, month)
+);
+
+-- Task output, appended in chunks as it streams rather than kept in the
+-- task's payload. path matches tasks.path.
+CREATE TABLE IF NOT EXISTS task_logs (
+  path TEXT NOT NULL,
+  sequence INTEGER NOT NULL,
+  stream TEXT NOT NULL,
+  content TEXT NOT NULL,
+  search TSVECTOR,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (path, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS task_logs_path_pattern ON task_logs (path text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS task_logs_search ON task_logs USING GIN (search);
+
+-- pocketci_merge_patch applies patch to target as an RFC 7396 JSON merge
+-- patch, like SQLite's jsonb_patch: objects merge recursively, a null removes
+-- the key, and anything else replaces the target.
+CREATE OR REPLACE FUNCTION pocketci_merge_patch(target JSONB, patch JSONB) RETURNS JSONB AS $$
+BEGIN
+  IF patch IS NULL OR jsonb_typeof(patch) <> 'object' THEN
+    RETURN patch;
+  END IF;
+
+  IF target IS NULL OR jsonb_typeof(target) <> 'object' THEN
+    target := '{}'::JSONB;
+  END IF;
+
+  RETURN COALESCE((
+    SELECT jsonb_object_agg(merged.key, merged.value)
+    FROM (
+      SELECT t.key, t.value FROM jsonb_each(target) t WHERE NOT patch ? t.key
+      UNION ALL
+      SELECT p.key, pocketci_merge_patch(target -> p.key, p.value)
+      FROM jsonb_each(patch) p
+      WHERE jsonb_typeof(p.value) <> 'null'
+    ) merged
+  ), '{}'::JSONB);
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Remove task data and logs stored under .../{namespace}/pipeline/{run_id}/...
+-- when a pipeline run is deleted, directly or by the cascade from its pipeline.
+CREATE OR REPLACE FUNCTION pocketci_pipeline_runs_tasks_delete() RETURNS TRIGGER AS $$
+BEGIN
+  DELETE FROM tasks WHERE strpos(path, '/pipeline/' || OLD.id || '/') > 0;
+  DELETE FROM task_logs WHERE strpos(path, '/pipeline/' || OLD.id || '/') > 0;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS pipeline_runs_tasks_delete ON pipeline_runs;
+
+CREATE TRIGGER pipeline_runs_tasks_delete
+AFTER
+  DELETE ON pipeline_runs FOR EACH ROW EXECUTE FUNCTION pocketci_pipeline_runs_tasks_delete();
@@ -3,6 +3,7 @@ package storage_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/jtarchie/pocketci/storage"
 	_ "github.com/jtarchie/pocketci/storage/s3"
@@ -162,6 +163,42 @@ func TestPipelineRunStorage(t *testing.T) {
 				assert.Expect(err).To(Equal(storage.ErrNotFound))
 			})
 
+			t.Run("DeleteRun removes the run and its task data", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+
+				pipeline, err := client.SavePipeline(ctx, "test-pipeline", "content", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				deleted, err := client.SaveRun(ctx, pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				kept, err := client.SaveRun(ctx, pipeline.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				assert.Expect(client.Set(ctx, "/pipeline/"+deleted.ID+"/jobs/build", map[string]any{"status": "success"})).To(Succeed())
+				assert.Expect(client.Set(ctx, "/pipeline/"+kept.ID+"/jobs/build", map[string]any{"status": "success"})).To(Succeed())
+
+				err = client.DeleteRun(ctx, deleted.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				_, err = client.GetRun(ctx, deleted.ID)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+
+				results, err := client.GetAll(ctx, "/pipeline/"+deleted.ID, []string{"status"})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(BeEmpty())
+
+				results, err = client.GetAll(ctx, "/pipeline/"+kept.ID, []string{"status"})
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(results).To(HaveLen(1))
+
+				err = client.DeleteRun(ctx, deleted.ID)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
+
 			t.Run("SearchRunsByPipeline", func(t *testing.T) {
 				t.Run("empty query returns all runs for pipeline", func(t *testing.T) {
 					assert := NewGomegaWithT(t)
@@ -276,6 +313,54 @@ func TestPipelineRunStorage(t *testing.T) {
 					assert.Expect(result.Items[0].PipelineID).To(Equal(pipA.ID))
 				})
 			})
+
+			t.Run("AddRunCost accumulates into the pipeline's cost", func(t *testing.T) {
+				assert := NewGomegaWithT(t)
+
+				client := newStorageClient(t, name, init, "namespace")
+
+				ctx := context.Background()
+				now := time.Now()
+
+				pipA, err := client.SavePipeline(ctx, "pipeline-a", "content", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+				pipB, err := client.SavePipeline(ctx, "pipeline-b", "other", "native://", "")
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				first, err := client.SaveRun(ctx, pipA.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				second, err := client.SaveRun(ctx, pipA.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				other, err := client.SaveRun(ctx, pipB.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+
+				assert.Expect(client.AddRunCost(ctx, first.ID, 0.25)).To(Succeed())
+				assert.Expect(client.AddRunCost(ctx, first.ID, 0.5)).To(Succeed())
+				assert.Expect(client.AddRunCost(ctx, second.ID, 1)).To(Succeed())
+				assert.Expect(client.AddRunCost(ctx, other.ID, 2)).To(Succeed())
+
+				run, err := client.GetRun(ctx, first.ID)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(run.Cost).To(BeNumerically("~", 0.75))
+
+				cost, err := client.GetPipelineCost(ctx, pipA.ID, now)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(cost).To(BeNumerically("~", 1.75))
+
+				cost, err = client.GetPipelineCost(ctx, pipA.ID, now.AddDate(0, 1, 0))
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(cost).To(BeZero())
+
+				// Deleting a run keeps what it spent in the pipeline's cost.
+				assert.Expect(client.DeleteRun(ctx, first.ID)).To(Succeed())
+
+				cost, err = client.GetPipelineCost(ctx, pipA.ID, now)
+				assert.Expect(err).NotTo(HaveOccurred())
+				assert.Expect(cost).To(BeNumerically("~", 1.75))
+
+				err = client.AddRunCost(ctx, "non-existent-id", 1)
+				assert.Expect(err).To(Equal(storage.ErrNotFound))
+			})
 		})
 	})
 }
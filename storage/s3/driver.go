@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jtarchie/pocketci/runtime/support"
@@ -25,6 +27,9 @@ type S3 struct {
 	*s3config.Client
 	namespace string
 	logger    *slog.Logger
+
+	logsMu           sync.Mutex
+	nextLogSequences map[string]int // next chunk sequence by logs key prefix, while the task's logs are open
 }
 
 // NewS3 creates a new S3-backed storage driver.
@@ -40,9 +45,10 @@ func NewS3(dsn string, namespace string, logger *slog.Logger) (storage.Driver, e
 	}
 
 	return &S3{
-		Client:    client,
-		namespace: namespace,
-		logger:    logger,
+		Client:           client,
+		namespace:        namespace,
+		logger:           logger,
+		nextLogSequences: map[string]int{},
 	}, nil
 }
 
@@ -190,6 +196,185 @@ func (s *S3) UpdateStatusForPrefix(ctx context.Context, prefix string, matchStat
 	return nil
 }
 
+// Delete removes the task at prefix and the tasks beneath it, along with
+// their logs.
+func (s *S3) Delete(ctx context.Context, prefix string) error {
+	keyPrefix := s.taskKey(prefix)
+
+	keys, err := s.ListKeys(ctx, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	for _, key := range keys {
+		if key != keyPrefix && !strings.HasPrefix(key, keyPrefix+"/") {
+			continue
+		}
+
+		err = s.DeleteKey(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to delete task for key %q: %w", key, err)
+		}
+	}
+
+	logsPrefix := s.logsPrefix(prefix)
+
+	logKeys, err := s.ListKeys(ctx, logsPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list task logs: %w", err)
+	}
+
+	for _, key := range logKeys {
+		err = s.DeleteKey(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to delete task log for key %q: %w", key, err)
+		}
+	}
+
+	s.logsMu.Lock()
+	for key := range s.nextLogSequences {
+		if strings.HasPrefix(key, logsPrefix) {
+			delete(s.nextLogSequences, key)
+		}
+	}
+	s.logsMu.Unlock()
+
+	return nil
+}
+
+// ─── Task logs ──────────────────────────────────────────────────────────────
+
+// AppendLogs stores each chunk as its own object after the task's existing
+// logs. The next sequence of a task is listed once and then kept in memory
+// until CloseLogs.
+func (s *S3) AppendLogs(ctx context.Context, prefix string, chunks []storage.LogChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	keyPrefix := s.logsPrefix(prefix)
+
+	s.logsMu.Lock()
+	next, ok := s.nextLogSequences[keyPrefix]
+	s.logsMu.Unlock()
+
+	if !ok {
+		sequences, err := s.logSequences(ctx, keyPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to list task logs: %w", err)
+		}
+
+		if len(sequences) > 0 {
+			next = sequences[len(sequences)-1] + 1
+		}
+	}
+
+	// Reserve the sequences before writing, so concurrent appends do not
+	// overwrite each other's chunks.
+	s.logsMu.Lock()
+	if cached, ok := s.nextLogSequences[keyPrefix]; ok && cached > next {
+		next = cached
+	}
+	s.nextLogSequences[keyPrefix] = next + len(chunks)
+	s.logsMu.Unlock()
+
+	for _, chunk := range chunks {
+		chunk.Sequence = next
+		if chunk.CreatedAt.IsZero() {
+			chunk.CreatedAt = time.Now().UTC()
+		}
+
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to marshal log chunk: %w", err)
+		}
+
+		err = s.putJSON(ctx, logKey(keyPrefix, next), data)
+		if err != nil {
+			return fmt.Errorf("failed to save log chunk: %w", err)
+		}
+
+		next++
+	}
+
+	return nil
+}
+
+// CloseLogs forgets the task's next sequence, so that the cache only holds
+// tasks whose logs are still being appended.
+func (s *S3) CloseLogs(_ context.Context, prefix string) error {
+	s.logsMu.Lock()
+	defer s.logsMu.Unlock()
+
+	delete(s.nextLogSequences, s.logsPrefix(prefix))
+
+	return nil
+}
+
+// GetLogs returns the task's logs from sequence offset, in order.
+func (s *S3) GetLogs(ctx context.Context, prefix string, offset, limit int) ([]storage.LogChunk, error) {
+	keyPrefix := s.logsPrefix(prefix)
+
+	sequences, err := s.logSequences(ctx, keyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task logs: %w", err)
+	}
+
+	chunks := []storage.LogChunk{}
+
+	for _, sequence := range sequences {
+		if sequence < offset {
+			continue
+		}
+
+		if limit > 0 && len(chunks) >= limit {
+			break
+		}
+
+		chunk, err := s.getLogChunk(ctx, logKey(keyPrefix, sequence))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		chunks = append(chunks, *chunk)
+	}
+
+	return chunks, nil
+}
+
+// logSequences returns the sequences of the chunks stored directly under
+// keyPrefix, in order. The chunks of tasks beneath it are skipped.
+func (s *S3) logSequences(ctx context.Context, keyPrefix string) ([]int, error) {
+	keys, err := s.ListKeys(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sequences := make([]int, 0, len(keys))
+
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, keyPrefix)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		sequence, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+
+		sequences = append(sequences, sequence)
+	}
+
+	sort.Ints(sequences)
+
+	return sequences, nil
+}
+
 // ─── Pipeline CRUD ──────────────────────────────────────────────────────────
 
 func (s *S3) SavePipeline(ctx context.Context, name, content, driverDSN, contentType string) (*storage.Pipeline, error) {
@@ -223,6 +408,7 @@ func (s *S3) SavePipeline(ctx context.Context, name, content, driverDSN, content
 
 	if existing != nil {
 		pipeline.CreatedAt = existing.CreatedAt
+		pipeline.Version = existing.Version
 	}
 
 	data, err := json.Marshal(pipeline)
@@ -279,9 +465,121 @@ func (s *S3) DeletePipeline(ctx context.Context, id string) error {
 		}
 	}
 
+	versionKeys, err := s.ListKeys(ctx, s.pipelineVersionsPrefix(id))
+	if err != nil {
+		return nil
+	}
+
+	for _, key := range versionKeys {
+		_ = s.DeleteKey(ctx, key)
+	}
+
+	costKeys, err := s.ListKeys(ctx, s.pipelineCostsPrefix(id))
+	if err != nil {
+		return nil
+	}
+
+	for _, key := range costKeys {
+		_ = s.DeleteKey(ctx, key)
+	}
+
 	return nil
 }
 
+// SavePipelineVersion records the pipeline's next version and makes it current.
+func (s *S3) SavePipelineVersion(ctx context.Context, version storage.PipelineVersion) (*storage.PipelineVersion, error) {
+	pipeline, err := s.GetPipeline(ctx, version.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.pipelineVersions(ctx, version.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	version.Version = 1
+	if len(versions) > 0 {
+		version.Version = versions[0].Version + 1
+	}
+
+	version.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pipeline version: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineVersionKey(version.PipelineID, version.Version), data); err != nil {
+		return nil, fmt.Errorf("failed to save pipeline version: %w", err)
+	}
+
+	pipeline.Version = version.Version
+
+	data, err = json.Marshal(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pipeline: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineByIDKey(pipeline.ID), data); err != nil {
+		return nil, fmt.Errorf("failed to update pipeline by id: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineByNameKey(pipeline.Name), data); err != nil {
+		return nil, fmt.Errorf("failed to update pipeline by name: %w", err)
+	}
+
+	return &version, nil
+}
+
+// GetPipelineVersion retrieves one version of a pipeline.
+func (s *S3) GetPipelineVersion(ctx context.Context, pipelineID string, version int) (*storage.PipelineVersion, error) {
+	return s.getPipelineVersion(ctx, s.pipelineVersionKey(pipelineID, version))
+}
+
+// ListPipelineVersions returns a pipeline's versions, newest first.
+func (s *S3) ListPipelineVersions(ctx context.Context, pipelineID string, page, perPage int) (*storage.PaginationResult[storage.PipelineVersion], error) {
+	if page < 1 {
+		page = 1
+	}
+
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	versions, err := s.pipelineVersions(ctx, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	return paginate(versions, page, perPage), nil
+}
+
+// pipelineVersions returns every version of a pipeline, newest first.
+func (s *S3) pipelineVersions(ctx context.Context, pipelineID string) ([]storage.PipelineVersion, error) {
+	keys, err := s.ListKeys(ctx, s.pipelineVersionsPrefix(pipelineID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipeline versions: %w", err)
+	}
+
+	versions := make([]storage.PipelineVersion, 0, len(keys))
+
+	for _, key := range keys {
+		version, err := s.getPipelineVersion(ctx, key)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, *version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+
+	return versions, nil
+}
+
 // UpdatePipelineResumeEnabled updates the resume_enabled flag for a pipeline.
 func (s *S3) UpdatePipelineResumeEnabled(ctx context.Context, pipelineID string, enabled bool) error {
 	pipeline, err := s.GetPipeline(ctx, pipelineID)
@@ -324,6 +622,69 @@ func (s *S3) UpdatePipelineRBACExpression(ctx context.Context, pipelineID, expre
 	return nil
 }
 
+// UpdatePipelineSchedule updates the pipeline-level schedule. A nil schedule removes it.
+func (s *S3) UpdatePipelineSchedule(ctx context.Context, pipelineID string, schedule *storage.PipelineSchedule) error {
+	pipeline, err := s.GetPipeline(ctx, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	pipeline.Schedule = schedule
+
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineByIDKey(pipelineID), data); err != nil {
+		return fmt.Errorf("failed to update pipeline: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePipelineMonthlyBudget sets the pipeline's monthly agent budget in USD. Zero removes it.
+func (s *S3) UpdatePipelineMonthlyBudget(ctx context.Context, pipelineID string, budget float64) error {
+	pipeline, err := s.GetPipeline(ctx, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	pipeline.MonthlyBudget = budget
+
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineByIDKey(pipelineID), data); err != nil {
+		return fmt.Errorf("failed to update pipeline: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePipelineRetention sets the pipeline's run retention policy. A nil policy removes it.
+func (s *S3) UpdatePipelineRetention(ctx context.Context, pipelineID string, retention *storage.PipelineRetention) error {
+	pipeline, err := s.GetPipeline(ctx, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	pipeline.Retention = retention
+
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline: %w", err)
+	}
+
+	if err := s.putJSON(ctx, s.pipelineByIDKey(pipelineID), data); err != nil {
+		return fmt.Errorf("failed to update pipeline: %w", err)
+	}
+
+	return nil
+}
+
 // ─── Pipeline Run operations ────────────────────────────────────────────────
 
 func (s *S3) SaveRun(ctx context.Context, pipelineID string) (*storage.PipelineRun, error) {
@@ -440,6 +801,113 @@ func (s *S3) UpdateRunStatus(ctx context.Context, runID string, status storage.R
 	return s.putJSON(ctx, s.runKey(runID), data)
 }
 
+// DeleteRun removes a run along with the task records stored under /pipeline/<runID>.
+func (s *S3) DeleteRun(ctx context.Context, runID string) error {
+	if _, err := s.GetRun(ctx, runID); err != nil {
+		return err
+	}
+
+	if err := s.Delete(ctx, "/pipeline/"+runID); err != nil {
+		return fmt.Errorf("failed to delete run tasks: %w", err)
+	}
+
+	if err := s.DeleteKey(ctx, s.runKey(runID)); err != nil {
+		return fmt.Errorf("failed to delete run: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRunPipelineVersion records the pipeline version a run executes.
+func (s *S3) UpdateRunPipelineVersion(ctx context.Context, runID string, version int) error {
+	run, err := s.GetRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	run.PipelineVersion = version
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated run: %w", err)
+	}
+
+	return s.putJSON(ctx, s.runKey(runID), data)
+}
+
+// pipelineCost is a pipeline's agent cost for one month.
+type pipelineCost struct {
+	Cost float64 `json:"cost"`
+}
+
+// AddRunCost adds cost, in USD, to the run's agent cost and to the pipeline's
+// monthly cost object, which outlives the run.
+func (s *S3) AddRunCost(ctx context.Context, runID string, cost float64) error {
+	run, err := s.GetRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	run.Cost += cost
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated run: %w", err)
+	}
+
+	err = s.putJSON(ctx, s.runKey(runID), data)
+	if err != nil {
+		return err
+	}
+
+	key := s.pipelineCostKey(run.PipelineID, time.Now())
+
+	monthly, err := s.getPipelineCost(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	monthly.Cost += cost
+
+	data, err = json.Marshal(monthly)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline cost: %w", err)
+	}
+
+	return s.putJSON(ctx, key, data)
+}
+
+// GetPipelineCost returns the pipeline's cost object for month.
+func (s *S3) GetPipelineCost(ctx context.Context, pipelineID string, month time.Time) (float64, error) {
+	monthly, err := s.getPipelineCost(ctx, s.pipelineCostKey(pipelineID, month))
+	if err != nil {
+		return 0, err
+	}
+
+	return monthly.Cost, nil
+}
+
+// getPipelineCost reads the cost object at key, which is zero until written.
+func (s *S3) getPipelineCost(ctx context.Context, key string) (pipelineCost, error) {
+	var monthly pipelineCost
+
+	data, err := s.GetBytes(ctx, key)
+	if err != nil {
+		if s3config.IsNotFound(err) {
+			return monthly, nil
+		}
+
+		return monthly, fmt.Errorf("failed to get pipeline cost: %w", err)
+	}
+
+	err = json.Unmarshal(data, &monthly)
+	if err != nil {
+		return monthly, fmt.Errorf("failed to unmarshal pipeline cost: %w", err)
+	}
+
+	return monthly, nil
+}
+
 func (s *S3) SearchRunsByPipeline(ctx context.Context, pipelineID, query string, page, perPage int) (*storage.PaginationResult[storage.PipelineRun], error) {
 	if page < 1 {
 		page = 1
@@ -531,6 +999,8 @@ func (s *S3) Search(ctx context.Context, prefix, query string) (storage.Results,
 	lowerQuery := strings.ToLower(query)
 	var results storage.Results
 
+	matched := map[string]bool{}
+
 	for _, key := range keys {
 		payload, err := s.getJSON(ctx, key)
 		if err != nil {
@@ -541,30 +1011,68 @@ func (s *S3) Search(ctx context.Context, prefix, query string) (storage.Results,
 		logicalPath = strings.TrimPrefix(logicalPath, "tasks")
 
 		if pathOrPayloadMatches(logicalPath, payload, lowerQuery) {
-			summary := storage.Payload{}
-			if v, ok := payload["status"]; ok {
-				summary["status"] = v
-			}
-
-			if v, ok := payload["elapsed"]; ok {
-				summary["elapsed"] = v
-			}
-
-			if v, ok := payload["started_at"]; ok {
-				summary["started_at"] = v
-			}
+			matched[logicalPath] = true
 
 			results = append(results, storage.Result{
 				ID:      0,
 				Path:    logicalPath,
-				Payload: summary,
+				Payload: searchSummary(payload),
 			})
 		}
 	}
 
+	logKeys, err := s.ListKeys(ctx, s.logsPrefix(prefix))
+	if err != nil {
+		return results, nil
+	}
+
+	sort.Strings(logKeys)
+
+	for _, key := range logKeys {
+		logicalPath := path.Dir(strings.TrimPrefix(s.StripPrefix(key), "logs"))
+		if matched[logicalPath] {
+			continue
+		}
+
+		chunk, err := s.getLogChunk(ctx, key)
+		if err != nil {
+			continue
+		}
+
+		if !strings.Contains(strings.ToLower(storage.LogSearchText(chunk.Content)), lowerQuery) {
+			continue
+		}
+
+		matched[logicalPath] = true
+
+		payload, err := s.getJSON(ctx, s.FullKey("tasks"+logicalPath))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+
+		results = append(results, storage.Result{
+			ID:      0,
+			Path:    logicalPath,
+			Payload: searchSummary(payload),
+		})
+	}
+
 	return results, nil
 }
 
+// searchSummary returns the fields of a task's payload that Search reports.
+func searchSummary(payload storage.Payload) storage.Payload {
+	summary := storage.Payload{}
+
+	for _, field := range []string{"status", "elapsed", "started_at"} {
+		if v, ok := payload[field]; ok {
+			summary[field] = v
+		}
+	}
+
+	return summary
+}
+
 // ─── S3 key helpers ─────────────────────────────────────────────────────────
 
 func (s *S3) pipelineByIDKey(id string) string {
@@ -575,6 +1083,32 @@ func (s *S3) pipelineByNameKey(name string) string {
 	return s.FullKey("pipelines/by-name/" + name + ".json")
 }
 
+func (s *S3) pipelineVersionsPrefix(pipelineID string) string {
+	return s.FullKey("pipelines/versions/" + pipelineID + "/")
+}
+
+func (s *S3) pipelineVersionKey(pipelineID string, version int) string {
+	return s.FullKey(fmt.Sprintf("pipelines/versions/%s/%08d.json", pipelineID, version))
+}
+
+// logsPrefix returns the key prefix under which the log chunks of the task at
+// prefix, and of the tasks beneath it, are stored.
+func (s *S3) logsPrefix(prefix string) string {
+	return s.FullKey("logs" + path.Clean("/"+s.namespace+"/"+prefix) + "/")
+}
+
+func logKey(logsPrefix string, sequence int) string {
+	return fmt.Sprintf("%s%010d.json", logsPrefix, sequence)
+}
+
+func (s *S3) pipelineCostsPrefix(pipelineID string) string {
+	return s.FullKey("pipelines/costs/" + pipelineID + "/")
+}
+
+func (s *S3) pipelineCostKey(pipelineID string, month time.Time) string {
+	return s.pipelineCostsPrefix(pipelineID) + storage.CostMonth(month) + ".json"
+}
+
 func (s *S3) runKey(id string) string {
 	return s.FullKey("runs/" + id + ".json")
 }
@@ -627,6 +1161,44 @@ func (s *S3) getPipeline(ctx context.Context, key string) (*storage.Pipeline, er
 	return &pipeline, nil
 }
 
+func (s *S3) getPipelineVersion(ctx context.Context, key string) (*storage.PipelineVersion, error) {
+	data, err := s.GetBytes(ctx, key)
+	if err != nil {
+		if s3config.IsNotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pipeline version %q: %w", key, err)
+	}
+
+	var version storage.PipelineVersion
+
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pipeline version %q: %w", key, err)
+	}
+
+	return &version, nil
+}
+
+func (s *S3) getLogChunk(ctx context.Context, key string) (*storage.LogChunk, error) {
+	data, err := s.GetBytes(ctx, key)
+	if err != nil {
+		if s3config.IsNotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get log chunk %q: %w", key, err)
+	}
+
+	var chunk storage.LogChunk
+
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log chunk %q: %w", key, err)
+	}
+
+	return &chunk, nil
+}
+
 func (s *S3) getRun(ctx context.Context, key string) (*storage.PipelineRun, error) {
 	data, err := s.GetBytes(ctx, key)
 	if err != nil {
@@ -20,12 +20,6 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-//go:embed schema.sql
-var schemaSQL string
-
-//go:embed migrations.sql
-var migrationsSQL string
-
 type Sqlite struct {
 	writer    *sql.DB
 	reader    *sql.DB
@@ -36,21 +30,41 @@ type Sqlite struct {
 // pipelineScan is an intermediate struct for scanning pipeline rows.
 // SQLite stores timestamps as RFC3339 strings, so we scan into strings first.
 type pipelineScan struct {
-	ID             string `db:"id"`
-	Name           string `db:"name"`
-	Content        string `db:"content"`
-	ContentType    string `db:"content_type"`
-	DriverDSN      string `db:"driver_dsn"`
-	ResumeEnabled  int    `db:"resume_enabled"`
-	RBACExpression string `db:"rbac_expression"`
-	CreatedAt      string `db:"created_at"`
-	UpdatedAt      string `db:"updated_at"`
+	ID             string  `db:"id"`
+	Name           string  `db:"name"`
+	Content        string  `db:"content"`
+	ContentType    string  `db:"content_type"`
+	DriverDSN      string  `db:"driver_dsn"`
+	ResumeEnabled  int     `db:"resume_enabled"`
+	RBACExpression string  `db:"rbac_expression"`
+	Schedule       string  `db:"schedule"`
+	MonthlyBudget  float64 `db:"monthly_budget"`
+	Retention      string  `db:"retention"`
+	Version        int     `db:"version"`
+	CreatedAt      string  `db:"created_at"`
+	UpdatedAt      string  `db:"updated_at"`
 }
 
 func (p pipelineScan) toStorage() storage.Pipeline {
 	createdAt, _ := time.Parse(time.RFC3339, p.CreatedAt)
 	updatedAt, _ := time.Parse(time.RFC3339, p.UpdatedAt)
 
+	var schedule *storage.PipelineSchedule
+	if p.Schedule != "" {
+		schedule = &storage.PipelineSchedule{}
+		if err := json.Unmarshal([]byte(p.Schedule), schedule); err != nil {
+			schedule = nil
+		}
+	}
+
+	var retention *storage.PipelineRetention
+	if p.Retention != "" {
+		retention = &storage.PipelineRetention{}
+		if err := json.Unmarshal([]byte(p.Retention), retention); err != nil {
+			retention = nil
+		}
+	}
+
 	return storage.Pipeline{
 		ID:             p.ID,
 		Name:           p.Name,
@@ -59,6 +73,10 @@ func (p pipelineScan) toStorage() storage.Pipeline {
 		DriverDSN:      p.DriverDSN,
 		ResumeEnabled:  p.ResumeEnabled != 0,
 		RBACExpression: p.RBACExpression,
+		Schedule:       schedule,
+		MonthlyBudget:  p.MonthlyBudget,
+		Retention:      retention,
+		Version:        p.Version,
 		CreatedAt:      createdAt,
 		UpdatedAt:      updatedAt,
 	}
@@ -66,20 +84,24 @@ func (p pipelineScan) toStorage() storage.Pipeline {
 
 // pipelineRunScan is an intermediate struct for scanning pipeline run rows.
 type pipelineRunScan struct {
-	ID           string         `db:"id"`
-	PipelineID   string         `db:"pipeline_id"`
-	Status       string         `db:"status"`
-	StartedAt    sql.NullString `db:"started_at"`
-	CompletedAt  sql.NullString `db:"completed_at"`
-	ErrorMessage sql.NullString `db:"error_message"`
-	CreatedAt    string         `db:"created_at"`
+	ID              string         `db:"id"`
+	PipelineID      string         `db:"pipeline_id"`
+	Status          string         `db:"status"`
+	StartedAt       sql.NullString `db:"started_at"`
+	CompletedAt     sql.NullString `db:"completed_at"`
+	ErrorMessage    sql.NullString `db:"error_message"`
+	Cost            float64        `db:"cost"`
+	PipelineVersion int            `db:"pipeline_version"`
+	CreatedAt       string         `db:"created_at"`
 }
 
 func (p pipelineRunScan) toStorage() storage.PipelineRun {
 	run := storage.PipelineRun{
-		ID:         p.ID,
-		PipelineID: p.PipelineID,
-		Status:     storage.RunStatus(p.Status),
+		ID:              p.ID,
+		PipelineID:      p.PipelineID,
+		Status:          storage.RunStatus(p.Status),
+		Cost:            p.Cost,
+		PipelineVersion: p.PipelineVersion,
 	}
 
 	run.CreatedAt, _ = time.Parse(time.RFC3339, p.CreatedAt)
@@ -118,32 +140,16 @@ func NewSqlite(dsn string, namespace string, _ *slog.Logger) (storage.Driver, er
 		dsn = tempFile
 	}
 
-	writer, err := lqs.Open("sqlite", dsn, `
-		PRAGMA journal_mode = WAL;
-		PRAGMA synchronous = NORMAL;
-		PRAGMA foreign_keys = ON;
-		PRAGMA busy_timeout = 5000;
-	`)
+	writer, err := openWriter(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	//nolint: noctx
-	_, err = writer.Exec(schemaSQL)
+	_, err = applyMigrations(context.Background(), writer, migrations)
 	if err != nil {
-		return nil, fmt.Errorf("failed to apply schema: %w", err)
-	}
+		_ = writer.Close()
 
-	// Run idempotent migrations from migrations.sql.
-	// Each ALTER TABLE is a no-op if the column already exists.
-	for _, stmt := range strings.Split(migrationsSQL, ";") {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-
-		//nolint: noctx
-		_, _ = writer.Exec(stmt)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	writer.SetMaxIdleConns(1)
@@ -166,6 +172,21 @@ func NewSqlite(dsn string, namespace string, _ *slog.Logger) (storage.Driver, er
 	}, nil
 }
 
+// openWriter opens the database for writing, creating it if needed.
+func openWriter(dsn string) (*sql.DB, error) {
+	writer, err := lqs.Open("sqlite", strings.TrimPrefix(dsn, "sqlite://"), `
+		PRAGMA journal_mode = WAL;
+		PRAGMA synchronous = NORMAL;
+		PRAGMA foreign_keys = ON;
+		PRAGMA busy_timeout = 5000;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return writer, nil
+}
+
 func (s *Sqlite) Set(ctx context.Context, prefix string, payload any) error {
 	path := filepath.Clean("/" + s.namespace + "/" + prefix)
 
@@ -194,7 +215,7 @@ func (s *Sqlite) Set(ctx context.Context, prefix string, payload any) error {
 		return fmt.Errorf("failed to clear data_fts: %w", err)
 	}
 
-	text := stripANSI(extractTextFromJSON(contents))
+	text := storage.SearchText(contents)
 	_, err = s.writer.ExecContext(ctx, `INSERT INTO data_fts(path, content) VALUES (?, ?)`, path, path+" "+text)
 	if err != nil {
 		return fmt.Errorf("failed to index data_fts: %w", err)
@@ -312,6 +333,165 @@ func (s *Sqlite) UpdateStatusForPrefix(ctx context.Context, prefix string, match
 	return nil
 }
 
+// Delete removes the tasks and logs at and beneath prefix. The
+// data_fts_delete and task_logs_fts_delete triggers remove their search
+// index entries.
+func (s *Sqlite) Delete(ctx context.Context, prefix string) error {
+	path := filepath.Clean("/" + s.namespace + "/" + prefix)
+
+	_, err := s.writer.ExecContext(ctx, `
+		DELETE FROM tasks WHERE path = ? OR path GLOB ?
+	`, path, path+"/*")
+	if err != nil {
+		return fmt.Errorf("failed to delete tasks for prefix %q: %w", prefix, err)
+	}
+
+	_, err = s.writer.ExecContext(ctx, `
+		DELETE FROM task_logs WHERE path = ? OR path GLOB ?
+	`, path, path+"/*")
+	if err != nil {
+		return fmt.Errorf("failed to delete task logs for prefix %q: %w", prefix, err)
+	}
+
+	return nil
+}
+
+// logChunkScan is an intermediate struct for scanning task log rows.
+type logChunkScan struct {
+	Sequence  int    `db:"sequence"`
+	Stream    string `db:"stream"`
+	Content   string `db:"content"`
+	CreatedAt string `db:"created_at"`
+}
+
+// CloseLogs does nothing, as appending logs keeps no state in memory.
+func (s *Sqlite) CloseLogs(_ context.Context, _ string) error {
+	return nil
+}
+
+// AppendLogs stores chunks after the task's existing logs and indexes them
+// for search.
+func (s *Sqlite) AppendLogs(ctx context.Context, prefix string, chunks []storage.LogChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	path := filepath.Clean("/" + s.namespace + "/" + prefix)
+
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	var sequence int
+
+	err = sqlscan.Get(ctx, tx, &sequence, `
+		SELECT COALESCE(MAX(sequence), -1) + 1 FROM task_logs WHERE path = ?
+	`, path)
+	if err != nil {
+		return fmt.Errorf("failed to get next log sequence: %w", err)
+	}
+
+	for _, chunk := range chunks {
+		createdAt := chunk.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO task_logs (path, sequence, stream, content, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, path, sequence, chunk.Stream, chunk.Content, createdAt.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return fmt.Errorf("failed to insert log chunk: %w", err)
+		}
+
+		rowID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get log chunk id: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO task_logs_fts (rowid, path, content) VALUES (?, ?, ?)
+		`, rowID, path, storage.LogSearchText(chunk.Content))
+		if err != nil {
+			return fmt.Errorf("failed to index log chunk: %w", err)
+		}
+
+		sequence++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit log chunks: %w", err)
+	}
+
+	return nil
+}
+
+// GetLogs returns the task's logs from sequence offset, in order.
+func (s *Sqlite) GetLogs(ctx context.Context, prefix string, offset, limit int) ([]storage.LogChunk, error) {
+	path := filepath.Clean("/" + s.namespace + "/" + prefix)
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var rows []logChunkScan
+
+	err := sqlscan.Select(ctx, s.reader, &rows, `
+		SELECT sequence, stream, content, created_at FROM task_logs
+		WHERE path = ? AND sequence >= ?
+		ORDER BY sequence ASC
+		LIMIT ?
+	`, path, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+
+	chunks := make([]storage.LogChunk, 0, len(rows))
+	for _, row := range rows {
+		createdAt, _ := time.Parse(time.RFC3339Nano, row.CreatedAt)
+
+		chunks = append(chunks, storage.LogChunk{
+			Sequence:  row.Sequence,
+			Stream:    row.Stream,
+			Content:   row.Content,
+			CreatedAt: createdAt,
+		})
+	}
+
+	return chunks, nil
+}
+
+// Checkpoint copies the write-ahead log into the database and truncates it.
+func (s *Sqlite) Checkpoint(ctx context.Context) error {
+	if _, err := s.writer.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint wal: %w", err)
+	}
+
+	return nil
+}
+
+// Vacuum merges the FTS indexes and rebuilds the database without its free pages.
+func (s *Sqlite) Vacuum(ctx context.Context) error {
+	if _, err := s.writer.ExecContext(ctx, `INSERT INTO data_fts(data_fts) VALUES('optimize')`); err != nil {
+		return fmt.Errorf("failed to optimize data_fts: %w", err)
+	}
+
+	if _, err := s.writer.ExecContext(ctx, `INSERT INTO task_logs_fts(task_logs_fts) VALUES('optimize')`); err != nil {
+		return fmt.Errorf("failed to optimize task_logs_fts: %w", err)
+	}
+
+	if _, err := s.writer.ExecContext(ctx, `VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Sqlite) Close() error {
 	err := s.writer.Close()
 	if err != nil {
@@ -394,7 +574,7 @@ func (s *Sqlite) GetPipeline(ctx context.Context, id string) (*storage.Pipeline,
 	var row pipelineScan
 
 	err := sqlscan.Get(ctx, s.writer, &row, `
-		SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, created_at, updated_at
+		SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, schedule, monthly_budget, retention, version, created_at, updated_at
 		FROM pipelines WHERE id = ?
 	`, id)
 	if err != nil {
@@ -415,7 +595,7 @@ func (s *Sqlite) GetPipelineByName(ctx context.Context, name string) (*storage.P
 	var row pipelineScan
 
 	err := sqlscan.Get(ctx, s.writer, &row, `
-		SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, created_at, updated_at
+		SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, schedule, monthly_budget, retention, version, created_at, updated_at
 		FROM pipelines WHERE name = ?
 		ORDER BY updated_at DESC LIMIT 1
 	`, name)
@@ -466,6 +646,10 @@ func (s *Sqlite) DeletePipeline(ctx context.Context, id string) error {
 		return fmt.Errorf("failed to optimize data_fts: %w", err)
 	}
 
+	if _, err := s.writer.ExecContext(ctx, `INSERT INTO task_logs_fts(task_logs_fts) VALUES('optimize')`); err != nil {
+		return fmt.Errorf("failed to optimize task_logs_fts: %w", err)
+	}
+
 	// Update query-planner statistics.
 	if _, err := s.writer.ExecContext(ctx, `PRAGMA optimize`); err != nil {
 		return fmt.Errorf("failed to run PRAGMA optimize: %w", err)
@@ -479,6 +663,155 @@ func (s *Sqlite) DeletePipeline(ctx context.Context, id string) error {
 	return nil
 }
 
+// pipelineVersionScan is an intermediate struct for scanning pipeline version rows.
+type pipelineVersionScan struct {
+	PipelineID     string `db:"pipeline_id"`
+	Version        int    `db:"version"`
+	Content        string `db:"content"`
+	ContentType    string `db:"content_type"`
+	DriverDSN      string `db:"driver_dsn"`
+	ResumeEnabled  int    `db:"resume_enabled"`
+	RBACExpression string `db:"rbac_expression"`
+	Author         string `db:"author"`
+	CreatedAt      string `db:"created_at"`
+}
+
+func (p pipelineVersionScan) toStorage() storage.PipelineVersion {
+	createdAt, _ := time.Parse(time.RFC3339, p.CreatedAt)
+
+	return storage.PipelineVersion{
+		PipelineID:     p.PipelineID,
+		Version:        p.Version,
+		Content:        p.Content,
+		ContentType:    p.ContentType,
+		DriverDSN:      p.DriverDSN,
+		ResumeEnabled:  p.ResumeEnabled != 0,
+		RBACExpression: p.RBACExpression,
+		Author:         p.Author,
+		CreatedAt:      createdAt,
+	}
+}
+
+// SavePipelineVersion records the pipeline's next version and makes it current.
+func (s *Sqlite) SavePipelineVersion(ctx context.Context, version storage.PipelineVersion) (*storage.PipelineVersion, error) {
+	now := time.Now().UTC()
+
+	resumeEnabled := 0
+	if version.ResumeEnabled {
+		resumeEnabled = 1
+	}
+
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	err = sqlscan.Get(ctx, tx, &version.Version, `
+		SELECT COALESCE(MAX(version), 0) + 1 FROM pipeline_versions WHERE pipeline_id = ?
+	`, version.PipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next pipeline version: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE pipelines SET version = ? WHERE id = ?`, version.Version, version.PipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pipeline version: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pipeline_versions (pipeline_id, version, content, content_type, driver_dsn, resume_enabled, rbac_expression, author, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, version.PipelineID, version.Version, version.Content, version.ContentType, version.DriverDSN,
+		resumeEnabled, version.RBACExpression, version.Author, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pipeline version: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit pipeline version: %w", err)
+	}
+
+	version.CreatedAt = now.Truncate(time.Second)
+
+	return &version, nil
+}
+
+// GetPipelineVersion retrieves one version of a pipeline.
+func (s *Sqlite) GetPipelineVersion(ctx context.Context, pipelineID string, version int) (*storage.PipelineVersion, error) {
+	var row pipelineVersionScan
+
+	err := sqlscan.Get(ctx, s.writer, &row, `
+		SELECT pipeline_id, version, content, content_type, driver_dsn, resume_enabled, rbac_expression, author, created_at
+		FROM pipeline_versions WHERE pipeline_id = ? AND version = ?
+	`, pipelineID, version)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pipeline version: %w", err)
+	}
+
+	v := row.toStorage()
+
+	return &v, nil
+}
+
+// ListPipelineVersions returns a pipeline's versions, newest first.
+func (s *Sqlite) ListPipelineVersions(ctx context.Context, pipelineID string, page, perPage int) (*storage.PaginationResult[storage.PipelineVersion], error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	var totalItems int
+	err := sqlscan.Get(ctx, s.writer, &totalItems, `SELECT COUNT(*) FROM pipeline_versions WHERE pipeline_id = ?`, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pipeline versions: %w", err)
+	}
+
+	var rows []pipelineVersionScan
+	err = sqlscan.Select(ctx, s.writer, &rows, `
+		SELECT pipeline_id, version, content, content_type, driver_dsn, resume_enabled, rbac_expression, author, created_at
+		FROM pipeline_versions WHERE pipeline_id = ?
+		ORDER BY version DESC
+		LIMIT ? OFFSET ?
+	`, pipelineID, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipeline versions: %w", err)
+	}
+
+	versions := make([]storage.PipelineVersion, 0, len(rows))
+	for _, row := range rows {
+		versions = append(versions, row.toStorage())
+	}
+
+	totalPages := (totalItems + perPage - 1) / perPage
+
+	return &storage.PaginationResult[storage.PipelineVersion]{
+		Items:      versions,
+		Page:       page,
+		PerPage:    perPage,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+	}, nil
+}
+
 // UpdatePipelineResumeEnabled updates the resume_enabled flag for a pipeline.
 func (s *Sqlite) UpdatePipelineResumeEnabled(ctx context.Context, pipelineID string, enabled bool) error {
 	val := 0
@@ -522,6 +855,85 @@ func (s *Sqlite) UpdatePipelineRBACExpression(ctx context.Context, pipelineID, e
 	return nil
 }
 
+// UpdatePipelineSchedule updates the pipeline-level schedule. A nil schedule removes it.
+func (s *Sqlite) UpdatePipelineSchedule(ctx context.Context, pipelineID string, schedule *storage.PipelineSchedule) error {
+	value := ""
+
+	if schedule != nil {
+		contents, err := json.Marshal(schedule)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pipeline schedule: %w", err)
+		}
+
+		value = string(contents)
+	}
+
+	result, err := s.writer.ExecContext(ctx, `UPDATE pipelines SET schedule = ? WHERE id = ?`, value, pipelineID)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdatePipelineMonthlyBudget sets the pipeline's monthly agent budget in USD. Zero removes it.
+func (s *Sqlite) UpdatePipelineMonthlyBudget(ctx context.Context, pipelineID string, budget float64) error {
+	result, err := s.writer.ExecContext(ctx, `UPDATE pipelines SET monthly_budget = ? WHERE id = ?`, budget, pipelineID)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline monthly budget: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdatePipelineRetention sets the pipeline's run retention policy. A nil policy removes it.
+func (s *Sqlite) UpdatePipelineRetention(ctx context.Context, pipelineID string, retention *storage.PipelineRetention) error {
+	value := ""
+
+	if retention != nil {
+		contents, err := json.Marshal(retention)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pipeline retention: %w", err)
+		}
+
+		value = string(contents)
+	}
+
+	result, err := s.writer.ExecContext(ctx, `UPDATE pipelines SET retention = ? WHERE id = ?`, value, pipelineID)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline retention: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
 // SaveRun creates a new pipeline run record.
 func (s *Sqlite) SaveRun(ctx context.Context, pipelineID string) (*storage.PipelineRun, error) {
 	id := support.UniqueID()
@@ -548,7 +960,7 @@ func (s *Sqlite) GetRun(ctx context.Context, runID string) (*storage.PipelineRun
 	var row pipelineRunScan
 
 	err := sqlscan.Get(ctx, s.writer, &row, `
-		SELECT id, pipeline_id, status, started_at, completed_at, error_message, created_at
+		SELECT id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at
 		FROM pipeline_runs WHERE id = ?
 	`, runID)
 	if err != nil {
@@ -569,7 +981,7 @@ func (s *Sqlite) GetRunsByStatus(ctx context.Context, status storage.RunStatus)
 	var rows []pipelineRunScan
 
 	err := sqlscan.Select(ctx, s.writer, &rows, `
-		SELECT id, pipeline_id, status, started_at, completed_at, error_message, created_at
+		SELECT id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at
 		FROM pipeline_runs WHERE status = ?
 		ORDER BY created_at DESC
 	`, string(status))
@@ -612,7 +1024,7 @@ func (s *Sqlite) GetRecentRunsByStatus(ctx context.Context, status storage.RunSt
 	var rows []pipelineRunScan
 
 	err := sqlscan.Select(ctx, s.writer, &rows, `
-		SELECT id, pipeline_id, status, started_at, completed_at, error_message, created_at
+		SELECT id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at
 		FROM pipeline_runs WHERE status = ?
 		ORDER BY created_at DESC
 		LIMIT ?
@@ -652,9 +1064,9 @@ func (s *Sqlite) SearchRunsByPipeline(ctx context.Context, pipelineID, query str
 
 		var rows []pipelineRunScan
 		err = sqlscan.Select(ctx, s.writer, &rows, `
-			SELECT id, pipeline_id, status, started_at, completed_at, error_message, created_at
+			SELECT id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at
 			FROM pipeline_runs WHERE pipeline_id = ?
-			ORDER BY created_at DESC
+			ORDER BY created_at DESC, rowid DESC
 			LIMIT ? OFFSET ?
 		`, pipelineID, perPage, offset)
 		if err != nil {
@@ -693,11 +1105,11 @@ func (s *Sqlite) SearchRunsByPipeline(ctx context.Context, pipelineID, query str
 	var rows []pipelineRunScan
 
 	err = sqlscan.Select(ctx, s.writer, &rows, `
-		SELECT id, pipeline_id, status, started_at, completed_at, error_message, created_at
+		SELECT id, pipeline_id, status, started_at, completed_at, error_message, cost, pipeline_version, created_at
 		FROM pipeline_runs
 		WHERE pipeline_id = ?
 		  AND id IN (SELECT id FROM pipeline_runs_fts WHERE pipeline_runs_fts MATCH ?)
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, rowid DESC
 		LIMIT ? OFFSET ?
 	`, pipelineID, ftsQuery, perPage, offset)
 	if err != nil {
@@ -756,6 +1168,102 @@ func (s *Sqlite) UpdateRunStatus(ctx context.Context, runID string, status stora
 	return nil
 }
 
+// DeleteRun removes a run. The pipeline_runs_tasks_delete and
+// pipeline_runs_task_logs_delete triggers remove the run's task records and
+// logs with it.
+func (s *Sqlite) DeleteRun(ctx context.Context, runID string) error {
+	result, err := s.writer.ExecContext(ctx, `DELETE FROM pipeline_runs WHERE id = ?`, runID)
+	if err != nil {
+		return fmt.Errorf("failed to delete run: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateRunPipelineVersion records the pipeline version a run executes.
+func (s *Sqlite) UpdateRunPipelineVersion(ctx context.Context, runID string, version int) error {
+	result, err := s.writer.ExecContext(ctx, `UPDATE pipeline_runs SET pipeline_version = ? WHERE id = ?`, version, runID)
+	if err != nil {
+		return fmt.Errorf("failed to update run pipeline version: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// AddRunCost adds cost, in USD, to the run's agent cost and to the
+// pipeline_costs ledger, which outlives the run.
+func (s *Sqlite) AddRunCost(ctx context.Context, runID string, cost float64) error {
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `UPDATE pipeline_runs SET cost = cost + ? WHERE id = ?`, cost, runID)
+	if err != nil {
+		return fmt.Errorf("failed to add run cost: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pipeline_costs (pipeline_id, month, cost)
+		SELECT pipeline_id, ?, ? FROM pipeline_runs WHERE id = ?
+		ON CONFLICT (pipeline_id, month) DO UPDATE SET cost = cost + excluded.cost
+	`, storage.CostMonth(time.Now()), cost, runID)
+	if err != nil {
+		return fmt.Errorf("failed to add pipeline cost: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit run cost: %w", err)
+	}
+
+	return nil
+}
+
+// GetPipelineCost returns the pipeline's cost in the ledger for month.
+func (s *Sqlite) GetPipelineCost(ctx context.Context, pipelineID string, month time.Time) (float64, error) {
+	var cost float64
+
+	err := sqlscan.Get(ctx, s.writer, &cost, `
+		SELECT COALESCE(SUM(cost), 0) FROM pipeline_costs
+		WHERE pipeline_id = ? AND month = ?
+	`, pipelineID, storage.CostMonth(month))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pipeline cost: %w", err)
+	}
+
+	return cost, nil
+}
+
 // SearchPipelines returns pipelines whose name or content contain query using
 // the FTS5 index. When query is empty it returns all pipelines ordered by
 // creation date descending.
@@ -780,7 +1288,7 @@ func (s *Sqlite) SearchPipelines(ctx context.Context, query string, page, perPag
 
 		var rows []pipelineScan
 		err = sqlscan.Select(ctx, s.writer, &rows, `
-			SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, created_at, updated_at
+			SELECT id, name, content, content_type, driver_dsn, resume_enabled, rbac_expression, schedule, monthly_budget, retention, version, created_at, updated_at
 			FROM pipelines ORDER BY created_at DESC
 			LIMIT ? OFFSET ?
 		`, perPage, offset)
@@ -820,7 +1328,7 @@ func (s *Sqlite) SearchPipelines(ctx context.Context, query string, page, perPag
 	var rows []pipelineScan
 
 	err = sqlscan.Select(ctx, s.writer, &rows, `
-		SELECT p.id, p.name, p.content, p.content_type, p.driver_dsn, p.resume_enabled, p.rbac_expression, p.created_at, p.updated_at
+		SELECT p.id, p.name, p.content, p.content_type, p.driver_dsn, p.resume_enabled, p.rbac_expression, p.schedule, p.monthly_budget, p.retention, p.version, p.created_at, p.updated_at
 		FROM pipelines p
 		WHERE p.id IN (SELECT id FROM pipelines_fts WHERE pipelines_fts MATCH ?)
 		ORDER BY p.created_at DESC
@@ -847,8 +1355,9 @@ func (s *Sqlite) SearchPipelines(ctx context.Context, query string, page, perPag
 	}, nil
 }
 
-// Search returns records whose indexed text matches query and whose path begins
-// with prefix. prefix follows the same convention as Set (no namespace prefix).
+// Search returns records whose indexed text or logs match query and whose path
+// begins with prefix. prefix follows the same convention as Set (no namespace
+// prefix).
 func (s *Sqlite) Search(ctx context.Context, prefix, query string) (storage.Results, error) {
 	if query == "" {
 		return nil, nil
@@ -871,11 +1380,16 @@ func (s *Sqlite) Search(ctx context.Context, prefix, query string) (storage.Resu
 				),
 				'{}'
 			) AS payload
-		FROM data_fts f
+		FROM (
+			SELECT path FROM data_fts
+			WHERE data_fts MATCH ? AND path LIKE ? || '/%'
+			UNION
+			SELECT path FROM task_logs_fts
+			WHERE task_logs_fts MATCH ? AND path LIKE ? || '/%'
+		) f
 		LEFT JOIN tasks t ON t.path = f.path
-		WHERE data_fts MATCH ? AND f.path LIKE ? || '/%'
-		ORDER BY COALESCE(t.id, f.rowid) ASC
-	`, ftsQuery, fullPrefix)
+		ORDER BY COALESCE(t.id, 0) ASC, f.path ASC
+	`, ftsQuery, fullPrefix, ftsQuery, fullPrefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/jtarchie/pocketci/storage"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is a versioned change to the schema or its data. Each runs once,
+// in its own transaction, and is recorded in the schema_migrations table.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations are applied in order. Append new ones with the next version;
+// never edit or renumber one that has been released.
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: sqlMigration("0001_initial_schema.sql")},
+	{version: 2, name: "add columns missing from older databases", up: addLegacyColumns},
+	{version: 3, name: "backfill full-text search", up: backfillSearch},
+	{version: 4, name: "pipeline versions", up: sqlMigration("0004_pipeline_versions.sql")},
+	{version: 5, name: "task logs", up: sqlMigration("0005_task_logs.sql")},
+	{version: 6, name: "pipeline cost ledger", up: sqlMigration("0006_pipeline_costs.sql")},
+}
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this build does not know about.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of pocketci")
+
+// MigrationStatus is a migration and, once applied, when it was applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrate applies the pending migrations of the SQLite database at dsn and
+// returns the ones it applied.
+func Migrate(ctx context.Context, dsn string) ([]MigrationStatus, error) {
+	db, err := openWriter(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	return applyMigrations(ctx, db, migrations)
+}
+
+// Status returns every migration of the SQLite database at dsn, without
+// applying any.
+func Status(ctx context.Context, dsn string) ([]MigrationStatus, error) {
+	db, err := openWriter(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	return migrationStatus(ctx, db, migrations)
+}
+
+func applyMigrations(ctx context.Context, db *sql.DB, migrations []migration) ([]MigrationStatus, error) {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		) STRICT
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	statuses, err := migrationStatus(ctx, db, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []MigrationStatus{}
+
+	for i, status := range statuses {
+		if status.AppliedAt != nil {
+			continue
+		}
+
+		appliedAt, err := applyMigration(ctx, db, migrations[i])
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", status.Version, status.Name, err)
+		}
+
+		status.AppliedAt = &appliedAt
+		applied = append(applied, status)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) (time.Time, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	err = m.up(ctx, tx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)
+	`, m.version, m.name, now.Format(time.RFC3339))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return now, nil
+}
+
+// migrationStatus reads which migrations are applied. It fails with
+// ErrSchemaTooNew when the database has a version it does not know.
+func migrationStatus(ctx context.Context, db *sql.DB, migrations []migration) ([]MigrationStatus, error) {
+	var exists int
+
+	err := sqlscan.Get(ctx, db, &exists, `
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+
+	type row struct {
+		Version   int    `db:"version"`
+		AppliedAt string `db:"applied_at"`
+	}
+
+	var rows []row
+
+	if exists > 0 {
+		err = sqlscan.Select(ctx, db, &rows, `SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+
+	appliedAt := make(map[int]time.Time, len(rows))
+	latest := migrations[len(migrations)-1].version
+
+	for _, r := range rows {
+		if r.Version > latest {
+			return nil, fmt.Errorf("%w: it is at version %d, but the latest known is %d", ErrSchemaTooNew, r.Version, latest)
+		}
+
+		appliedAt[r.Version], _ = time.Parse(time.RFC3339, r.AppliedAt)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := appliedAt[m.version]; ok {
+			status.AppliedAt = &at
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// sqlMigration runs the statements of a file in the migrations directory.
+func sqlMigration(filename string) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		contents, err := migrationFiles.ReadFile("migrations/" + filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+
+		_, err = tx.ExecContext(ctx, string(contents))
+		if err != nil {
+			return fmt.Errorf("failed to execute %s: %w", filename, err)
+		}
+
+		return nil
+	}
+}
+
+// addLegacyColumns adds the columns that databases created before versioned
+// migrations may lack. SQLite has no ADD COLUMN IF NOT EXISTS, so each column
+// is checked first.
+func addLegacyColumns(ctx context.Context, tx *sql.Tx) error {
+	columns := []struct{ table, column, definition string }{
+		{"pipelines", "content_type", "TEXT NOT NULL DEFAULT ''"},
+		{"pipelines", "resume_enabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"pipelines", "rbac_expression", "TEXT NOT NULL DEFAULT ''"},
+		{"pipelines", "schedule", "TEXT NOT NULL DEFAULT ''"},
+		{"pipelines", "monthly_budget", "REAL NOT NULL DEFAULT 0"},
+		{"pipelines", "retention", "TEXT NOT NULL DEFAULT ''"},
+		{"pipeline_runs", "cost", "REAL NOT NULL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		var count int
+
+		err := sqlscan.Get(ctx, tx, &count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s.%s: %w", c.table, c.column, err)
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.definition))
+		if err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillSearchBatch is how many tasks backfillSearch indexes per query.
+const backfillSearchBatch = 500
+
+// backfillSearch indexes the pipelines, runs, and tasks that are missing from
+// the full-text search tables, such as those written before the tables existed.
+func backfillSearch(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO pipelines_fts (id, name, content)
+		SELECT id, name, content FROM pipelines
+		WHERE id NOT IN (SELECT id FROM pipelines_fts)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill pipelines_fts: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pipeline_runs_fts (id, status, error_message)
+		SELECT id, status, COALESCE(error_message, '') FROM pipeline_runs
+		WHERE id NOT IN (SELECT id FROM pipeline_runs_fts)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill pipeline_runs_fts: %w", err)
+	}
+
+	type task struct {
+		ID      int    `db:"id"`
+		Path    string `db:"path"`
+		Payload []byte `db:"payload"`
+	}
+
+	lastID := 0
+
+	for {
+		var tasks []task
+
+		err := sqlscan.Select(ctx, tx, &tasks, `
+			SELECT id, path, json(payload) AS payload FROM tasks
+			WHERE id > ? AND path NOT IN (SELECT path FROM data_fts)
+			ORDER BY id ASC
+			LIMIT ?
+		`, lastID, backfillSearchBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read tasks: %w", err)
+		}
+
+		for _, t := range tasks {
+			text := t.Path + " " + storage.SearchText(t.Payload)
+
+			_, err := tx.ExecContext(ctx, `INSERT INTO data_fts (path, content) VALUES (?, ?)`, t.Path, text)
+			if err != nil {
+				return fmt.Errorf("failed to index %s: %w", t.Path, err)
+			}
+
+			lastID = t.ID
+		}
+
+		if len(tasks) < backfillSearchBatch {
+			return nil
+		}
+	}
+}
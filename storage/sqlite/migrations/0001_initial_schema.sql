@@ -14,6 +14,9 @@ CREATE TABLE IF NOT EXISTS pipelines (
   driver_dsn TEXT NOT NULL,
   resume_enabled INTEGER NOT NULL DEFAULT 0,
   rbac_expression TEXT NOT NULL DEFAULT '',
+  schedule TEXT NOT NULL DEFAULT '',
+  monthly_budget REAL NOT NULL DEFAULT 0,
+  retention TEXT NOT NULL DEFAULT '',
   created_at TEXT DEFAULT CURRENT_TIMESTAMP,
   updated_at TEXT DEFAULT CURRENT_TIMESTAMP
 ) STRICT;
@@ -25,6 +28,7 @@ CREATE TABLE IF NOT EXISTS pipeline_runs (
   started_at TEXT,
   completed_at TEXT,
   error_message TEXT,
+  cost REAL NOT NULL DEFAULT 0,
   created_at TEXT DEFAULT CURRENT_TIMESTAMP,
   FOREIGN KEY (pipeline_id) REFERENCES pipelines(id) ON DELETE CASCADE
 ) STRICT;
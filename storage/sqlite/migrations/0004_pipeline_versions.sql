@@ -0,0 +1,18 @@
+ALTER TABLE pipelines ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE pipeline_runs ADD COLUMN pipeline_version INTEGER NOT NULL DEFAULT 0;
+
+-- Immutable snapshots of a pipeline's definition, one per set-pipeline.
+CREATE TABLE IF NOT EXISTS pipeline_versions (
+  pipeline_id TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  content TEXT NOT NULL,
+  content_type TEXT NOT NULL DEFAULT '',
+  driver_dsn TEXT NOT NULL,
+  resume_enabled INTEGER NOT NULL DEFAULT 0,
+  rbac_expression TEXT NOT NULL DEFAULT '',
+  author TEXT NOT NULL DEFAULT '',
+  created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (pipeline_id, version),
+  FOREIGN KEY (pipeline_id) REFERENCES pipelines(id) ON DELETE CASCADE
+) STRICT;
@@ -0,0 +1,37 @@
+-- Task output, appended in chunks as it streams rather than kept in the
+-- task's payload. path matches tasks.path.
+CREATE TABLE IF NOT EXISTS task_logs (
+  path TEXT NOT NULL,
+  sequence INTEGER NOT NULL,
+  stream TEXT NOT NULL,
+  content TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (path, sequence)
+) STRICT;
+
+-- FTS5 virtual table for searching task logs. Each row shares the rowid of
+-- its chunk; content holds the chunk's ANSI-stripped text.
+CREATE VIRTUAL TABLE IF NOT EXISTS task_logs_fts USING fts5(path UNINDEXED, content, tokenize = 'unicode61');
+
+-- Remove FTS entries when a log chunk is deleted.
+CREATE TRIGGER IF NOT EXISTS task_logs_fts_delete
+AFTER
+  DELETE ON task_logs BEGIN
+DELETE FROM
+  task_logs_fts
+WHERE
+  rowid = OLD.rowid;
+
+END;
+
+-- Remove the logs stored under .../{namespace}/pipeline/{run_id}/... when a
+-- pipeline run is deleted, like pipeline_runs_tasks_delete does for tasks.
+CREATE TRIGGER IF NOT EXISTS pipeline_runs_task_logs_delete
+AFTER
+  DELETE ON pipeline_runs BEGIN
+DELETE FROM
+  task_logs
+WHERE
+  path LIKE '%/pipeline/' || OLD.id || '/%';
+
+END;
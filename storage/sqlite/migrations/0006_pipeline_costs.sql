@@ -0,0 +1,15 @@
+-- Each pipeline's agent cost by month, kept apart from its runs so that
+-- deleting old runs does not lower what counts against its monthly budget.
+CREATE TABLE IF NOT EXISTS pipeline_costs (
+  pipeline_id TEXT NOT NULL,
+  month TEXT NOT NULL,
+  cost REAL NOT NULL DEFAULT 0,
+  PRIMARY KEY (pipeline_id, month),
+  FOREIGN KEY (pipeline_id) REFERENCES pipelines(id) ON DELETE CASCADE
+) STRICT;
+
+INSERT INTO pipeline_costs (pipeline_id, month, cost)
+SELECT pipeline_id, strftime('%Y-%m', created_at), SUM(cost)
+FROM pipeline_runs
+WHERE cost > 0
+GROUP BY pipeline_id, strftime('%Y-%m', created_at);
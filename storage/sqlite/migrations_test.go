@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	. "github.com/onsi/gomega"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("applies every migration once", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		dsn := "sqlite://" + filepath.Join(t.TempDir(), "pocketci.db")
+
+		statuses, err := Status(context.Background(), dsn)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(statuses).To(HaveLen(len(migrations)))
+		assert.Expect(statuses[0].AppliedAt).To(BeNil())
+
+		applied, err := Migrate(context.Background(), dsn)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(applied).To(HaveLen(len(migrations)))
+
+		applied, err = Migrate(context.Background(), dsn)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(applied).To(BeEmpty())
+
+		statuses, err = Status(context.Background(), dsn)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		for _, status := range statuses {
+			assert.Expect(status.AppliedAt).NotTo(BeNil())
+		}
+	})
+
+	t.Run("upgrades a database created before versioned migrations", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		path := filepath.Join(t.TempDir(), "pocketci.db")
+
+		legacy, err := sql.Open("sqlite", path)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = legacy.Exec(`
+			CREATE TABLE tasks (
+				id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				path TEXT NOT NULL,
+				payload BLOB,
+				created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(path)
+			) STRICT;
+			CREATE TABLE pipelines (
+				id TEXT NOT NULL PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				content TEXT NOT NULL,
+				driver_dsn TEXT NOT NULL,
+				created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+				updated_at TEXT DEFAULT CURRENT_TIMESTAMP
+			) STRICT;
+			CREATE TABLE pipeline_runs (
+				id TEXT NOT NULL PRIMARY KEY,
+				pipeline_id TEXT NOT NULL,
+				status TEXT NOT NULL,
+				started_at TEXT,
+				completed_at TEXT,
+				error_message TEXT,
+				created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (pipeline_id) REFERENCES pipelines(id) ON DELETE CASCADE
+			) STRICT;
+			INSERT INTO pipelines (id, name, content, driver_dsn) VALUES ('p1', 'legacy-pipeline', 'export { pipeline };', 'native://');
+			INSERT INTO pipeline_runs (id, pipeline_id, status) VALUES ('r1', 'p1', 'success');
+			INSERT INTO tasks (path, payload) VALUES ('/ns/pipeline/r1/tasks/build', jsonb('{"stdout":"legacy-output"}'));
+		`)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(legacy.Close()).To(Succeed())
+
+		client, err := NewSqlite(path, "ns", slog.Default())
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = client.Close() })
+
+		pipelines, err := client.SearchPipelines(context.Background(), "legacy", 1, 10)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(pipelines.Items).To(HaveLen(1))
+
+		runs, err := client.SearchRunsByPipeline(context.Background(), "p1", "success", 1, 10)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(runs.Items).To(HaveLen(1))
+
+		results, err := client.Search(context.Background(), "pipeline/r1", "legacy")
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(results).To(HaveLen(1))
+
+		err = client.UpdatePipelineMonthlyBudget(context.Background(), "p1", 5)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		err = client.AddRunCost(context.Background(), "r1", 1.5)
+		assert.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("backfills the pipeline cost ledger from existing runs", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		db, err := openWriter(filepath.Join(t.TempDir(), "pocketci.db"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = db.Close() })
+
+		_, err = applyMigrations(context.Background(), db, migrations[:5])
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = db.Exec(`
+			INSERT INTO pipelines (id, name, content, driver_dsn) VALUES ('p1', 'costly', 'content', 'native://');
+			INSERT INTO pipeline_runs (id, pipeline_id, status, cost, created_at) VALUES
+				('r1', 'p1', 'success', 1.5, '2026-09-30 23:00:00'),
+				('r2', 'p1', 'success', 0.25, '2026-10-01 01:00:00'),
+				('r3', 'p1', 'success', 0.5, '2026-10-02T10:00:00Z');
+		`)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		applied, err := applyMigrations(context.Background(), db, migrations)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(applied).To(HaveLen(len(migrations) - 5))
+
+		var costs []struct {
+			Month string  `db:"month"`
+			Cost  float64 `db:"cost"`
+		}
+
+		err = sqlscan.Select(context.Background(), db, &costs, `SELECT month, cost FROM pipeline_costs WHERE pipeline_id = 'p1' ORDER BY month`)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(costs).To(HaveLen(2))
+		assert.Expect(costs[0].Month).To(Equal("2026-09"))
+		assert.Expect(costs[0].Cost).To(BeNumerically("~", 1.5))
+		assert.Expect(costs[1].Month).To(Equal("2026-10"))
+		assert.Expect(costs[1].Cost).To(BeNumerically("~", 0.75))
+	})
+
+	t.Run("stops at a failing migration and leaves it pending", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		db, err := openWriter(filepath.Join(t.TempDir(), "pocketci.db"))
+		assert.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { _ = db.Close() })
+
+		steps := []migration{
+			{version: 1, name: "create", up: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `CREATE TABLE things (id INTEGER PRIMARY KEY)`)
+
+				return err
+			}},
+			{version: 2, name: "broken", up: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `INSERT INTO things (id) VALUES (1)`)
+				if err != nil {
+					return err
+				}
+
+				return errors.New("backfill failed")
+			}},
+		}
+
+		applied, err := applyMigrations(context.Background(), db, steps)
+		assert.Expect(err).To(MatchError(ContainSubstring("migration 2 (broken) failed: backfill failed")))
+		assert.Expect(applied).To(HaveLen(1))
+
+		var count int
+		assert.Expect(db.QueryRow(`SELECT COUNT(*) FROM things`).Scan(&count)).To(Succeed())
+		assert.Expect(count).To(Equal(0))
+
+		statuses, err := migrationStatus(context.Background(), db, steps)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(statuses[0].AppliedAt).NotTo(BeNil())
+		assert.Expect(statuses[1].AppliedAt).To(BeNil())
+	})
+
+	t.Run("refuses a database migrated by a newer version", func(t *testing.T) {
+		t.Parallel()
+		assert := NewGomegaWithT(t)
+
+		path := filepath.Join(t.TempDir(), "pocketci.db")
+
+		_, err := Migrate(context.Background(), path)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		db, err := openWriter(path)
+		assert.Expect(err).NotTo(HaveOccurred())
+
+		_, err = db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (999, 'future', '2030-01-01T00:00:00Z')`)
+		assert.Expect(err).NotTo(HaveOccurred())
+		assert.Expect(db.Close()).To(Succeed())
+
+		_, err = NewSqlite(path, "ns", slog.Default())
+		assert.Expect(err).To(MatchError(ErrSchemaTooNew))
+	})
+}
@@ -24,15 +24,48 @@ const (
 
 // Pipeline represents a stored pipeline definition.
 type Pipeline struct {
-	ID             string      `json:"id"`
-	Name           string      `json:"name"`
+	ID             string             `json:"id"`
+	Name           string             `json:"name"`
+	Content        string             `json:"content"`
+	ContentType    ContentType        `json:"content_type"`
+	DriverDSN      string             `json:"driver_dsn"`
+	ResumeEnabled  bool               `json:"resume_enabled"`
+	RBACExpression string             `json:"rbac_expression,omitempty"`
+	Schedule       *PipelineSchedule  `json:"schedule,omitempty"`
+	MonthlyBudget  float64            `json:"monthly_budget,omitempty"`
+	Retention      *PipelineRetention `json:"retention,omitempty"`
+	Version        int                `json:"version,omitempty"` // current PipelineVersion; zero before the first is saved
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// PipelineVersion is an immutable snapshot of a pipeline's definition, saved
+// each time the pipeline is set. Versions are numbered from 1 per pipeline.
+type PipelineVersion struct {
+	PipelineID     string      `json:"pipeline_id"`
+	Version        int         `json:"version"`
 	Content        string      `json:"content"`
 	ContentType    ContentType `json:"content_type"`
 	DriverDSN      string      `json:"driver_dsn"`
 	ResumeEnabled  bool        `json:"resume_enabled"`
 	RBACExpression string      `json:"rbac_expression,omitempty"`
+	Author         string      `json:"author,omitempty"`
 	CreatedAt      time.Time   `json:"created_at"`
-	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// PipelineSchedule is a cron schedule that triggers every job of a pipeline.
+type PipelineSchedule struct {
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"`
+	CatchUp  string `json:"catch_up,omitempty"`
+}
+
+// PipelineRetention limits how many of a pipeline's runs are kept. Runs beyond
+// the newest Runs, or created more than Days ago, are deleted with their logs.
+// A zero value means no limit.
+type PipelineRetention struct {
+	Runs int `json:"runs,omitempty"`
+	Days int `json:"days,omitempty"`
 }
 
 // RunStatus represents the status of a pipeline run.
@@ -48,13 +81,24 @@ const (
 
 // PipelineRun represents an execution of a pipeline.
 type PipelineRun struct {
-	ID           string     `json:"id"`
-	PipelineID   string     `json:"pipeline_id"`
-	Status       RunStatus  `json:"status"`
-	StartedAt    *time.Time `json:"started_at,omitempty"`
-	CompletedAt  *time.Time `json:"completed_at,omitempty"`
-	ErrorMessage string     `json:"error_message,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
+	ID              string     `json:"id"`
+	PipelineID      string     `json:"pipeline_id"`
+	Status          RunStatus  `json:"status"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	ErrorMessage    string     `json:"error_message,omitempty"`
+	Cost            float64    `json:"cost,omitempty"`
+	PipelineVersion int        `json:"pipeline_version,omitempty"` // version of the pipeline the run executed
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// LogChunk is a piece of a task's output. Chunks are appended as the task
+// streams and numbered per task from 0 in the order they were written.
+type LogChunk struct {
+	Sequence  int       `json:"sequence"`
+	Stream    string    `json:"stream"` // "stdout" or "stderr"
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // PaginationResult holds paginated items along with pagination metadata.
@@ -77,14 +121,43 @@ type Driver interface {
 	// It uses the same jsonb_patch upsert semantics as Set, so only the status
 	// field is overwritten and all other payload fields are preserved.
 	UpdateStatusForPrefix(ctx context.Context, prefix string, matchStatuses []string, newStatus string) error
+	// Delete removes the record at prefix and every record beneath it, along
+	// with their logs. Deleting a prefix with no records is not an error.
+	Delete(ctx context.Context, prefix string) error
+
+	// Task log operations
+	//
+	// AppendLogs stores chunks after the logs of the task at prefix, numbering
+	// them on from its last chunk. The Sequence of each argument is ignored.
+	AppendLogs(ctx context.Context, prefix string, chunks []LogChunk) error
+	// GetLogs returns the logs of the task at prefix from sequence offset, in
+	// order. A limit of zero or less returns every chunk from offset.
+	GetLogs(ctx context.Context, prefix string, offset, limit int) ([]LogChunk, error)
+	// CloseLogs is called once the task at prefix has appended all of its
+	// logs, so that the driver can drop what it keeps in memory to append
+	// them. Appending after CloseLogs still numbers on from the last chunk.
+	CloseLogs(ctx context.Context, prefix string) error
 
 	// Pipeline CRUD operations
 	SavePipeline(ctx context.Context, name, content, driverDSN, contentType string) (*Pipeline, error)
 	UpdatePipelineResumeEnabled(ctx context.Context, pipelineID string, enabled bool) error
 	UpdatePipelineRBACExpression(ctx context.Context, pipelineID, expression string) error
+	// UpdatePipelineSchedule sets the pipeline-level schedule. A nil schedule removes it.
+	UpdatePipelineSchedule(ctx context.Context, pipelineID string, schedule *PipelineSchedule) error
+	// UpdatePipelineMonthlyBudget sets the pipeline's monthly agent budget in USD. Zero removes it.
+	UpdatePipelineMonthlyBudget(ctx context.Context, pipelineID string, budget float64) error
+	// UpdatePipelineRetention sets the pipeline's run retention policy. A nil policy removes it.
+	UpdatePipelineRetention(ctx context.Context, pipelineID string, retention *PipelineRetention) error
 	GetPipeline(ctx context.Context, id string) (*Pipeline, error)
 	GetPipelineByName(ctx context.Context, name string) (*Pipeline, error)
 	DeletePipeline(ctx context.Context, id string) error
+	// SavePipelineVersion records version as the pipeline's next version,
+	// numbered one past its latest, and makes it the pipeline's current version.
+	// The Version and CreatedAt of the argument are ignored.
+	SavePipelineVersion(ctx context.Context, version PipelineVersion) (*PipelineVersion, error)
+	GetPipelineVersion(ctx context.Context, pipelineID string, version int) (*PipelineVersion, error)
+	// ListPipelineVersions returns a pipeline's versions, newest first.
+	ListPipelineVersions(ctx context.Context, pipelineID string, page, perPage int) (*PaginationResult[PipelineVersion], error)
 
 	// Pipeline run operations
 	SaveRun(ctx context.Context, pipelineID string) (*PipelineRun, error)
@@ -96,6 +169,17 @@ type Driver interface {
 	GetRecentRunsByStatus(ctx context.Context, status RunStatus, limit int) ([]PipelineRun, error)
 	SearchRunsByPipeline(ctx context.Context, pipelineID, query string, page, perPage int) (*PaginationResult[PipelineRun], error)
 	UpdateRunStatus(ctx context.Context, runID string, status RunStatus, errorMessage string) error
+	// DeleteRun removes a run along with the task records stored under /pipeline/<runID>.
+	DeleteRun(ctx context.Context, runID string) error
+	// UpdateRunPipelineVersion records the pipeline version a run executes.
+	UpdateRunPipelineVersion(ctx context.Context, runID string, version int) error
+	// AddRunCost adds cost, in USD, to the run's agent cost and to its
+	// pipeline's cost for the current month. Deleting the run later does not
+	// take it off the pipeline's cost.
+	AddRunCost(ctx context.Context, runID string, cost float64) error
+	// GetPipelineCost returns the agent cost a pipeline's runs added in the
+	// calendar month (UTC) of month, including runs since deleted.
+	GetPipelineCost(ctx context.Context, pipelineID string, month time.Time) (float64, error)
 
 	// Full-text search operations
 	//
@@ -103,13 +187,29 @@ type Driver interface {
 	// FTS5. An empty query returns all pipelines.
 	SearchPipelines(ctx context.Context, query string, page, perPage int) (*PaginationResult[Pipeline], error)
 
-	// Search returns records whose indexed text matches query, scoped to paths
-	// that begin with prefix. Set automatically indexes content on write so no
-	// separate indexing step is required. prefix follows the same convention as
-	// Set (no namespace; the implementation adds it internally).
+	// Search returns records whose indexed text or logs match query, scoped to
+	// paths that begin with prefix. Set and AppendLogs automatically index
+	// content on write so no separate indexing step is required. prefix
+	// follows the same convention as Set (no namespace; the implementation
+	// adds it internally).
 	Search(ctx context.Context, prefix, query string) (Results, error)
 }
 
+// CostMonth returns the key of t's calendar month (UTC) in a pipeline's cost
+// ledger, like "2026-10".
+func CostMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Compactor is implemented by drivers that need periodic upkeep to return the
+// space of deleted records to the disk.
+type Compactor interface {
+	// Checkpoint copies the write-ahead log into the database and truncates it.
+	Checkpoint(ctx context.Context) error
+	// Vacuum rebuilds the database without its free pages.
+	Vacuum(ctx context.Context) error
+}
+
 type Payload map[string]any
 
 func (p *Payload) Value() (driver.Value, error) {
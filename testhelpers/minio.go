@@ -9,6 +9,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jtarchie/pocketci/s3config"
 	"github.com/phayes/freeport"
 
 	gonanoid "github.com/matoous/go-nanoid/v2"
@@ -113,3 +117,27 @@ func (m *MinioServer) Endpoint() string {
 func (m *MinioServer) Bucket() string {
 	return m.bucket
 }
+
+// EnableVersioning turns on object versioning for the test bucket.
+func (m *MinioServer) EnableVersioning(t *testing.T) {
+	t.Helper()
+
+	assert := gomega.NewGomegaWithT(t)
+	ctx := context.Background()
+
+	cfg, err := s3config.ParseDSN(m.CacheURL())
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	awsCfg, err := cfg.LoadAWSConfig(ctx)
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	client := s3.NewFromConfig(awsCfg, cfg.ClientOptions()...)
+
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(m.bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+}
@@ -0,0 +1,172 @@
+package testhelpers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/onsi/gomega"
+	"github.com/phayes/freeport"
+)
+
+type PostgresServer struct {
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	dataDir string
+	port    int
+}
+
+// PostgresBinary returns the path of a PostgreSQL server binary, such as
+// initdb or postgres, from the PATH or else from `pg_config --bindir`, as
+// distribution packages do not put them on the PATH.
+func PostgresBinary(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+
+	output, bindirErr := exec.Command("pg_config", "--bindir").Output()
+	if bindirErr != nil {
+		return "", err
+	}
+
+	path = filepath.Join(strings.TrimSpace(string(output)), name)
+
+	_, statErr := os.Stat(path)
+	if statErr != nil {
+		return "", fmt.Errorf("could not find %s on the PATH or in pg_config's bindir: %w", name, statErr)
+	}
+
+	return path, nil
+}
+
+// StartPostgres initializes a PostgreSQL cluster in a temporary directory and
+// starts a server for it on a random free port. The initdb and postgres
+// binaries are found with PostgresBinary. PostgreSQL refuses to run as root,
+// so as root they run as the nobody user. Call Stop() to clean up when done.
+func StartPostgres(t *testing.T) *PostgresServer {
+	t.Helper()
+
+	assert := gomega.NewGomegaWithT(t)
+
+	initdb, err := PostgresBinary("initdb")
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	postgres, err := PostgresBinary("postgres")
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	dataDir, err := os.MkdirTemp("", "postgres-test-*")
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	attr, err := postgresProcAttr(dataDir)
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	initCmd := exec.Command(initdb, "-D", dataDir+"/data", "-U", "postgres", "--auth=trust", "--no-sync", "-E", "UTF8")
+	initCmd.Dir = dataDir
+	initCmd.SysProcAttr = attr
+
+	output, err := initCmd.CombinedOutput()
+	assert.Expect(err).NotTo(gomega.HaveOccurred(), string(output))
+
+	// Get a free port from the OS to avoid conflicts
+	port, err := freeport.GetFreePort()
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, postgres,
+		"-D", dataDir+"/data",
+		"-p", fmt.Sprintf("%d", port),
+		"-k", dataDir,
+		"-c", "listen_addresses=localhost",
+		"-c", "fsync=off",
+	)
+	cmd.Dir = dataDir
+	cmd.SysProcAttr = attr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	assert.Expect(err).NotTo(gomega.HaveOccurred())
+
+	server := &PostgresServer{
+		cmd:     cmd,
+		cancel:  cancel,
+		dataDir: dataDir,
+		port:    port,
+	}
+
+	// Wait for PostgreSQL to accept connections
+	assert.Eventually(func() bool {
+		db, err := sql.Open("postgres", server.DSN())
+		if err != nil {
+			return false
+		}
+		defer func() { _ = db.Close() }()
+
+		return db.Ping() == nil
+	}, "30s", "100ms").Should(gomega.BeTrue(), "PostgreSQL should start")
+
+	return server
+}
+
+// postgresProcAttr returns nil, or when running as root, the attributes that
+// run PostgreSQL as the nobody user with dataDir handed over to it.
+func postgresProcAttr(dataDir string) (*syscall.SysProcAttr, error) {
+	if os.Geteuid() != 0 {
+		return nil, nil
+	}
+
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		return nil, fmt.Errorf("could not find an unprivileged user to run postgres as: %w", err)
+	}
+
+	uid, err := strconv.ParseUint(nobody.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse uid of %q: %w", nobody.Username, err)
+	}
+
+	gid, err := strconv.ParseUint(nobody.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse gid of %q: %w", nobody.Username, err)
+	}
+
+	err = os.Chown(dataDir, int(uid), int(gid))
+	if err != nil {
+		return nil, fmt.Errorf("could not hand %q to %q: %w", dataDir, nobody.Username, err)
+	}
+
+	return &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+	}, nil
+}
+
+// Stop stops the PostgreSQL server and cleans up temporary files.
+func (p *PostgresServer) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	if p.cmd != nil && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+		_ = p.cmd.Wait()
+	}
+
+	if p.dataDir != "" {
+		_ = os.RemoveAll(p.dataDir)
+	}
+}
+
+// DSN returns the connection URL of the server's default database.
+func (p *PostgresServer) DSN() string {
+	return fmt.Sprintf("postgres://postgres@localhost:%d/postgres?sslmode=disable", p.port)
+}